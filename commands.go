@@ -0,0 +1,324 @@
+package main
+
+// The program's commands, e.g. "contentscraper harvest -once". Each command has its own flags,
+// which follow it on the command line. (The flags defined in main.go and the config package
+// precede the command, and apply to all of them).
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/coverprice/contentscraper/config"
+	"github.com/coverprice/contentscraper/database"
+	"github.com/coverprice/contentscraper/drivers"
+	"github.com/coverprice/contentscraper/server"
+	"github.com/ghodss/yaml"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The program's exit codes, which scripts can rely on.
+const (
+	EXIT_OK             = 0
+	EXIT_ERROR          = 1 // The command failed, e.g. the database couldn't be opened
+	EXIT_USAGE          = 2 // An unknown command, or invalid flags or arguments
+	EXIT_INVALID_CONFIG = 3 // The config file is missing or invalid
+	EXIT_HARVEST_ERRORS = 4 // The harvest finished, but some feeds failed to harvest (at least partially)
+)
+
+type command struct {
+	name        string
+	description string
+	run         func(args []string) int // Returns the exit code
+}
+
+var commands []command
+
+func init() {
+	// (Assigned here, since the commands' usage refers back to this list).
+	commands = []command{
+		command{"serve", "Serve the feeds, without harvesting them", runServe},
+		command{"harvest", "Harvest the feeds periodically, or once with -once, without serving them", runHarvest},
+		command{"validate-config", "Check the config file, and print it with the defaults filled in", runValidateConfig},
+		command{"stats", "Print each feed's sources' recent harvest statistics", runStats},
+		command{"export", "Write the feeds' posts as JSON, in the same schema as the API", runExport},
+	}
+}
+
+func usage() {
+	var out = flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [flags] [command [command flags]]\n\n", os.Args[0])
+	fmt.Fprintf(out, "Without a command, the feeds are both harvested and served.\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-16s %s\n", cmd.name, cmd.description)
+	}
+	fmt.Fprintf(out, "\nRun '%s <command> -h' for the command's flags.\n\nFlags:\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+func newCommandFlagSet(name string) *flag.FlagSet {
+	var flags = flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		var out = flags.Output()
+		fmt.Fprintf(out, "Usage: %s [flags] %s [command flags]\n\n", os.Args[0], name)
+		for _, cmd := range commands {
+			if cmd.name == name {
+				fmt.Fprintf(out, "%s.\n\n", cmd.description)
+			}
+		}
+		fmt.Fprintf(out, "Command flags:\n")
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// parseCommandFlags parses the command's flags. If they're invalid, or help was requested, ok is
+// false and the program should exit with the exit code.
+func parseCommandFlags(flags *flag.FlagSet, args []string) (exitCode int, ok bool) {
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return EXIT_OK, false
+		}
+		return EXIT_USAGE, false
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(flags.Output(), "Unexpected arguments: %s\n\n", strings.Join(flags.Args(), " "))
+		flags.Usage()
+		return EXIT_USAGE, false
+	}
+	return EXIT_OK, true
+}
+
+// initCommand loads the config and initializes the drivers, for the commands that use them.
+func initCommand() (exitCode int, ok bool) {
+	initLogging()
+	conf, err := loadConfig()
+	if err != nil {
+		log.Error(err)
+		return EXIT_INVALID_CONFIG, false
+	}
+	if err = initDrivers(conf); err != nil {
+		log.Error(err)
+		database.Shutdown()
+		return EXIT_ERROR, false
+	}
+	return EXIT_OK, true
+}
+
+// runServe serves the feeds until the program is told to shut down.
+func runServe(args []string) int {
+	var flags = newCommandFlagSet("serve")
+	flags.IntVar(&port, "port", port, "Port to listen on")
+	flags.IntVar(&configPollInterval, "config-poll-interval", configPollInterval, "Seconds between checks for changes to the config file (0 to disable)")
+	if exitCode, ok := parseCommandFlags(flags, args); !ok {
+		return exitCode
+	}
+	if exitCode, ok := initCommand(); !ok {
+		return exitCode
+	}
+	initWebServer()
+	defer shutdown()
+
+	log.Info("Launching web service")
+	go webServer.Launch()
+	waitForSignals()
+	return EXIT_OK
+}
+
+// runHarvest harvests the feeds periodically until the program is told to shut down or, with
+// -once, harvests them once.
+func runHarvest(args []string) int {
+	var isOnce bool
+	var flags = newCommandFlagSet("harvest")
+	flags.BoolVar(&isOnce, "once", false, "Harvest once and exit, e.g. when run by cron")
+	flags.IntVar(&harvestInterval, "interval", harvestInterval, "Minutes to wait between harvest runs (without -once)")
+	flags.BoolVar(&isPruneEnabled, "prune", isPruneEnabled, "False to keep posts that are past their retention period")
+	flags.IntVar(&configPollInterval, "config-poll-interval", configPollInterval, "Seconds between checks for changes to the config file (0 to disable; without -once)")
+	if exitCode, ok := parseCommandFlags(flags, args); !ok {
+		return exitCode
+	}
+	if exitCode, ok := initCommand(); !ok {
+		return exitCode
+	}
+	defer database.Shutdown()
+	if err := initPruning(); err != nil {
+		log.Error(err)
+		return EXIT_ERROR
+	}
+
+	if !isOnce {
+		defer shutdown()
+		beginHarvest()
+		waitForSignals()
+		return EXIT_OK
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sig)
+	go func() {
+		select {
+		case s := <-sig:
+			log.Infof("Received signal %s, cancelling the harvest", s)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if err := harvestAll(ctx); err != nil {
+		if ctx.Err() != nil {
+			log.Info("Harvest cancelled")
+		} else {
+			log.Error(err)
+		}
+		return EXIT_ERROR
+	}
+	var numFeedErrors int
+	for _, driver := range sourceDrivers {
+		for _, feed := range driver.GetFeeds() {
+			if feed.Status == drivers.FEEDHARVESTSTATUS_ERROR {
+				numFeedErrors++
+			}
+		}
+	}
+	if numFeedErrors > 0 {
+		log.Warnf("Harvest complete, but %d feed(s) had errors", numFeedErrors)
+		return EXIT_HARVEST_ERRORS
+	}
+	log.Info("Harvest complete")
+	return EXIT_OK
+}
+
+// runValidateConfig checks the config file and, if it's valid, prints it (as YAML) with the
+// defaults filled in, and its secrets redacted.
+func runValidateConfig(args []string) int {
+	var flags = newCommandFlagSet("validate-config")
+	if exitCode, ok := parseCommandFlags(flags, args); !ok {
+		return exitCode
+	}
+	initLogging()
+	conf, err := config.GetConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "The config file is invalid: %v\n", err)
+		return EXIT_INVALID_CONFIG
+	}
+	contents, err := yaml.Marshal(conf.Redacted())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not print the config: %v\n", err)
+		return EXIT_ERROR
+	}
+	configFilePath, _ := config.ConfigFilePath()
+	fmt.Printf("# %s is valid. With the defaults filled in, it is:\n%s", configFilePath, contents)
+	return EXIT_OK
+}
+
+// runStats prints each feed's sources' harvest statistics.
+func runStats(args []string) int {
+	var days int
+	var flags = newCommandFlagSet("stats")
+	flags.IntVar(&days, "days", server.STATS_DEFAULT_DAYS, "Summarize the harvests of this many days")
+	if exitCode, ok := parseCommandFlags(flags, args); !ok {
+		return exitCode
+	}
+	if days < 1 {
+		fmt.Fprintf(os.Stderr, "-days must be at least 1\n")
+		return EXIT_USAGE
+	}
+	if exitCode, ok := initCommand(); !ok {
+		return exitCode
+	}
+	defer database.Shutdown()
+
+	if !server.WriteStatsReport(os.Stdout, sourceDrivers, days) {
+		return EXIT_ERROR
+	}
+	return EXIT_OK
+}
+
+type exportedFeed struct {
+	Name  string             `json:"name"`
+	Posts []drivers.IApiPost `json:"posts"`
+}
+
+type byExportedFeedName []exportedFeed
+
+func (a byExportedFeedName) Len() int      { return len(a) }
+func (a byExportedFeedName) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byExportedFeedName) Less(i, j int) bool {
+	return a[i].Name < a[j].Name
+}
+
+// runExport writes the posts currently in the feeds (i.e. as the UI shows them) as JSON.
+func runExport(args []string) int {
+	var feedName, outputFilename string
+	var flags = newCommandFlagSet("export")
+	flags.StringVar(&feedName, "feed", "", "Only export the named feed's posts")
+	flags.StringVar(&outputFilename, "output", "-", "The file to write the posts to ('-' for stdout)")
+	if exitCode, ok := parseCommandFlags(flags, args); !ok {
+		return exitCode
+	}
+	if exitCode, ok := initCommand(); !ok {
+		return exitCode
+	}
+	defer database.Shutdown()
+
+	var feeds = make([]exportedFeed, 0)
+	for _, driver := range sourceDrivers {
+		apiDriver, ok := driver.(drivers.IApiDriver)
+		if !ok {
+			continue
+		}
+		for _, feed := range driver.GetFeeds() {
+			if feedName != "" && feed.Name != feedName {
+				continue
+			}
+			posts, err := apiDriver.GetApiPosts(feed.Name)
+			if err != nil {
+				log.Errorf("Could not retrieve the posts of feed '%s': %v", feed.Name, err)
+				return EXIT_ERROR
+			}
+			feeds = append(feeds, exportedFeed{Name: feed.Name, Posts: posts})
+		}
+	}
+	if feedName != "" && len(feeds) == 0 {
+		fmt.Fprintf(os.Stderr, "Unknown feed, or its posts can't be exported: '%s'\n", feedName)
+		return EXIT_USAGE
+	}
+	sort.Sort(byExportedFeedName(feeds))
+
+	var w io.Writer = os.Stdout
+	var file *os.File
+	if outputFilename != "-" {
+		var err error
+		if file, err = os.Create(outputFilename); err != nil {
+			log.Errorf("Could not create the output file: %v", err)
+			return EXIT_ERROR
+		}
+		w = file
+	}
+	var encoder = json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(struct {
+		Feeds []exportedFeed `json:"feeds"`
+	}{feeds})
+	if file != nil {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		log.Errorf("Could not write the posts: %v", err)
+		return EXIT_ERROR
+	}
+	return EXIT_OK
+}
@@ -55,6 +55,11 @@ func StorageDir() string {
 	return storageDir
 }
 
+// ConfigFilePath returns the path of the config file that GetConfig loads.
+func ConfigFilePath() (string, error) {
+	return locateConfigFile()
+}
+
 func locateConfigFile() (filePath string, err error) {
 	if configFileName == "" {
 		// means use the default name & search paths
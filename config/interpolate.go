@@ -0,0 +1,133 @@
+package config
+
+// Any string in the config file may refer to environment variables, e.g. "${REDDIT_PASSWORD}",
+// or be a reference to a file whose contents are the value, e.g. "file:/run/secrets/reddit_password".
+// So secrets needn't be written in the config file itself.
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// A string with this prefix is replaced by the contents of the file named by the rest of it.
+// (Except for file:// URLs).
+const FILE_REFERENCE_PREFIX = "file:"
+
+// Matches "${NAME}" environment variable references, and "$${", which is an escaped "${". (A "$"
+// that isn't followed by "{" needn't be escaped, e.g. in a regex, so "$$" alone is left as it is).
+var envVarReferenceRe = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// interpolate replaces the environment variable and file references in the config's strings.
+// Relative file paths are relative to baseDir, i.e. the config file's directory. Only the fields
+// that are read from the config file (i.e. that have a json tag) are interpolated. The paths of
+// the strings that had references replaced are recorded, so that Redacted can hide them.
+func (this *Config) interpolate(baseDir string) error {
+	return walkStrings(reflect.ValueOf(this).Elem(), "", func(value reflect.Value, path string) error {
+		interpolated, isReplaced, err := interpolateString(value.String(), baseDir)
+		if err != nil {
+			return fmt.Errorf("Invalid value of '%s': %v", path, err)
+		}
+		if isReplaced {
+			this.markInterpolated(path)
+		}
+		value.SetString(interpolated)
+		return nil
+	})
+}
+
+// markInterpolated records that the string at the path (see walkStrings) came from an
+// environment variable or file, so may be secret.
+func (this *Config) markInterpolated(path string) {
+	if this.interpolatedPaths == nil {
+		this.interpolatedPaths = make(map[string]bool)
+	}
+	this.interpolatedPaths[path] = true
+}
+
+// redactInterpolated replaces the strings that came from environment variables or files with
+// REDACTED.
+func (this *Config) redactInterpolated() {
+	if len(this.interpolatedPaths) == 0 {
+		return
+	}
+	walkStrings(reflect.ValueOf(this).Elem(), "", func(value reflect.Value, path string) error {
+		if this.interpolatedPaths[path] {
+			value.SetString(redact(value.String()))
+		}
+		return nil
+	})
+}
+
+// walkStrings calls fn with each of the strings in the value, recursing into structs and slices,
+// and stops at the first error. The path (e.g. "reddit.secrets.password") identifies the string.
+// Slices are copied before they're walked, so that changing their strings doesn't change the
+// copies of the value that share them. (See Config.Redacted)
+func walkStrings(value reflect.Value, path string, fn func(value reflect.Value, path string) error) error {
+	switch value.Kind() {
+	case reflect.String:
+		return fn(value, path)
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			var name = strings.Split(value.Type().Field(i).Tag.Get("json"), ",")[0]
+			if name == "" || name == "-" {
+				continue
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+			if err := walkStrings(value.Field(i), name, fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		var elems = reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		reflect.Copy(elems, value)
+		value.Set(elems)
+		for i := 0; i < value.Len(); i++ {
+			if err := walkStrings(value.Index(i), fmt.Sprintf("%s[%d]", path, i), fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// interpolateString replaces the environment variable references in s and then, if it's a file
+// reference, replaces it with the file's contents. isReplaced is whether there were any
+// references. The errors never contain the values, since they may be secret.
+func interpolateString(s string, baseDir string) (interpolated string, isReplaced bool, err error) {
+	s = envVarReferenceRe.ReplaceAllStringFunc(s, func(reference string) string {
+		if reference == "$${" {
+			return "${"
+		}
+		isReplaced = true
+		var name = reference[2 : len(reference)-1]
+		value, ok := os.LookupEnv(name)
+		if !ok && err == nil {
+			err = fmt.Errorf("Environment variable '%s' is not set", name)
+		}
+		return value
+	})
+	if err != nil {
+		return "", false, err
+	}
+
+	if !strings.HasPrefix(s, FILE_REFERENCE_PREFIX) || strings.HasPrefix(s, "file://") {
+		return s, isReplaced, nil
+	}
+	var filePath = strings.TrimPrefix(s, FILE_REFERENCE_PREFIX)
+	if !filepath.IsAbs(filePath) {
+		filePath = filepath.Join(baseDir, filePath)
+	}
+	contents, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return "", false, fmt.Errorf("Could not read file '%s': %v", filePath, err)
+	}
+	// Files usually end with a newline, which isn't part of the value.
+	return strings.TrimRight(string(contents), "\r\n"), true, nil
+}
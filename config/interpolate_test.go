@@ -0,0 +1,116 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInterpolateEnvironmentVariables(t *testing.T) {
+	os.Setenv("CONTENTSCRAPER_TEST_PASSWORD", "somepassword")
+	defer os.Unsetenv("CONTENTSCRAPER_TEST_PASSWORD")
+	os.Setenv("CONTENTSCRAPER_TEST_NAME", "pics")
+	defer os.Unsetenv("CONTENTSCRAPER_TEST_NAME")
+
+	var conf *Config
+	var err error
+	if conf, err = parseFromString(`
+reddit:
+    secrets:
+        password: "${CONTENTSCRAPER_TEST_PASSWORD}"
+        username: "cost$${CONTENTSCRAPER_TEST_NAME}"
+    feeds:
+      - name: "my_${CONTENTSCRAPER_TEST_NAME}"
+        include:
+            title_regexes: ['^\$$', 'costs \$$$']
+`); err != nil {
+		t.Fatal("Could not parse fake config file", err)
+	}
+	if err = conf.interpolate(""); err != nil {
+		t.Fatal("Could not interpolate the config", err)
+	}
+	if conf.Reddit.Secrets.Password != "somepassword" {
+		t.Error("Expected the environment variable to be interpolated")
+	}
+	if conf.Reddit.Secrets.Username != "cost${CONTENTSCRAPER_TEST_NAME}" {
+		t.Error("Expected '$${' to be interpolated as '${'", conf.Reddit.Secrets.Username)
+	}
+	// A "$" that isn't followed by "{" needn't be escaped, so existing regexes are unchanged.
+	if !reflect.DeepEqual(conf.Reddit.Feeds[0].Include.TitleRegexes, []string{`^\$$`, `costs \$$$`}) {
+		t.Error("Expected '$$' to be left alone", conf.Reddit.Feeds[0].Include.TitleRegexes)
+	}
+	if conf.Reddit.Feeds[0].Name != "my_pics" {
+		t.Error("Expected environment variables to be interpolated in feeds", conf.Reddit.Feeds[0].Name)
+	}
+}
+
+func TestInterpolateFileReferences(t *testing.T) {
+	dir, err := ioutil.TempDir("", "contentscraper_config")
+	if err != nil {
+		t.Fatal("Could not create temp dir", err)
+	}
+	defer os.RemoveAll(dir)
+	if err = ioutil.WriteFile(filepath.Join(dir, "client_secret"), []byte("somesecret\n"), 0600); err != nil {
+		t.Fatal("Could not write secret file", err)
+	}
+	os.Setenv("CONTENTSCRAPER_TEST_DIR", dir)
+	defer os.Unsetenv("CONTENTSCRAPER_TEST_DIR")
+
+	var conf *Config
+	if conf, err = parseFromString(`
+reddit:
+    secrets:
+        clientsecret: "file:client_secret"
+        password: "file:${CONTENTSCRAPER_TEST_DIR}/client_secret"
+twitter:
+    secrets:
+        clientsecret: "file:///not/a/reference"
+`); err != nil {
+		t.Fatal("Could not parse fake config file", err)
+	}
+	if err = conf.interpolate(dir); err != nil {
+		t.Fatal("Could not interpolate the config", err)
+	}
+	if conf.Reddit.Secrets.ClientSecret != "somesecret" {
+		t.Error("Expected a relative file reference to be read, without its trailing newline")
+	}
+	if conf.Reddit.Secrets.Password != "somesecret" {
+		t.Error("Expected an absolute file reference to be read")
+	}
+	if conf.Twitter.Secrets.ClientSecret != "file:///not/a/reference" {
+		t.Error("Expected a file:// URL to be left alone", conf.Twitter.Secrets.ClientSecret)
+	}
+}
+
+func TestInterpolateErrors(t *testing.T) {
+	os.Unsetenv("CONTENTSCRAPER_TEST_UNSET")
+	var tests = []struct {
+		yaml          string
+		expectedError string
+	}{
+		{`
+reddit:
+    secrets:
+        password: "${CONTENTSCRAPER_TEST_UNSET}"
+`, "Invalid value of 'reddit.secrets.password': Environment variable 'CONTENTSCRAPER_TEST_UNSET' is not set"},
+		{`
+reddit:
+    feeds:
+      - name: "ok"
+      - name: "file:does_not_exist"
+`, "Invalid value of 'reddit.feeds[1].name': Could not read file"},
+	}
+	for _, test := range tests {
+		conf, err := parseFromString(test.yaml)
+		if err != nil {
+			t.Fatal("Could not parse fake config file", err)
+		}
+		err = conf.interpolate(os.TempDir())
+		if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+			t.Errorf("Expected error '%s', got: %v", test.expectedError, err)
+		}
+	}
+}
@@ -2,10 +2,11 @@ package config
 
 import (
 	"fmt"
-	//"github.com/davecgh/go-spew/spew"
+	"github.com/coverprice/contentscraper/toolbox"
 	"github.com/ghodss/yaml"
 	log "github.com/sirupsen/logrus"
 	"io/ioutil"
+	"net/url"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -16,22 +17,195 @@ const (
 	MEDIA_TYPE_IMAGE = "image"
 )
 
+// How a Reddit feed decides which posts to show. "percentile" shows each subreddit's posts whose
+// score is in its top percentile. "velocity" additionally shows posts whose score rose fastest in
+// the hours after they were posted, so that rising posts appear before they pass the percentile.
+const (
+	REDDIT_RANKING_PERCENTILE = "percentile"
+	REDDIT_RANKING_VELOCITY   = "velocity"
+)
+
+// The orders that a Reddit feed's posts can be displayed in.
+const (
+	REDDIT_SORT_CHRONOLOGICAL = "chronological" // Most recently harvested first
+	REDDIT_SORT_SCORE         = "score"         // Highest score first
+	REDDIT_SORT_GRAVITY       = "gravity"       // Score decayed by age, like Hacker News' front page
+	REDDIT_SORT_NORMALIZED    = "normalized"    // Score relative to the percentile cutoff of the post's subreddit
+	REDDIT_SORT_ROUNDROBIN    = "roundrobin"    // Each subreddit's posts in turn, most recent first
+)
+
+var RedditSortNames = []string{
+	REDDIT_SORT_CHRONOLOGICAL,
+	REDDIT_SORT_SCORE,
+	REDDIT_SORT_GRAVITY,
+	REDDIT_SORT_NORMALIZED,
+	REDDIT_SORT_ROUNDROBIN,
+}
+
+const (
+	// Reddit permits OAuth clients 60 requests per minute, and anonymous clients 10.
+	defaultRedditConcurrency                = 4
+	defaultRedditRequestsPerMinute          = 60
+	defaultRedditAnonymousRequestsPerMinute = 10
+	defaultRedditRequestBurst               = 5
+	defaultRedditMaxRetries                 = 3
+
+	// Comments are refreshed (as their scores change) for this long after a post is created.
+	defaultCommentRefreshHours = 24
+	// The velocity of a post's score is measured over this long after it's created.
+	defaultVelocityHours = 12
+	// The most comments that can be harvested per post
+	maxTopComments = 100
+
+	// Posts are deleted from the database once they haven't been harvested for this many days.
+	defaultRetentionDays = 30
+	// Feeds show the posts stored within the last week, so they must be kept at least that long.
+	minRetentionDays = 7
+)
+
+// The Reddit listings that a subreddit's posts can be harvested from. "top" is for a time window,
+// given after a colon, e.g. "top:week". (Plain "top" means "top:day", as on Reddit).
+const (
+	REDDIT_LISTING_NEW    = "new"
+	REDDIT_LISTING_HOT    = "hot"
+	REDDIT_LISTING_RISING = "rising"
+	REDDIT_LISTING_TOP    = "top"
+)
+
+var RedditListingNames = []string{REDDIT_LISTING_NEW, REDDIT_LISTING_HOT, REDDIT_LISTING_RISING, REDDIT_LISTING_TOP}
+var RedditTopWindows = []string{"hour", "day", "week", "month", "year", "all"}
+
+// Subreddits are harvested from these listings unless configured otherwise.
+var defaultRedditListings = []string{REDDIT_LISTING_HOT}
+
+// ParseRedditListing splits a listing into its name and time window, e.g. "top:week" -> ("top", "week").
+func ParseRedditListing(listing string) (name, window string) {
+	var parts = strings.SplitN(listing, ":", 2)
+	name = parts[0]
+	if len(parts) > 1 {
+		window = parts[1]
+	}
+	return
+}
+
+func validateRedditListings(listings []string) error {
+	var seen = make(map[string]bool)
+	for _, listing := range listings {
+		name, window := ParseRedditListing(listing)
+		if !toolbox.ContainsStr(RedditListingNames, name) {
+			return fmt.Errorf("Invalid listing: '%s', must be one of: %s", listing, strings.Join(RedditListingNames, ", "))
+		}
+		if name == REDDIT_LISTING_TOP {
+			if !toolbox.ContainsStr(RedditTopWindows, window) {
+				return fmt.Errorf("Invalid time window for listing: '%s', must be one of: %s", listing, strings.Join(RedditTopWindows, ", "))
+			}
+		} else if window != "" {
+			return fmt.Errorf("Only the '%s' listing has a time window: '%s'", REDDIT_LISTING_TOP, listing)
+		}
+		if seen[listing] {
+			return fmt.Errorf("Duplicate listing: '%s'", listing)
+		}
+		seen[listing] = true
+	}
+	return nil
+}
+
+// canonicalizeRedditListings lowercases the listings, and gives "top" its default time window.
+func canonicalizeRedditListings(listings []string) (ret []string) {
+	for _, listing := range listings {
+		listing = strings.ToLower(strings.TrimSpace(listing))
+		if listing == REDDIT_LISTING_TOP {
+			listing = REDDIT_LISTING_TOP + ":day"
+		}
+		ret = append(ret, listing)
+	}
+	return
+}
+
+// Besides subreddits, a RedditFeed's posts can come from these types of source.
+const (
+	REDDIT_SOURCE_SUBREDDIT   = "subreddit"
+	REDDIT_SOURCE_MULTIREDDIT = "multireddit" // e.g. "someuser/somemulti", i.e. /user/someuser/m/somemulti
+	REDDIT_SOURCE_USER        = "user"        // A user's submissions, i.e. /user/someuser/submitted
+	REDDIT_SOURCE_SEARCH      = "search"      // A search query, e.g. "subreddit:golang self:yes"
+)
+
+// ParseRedditSourceKey splits a source key (see Subreddit.SourceKey) into the source type
+// and the subreddit name, multireddit, user name or search query.
+func ParseRedditSourceKey(sourceKey string) (sourceType, value string) {
+	var parts = strings.SplitN(sourceKey, ":", 2)
+	if len(parts) == 1 {
+		return REDDIT_SOURCE_SUBREDDIT, sourceKey
+	}
+	return parts[0], parts[1]
+}
+
 // Config is a struct that stores the configs of each type of data source.
-// (Note: While Twitter's config is supported, the actual harvesting code has
-// not been implemented yet)
 type Config struct {
-	Reddit           RedditConfig  `json:"reddit"`
-	Twitter          TwitterConfig `json:"twitter"`
-	BackendStorePath string        // Path to the database file
+	Reddit           RedditConfig     `json:"reddit"`
+	Twitter          TwitterConfig    `json:"twitter"`
+	HackerNews       HackerNewsConfig `json:"hackernews"`
+	Rss              RssConfig        `json:"rss"`
+	RetentionDays    int              `json:"retention_days"` // Days posts are kept after they were last harvested (0 = forever)
+	BackendStorePath string           // Path to the database file
+	// The paths (see walkStrings) of the strings that came from environment variables or files
+	interpolatedPaths map[string]bool
+}
+
+// REDACTED replaces the secrets in a Redacted config.
+const REDACTED = "REDACTED"
+
+// Redacted returns a copy of the config with its secrets (credentials, API keys, etc), and any
+// other strings that came from environment variables or files, replaced by REDACTED, so that it
+// can be shown.
+func (this Config) Redacted() Config {
+	this.Reddit.Secrets = this.Reddit.Secrets.redacted()
+	this.Twitter.Secrets = this.Twitter.Secrets.redacted()
+	this.redactInterpolated()
+	return this
+}
+
+// String and GoString redact the config, so that it can't leak secrets into log lines or debug dumps.
+func (this Config) String() string {
+	return fmt.Sprintf("%+v", configFields(this.Redacted()))
+}
+
+func (this Config) GoString() string {
+	return fmt.Sprintf("%#v", configFields(this.Redacted()))
+}
+
+// configFields has the same fields as Config, but not its methods, so that it can be formatted
+// without recursing.
+type configFields Config
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return REDACTED
 }
 
 // RedditConfig is a struct that stores all Reddit-related configuration.
 type RedditConfig struct {
 	Secrets RedditSecrets `json:"secrets"`
 	Feeds   []RedditFeed  `json:"feeds"`
+	// The # of subreddits harvested simultaneously
+	Concurrency int `json:"concurrency"`
+	// The maximum rate of requests to Reddit, shared by all concurrent harvests
+	RequestsPerMinute int `json:"requests_per_minute"`
+	// The # of requests that may be made at once after a quiet period, while staying within
+	// RequestsPerMinute on average
+	RequestBurst int `json:"request_burst"`
+	// The # of times a request that failed for a transient reason (e.g. Reddit being overloaded)
+	// is retried
+	MaxRetries int `json:"max_retries"`
+	// If set, Reddit's responses are saved in this directory, so that they can be replayed
+	// later (e.g. as test fixtures)
+	RecordResponsesDir string `json:"record_responses_dir"`
 }
 
-// RedditSecrets stores the credentials used by the harvesting robot account.
+// RedditSecrets stores the credentials used by the harvesting robot account. If they are
+// omitted, Reddit is harvested anonymously instead.
 type RedditSecrets struct {
 	ClientId     string `json:"clientid"`
 	ClientSecret string `json:"clientsecret"`
@@ -39,6 +213,44 @@ type RedditSecrets struct {
 	Password     string `json:"password"`
 }
 
+func (this RedditSecrets) redacted() RedditSecrets {
+	return RedditSecrets{
+		ClientId:     redact(this.ClientId),
+		ClientSecret: redact(this.ClientSecret),
+		Username:     redact(this.Username),
+		Password:     redact(this.Password),
+	}
+}
+
+// String and GoString redact the secrets, so that they can't leak into log lines or debug dumps.
+func (this RedditSecrets) String() string {
+	return fmt.Sprintf("%+v", redditSecretsFields(this.redacted()))
+}
+
+func (this RedditSecrets) GoString() string {
+	return fmt.Sprintf("%#v", redditSecretsFields(this.redacted()))
+}
+
+// redditSecretsFields has the same fields as RedditSecrets, but not its methods, so that it
+// can be formatted without recursing.
+type redditSecretsFields RedditSecrets
+
+// IsEmpty returns whether no credentials were given, i.e. Reddit should be harvested anonymously.
+func (this RedditSecrets) IsEmpty() bool {
+	return this == RedditSecrets{}
+}
+
+// Validate returns nil if the RedditSecrets structure is either complete or empty, or an error if not.
+func (this RedditSecrets) Validate() (err error) {
+	if this.IsEmpty() {
+		return nil
+	}
+	if this.ClientId == "" || this.ClientSecret == "" || this.Username == "" || this.Password == "" {
+		return fmt.Errorf("Incomplete secrets. Either give all of clientid, clientsecret, username & password, or none of them")
+	}
+	return nil
+}
+
 // RedditFeed describes the filtering configuration for feeds from Reddit sources, i.e. 1-many Subreddits.
 // It's expected that subreddits primarily share the same media type (text or graphics). E.g. a "images"
 // feed might include image-heavy subreddits like "funny" and "gifs", and a "text" feed might include
@@ -50,6 +262,22 @@ type RedditFeed struct {
 	Subreddits           []Subreddit `json:"subreddits"`
 	DefaultPercentile    float64     `json:"percentile"`
 	DefaultMaxDailyPosts int         `json:"max_daily_posts"`
+	// Default # of top comments to harvest for posts that pass the percentile filter. (0 = none)
+	DefaultTopComments         int `json:"top_comments"`
+	DefaultCommentRefreshHours int `json:"comment_refresh_hours"`
+	// Default listings to harvest posts from, e.g. ["hot", "new", "top:week"]
+	DefaultListings []string `json:"listings"`
+	// Either "percentile" (the default) or "velocity". (See REDDIT_RANKING_VELOCITY)
+	Ranking string `json:"ranking"`
+	// For "velocity" ranking, the # of hours after a post is created that its velocity is measured over
+	VelocityHours int `json:"velocity_hours"`
+	// The order the posts are displayed in, one of RedditSortNames. (Default: chronological)
+	Sort string `json:"sort"`
+	// Rules that select which posts are shown, applied to all the feed's subreddits
+	Include RedditFilterRules `json:"include"`
+	Exclude RedditFilterRules `json:"exclude"`
+	// # of days the feed's posts are kept after they were last harvested. (Default: Config.RetentionDays)
+	RetentionDays int `json:"retention_days"`
 }
 
 // Validate returns nil if the RedditFeed structure is syntactically valid, or an error if it is not.
@@ -71,68 +299,267 @@ func (this RedditFeed) Validate() (err error) {
 			MEDIA_TYPE_TEXT,
 		)
 	}
+	if err = validateRedditListings(this.DefaultListings); err != nil {
+		return
+	}
+	if !(this.Ranking == REDDIT_RANKING_PERCENTILE || this.Ranking == REDDIT_RANKING_VELOCITY) {
+		return fmt.Errorf(
+			"Invalid ranking: '%s', must be one of '%s' or '%s'",
+			this.Ranking,
+			REDDIT_RANKING_PERCENTILE,
+			REDDIT_RANKING_VELOCITY,
+		)
+	}
+	if this.VelocityHours < 1 {
+		return fmt.Errorf("velocity_hours must be a +ve integer: %d", this.VelocityHours)
+	}
+	if !toolbox.ContainsStr(RedditSortNames, this.Sort) {
+		return fmt.Errorf("Invalid sort: '%s', must be one of: %s", this.Sort, strings.Join(RedditSortNames, ", "))
+	}
+	if err = this.Include.Validate(); err != nil {
+		return fmt.Errorf("Problem in include rules: %v", err)
+	}
+	if err = this.Exclude.Validate(); err != nil {
+		return fmt.Errorf("Problem in exclude rules: %v", err)
+	}
+	if err = validateRetentionDays(this.RetentionDays); err != nil {
+		return
+	}
+	return nil
+}
+
+// validateRetentionDays checks a (canonicalized) retention period, where 0 means forever.
+func validateRetentionDays(days int) error {
+	if days != 0 && days < minRetentionDays {
+		return fmt.Errorf("retention_days must be at least %d, since feeds show the last %d days of posts: %d", minRetentionDays, minRetentionDays, days)
+	}
 	return nil
 }
 
 // Subreddit describes the filtering configuration for specific subreddit, e.g. /r/funny.
-// It is an element of the RedditFeed structure.
+// It is an element of the RedditFeed structure. Instead of a subreddit, it may describe
+// another source of posts: a multireddit, a user's submissions, or a search query.
+// Exactly one of Name, Multireddit, User and Search must be given.
 type Subreddit struct {
 	Name          string  `json:"name"`            // The subreddit name, without the leading '/r/'
+	Multireddit   string  `json:"multireddit"`     // "<user>/<multireddit name>"
+	User          string  `json:"user"`            // The user name, without the leading '/u/'
+	Search        string  `json:"search"`          // A Reddit search query
 	Percentile    float64 `json:"percentile"`      // Percent of posts to include from this subreddit (0-100)
 	MaxDailyPosts int     `json:"max_daily_posts"` // Maximum # of posts to include per day from this subreddit.
+	// # of top comments to harvest for posts that pass the percentile filter. (0 = none)
+	TopComments int `json:"top_comments"`
+	// # of hours after a post is created that its comments continue to be refreshed
+	CommentRefreshHours int `json:"comment_refresh_hours"`
+	// The listings to harvest posts from. Posts found in several listings are only stored once.
+	Listings []string `json:"listings"`
+	// Rules that select which of this subreddit's posts are shown, in addition to the feed's rules
+	Include RedditFilterRules `json:"include"`
+	Exclude RedditFilterRules `json:"exclude"`
+}
+
+// SourceKey identifies the source of posts. A subreddit's is just its name, while other sources'
+// are prefixed by their type, e.g. "user:someuser". (Subreddit names can't contain ':').
+func (this Subreddit) SourceKey() string {
+	switch {
+	case this.Multireddit != "":
+		return REDDIT_SOURCE_MULTIREDDIT + ":" + this.Multireddit
+	case this.User != "":
+		return REDDIT_SOURCE_USER + ":" + this.User
+	case this.Search != "":
+		return REDDIT_SOURCE_SEARCH + ":" + this.Search
+	}
+	return this.Name
 }
 
 // Validate returns nil if the Subreddit structure is syntactically valid, or an error if it is not.
 func (this Subreddit) Validate() (err error) {
-	if this.Name == "" {
+	var numSources int
+	for _, source := range []string{this.Name, this.Multireddit, this.User, this.Search} {
+		if source != "" {
+			numSources++
+		}
+	}
+	if numSources == 0 {
 		return fmt.Errorf("Empty subreddit name")
 	}
+	if numSources > 1 {
+		return fmt.Errorf("Only one of name, multireddit, user or search may be given")
+	}
+	if this.Name != "" && !regexp.MustCompile("^[_a-zA-Z0-9]+$").MatchString(this.Name) {
+		return fmt.Errorf("Invalid subreddit name, must contain only chars from A-Z, a-z, 0-9, & '_'")
+	}
+	if this.Multireddit != "" && !regexp.MustCompile("^[-_a-zA-Z0-9]+/[_a-zA-Z0-9]+$").MatchString(this.Multireddit) {
+		return fmt.Errorf("Invalid multireddit, must be of the form '<user>/<multireddit name>': '%s'", this.Multireddit)
+	}
+	if this.User != "" && !regexp.MustCompile("^[-_a-zA-Z0-9]+$").MatchString(this.User) {
+		return fmt.Errorf("Invalid user name: '%s'", this.User)
+	}
 	if this.Percentile < 0.0 || this.Percentile > 100.0 {
 		return fmt.Errorf("Percentile out of 0-100 range. : %f", this.Percentile)
 	}
 	if this.MaxDailyPosts < 0 {
 		return fmt.Errorf("MaxDailyPosts must be a +ve integer. : %d", this.MaxDailyPosts)
 	}
+	if this.TopComments > maxTopComments {
+		return fmt.Errorf("TopComments must be at most %d. : %d", maxTopComments, this.TopComments)
+	}
+	if this.CommentRefreshHours < 0 {
+		return fmt.Errorf("CommentRefreshHours must be a +ve integer. : %d", this.CommentRefreshHours)
+	}
+	if len(this.Listings) == 0 {
+		return fmt.Errorf("No listings to harvest")
+	}
+	if err = validateRedditListings(this.Listings); err != nil {
+		return
+	}
+	if this.User != "" || this.Search != "" {
+		for _, listing := range this.Listings {
+			if listing == REDDIT_LISTING_RISING {
+				return fmt.Errorf("The '%s' listing is only available for subreddits and multireddits", REDDIT_LISTING_RISING)
+			}
+		}
+	}
+	if err = this.Include.Validate(); err != nil {
+		return fmt.Errorf("Problem in include rules: %v", err)
+	}
+	if err = this.Exclude.Validate(); err != nil {
+		return fmt.Errorf("Problem in exclude rules: %v", err)
+	}
 	return nil
 }
 
+// RedditFilterRules match posts by their properties. A post matches if it matches any of the
+// rules. A feed (or subreddit) shows only the posts that match its "include" rules, if it has
+// any, and never shows posts that match its "exclude" rules.
+type RedditFilterRules struct {
+	TitleKeywords []string `json:"title_keywords"` // Words or phrases in the title (case-insensitive)
+	TitleRegexes  []string `json:"title_regexes"`  // Regular expressions matched against the title
+	Domains       []string `json:"domains"`        // The link's domain, including its subdomains
+	Flairs        []string `json:"flairs"`         // The post's link flair (case-insensitive)
+	Authors       []string `json:"authors"`        // The user name of the post's author
+	Nsfw          bool     `json:"nsfw"`           // Posts marked NSFW
+	Spoiler       bool     `json:"spoiler"`        // Posts marked as spoilers
+}
+
+// Validate returns nil if the rules are syntactically valid, or an error if they are not.
+func (this RedditFilterRules) Validate() (err error) {
+	for _, keyword := range this.TitleKeywords {
+		if keyword == "" {
+			return fmt.Errorf("Empty title keyword")
+		}
+	}
+	for _, expr := range this.TitleRegexes {
+		if _, err = regexp.Compile(expr); err != nil {
+			return fmt.Errorf("Invalid title regex: '%s' %v", expr, err)
+		}
+	}
+	for _, domain := range this.Domains {
+		if !regexp.MustCompile("^[-a-z0-9]+(\\.[-a-z0-9]+)*$").MatchString(domain) {
+			return fmt.Errorf("Invalid domain: '%s'", domain)
+		}
+	}
+	for _, flair := range this.Flairs {
+		if flair == "" {
+			return fmt.Errorf("Empty flair")
+		}
+	}
+	for _, author := range this.Authors {
+		if !regexp.MustCompile("^[-_a-z0-9]+$").MatchString(author) {
+			return fmt.Errorf("Invalid author name: '%s'", author)
+		}
+	}
+	return nil
+}
+
+// canonicalize lowercases the rules that are case-insensitive, and strips the decorations that
+// are commonly copied along with domains & user names, e.g. "www." and "u/".
+func (this *RedditFilterRules) canonicalize() {
+	for i, keyword := range this.TitleKeywords {
+		this.TitleKeywords[i] = strings.TrimSpace(keyword)
+	}
+	for i, domain := range this.Domains {
+		this.Domains[i] = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "www.")
+	}
+	for i, flair := range this.Flairs {
+		this.Flairs[i] = strings.TrimSpace(flair)
+	}
+	for i, author := range this.Authors {
+		this.Authors[i] = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(author)), "u/")
+	}
+}
+
+// TwitterConfig is a struct that stores all Twitter-related configuration.
 type TwitterConfig struct {
 	Secrets TwitterSecrets `json:"secrets"`
 	Feeds   []TwitterFeed  `json:"feeds"`
 }
 
+// TwitterSecrets stores the credentials used by the harvester. ClientSecret is
+// the app's API bearer token (app-only authentication), which is all that's needed
+// to read public timelines.
 type TwitterSecrets struct {
 	ClientSecret string `json:"clientsecret"`
 }
 
+func (this TwitterSecrets) redacted() TwitterSecrets {
+	return TwitterSecrets{ClientSecret: redact(this.ClientSecret)}
+}
+
+// String and GoString redact the secrets, so that they can't leak into log lines or debug dumps.
+func (this TwitterSecrets) String() string {
+	return fmt.Sprintf("%+v", twitterSecretsFields(this.redacted()))
+}
+
+func (this TwitterSecrets) GoString() string {
+	return fmt.Sprintf("%#v", twitterSecretsFields(this.redacted()))
+}
+
+type twitterSecretsFields TwitterSecrets
+
+// TwitterFeed describes a feed of tweets from 1-many Twitter accounts. Each account
+// is described by one or more TwitterFilters.
 type TwitterFeed struct {
 	Name        string          `json:"name"`
 	Description string          `json:"description"`
 	Filters     []TwitterFilter `json:"filters"`
 }
 
+// Validate returns nil if the TwitterFeed structure is syntactically valid, or an error if it is not.
 func (this TwitterFeed) Validate() (err error) {
 	if this.Name == "" {
 		return fmt.Errorf("Empty feed name")
 	}
+	if !regexp.MustCompile("^[-_a-zA-Z0-9]+$").MatchString(this.Name) {
+		return fmt.Errorf("Invalid feed name, must contain only chars from A-Z, a-z, 0-9, '_', & '-'")
+	}
 	if this.Description == "" {
 		return fmt.Errorf("Empty feed description")
 	}
 	return nil
 }
 
+const (
+	TWITTER_FILTERTYPE_ORIGINAL = "original"
+	TWITTER_FILTERTYPE_RETWEETS = "retweets"
+)
+
+// TwitterFilter describes the filtering configuration for a specific Twitter account.
+// FilterType selects whether it applies to the account's original tweets or to its retweets,
+// so an account may appear twice in a feed with different criteria for each.
 type TwitterFilter struct {
-	AccountName   string  `json:"account"`
-	FilterType    string  `json:"filtertype"`
-	Percentile    float64 `json:"percentile"`
-	MaxDailyPosts int     `json:"max_daily_posts"`
+	AccountName   string  `json:"account"`         // The account name, without the leading '@'
+	FilterType    string  `json:"filtertype"`      // One of "original" or "retweets"
+	Percentile    float64 `json:"percentile"`      // Percent of tweets to include from this account (0-100)
+	MaxDailyPosts int     `json:"max_daily_posts"` // Maximum # of tweets to include per day from this account.
 }
 
+// Validate returns nil if the TwitterFilter structure is syntactically valid, or an error if it is not.
 func (this TwitterFilter) Validate() (err error) {
 	if this.AccountName == "" {
 		return fmt.Errorf("Empty account name")
 	}
-	if !(this.FilterType == "original" || this.FilterType == "retweets") {
+	if !(this.FilterType == TWITTER_FILTERTYPE_ORIGINAL || this.FilterType == TWITTER_FILTERTYPE_RETWEETS) {
 		return fmt.Errorf("Invalid filter type: '%s'", this.FilterType)
 	}
 	if this.Percentile < 0.0 || this.Percentile > 100.0 {
@@ -144,15 +571,146 @@ func (this TwitterFilter) Validate() (err error) {
 	return nil
 }
 
+// HackerNewsConfig is a struct that stores all Hacker News-related configuration.
+// (No credentials are required to read Hacker News)
+type HackerNewsConfig struct {
+	Feeds []HackerNewsFeed `json:"feeds"`
+}
+
+// The story lists published by Hacker News.
+var HackerNewsListNames = []string{"top", "new", "best", "ask", "show"}
+
+// HackerNewsFeed describes the filtering configuration for a feed of Hacker News stories,
+// drawn from 1-many of HN's story lists.
+type HackerNewsFeed struct {
+	Name                 string           `json:"name"`
+	Description          string           `json:"description"`
+	Lists                []HackerNewsList `json:"lists"`
+	DefaultPercentile    float64          `json:"percentile"`
+	DefaultMaxDailyPosts int              `json:"max_daily_posts"`
+}
+
+// Validate returns nil if the HackerNewsFeed structure is syntactically valid, or an error if it is not.
+func (this HackerNewsFeed) Validate() (err error) {
+	if this.Name == "" {
+		return fmt.Errorf("Empty feed name")
+	}
+	if !regexp.MustCompile("^[-_a-zA-Z0-9]+$").MatchString(this.Name) {
+		return fmt.Errorf("Invalid feed name, must contain only chars from A-Z, a-z, 0-9, '_', & '-'")
+	}
+	if this.Description == "" {
+		return fmt.Errorf("Empty feed description")
+	}
+	return nil
+}
+
+// HackerNewsList describes the filtering configuration for one of HN's story lists, e.g. "top".
+// It is an element of the HackerNewsFeed structure.
+type HackerNewsList struct {
+	Name          string  `json:"name"`            // One of the HackerNewsListNames
+	Percentile    float64 `json:"percentile"`      // Percent of stories to include from this list (0-100)
+	MaxDailyPosts int     `json:"max_daily_posts"` // Maximum # of stories to include per day from this list.
+}
+
+// Validate returns nil if the HackerNewsList structure is syntactically valid, or an error if it is not.
+func (this HackerNewsList) Validate() (err error) {
+	if !toolbox.ContainsStr(HackerNewsListNames, this.Name) {
+		return fmt.Errorf("Invalid list name: '%s', must be one of: %s", this.Name, strings.Join(HackerNewsListNames, ", "))
+	}
+	if this.Percentile < 0.0 || this.Percentile > 100.0 {
+		return fmt.Errorf("Percentile out of 0-100 range: %f", this.Percentile)
+	}
+	if this.MaxDailyPosts < 0 {
+		return fmt.Errorf("MaxDailyPosts must be a +ve integer: %d", this.MaxDailyPosts)
+	}
+	return nil
+}
+
+// RssConfig is a struct that stores all configuration for RSS/Atom feeds.
+type RssConfig struct {
+	Feeds []RssFeed `json:"feeds"`
+}
+
+// RssFeed describes a feed that gloms together items from 1-many RSS or Atom documents
+// (sources). Because these sources have no notion of a score, there is no percentile
+// filtering; only the most recent MaxDailyPosts items per day are shown from each source.
+type RssFeed struct {
+	Name                 string      `json:"name"`
+	Description          string      `json:"description"`
+	Media                string      `json:"media"`
+	Sources              []RssSource `json:"sources"`
+	DefaultMaxDailyPosts int         `json:"max_daily_posts"`
+}
+
+// Validate returns nil if the RssFeed structure is syntactically valid, or an error if it is not.
+func (this RssFeed) Validate() (err error) {
+	if this.Name == "" {
+		return fmt.Errorf("Empty feed name")
+	}
+	if !regexp.MustCompile("^[-_a-zA-Z0-9]+$").MatchString(this.Name) {
+		return fmt.Errorf("Invalid feed name, must contain only chars from A-Z, a-z, 0-9, '_', & '-'")
+	}
+	if this.Description == "" {
+		return fmt.Errorf("Empty feed description")
+	}
+	if !(this.Media == MEDIA_TYPE_IMAGE || this.Media == MEDIA_TYPE_TEXT) {
+		return fmt.Errorf(
+			"Invalid media type: '%s', must be one of '%s' or '%s'",
+			this.Media,
+			MEDIA_TYPE_IMAGE,
+			MEDIA_TYPE_TEXT,
+		)
+	}
+	return nil
+}
+
+// RssSource describes a single RSS or Atom document to poll. It is an element of the RssFeed structure.
+type RssSource struct {
+	Name          string `json:"name"`            // A label for the source. Defaults to the URL.
+	Url           string `json:"url"`             // The URL of the RSS/Atom document
+	MaxDailyPosts int    `json:"max_daily_posts"` // Maximum # of items to include per day from this source.
+}
+
+// Validate returns nil if the RssSource structure is syntactically valid, or an error if it is not.
+func (this RssSource) Validate() (err error) {
+	if this.Url == "" {
+		return fmt.Errorf("Empty URL")
+	}
+	u, err := url.Parse(this.Url)
+	if err != nil {
+		return fmt.Errorf("Invalid URL: %v", err)
+	}
+	if !(u.Scheme == "http" || u.Scheme == "https") || u.Host == "" {
+		return fmt.Errorf("Invalid URL, must be an absolute http or https URL: '%s'", this.Url)
+	}
+	if this.MaxDailyPosts < 0 {
+		return fmt.Errorf("MaxDailyPosts must be a +ve integer: %d", this.MaxDailyPosts)
+	}
+	return nil
+}
+
 // Validate returns nil if the Config structure is syntactically and semantically valid, otherwise it returns an error.
 func (this *Config) Validate() (err error) {
 	// validation is mainly concerned with ensuring that all feed names are unique
 	// across source types, that percentile values are within range, etc.
 
 	var feednames = make(map[string]bool)
-	var subredditnames = make(map[string]bool)
-
 	log.Debug("Validating config file")
+	if err := this.Reddit.Secrets.Validate(); err != nil {
+		return fmt.Errorf("Problem in Reddit secrets: %s", err)
+	}
+	if this.Reddit.Concurrency < 0 {
+		return fmt.Errorf("Reddit concurrency must be a +ve integer: %d", this.Reddit.Concurrency)
+	}
+	if this.Reddit.RequestsPerMinute < 0 {
+		return fmt.Errorf("Reddit requests_per_minute must be a +ve integer: %d", this.Reddit.RequestsPerMinute)
+	}
+	if this.Reddit.RequestBurst < 0 {
+		return fmt.Errorf("Reddit request_burst must be a +ve integer: %d", this.Reddit.RequestBurst)
+	}
+	if err := validateRetentionDays(this.RetentionDays); err != nil {
+		return err
+	}
 	for idx, redditFeed := range this.Reddit.Feeds {
 		var feedname = redditFeed.Name
 		var feederr_template = fmt.Sprintf("Problem in Reddit feed '%s', index %d ", feedname, idx+1)
@@ -164,14 +722,17 @@ func (this *Config) Validate() (err error) {
 		}
 		feednames[feedname] = true
 
+		// A source may be in several feeds, but only once in each.
+		var sourceKeys = make(map[string]bool)
 		for sub_idx, subreddit := range redditFeed.Subreddits {
 			var subredditerr_template = fmt.Sprintf("%s, subreddit: '%s' (index %d) ", feederr_template, subreddit.Name, sub_idx+1)
 			if err := subreddit.Validate(); err != nil {
 				return fmt.Errorf("%s: %s", subredditerr_template, err)
 			}
-			if _, is_present := subredditnames[subreddit.Name]; is_present {
-				return fmt.Errorf("%s: Duplicate subreddit name. Subreddits must be unique across feeds.", subredditerr_template)
+			if _, is_present := sourceKeys[subreddit.SourceKey()]; is_present {
+				return fmt.Errorf("%s: Duplicate source '%s'. A feed's sources must be unique.", subredditerr_template, subreddit.SourceKey())
 			}
+			sourceKeys[subreddit.SourceKey()] = true
 		}
 	}
 
@@ -185,11 +746,63 @@ func (this *Config) Validate() (err error) {
 		}
 		feednames[twitterFeed.Name] = true
 
+		var filterkeys = make(map[string]bool)
 		for sub_idx, twitterFilter := range twitterFeed.Filters {
 			var filtererr_template = fmt.Sprintf("%s, filter index %d ", feederr_template, sub_idx+1)
 			if err := twitterFilter.Validate(); err != nil {
 				return fmt.Errorf("%s: %s", filtererr_template, err)
 			}
+			var filterkey = twitterFilter.AccountName + "/" + twitterFilter.FilterType
+			if _, is_present := filterkeys[filterkey]; is_present {
+				return fmt.Errorf("%s: Duplicate account/filtertype detected.", filtererr_template)
+			}
+			filterkeys[filterkey] = true
+		}
+	}
+
+	for idx, hnFeed := range this.HackerNews.Feeds {
+		var feederr_template = fmt.Sprintf("Problem in Hacker News feed #%d, name: '%s' ", idx+1, hnFeed.Name)
+		if err := hnFeed.Validate(); err != nil {
+			return fmt.Errorf("%s: %s", feederr_template, err)
+		}
+		if _, is_present := feednames[hnFeed.Name]; is_present {
+			return fmt.Errorf("%s: Duplicate name detected. Feed names must be globally unique.", feederr_template)
+		}
+		feednames[hnFeed.Name] = true
+
+		var listnames = make(map[string]bool)
+		for list_idx, hnList := range hnFeed.Lists {
+			var listerr_template = fmt.Sprintf("%s, list: '%s' (index %d) ", feederr_template, hnList.Name, list_idx+1)
+			if err := hnList.Validate(); err != nil {
+				return fmt.Errorf("%s: %s", listerr_template, err)
+			}
+			if _, is_present := listnames[hnList.Name]; is_present {
+				return fmt.Errorf("%s: Duplicate list name detected.", listerr_template)
+			}
+			listnames[hnList.Name] = true
+		}
+	}
+
+	for idx, rssFeed := range this.Rss.Feeds {
+		var feederr_template = fmt.Sprintf("Problem in RSS feed #%d, name: '%s' ", idx+1, rssFeed.Name)
+		if err := rssFeed.Validate(); err != nil {
+			return fmt.Errorf("%s: %s", feederr_template, err)
+		}
+		if _, is_present := feednames[rssFeed.Name]; is_present {
+			return fmt.Errorf("%s: Duplicate name detected. Feed names must be globally unique.", feederr_template)
+		}
+		feednames[rssFeed.Name] = true
+
+		var sourceurls = make(map[string]bool)
+		for source_idx, rssSource := range rssFeed.Sources {
+			var sourceerr_template = fmt.Sprintf("%s, source: '%s' (index %d) ", feederr_template, rssSource.Name, source_idx+1)
+			if err := rssSource.Validate(); err != nil {
+				return fmt.Errorf("%s: %s", sourceerr_template, err)
+			}
+			if _, is_present := sourceurls[rssSource.Url]; is_present {
+				return fmt.Errorf("%s: Duplicate source URL detected.", sourceerr_template)
+			}
+			sourceurls[rssSource.Url] = true
 		}
 	}
 	return nil
@@ -197,6 +810,31 @@ func (this *Config) Validate() (err error) {
 
 func (this *Config) populateDefaults() {
 	// Populate defaults
+	// As with MaxDailyPosts, a -ve number means 0 (i.e. keep posts forever), since 0 means the default.
+	if this.RetentionDays < 0 {
+		this.RetentionDays = 0
+	} else if this.RetentionDays == 0 {
+		this.RetentionDays = defaultRetentionDays
+	}
+	if this.Reddit.Concurrency == 0 {
+		this.Reddit.Concurrency = defaultRedditConcurrency
+	}
+	if this.Reddit.RequestsPerMinute == 0 {
+		if this.Reddit.Secrets.IsEmpty() {
+			this.Reddit.RequestsPerMinute = defaultRedditAnonymousRequestsPerMinute
+		} else {
+			this.Reddit.RequestsPerMinute = defaultRedditRequestsPerMinute
+		}
+	}
+	if this.Reddit.RequestBurst == 0 {
+		this.Reddit.RequestBurst = defaultRedditRequestBurst
+	}
+	// As with MaxDailyPosts, a -ve number means 0 (i.e. no retries), since 0 means the default.
+	if this.Reddit.MaxRetries < 0 {
+		this.Reddit.MaxRetries = 0
+	} else if this.Reddit.MaxRetries == 0 {
+		this.Reddit.MaxRetries = defaultRedditMaxRetries
+	}
 	for idx, redditfeed := range this.Reddit.Feeds {
 		if redditfeed.DefaultPercentile == 0 {
 			this.Reddit.Feeds[idx].DefaultPercentile = float64(defaultPercentile)
@@ -213,9 +851,45 @@ func (this *Config) populateDefaults() {
 		if redditfeed.Media == "" {
 			this.Reddit.Feeds[idx].Media = MEDIA_TYPE_TEXT
 		}
+		// Comments are not harvested unless configured, so 0 and -ve numbers both mean "none".
+		if redditfeed.DefaultTopComments < 0 {
+			this.Reddit.Feeds[idx].DefaultTopComments = 0
+		}
+		if redditfeed.DefaultCommentRefreshHours == 0 {
+			this.Reddit.Feeds[idx].DefaultCommentRefreshHours = defaultCommentRefreshHours
+		}
+		if redditfeed.Ranking == "" {
+			this.Reddit.Feeds[idx].Ranking = REDDIT_RANKING_PERCENTILE
+		}
+		if redditfeed.VelocityHours == 0 {
+			this.Reddit.Feeds[idx].VelocityHours = defaultVelocityHours
+		}
+		// See above.
+		if redditfeed.RetentionDays < 0 {
+			this.Reddit.Feeds[idx].RetentionDays = 0
+		} else if redditfeed.RetentionDays == 0 {
+			this.Reddit.Feeds[idx].RetentionDays = this.RetentionDays
+		}
+		if redditfeed.Sort == "" {
+			this.Reddit.Feeds[idx].Sort = REDDIT_SORT_CHRONOLOGICAL
+		} else {
+			this.Reddit.Feeds[idx].Sort = strings.ToLower(redditfeed.Sort)
+		}
+		this.Reddit.Feeds[idx].Include.canonicalize()
+		this.Reddit.Feeds[idx].Exclude.canonicalize()
+		if len(redditfeed.DefaultListings) == 0 {
+			this.Reddit.Feeds[idx].DefaultListings = append([]string(nil), defaultRedditListings...)
+		} else {
+			this.Reddit.Feeds[idx].DefaultListings = canonicalizeRedditListings(redditfeed.DefaultListings)
+		}
 		for subidx, subreddit := range redditfeed.Subreddits {
-			// Canonicalize subreddit name (i.e. lowercase)
+			// Canonicalize subreddit, multireddit & user names (i.e. lowercase)
 			this.Reddit.Feeds[idx].Subreddits[subidx].Name = strings.ToLower(subreddit.Name)
+			this.Reddit.Feeds[idx].Subreddits[subidx].Multireddit = strings.ToLower(subreddit.Multireddit)
+			this.Reddit.Feeds[idx].Subreddits[subidx].User = strings.ToLower(strings.TrimPrefix(subreddit.User, "u/"))
+			this.Reddit.Feeds[idx].Subreddits[subidx].Search = strings.TrimSpace(subreddit.Search)
+			this.Reddit.Feeds[idx].Subreddits[subidx].Include.canonicalize()
+			this.Reddit.Feeds[idx].Subreddits[subidx].Exclude.canonicalize()
 			if subreddit.Percentile == 0 {
 				this.Reddit.Feeds[idx].Subreddits[subidx].Percentile = this.Reddit.Feeds[idx].DefaultPercentile
 			}
@@ -225,6 +899,89 @@ func (this *Config) populateDefaults() {
 			} else if subreddit.MaxDailyPosts == 0 {
 				this.Reddit.Feeds[idx].Subreddits[subidx].MaxDailyPosts = this.Reddit.Feeds[idx].DefaultMaxDailyPosts
 			}
+			// See above.
+			if subreddit.TopComments < 0 {
+				this.Reddit.Feeds[idx].Subreddits[subidx].TopComments = 0
+			} else if subreddit.TopComments == 0 {
+				this.Reddit.Feeds[idx].Subreddits[subidx].TopComments = this.Reddit.Feeds[idx].DefaultTopComments
+			}
+			if subreddit.CommentRefreshHours == 0 {
+				this.Reddit.Feeds[idx].Subreddits[subidx].CommentRefreshHours = this.Reddit.Feeds[idx].DefaultCommentRefreshHours
+			}
+			if len(subreddit.Listings) == 0 {
+				this.Reddit.Feeds[idx].Subreddits[subidx].Listings = this.Reddit.Feeds[idx].DefaultListings
+			} else {
+				this.Reddit.Feeds[idx].Subreddits[subidx].Listings = canonicalizeRedditListings(subreddit.Listings)
+			}
+		}
+	}
+
+	for idx, twitterfeed := range this.Twitter.Feeds {
+		for filteridx, filter := range twitterfeed.Filters {
+			// Canonicalize account name (i.e. lowercase, no leading '@')
+			this.Twitter.Feeds[idx].Filters[filteridx].AccountName = strings.ToLower(strings.TrimPrefix(filter.AccountName, "@"))
+			if filter.Percentile == 0 {
+				this.Twitter.Feeds[idx].Filters[filteridx].Percentile = float64(defaultPercentile)
+			}
+			// See above.
+			if filter.MaxDailyPosts < 0 {
+				this.Twitter.Feeds[idx].Filters[filteridx].MaxDailyPosts = 0
+			} else if filter.MaxDailyPosts == 0 {
+				this.Twitter.Feeds[idx].Filters[filteridx].MaxDailyPosts = defaultMaxDailyPosts
+			}
+		}
+	}
+
+	for idx, hnfeed := range this.HackerNews.Feeds {
+		if hnfeed.DefaultPercentile == 0 {
+			this.HackerNews.Feeds[idx].DefaultPercentile = float64(defaultPercentile)
+		}
+		// See above.
+		if hnfeed.DefaultMaxDailyPosts < 0 {
+			this.HackerNews.Feeds[idx].DefaultMaxDailyPosts = 0
+		} else if hnfeed.DefaultMaxDailyPosts == 0 {
+			this.HackerNews.Feeds[idx].DefaultMaxDailyPosts = defaultMaxDailyPosts
+		}
+		for listidx, hnlist := range hnfeed.Lists {
+			// Canonicalize list name (i.e. lowercase)
+			this.HackerNews.Feeds[idx].Lists[listidx].Name = strings.ToLower(hnlist.Name)
+			if hnlist.Percentile == 0 {
+				this.HackerNews.Feeds[idx].Lists[listidx].Percentile = this.HackerNews.Feeds[idx].DefaultPercentile
+			}
+			// See above.
+			if hnlist.MaxDailyPosts < 0 {
+				this.HackerNews.Feeds[idx].Lists[listidx].MaxDailyPosts = 0
+			} else if hnlist.MaxDailyPosts == 0 {
+				this.HackerNews.Feeds[idx].Lists[listidx].MaxDailyPosts = this.HackerNews.Feeds[idx].DefaultMaxDailyPosts
+			}
+		}
+	}
+
+	for idx, rssfeed := range this.Rss.Feeds {
+		// See above.
+		if rssfeed.DefaultMaxDailyPosts < 0 {
+			this.Rss.Feeds[idx].DefaultMaxDailyPosts = 0
+		} else if rssfeed.DefaultMaxDailyPosts == 0 {
+			this.Rss.Feeds[idx].DefaultMaxDailyPosts = defaultMaxDailyPosts
+		}
+		if rssfeed.Media == "" {
+			this.Rss.Feeds[idx].Media = MEDIA_TYPE_TEXT
+		}
+		for sourceidx, source := range rssfeed.Sources {
+			if source.Name == "" {
+				this.Rss.Feeds[idx].Sources[sourceidx].Name = source.Url
+				// The URL may contain a secret, e.g. an API key.
+				var path = fmt.Sprintf("rss.feeds[%d].sources[%d].", idx, sourceidx)
+				if this.interpolatedPaths[path+"url"] {
+					this.markInterpolated(path + "name")
+				}
+			}
+			// See above.
+			if source.MaxDailyPosts < 0 {
+				this.Rss.Feeds[idx].Sources[sourceidx].MaxDailyPosts = 0
+			} else if source.MaxDailyPosts == 0 {
+				this.Rss.Feeds[idx].Sources[sourceidx].MaxDailyPosts = this.Rss.Feeds[idx].DefaultMaxDailyPosts
+			}
 		}
 	}
 }
@@ -265,10 +1022,12 @@ func GetConfig() (conf *Config, err error) {
 	if conf, err = parseFromString(contents); err != nil {
 		return nil, fmt.Errorf("Failed to parse config file: %v", err)
 	}
+	if err = conf.interpolate(filepath.Dir(configFilePath)); err != nil {
+		return nil, fmt.Errorf("Failed to resolve config file references: %v", err)
+	}
 
 	conf.populateDefaults()
 
 	err = conf.Validate()
-	// log.Fatal(spew.Sdump(conf))
 	return
 }
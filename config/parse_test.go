@@ -3,8 +3,10 @@ package config
 import (
 	"fmt"
 	"github.com/davecgh/go-spew/spew"
+	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -15,6 +17,8 @@ reddit:
         clientsecret: "some_client_secret"
         username: "some_reddit_user"
         password: "some_password"
+    requests_per_minute: 30
+    max_retries: -1
 
     feeds:
         - name: "foo"
@@ -33,13 +37,30 @@ reddit:
           description: "bar bar"
           media: "text"
           percentile: 85.0
+          top_comments: 5
+          listings: ["new", "Top"]
+          ranking: "velocity"
+          velocity_hours: 6
+          sort: "Gravity"
+          retention_days: 14
+          exclude:
+            title_keywords: ["spoilers "]
+            domains: ["WWW.Example.com"]
+            nsfw: true
           subreddits:
             - name: "subreddit3"
               percentile: 70.0
               max_daily_posts: 22
+              top_comments: 10
+              comment_refresh_hours: 48
+              listings: ["rising", "top:week"]
+              include:
+                flairs: ["Discussion"]
+                authors: ["u/SomeUser"]
             - name: "subreddit4"
             - name: "subreddit5"
               percentile: 72.0
+              top_comments: -1
 
 twitter:
     secrets:
@@ -67,49 +88,89 @@ func TestConfigParsing(t *testing.T) {
 				Username:     "some_reddit_user",
 				Password:     "some_password",
 			},
+			Concurrency:       defaultRedditConcurrency,
+			RequestsPerMinute: 30,
+			RequestBurst:      defaultRedditRequestBurst,
+			MaxRetries:        0,
 			Feeds: []RedditFeed{
 				RedditFeed{
-					Name:                 "foo",
-					Description:          "foo foo",
-					Media:                "image",
-					DefaultPercentile:    87.0,
-					DefaultMaxDailyPosts: 103,
+					Name:                       "foo",
+					Description:                "foo foo",
+					Media:                      "image",
+					DefaultPercentile:          87.0,
+					DefaultMaxDailyPosts:       103,
+					DefaultCommentRefreshHours: 24,
+					DefaultListings:            []string{"hot"}, // The global default
+					Ranking:                    "percentile",    // The global default
+					VelocityHours:              12,              // The global default
+					Sort:                       "chronological", // The global default
+					RetentionDays:              defaultRetentionDays,
 					Subreddits: []Subreddit{
 						Subreddit{
-							Name:          "subreddit1",
-							Percentile:    80.0,
-							MaxDailyPosts: 100,
+							Name:                "subreddit1",
+							Percentile:          80.0,
+							MaxDailyPosts:       100,
+							CommentRefreshHours: 24,
+							Listings:            []string{"hot"},
 						},
 						Subreddit{
-							Name:          "subreddit2",
-							Percentile:    90.0,
-							MaxDailyPosts: 73,
+							Name:                "subreddit2",
+							Percentile:          90.0,
+							MaxDailyPosts:       73,
+							CommentRefreshHours: 24,
+							Listings:            []string{"hot"},
 						},
 					},
 				},
 				RedditFeed{
-					Name:                 "bar",
-					Description:          "bar bar",
-					Media:                "text",
-					DefaultPercentile:    85.0,
-					DefaultMaxDailyPosts: 100, // The global default
+					Name:                       "bar",
+					Description:                "bar bar",
+					Media:                      "text",
+					DefaultPercentile:          85.0,
+					DefaultMaxDailyPosts:       100, // The global default
+					DefaultTopComments:         5,
+					DefaultCommentRefreshHours: 24, // The global default
+					DefaultListings:            []string{"new", "top:day"},
+					Ranking:                    "velocity",
+					VelocityHours:              6,
+					Sort:                       "gravity",
+					RetentionDays:              14,
+					Exclude: RedditFilterRules{
+						TitleKeywords: []string{"spoilers"},
+						Domains:       []string{"example.com"},
+						Nsfw:          true,
+					},
 					Subreddits: []Subreddit{
 						Subreddit{
-							Name:          "subreddit3",
-							Percentile:    70.0,
-							MaxDailyPosts: 22,
+							Name:                "subreddit3",
+							Percentile:          70.0,
+							MaxDailyPosts:       22,
+							TopComments:         10,
+							CommentRefreshHours: 48,
+							Listings:            []string{"rising", "top:week"},
+							Include: RedditFilterRules{
+								Flairs:  []string{"Discussion"},
+								Authors: []string{"someuser"},
+							},
 						},
 						Subreddit{
 							Name: "subreddit4",
-							// Percentile + MaxDailyPosts inherited from Feed default
-							Percentile:    85.0,
-							MaxDailyPosts: 100,
+							// Percentile, MaxDailyPosts, TopComments, CommentRefreshHours + Listings inherited from Feed default
+							Percentile:          85.0,
+							MaxDailyPosts:       100,
+							TopComments:         5,
+							CommentRefreshHours: 24,
+							Listings:            []string{"new", "top:day"},
 						},
 						Subreddit{
 							Name:       "subreddit5",
 							Percentile: 72.0,
-							// MaxDailyPosts inherited from Feed default
+							// MaxDailyPosts, CommentRefreshHours + Listings inherited from Feed default
 							MaxDailyPosts: 100,
+							// -ve means none
+							TopComments:         0,
+							CommentRefreshHours: 24,
+							Listings:            []string{"new", "top:day"},
 						},
 					},
 				},
@@ -140,6 +201,7 @@ func TestConfigParsing(t *testing.T) {
 				},
 			},
 		},
+		RetentionDays:    defaultRetentionDays,
 		BackendStorePath: filepath.Join(storageDir, databaseFileName),
 	}
 
@@ -157,3 +219,421 @@ func TestConfigParsing(t *testing.T) {
 		t.Error("Config structure differed from expectation: (actual, expected)\n", spew.Sdump(conf), spew.Sdump(expected))
 	}
 }
+
+func TestTwitterFilterDefaultsAndValidation(t *testing.T) {
+	var conf *Config
+	var err error
+	if conf, err = parseFromString(`
+twitter:
+    feeds:
+        - name: "news"
+          description: "news tweets"
+          filters:
+            - account: "@SomeAccount"
+              filtertype: "original"
+            - account: "someaccount"
+              filtertype: "retweets"
+              max_daily_posts: -1
+`); err != nil {
+		t.Fatal("Could not parse fake config file", err)
+	}
+	conf.populateDefaults()
+	if err = conf.Validate(); err != nil {
+		t.Error("Did not validate", err)
+	}
+	filters := conf.Twitter.Feeds[0].Filters
+	if filters[0].AccountName != "someaccount" || filters[0].Percentile != float64(defaultPercentile) || filters[0].MaxDailyPosts != defaultMaxDailyPosts {
+		t.Error("Unexpected defaults for 1st filter", spew.Sdump(filters[0]))
+	}
+	if filters[1].MaxDailyPosts != 0 {
+		t.Error("Expected a negative max_daily_posts to become 0", spew.Sdump(filters[1]))
+	}
+
+	// The same account & filter type twice is ambiguous.
+	conf.Twitter.Feeds[0].Filters[1].FilterType = TWITTER_FILTERTYPE_ORIGINAL
+	if err = conf.Validate(); err == nil {
+		t.Error("Expected duplicate account/filtertype to fail validation")
+	}
+}
+
+func TestRedditListingValidation(t *testing.T) {
+	var conf *Config
+	var err error
+	if conf, err = parseFromString(`
+reddit:
+    feeds:
+        - name: "funny"
+          description: "Funny pictures"
+          subreddits:
+            - name: "funny"
+              listings: ["hot", "new"]
+`); err != nil {
+		t.Fatal("Could not parse fake config file", err)
+	}
+	conf.populateDefaults()
+	if err = conf.Validate(); err != nil {
+		t.Error("Did not validate", err)
+	}
+
+	for _, listings := range [][]string{
+		[]string{"best"},
+		[]string{"top:fortnight"},
+		[]string{"new:week"},
+		[]string{"hot", "hot"},
+	} {
+		conf.Reddit.Feeds[0].Subreddits[0].Listings = listings
+		if err = conf.Validate(); err == nil {
+			t.Error("Expected invalid listings to fail validation", listings)
+		}
+	}
+}
+
+func TestRedditFilterRuleValidation(t *testing.T) {
+	var conf *Config
+	var err error
+	if conf, err = parseFromString(`
+reddit:
+    feeds:
+        - name: "funny"
+          description: "Funny pictures"
+          exclude:
+            title_regexes: ["(?i)^\\[meta\\]"]
+          subreddits:
+            - name: "funny"
+`); err != nil {
+		t.Fatal("Could not parse fake config file", err)
+	}
+	conf.populateDefaults()
+	if err = conf.Validate(); err != nil {
+		t.Error("Did not validate", err)
+	}
+
+	for _, rules := range []RedditFilterRules{
+		RedditFilterRules{TitleRegexes: []string{"[unclosed"}},
+		RedditFilterRules{TitleKeywords: []string{""}},
+		RedditFilterRules{Domains: []string{"https://example.com/"}},
+		RedditFilterRules{Authors: []string{"some user"}},
+	} {
+		conf.Reddit.Feeds[0].Subreddits[0].Exclude = rules
+		if err = conf.Validate(); err == nil {
+			t.Error("Expected invalid rules to fail validation", spew.Sdump(rules))
+		}
+	}
+}
+
+func TestRedditSecretsAreOptional(t *testing.T) {
+	var conf *Config
+	var err error
+	if conf, err = parseFromString(`
+reddit:
+    feeds:
+        - name: "funny"
+          description: "Funny pictures"
+          subreddits:
+            - name: "funny"
+`); err != nil {
+		t.Fatal("Could not parse fake config file", err)
+	}
+	conf.populateDefaults()
+	if err = conf.Validate(); err != nil {
+		t.Error("Did not validate", err)
+	}
+	if !conf.Reddit.Secrets.IsEmpty() || conf.Reddit.RequestsPerMinute != defaultRedditAnonymousRequestsPerMinute {
+		t.Error("Expected anonymous harvesting to default to a lower request rate", spew.Sdump(conf.Reddit))
+	}
+
+	conf.Reddit.Secrets.ClientId = "some_client_id"
+	if err = conf.Validate(); err == nil {
+		t.Error("Expected incomplete secrets to fail validation")
+	}
+}
+
+func TestRedditSources(t *testing.T) {
+	var conf *Config
+	var err error
+	if conf, err = parseFromString(`
+reddit:
+    feeds:
+        - name: "golang"
+          description: "Go"
+          subreddits:
+            - name: "GoLang"
+            - multireddit: "SomeOne/Programming"
+            - user: "u/SomeOne"
+              listings: ["new"]
+            - search: " subreddit:golang self:yes "
+`); err != nil {
+		t.Fatal("Could not parse fake config file", err)
+	}
+	conf.populateDefaults()
+	if err = conf.Validate(); err != nil {
+		t.Error("Did not validate", err)
+	}
+	var sourceKeys []string
+	for _, subreddit := range conf.Reddit.Feeds[0].Subreddits {
+		sourceKeys = append(sourceKeys, subreddit.SourceKey())
+	}
+	expected := []string{"golang", "multireddit:someone/programming", "user:someone", "search:subreddit:golang self:yes"}
+	if !reflect.DeepEqual(sourceKeys, expected) {
+		t.Error("Unexpected source keys", spew.Sdump(sourceKeys))
+	}
+	sourceType, value := ParseRedditSourceKey(sourceKeys[3])
+	if sourceType != REDDIT_SOURCE_SEARCH || value != "subreddit:golang self:yes" {
+		t.Error("Could not parse source key", sourceType, value)
+	}
+
+	conf.Reddit.Feeds[0].Subreddits[2].Listings = []string{"rising"}
+	if err = conf.Validate(); err == nil {
+		t.Error("Expected the rising listing of a user to fail validation")
+	}
+	conf.Reddit.Feeds[0].Subreddits[2].Listings = []string{"new"}
+
+	conf.Reddit.Feeds[0].Subreddits[1].Name = "programming"
+	if err = conf.Validate(); err == nil {
+		t.Error("Expected a source with both a name and a multireddit to fail validation")
+	}
+	conf.Reddit.Feeds[0].Subreddits[1].Name = ""
+
+	conf.Reddit.Feeds[0].Subreddits[1].Multireddit = "programming"
+	if err = conf.Validate(); err == nil {
+		t.Error("Expected a multireddit without a user to fail validation")
+	}
+}
+
+func TestRedditSourcesMustBeUniqueWithinAFeed(t *testing.T) {
+	var conf *Config
+	var err error
+	if conf, err = parseFromString(`
+reddit:
+    feeds:
+        - name: "golang"
+          description: "Go"
+          subreddits:
+            - name: "golang"
+            - multireddit: "someone/programming"
+        - name: "programming"
+          description: "Programming"
+          subreddits:
+            - name: "golang"
+            - multireddit: "SomeOne/Programming"
+`); err != nil {
+		t.Fatal("Could not parse fake config file", err)
+	}
+	conf.populateDefaults()
+	if err = conf.Validate(); err != nil {
+		t.Error("Expected a source to be allowed in several feeds", err)
+	}
+
+	conf.Reddit.Feeds[1].Subreddits[0] = Subreddit{Multireddit: "someone/PROGRAMMING"}
+	conf.populateDefaults()
+	if err = conf.Validate(); err == nil || !strings.Contains(err.Error(), "Duplicate source 'multireddit:someone/programming'") {
+		t.Error("Expected a feed that lists the same source twice to fail validation", err)
+	}
+}
+
+func TestFeedNamesMustBeUniqueAcrossSourceTypes(t *testing.T) {
+	var conf *Config
+	var err error
+	if conf, err = parseFromString(`
+reddit:
+    feeds:
+        - name: "news"
+          description: "reddit news"
+          subreddits:
+            - name: "news"
+hackernews:
+    feeds:
+        - name: "hn"
+          description: "Hacker News"
+          lists:
+            - name: "Top"
+            - name: "show"
+              percentile: 30
+`); err != nil {
+		t.Fatal("Could not parse fake config file", err)
+	}
+	conf.populateDefaults()
+	if err = conf.Validate(); err != nil {
+		t.Error("Did not validate", err)
+	}
+	lists := conf.HackerNews.Feeds[0].Lists
+	if lists[0].Name != "top" || lists[0].Percentile != float64(defaultPercentile) || lists[1].Percentile != 30.0 {
+		t.Error("Unexpected defaults for Hacker News lists", spew.Sdump(lists))
+	}
+
+	conf.HackerNews.Feeds[0].Lists[1].Name = "nosuchlist"
+	if err = conf.Validate(); err == nil {
+		t.Error("Expected an unknown list name to fail validation")
+	}
+	conf.HackerNews.Feeds[0].Lists[1].Name = "show"
+
+	conf.HackerNews.Feeds[0].Name = "news"
+	if err = conf.Validate(); err == nil {
+		t.Error("Expected a duplicate feed name to fail validation")
+	}
+}
+
+func TestRssSourceDefaultsAndValidation(t *testing.T) {
+	var conf *Config
+	var err error
+	if conf, err = parseFromString(`
+rss:
+    feeds:
+        - name: "blogs"
+          description: "Some blogs"
+          max_daily_posts: 3
+          sources:
+            - url: "https://example.com/rss"
+            - name: "Example Atom"
+              url: "https://example.org/atom"
+              max_daily_posts: 1
+`); err != nil {
+		t.Fatal("Could not parse fake config file", err)
+	}
+	conf.populateDefaults()
+	if err = conf.Validate(); err != nil {
+		t.Error("Did not validate", err)
+	}
+	feed := conf.Rss.Feeds[0]
+	if feed.Media != MEDIA_TYPE_TEXT {
+		t.Error("Expected media to default to text", spew.Sdump(feed))
+	}
+	if feed.Sources[0].Name != "https://example.com/rss" || feed.Sources[0].MaxDailyPosts != 3 || feed.Sources[1].MaxDailyPosts != 1 {
+		t.Error("Unexpected defaults for RSS sources", spew.Sdump(feed.Sources))
+	}
+
+	conf.Rss.Feeds[0].Sources[1].Url = "https://example.com/rss"
+	if err = conf.Validate(); err == nil {
+		t.Error("Expected a duplicate source URL to fail validation")
+	}
+
+	conf.Rss.Feeds[0].Sources[1].Url = "example.org/atom"
+	if err = conf.Validate(); err == nil {
+		t.Error("Expected a relative source URL to fail validation")
+	}
+}
+
+func TestRetentionDaysDefaultsAndValidation(t *testing.T) {
+	var conf *Config
+	var err error
+	if conf, err = parseFromString(`
+retention_days: 60
+reddit:
+    feeds:
+        - name: "funny"
+          description: "Funny pictures"
+          subreddits:
+            - name: "funny"
+        - name: "archive"
+          description: "Kept forever"
+          retention_days: -1
+          subreddits:
+            - name: "archive"
+`); err != nil {
+		t.Fatal("Could not parse fake config file", err)
+	}
+	conf.populateDefaults()
+	if err = conf.Validate(); err != nil {
+		t.Error("Did not validate", err)
+	}
+	if conf.Reddit.Feeds[0].RetentionDays != 60 {
+		t.Error("Expected the feed to inherit the global retention_days", conf.Reddit.Feeds[0].RetentionDays)
+	}
+	if conf.Reddit.Feeds[1].RetentionDays != 0 {
+		t.Error("Expected -ve retention_days to mean forever (0)", conf.Reddit.Feeds[1].RetentionDays)
+	}
+
+	conf.Reddit.Feeds[0].RetentionDays = minRetentionDays - 1
+	if err = conf.Validate(); err == nil {
+		t.Error("Expected a feed retention period shorter than the feeds' window to fail validation")
+	}
+	conf.Reddit.Feeds[0].RetentionDays = minRetentionDays
+	conf.RetentionDays = 1
+	if err = conf.Validate(); err == nil {
+		t.Error("Expected a global retention period shorter than the feeds' window to fail validation")
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	var conf *Config
+	var err error
+	if conf, err = parseFromString(`
+reddit:
+    secrets:
+        clientid: "someclient"
+        clientsecret: "somesecret"
+        username: "someone"
+        password: "somepassword"
+`); err != nil {
+		t.Fatal("Could not parse fake config file", err)
+	}
+	var redacted = conf.Redacted()
+	if redacted.Reddit.Secrets != (RedditSecrets{ClientId: REDACTED, ClientSecret: REDACTED, Username: REDACTED, Password: REDACTED}) {
+		t.Error("Expected the secrets to be redacted", redacted.Reddit.Secrets)
+	}
+	if redacted.Twitter.Secrets.ClientSecret != "" {
+		t.Error("Expected empty secrets to stay empty", redacted.Twitter.Secrets.ClientSecret)
+	}
+	if conf.Reddit.Secrets.Password != "somepassword" {
+		t.Error("Expected the original config to be unchanged")
+	}
+	// The secrets mustn't leak when the config is logged or dumped.
+	for _, format := range []string{"%v", "%+v", "%#v", "spew"} {
+		var dump string
+		if format == "spew" {
+			dump = spew.Sdump(conf)
+		} else {
+			dump = fmt.Sprintf(format, *conf)
+		}
+		for _, secret := range []string{"someclient", "somesecret", "someone", "somepassword"} {
+			if strings.Contains(dump, secret) {
+				t.Errorf("Expected the secrets to be redacted when formatted with %s", format)
+			}
+		}
+	}
+}
+
+func TestRedactedHidesInterpolatedStrings(t *testing.T) {
+	os.Setenv("CONTENTSCRAPER_TEST_FEED_TOKEN", "sometoken")
+	defer os.Unsetenv("CONTENTSCRAPER_TEST_FEED_TOKEN")
+
+	var conf *Config
+	var err error
+	if conf, err = parseFromString(`
+rss:
+    feeds:
+      - name: "news"
+        sources:
+          - url: "https://example.com/feed?token=${CONTENTSCRAPER_TEST_FEED_TOKEN}"
+          - url: "https://example.com/public"
+`); err != nil {
+		t.Fatal("Could not parse fake config file", err)
+	}
+	if err = conf.interpolate(""); err != nil {
+		t.Fatal("Could not interpolate the config", err)
+	}
+	conf.populateDefaults()
+
+	var redacted = conf.Redacted()
+	var sources = redacted.Rss.Feeds[0].Sources
+	if sources[0].Url != REDACTED || sources[0].Name != REDACTED {
+		t.Error("Expected the interpolated URL, and the name defaulted from it, to be redacted", sources[0])
+	}
+	if sources[1].Url != "https://example.com/public" {
+		t.Error("Expected strings without references to be left alone", sources[1].Url)
+	}
+	if conf.Rss.Feeds[0].Sources[0].Url != "https://example.com/feed?token=sometoken" {
+		t.Error("Expected the original config to be unchanged", conf.Rss.Feeds[0].Sources[0].Url)
+	}
+	for _, format := range []string{"%v", "%+v", "%#v", "spew"} {
+		var dump string
+		if format == "spew" {
+			dump = spew.Sdump(conf)
+		} else {
+			dump = fmt.Sprintf(format, *conf)
+		}
+		if strings.Contains(dump, "sometoken") {
+			t.Errorf("Expected the interpolated URL to be redacted when formatted with %s", format)
+		}
+	}
+}
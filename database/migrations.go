@@ -0,0 +1,181 @@
+package database
+
+// A simple schema migration system. Each component (typically a driver's persistence layer)
+// registers an ordered list of Migrations from an init() function. At startup, Migrate()
+// applies any that haven't been applied to the database yet, each inside a transaction.
+// The migrations applied so far are recorded in the schema_version table.
+
+import (
+	"database/sql"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"time"
+)
+
+// Migration is a single change to a component's tables. Once released, a Migration must
+// never be changed; add a new one instead.
+type Migration struct {
+	Version     int    // Starts at 1, and increases by 1 for each Migration
+	Description string // Human-readable description of the change
+	Sql         string // May contain several statements, separated by ';'
+}
+
+// PendingMigration is a Migration that has not yet been applied to the database.
+type PendingMigration struct {
+	Component string
+	Migration
+}
+
+// Component name -> ordered list of Migrations
+var migrationRegistry = make(map[string][]Migration)
+
+// RegisterMigrations registers the ordered list of migrations for a component. It's expected
+// to be called from an init() function, and panics if the migrations are misnumbered.
+//
+// A component's first migration creates the tables it had before migrations were introduced.
+// Databases created back then already have those tables, so the first migration must remain
+// idempotent (e.g. "CREATE TABLE IF NOT EXISTS").
+func RegisterMigrations(component string, migrations []Migration) {
+	if _, ok := migrationRegistry[component]; ok {
+		panic(fmt.Sprintf("Migrations for '%s' were registered twice", component))
+	}
+	for idx, migration := range migrations {
+		if migration.Version != idx+1 {
+			panic(fmt.Sprintf("Migration #%d for '%s' has version %d, expected %d", idx+1, component, migration.Version, idx+1))
+		}
+	}
+	migrationRegistry[component] = migrations
+}
+
+// Migrate applies the component's pending migrations, in order. Each migration is applied in its
+// own transaction, so if one fails the database is left at the previous version.
+func Migrate(dbconn *sql.DB, component string) (err error) {
+	migrations, ok := migrationRegistry[component]
+	if !ok {
+		return fmt.Errorf("No migrations registered for '%s'", component)
+	}
+	if err = initSchemaVersionTable(dbconn); err != nil {
+		return fmt.Errorf("Could not create schema_version table: %v", err)
+	}
+
+	var currentVersion int
+	if currentVersion, err = getSchemaVersion(dbconn, component); err != nil {
+		return
+	}
+	if currentVersion > len(migrations) {
+		return fmt.Errorf(
+			"Database schema for '%s' is at version %d, but this program only knows about version %d. Is the program out of date?",
+			component,
+			currentVersion,
+			len(migrations),
+		)
+	}
+
+	for _, migration := range migrations[currentVersion:] {
+		log.Infof("Applying database migration for '%s' #%d: %s", component, migration.Version, migration.Description)
+		if err = applyMigration(dbconn, component, migration); err != nil {
+			return fmt.Errorf("Could not apply database migration for '%s' #%d (%s): %v", component, migration.Version, migration.Description, err)
+		}
+	}
+	return nil
+}
+
+// GetPendingMigrations returns the migrations (of every registered component) that have not yet
+// been applied to the database. It does not modify the database.
+func GetPendingMigrations(dbconn *sql.DB) (pending []PendingMigration, err error) {
+	var hasTable int
+	err = dbconn.QueryRow(`
+        SELECT COUNT(*)
+        FROM sqlite_master
+        WHERE type = 'table'
+          AND name = 'schema_version'
+    `).Scan(&hasTable)
+	if err != nil {
+		return
+	}
+
+	var components []string
+	for component, _ := range migrationRegistry {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+
+	for _, component := range components {
+		var currentVersion int
+		if hasTable != 0 {
+			if currentVersion, err = getSchemaVersion(dbconn, component); err != nil {
+				return
+			}
+		}
+		migrations := migrationRegistry[component]
+		if currentVersion >= len(migrations) {
+			continue
+		}
+		for _, migration := range migrations[currentVersion:] {
+			pending = append(pending, PendingMigration{Component: component, Migration: migration})
+		}
+	}
+	return pending, nil
+}
+
+func initSchemaVersionTable(dbconn *sql.DB) (err error) {
+	_, err = dbconn.Exec(`
+        CREATE TABLE IF NOT EXISTS schema_version
+            ( component TEXT NOT NULL
+            , version INTEGER NOT NULL
+            , description TEXT NOT NULL
+            , time_applied INTEGER NOT NULL
+            , PRIMARY KEY (component, version)
+        ) WITHOUT ROWID
+    `)
+	return
+}
+
+// getSchemaVersion returns the version of the last migration applied for the component,
+// or 0 if none have been applied.
+func getSchemaVersion(dbconn *sql.DB, component string) (version int, err error) {
+	err = dbconn.QueryRow(`
+        SELECT COALESCE(MAX(version), 0)
+        FROM schema_version
+        WHERE component = $a
+    `, component).Scan(&version)
+	return
+}
+
+func applyMigration(dbconn *sql.DB, component string, migration Migration) (err error) {
+	var tx *sql.Tx
+	if tx, err = dbconn.Begin(); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.Exec(migration.Sql); err != nil {
+		return
+	}
+	_, err = tx.Exec(`
+        INSERT INTO schema_version
+            ( component
+            , version
+            , description
+            , time_applied
+        ) VALUES
+            ( $a
+            , $b
+            , $c
+            , $d
+        )`,
+		component,
+		migration.Version,
+		migration.Description,
+		time.Now().Unix(),
+	)
+	if err != nil {
+		return
+	}
+	return tx.Commit()
+}
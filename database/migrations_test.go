@@ -0,0 +1,122 @@
+package database
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+var testMigrations = []Migration{
+	Migration{
+		Version:     1,
+		Description: "Create fruit table",
+		Sql: `
+            CREATE TABLE IF NOT EXISTS fruit
+                ( name TEXT PRIMARY KEY
+            )`,
+	},
+	Migration{
+		Version:     2,
+		Description: "Add color to fruit",
+		Sql: `
+            ALTER TABLE fruit ADD COLUMN color TEXT NOT NULL DEFAULT ''
+            ;
+            CREATE INDEX fruit_color ON fruit(color)
+        `,
+	},
+}
+
+func init() {
+	RegisterMigrations("test_fruit", testMigrations)
+	RegisterMigrations("test_broken", []Migration{
+		Migration{
+			Version:     1,
+			Description: "Create vegetable table",
+			Sql:         `CREATE TABLE vegetable (name TEXT)`,
+		},
+		Migration{
+			Version:     2,
+			Description: "Broken migration",
+			Sql: `
+                CREATE TABLE mineral (name TEXT)
+                ;
+                THIS IS NOT SQL
+            `,
+		},
+	})
+}
+
+func TestExistingDatabaseIsUpgradedInPlace(t *testing.T) {
+	testDb := InitTestDb(t)
+	defer testDb.Cleanup()
+
+	// Simulate a database created before migrations existed.
+	_, err := testDb.DbConn.Exec(testMigrations[0].Sql)
+	require.Nil(t, err, "Could not create table")
+	_, err = testDb.DbConn.Exec(`INSERT INTO fruit (name) VALUES ('apple')`)
+	require.Nil(t, err, "Could not insert row")
+
+	pending, err := GetPendingMigrations(testDb.DbConn)
+	require.Nil(t, err, "Could not get pending migrations")
+	var fruitPending []int
+	for _, migration := range pending {
+		if migration.Component == "test_fruit" {
+			fruitPending = append(fruitPending, migration.Version)
+		}
+	}
+	require.Equal(t, []int{1, 2}, fruitPending)
+
+	err = Migrate(testDb.DbConn, "test_fruit")
+	require.Nil(t, err, "Could not migrate")
+
+	var color string
+	err = testDb.DbConn.QueryRow(`SELECT color FROM fruit WHERE name = 'apple'`).Scan(&color)
+	require.Nil(t, err, "Existing data was lost")
+	require.Equal(t, "", color)
+
+	version, err := getSchemaVersion(testDb.DbConn, "test_fruit")
+	require.Nil(t, err, "Could not get schema version")
+	require.Equal(t, 2, version)
+
+	// Migrating again is a no-op
+	err = Migrate(testDb.DbConn, "test_fruit")
+	require.Nil(t, err, "Could not re-migrate")
+
+	pending, err = GetPendingMigrations(testDb.DbConn)
+	require.Nil(t, err, "Could not get pending migrations")
+	for _, migration := range pending {
+		require.NotEqual(t, "test_fruit", migration.Component)
+	}
+}
+
+func TestFailedMigrationIsRolledBack(t *testing.T) {
+	testDb := InitTestDb(t)
+	defer testDb.Cleanup()
+
+	err := Migrate(testDb.DbConn, "test_broken")
+	require.NotNil(t, err, "Expected the broken migration to fail")
+
+	version, err := getSchemaVersion(testDb.DbConn, "test_broken")
+	require.Nil(t, err, "Could not get schema version")
+	require.Equal(t, 1, version, "The 1st migration should have been applied")
+
+	var cnt int
+	err = testDb.DbConn.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'mineral'`).Scan(&cnt)
+	require.Nil(t, err, "Could not query sqlite_master")
+	require.Equal(t, 0, cnt, "The broken migration should have been rolled back")
+}
+
+func TestNewerDatabaseIsRejected(t *testing.T) {
+	testDb := InitTestDb(t)
+	defer testDb.Cleanup()
+
+	err := Migrate(testDb.DbConn, "test_fruit")
+	require.Nil(t, err, "Could not migrate")
+	_, err = testDb.DbConn.Exec(`
+        INSERT INTO schema_version (component, version, description, time_applied)
+        VALUES ('test_fruit', 3, 'From the future', 0)
+    `)
+	require.Nil(t, err, "Could not insert schema version")
+
+	err = Migrate(testDb.DbConn, "test_fruit")
+	require.NotNil(t, err, "Expected a database from a newer program to be rejected")
+}
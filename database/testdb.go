@@ -22,6 +22,9 @@ func NewTestDatabase() (*TestDatabase, error) {
 	if err != nil {
 		return nil, fmt.Errorf("Could not get new database connection: %v", err)
 	}
+	// Each connection to ":memory:" gets its own, empty, database. So the pool must be limited
+	// to a single connection, otherwise e.g. a transaction may not see the tables.
+	dbconn.SetMaxOpenConns(1)
 
 	return &TestDatabase{
 		DbConn: dbconn,
@@ -0,0 +1,43 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+)
+
+// The value of "PRAGMA auto_vacuum" when the database is in incremental mode.
+const AUTO_VACUUM_INCREMENTAL = 2
+
+// IncrementalVacuum returns the pages freed by deleted rows to the filesystem, so that pruning
+// old rows shrinks the database file. Databases are created without auto-vacuuming, so the first
+// call switches the database to incremental mode, which requires a (one-off) full VACUUM.
+func IncrementalVacuum(dbconn *sql.DB) (err error) {
+	// The auto_vacuum setting only applies to the connection it's set on, so the same connection
+	// must be used throughout.
+	var ctx = context.Background()
+	var conn *sql.Conn
+	if conn, err = dbconn.Conn(ctx); err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var mode int
+	if err = conn.QueryRowContext(ctx, "PRAGMA auto_vacuum").Scan(&mode); err != nil {
+		return fmt.Errorf("Could not read auto_vacuum mode: %v", err)
+	}
+	if mode != AUTO_VACUUM_INCREMENTAL {
+		log.Info("Switching the database to incremental vacuuming. The full VACUUM this requires may take a while.")
+		if _, err = conn.ExecContext(ctx, "PRAGMA auto_vacuum = INCREMENTAL"); err != nil {
+			return fmt.Errorf("Could not set auto_vacuum mode: %v", err)
+		}
+		if _, err = conn.ExecContext(ctx, "VACUUM"); err != nil {
+			return fmt.Errorf("Could not VACUUM database: %v", err)
+		}
+	}
+	if _, err = conn.ExecContext(ctx, "PRAGMA incremental_vacuum"); err != nil {
+		return fmt.Errorf("Could not vacuum database: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package database
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestIncrementalVacuumFreesPages(t *testing.T) {
+	testdb := InitTestDb(t)
+	defer testdb.Cleanup()
+
+	createTestTable(t, testdb.DbConn)
+	for i := 0; i < 1000; i++ {
+		_, err := testdb.DbConn.Exec(`INSERT INTO x (a, b, c) VALUES ($a, $b, $c)`, i, fmt.Sprintf("Row %d of some padding text", i), 1.5)
+		require.Nil(t, err, "Could not insert row")
+	}
+	require.Nil(t, IncrementalVacuum(testdb.DbConn), "Could not vacuum the database")
+
+	var mode int
+	require.Nil(t, testdb.DbConn.QueryRow("PRAGMA auto_vacuum").Scan(&mode))
+	require.Equal(t, AUTO_VACUUM_INCREMENTAL, mode)
+
+	_, err := testdb.DbConn.Exec(`DELETE FROM x`)
+	require.Nil(t, err, "Could not delete rows")
+	var freePages int
+	require.Nil(t, testdb.DbConn.QueryRow("PRAGMA freelist_count").Scan(&freePages))
+	require.NotEqual(t, 0, freePages, "Deleting rows should leave free pages")
+
+	require.Nil(t, IncrementalVacuum(testdb.DbConn), "Could not vacuum the database")
+	require.Nil(t, testdb.DbConn.QueryRow("PRAGMA freelist_count").Scan(&freePages))
+	require.Equal(t, 0, freePages)
+}
@@ -0,0 +1,34 @@
+package feedserver
+
+import (
+	"github.com/coverprice/contentscraper/drivers"
+)
+
+// The ApiRequestHandler retrieves a feed's posts for the JSON API. They are the same
+// posts as the HtmlViewerRequestHandler shows, in the same order.
+type ApiRequestHandler struct {
+	*PostRetriever
+}
+
+func NewApiRequestHandler(postRetriever *PostRetriever) *ApiRequestHandler {
+	return &ApiRequestHandler{
+		PostRetriever: postRetriever,
+	}
+}
+
+// GetApiPosts returns the named feed's posts in the driver's JSON API representation.
+func (this *ApiRequestHandler) GetApiPosts(feedName string) (apiPosts []drivers.IApiPost, err error) {
+	var feed *Feed
+	if feed, err = this.adapter.GetFeed(feedName); err != nil {
+		return
+	}
+	var posts []Post
+	if posts, err = this.GetPosts(feed); err != nil {
+		return
+	}
+	apiPosts = make([]drivers.IApiPost, 0, len(posts))
+	for _, post := range posts {
+		apiPosts = append(apiPosts, this.adapter.GetApiPost(post))
+	}
+	return apiPosts, nil
+}
@@ -0,0 +1,88 @@
+package feedserver
+
+import (
+	"github.com/coverprice/contentscraper/config"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestFilterAndSortPosts(t *testing.T) {
+	const oneDay = 24 * 60 * 60
+	now := int64(1508230800)
+	// fakePost returns a post from the source that's the sourceIdx'th in the feed config,
+	// which is displayed in order of its age, then its id.
+	fakePost := func(id string, source string, sourceIdx int, ageInDays, rank int64) Post {
+		return Post{
+			Id:         id,
+			Source:     source,
+			SourceIdx:  sourceIdx,
+			Rank:       rank,
+			TimeSorted: now - ageInDays*oneDay,
+			TimeAged:   now - ageInDays*oneDay,
+		}
+	}
+	withDuplicateKey := func(post Post, key string) Post {
+		post.DuplicateKey = key
+		return post
+	}
+	withUrl := func(post Post, url string) Post {
+		post.Url = url
+		return post
+	}
+
+	var tests = []struct {
+		name        string
+		feed        Feed
+		posts       []Post
+		expectedIds []string
+	}{
+		{
+			name: "each source's highest ranked max_daily_posts are shown per day",
+			feed: Feed{MaxDailyPosts: map[string]int{"alice/original": 2, "alice/retweets": 1}},
+			posts: []Post{
+				fakePost("a", "alice/original", 0, 0, 10),
+				fakePost("b", "alice/original", 0, 0, 30),
+				fakePost("c", "alice/original", 0, 0, 20),
+				fakePost("d", "alice/original", 0, 1, 20),
+				fakePost("e", "alice/retweets", 1, 0, 50),
+				fakePost("f", "alice/retweets", 1, 0, 40),
+				// bob has no max_daily_posts, so none of his posts should be shown.
+				fakePost("g", "bob/original", 2, 0, 10),
+			},
+			expectedIds: []string{"b", "c", "e", "d"},
+		},
+		{
+			name: "duplicates are only shown in the first of the feed's sources",
+			feed: Feed{MaxDailyPosts: map[string]int{"best": 100, "top": 100}},
+			posts: []Post{
+				withDuplicateKey(fakePost("1-top", "top", 1, 1, 1), "1"),
+				withDuplicateKey(fakePost("2-top", "top", 1, 2, 2), "2"),
+				withDuplicateKey(fakePost("2-best", "best", 0, 2, 2), "2"),
+				withDuplicateKey(fakePost("3-best", "best", 0, 3, 3), "3"),
+				withDuplicateKey(fakePost("3-top", "top", 1, 3, 3), "3"),
+				// Posts without a key are never duplicates.
+				fakePost("4-top", "top", 1, 4, 4),
+				fakePost("4-best", "best", 0, 4, 4),
+			},
+			expectedIds: []string{"1-top", "2-best", "3-best", "4-best", "4-top"},
+		},
+		{
+			name: "image feeds only show posts with media",
+			feed: Feed{Media: config.MEDIA_TYPE_IMAGE, MaxDailyPosts: map[string]int{"a": 100}},
+			posts: []Post{
+				withUrl(fakePost("image", "a", 0, 0, 1), "https://example.com/cat.jpg"),
+				withUrl(fakePost("article", "a", 0, 0, 1), "https://example.com/article.html"),
+				fakePost("text", "a", 0, 0, 1),
+			},
+			expectedIds: []string{"image"},
+		},
+	}
+	for _, test := range tests {
+		results := filterAndSortPosts(now, &test.feed, test.posts)
+		var ids []string
+		for _, post := range results {
+			ids = append(ids, post.Id)
+		}
+		require.Equal(t, test.expectedIds, ids, test.name)
+	}
+}
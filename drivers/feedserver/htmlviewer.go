@@ -0,0 +1,202 @@
+package feedserver
+
+import (
+	"fmt"
+	"github.com/coverprice/contentscraper/server/htmlutil"
+	"net/http"
+)
+
+const (
+	NUM_ITEMS_PER_PAGE = 10
+)
+
+// Verify that HtmlViewerRequestHandler implements IRequestHandler interface
+var _ IRequestHandler = &HtmlViewerRequestHandler{}
+
+// The HtmlViewerRequestHandler handles a single request for a page within a specific
+// feed. It requests the posts from the PostRetriever, and converts them to an HTML response.
+type HtmlViewerRequestHandler struct {
+	*PostRetriever
+}
+
+func NewHtmlViewerRequestHandler(postRetriever *PostRetriever) *HtmlViewerRequestHandler {
+	return &HtmlViewerRequestHandler{
+		PostRetriever: postRetriever,
+	}
+}
+
+var htmlTemplateStr = `
+    {{define "title"}}{{.SourceName}} Feed - {{.Title}}{{end}}
+    {{define "js"}}
+    <script src="/static/imagesloaded.pkgd.min.js"></script>
+    <script>
+    let globals = {
+        numPages: {{.NumPages}},
+        currentPageNum: {{.PageNum}},
+        previousPageLink: '{{.PreviousPagelink.Link}}',
+        nextPageLink: '{{.NextPagelink.Link}}',
+    };
+    </script>
+    <script src="/static/viewer.js"></script>
+    {{end}}
+    {{define "pagination"}}
+    <nav>
+        <ul class="pagination">
+        {{range .Pagelinks}}
+            <li class="page-item{{if not .IsEnabled}} disabled{{end}} {{if .IsHighlighted}} active{{end}}">
+                <a class="page-link" href="{{.Link}}" {{if not .IsEnabled}} tabindex="-1"{{end}}>{{.Text}}</a>
+            </li>
+        {{end}}
+        </ul>
+    </nav>
+    {{end}}
+    {{define "content"}}
+    <h4>
+        {{.SourceName}} Feed: {{.Title}}
+        <small class="text-muted">{{.Description}}</small>
+    </h4>
+
+    {{template "pagination" .}}
+
+    <div class="container-fluid">
+        {{range $itemIndex, $post := .Posts}}
+        <div class="row feeditem">
+            <div class="col">
+                <div class="container-fluid">
+                    <div class="row">
+                        <div class="col alert alert-info">
+                            {{if .Link}}<a href="{{.Link}}">{{.Title}}</a>{{else}}{{.Title}}{{end}}
+                            {{if .HasScore}}<small>Score: {{.Score}}</small>{{end}}
+                            {{if .CommentsLink}}<small><a href="{{.CommentsLink}}">{{.NumComments}} comments</a></small>{{end}}
+                            <small>Days old: {{.AgeInDays}}</small>
+                            <small class="text-muted">{{.Byline}}</small>
+                        </div>
+                    </div>
+                    {{if .MediaLink}}
+                    <div class="row">
+                        <div class="col">
+                            <a href="{{.Url}}">
+                                {{if not (eq .MediaLink.Embed "")}}
+                                    {{.MediaLink.Embed}}
+                                {{else if hasSuffix .MediaLink.Url ".mp4"}}
+                                    <video playsinline autoplay loop controls class="videocontainer">
+                                        <source src="{{.MediaLink.Url}}" type="video/mp4" />
+                                    </video>
+                                {{else if not (eq .MediaLink.Url "")}}
+                                    <img src="{{.MediaLink.Url}}">
+                                {{end}}
+                            </a>
+                        </div>
+                    </div>
+                    {{end}}
+                </div>
+            </div>
+        </div>
+        {{end}}
+    </div>
+
+    {{template "pagination" .}}
+
+    {{end}}
+`
+var htmlTempl = htmlutil.ParseTemplate(htmlTemplateStr)
+
+type pagelink struct {
+	Text          string
+	Link          string
+	IsEnabled     bool
+	IsHighlighted bool
+}
+
+func (this *HtmlViewerRequestHandler) HandleFeed(
+	feed *Feed,
+	pageNum int,
+	w http.ResponseWriter,
+	_ *http.Request,
+) {
+	if pageNum == 0 {
+		pageNum = 1
+	}
+
+	posts, err := this.GetPosts(feed)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal error retrieving posts for feed: %s %v", feed.Name, err), 500)
+		return
+	}
+
+	itemsPerPage := NUM_ITEMS_PER_PAGE
+	numPages := (len(posts) + NUM_ITEMS_PER_PAGE - 1) / NUM_ITEMS_PER_PAGE
+	startIdx := itemsPerPage * (pageNum - 1)
+	endIdx := startIdx + itemsPerPage
+
+	if startIdx >= len(posts) {
+		// Out of bounds.
+		posts = []Post{}
+	} else {
+		if endIdx > len(posts) {
+			endIdx = len(posts)
+		}
+		posts = posts[startIdx:endIdx]
+	}
+
+	pagelinks := this.getPagelinks(feed.Name, pageNum, numPages)
+	data := struct {
+		SourceName  string
+		Title       string
+		Description string
+		htmlutil.Breadcrumbs
+		Posts            []Post
+		Pagelinks        []pagelink
+		PreviousPagelink pagelink
+		NextPagelink     pagelink
+		NumPages         int
+		PageNum          int
+	}{
+		SourceName:  this.adapter.GetName(),
+		Title:       feed.Name,
+		Description: feed.Description,
+		Breadcrumbs: []htmlutil.Breadcrumb{
+			htmlutil.NewBreadcrumb("Home", "/"),
+			htmlutil.NewBreadcrumb(feed.Name, "/"),
+		},
+		Posts:            posts,
+		Pagelinks:        pagelinks,
+		PreviousPagelink: pagelinks[0], // Clunky, but necessary since arithmetic isn't possible in templates.
+		NextPagelink:     pagelinks[len(pagelinks)-1],
+		NumPages:         numPages,
+		PageNum:          pageNum,
+	}
+	htmlutil.RenderTemplate(w, htmlTempl, data)
+}
+
+func (this *HtmlViewerRequestHandler) getPagelinks(feedname string, pageNum, numPages int) (links []pagelink) {
+	var baseUrlPath = this.adapter.GetBaseUrlPath()
+	link := pagelink{
+		Text:          "Previous",
+		Link:          constructUrl(baseUrlPath, &feedname, pageNum-1),
+		IsEnabled:     true,
+		IsHighlighted: false,
+	}
+	if pageNum == 1 {
+		link.IsEnabled = false
+	}
+	links = append(links, link)
+
+	for pn := 1; pn <= numPages; pn++ {
+		link = pagelink{
+			Text:          fmt.Sprintf("%d", pn),
+			Link:          constructUrl(baseUrlPath, &feedname, pn),
+			IsEnabled:     true,
+			IsHighlighted: (pageNum == pn),
+		}
+		links = append(links, link)
+	}
+	link = pagelink{
+		Text:          "Next",
+		Link:          constructUrl(baseUrlPath, &feedname, pageNum+1),
+		IsEnabled:     true,
+		IsHighlighted: false,
+	}
+	links = append(links, link)
+	return
+}
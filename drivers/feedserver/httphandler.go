@@ -0,0 +1,73 @@
+package feedserver
+
+import (
+	"fmt"
+	"github.com/coverprice/contentscraper/server/syndication"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Verify that HttpHandler implements http.Handler interface
+var _ http.Handler = &HttpHandler{}
+
+// HttpHandler is attached to the standard "http" server, bound to the driver's base URL.
+// It knows the base URL and understands HTTP. It parses the URL and delegates
+// the response to the IRequestHandler for the requested format, e.g. the
+// HtmlViewerRequestHandler.
+type HttpHandler struct {
+	adapter         IAdapter
+	requestHandlers map[string]IRequestHandler // Format (e.g. syndication.FORMAT_HTML) -> handler
+}
+
+// NewHttpHandler returns a handler that serves the retriever's feeds as HTML pages, and as RSS
+// and Atom documents.
+func NewHttpHandler(postRetriever *PostRetriever) *HttpHandler {
+	handler := HttpHandler{
+		adapter: postRetriever.adapter,
+		requestHandlers: map[string]IRequestHandler{
+			syndication.FORMAT_HTML: NewHtmlViewerRequestHandler(postRetriever),
+			syndication.FORMAT_RSS:  NewSyndicationRequestHandler(postRetriever, syndication.FORMAT_RSS),
+			syndication.FORMAT_ATOM: NewSyndicationRequestHandler(postRetriever, syndication.FORMAT_ATOM),
+		},
+	}
+	return &handler
+}
+
+func (this HttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	values, err := url.ParseQuery(r.URL.RawQuery)
+	if err != nil {
+		http.Error(w, "Invalid request. Cannot parse URL query", 500)
+		return
+	}
+	// The base URL has been stripped from the path, so all that's left is the (optional) format.
+	format, err := syndication.GetRequestedFormat(r, r.URL.Path)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request. %v", err), 400)
+		return
+	}
+	requestHandler, ok := this.requestHandlers[format]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	feedname := values.Get("feed")
+	feed, err := this.adapter.GetFeed(feedname)
+	if err != nil {
+		log.Errorf("Unknown %s feed name: '%s'", this.adapter.GetName(), feedname)
+		http.NotFound(w, r)
+		return
+	}
+
+	var pagenum int
+	pagenumStr := values.Get("page")
+	if pagenumStr != "" {
+		pagenum, err = strconv.Atoi(pagenumStr)
+	}
+	if pagenum < 0 || pagenum > 5000 {
+		http.Error(w, "Invalid request. Cannot parse page number", 500)
+		return
+	}
+	requestHandler.HandleFeed(feed, pagenum, w, r)
+}
@@ -0,0 +1,203 @@
+package feedserver
+
+import (
+	"github.com/coverprice/contentscraper/config"
+	"github.com/coverprice/contentscraper/server/medialink"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"sync"
+	"time"
+)
+
+// PostRetriever retrieves a feed's posts, filtered and sorted into display order. It's shared
+// by the IRequestHandlers so that every presentation format shows the same posts.
+type PostRetriever struct {
+	adapter IAdapter
+	// cacheLock is held while a feed's posts are retrieved, so that concurrent requests don't
+	// retrieve them at the same time, or read the cache while it's written.
+	cacheLock sync.Mutex
+	cache     map[string]cachedPosts // Feed name -> posts
+}
+
+type cachedPosts struct {
+	Posts       []Post
+	TimeCreated int64
+}
+
+func NewPostRetriever(adapter IAdapter) *PostRetriever {
+	return &PostRetriever{
+		adapter: adapter,
+		cache:   make(map[string]cachedPosts),
+	}
+}
+
+// GetPosts retrieves all the posts for the given feed, and sorts them in display order.
+// (The result may be large, and is cached).
+func (this *PostRetriever) GetPosts(feed *Feed) (posts []Post, err error) {
+	now := int64(time.Now().Unix())
+
+	this.cacheLock.Lock()
+	defer this.cacheLock.Unlock()
+	cache, ok := this.cache[feed.Name]
+	if !ok || (cache.TimeCreated+1*60*60 < now) {
+		if posts, err = this.getPostsImpl(now, feed); err != nil {
+			return
+		}
+		cache = cachedPosts{
+			Posts:       posts,
+			TimeCreated: now,
+		}
+		this.cache[feed.Name] = cache
+	}
+	return cache.Posts, nil
+}
+
+func (this *PostRetriever) getPostsImpl(now int64, feed *Feed) (posts []Post, err error) {
+	minTime := int64(now - 7*24*60*60)
+	if posts, err = this.adapter.GetPosts(feed, minTime); err != nil {
+		return
+	}
+	return filterAndSortPosts(now, feed, posts), nil
+}
+
+// filterAndSortPosts applies the feed's criteria to the posts, and sorts them into display order.
+func filterAndSortPosts(now int64, feed *Feed, posts []Post) []Post {
+	// A post may come from several sources. Only keep it in the first of the feed's sources.
+	posts = dedupePosts(posts)
+
+	// Decorate the posts with the age in days.
+	decoratePostAge(getTimeBoundary(now), posts)
+
+	// Convert image links into embedded links
+	decoratePostsWithMediaLinks(posts)
+
+	// Filter out posts that are not images (if required)
+	if feed.Media == config.MEDIA_TYPE_IMAGE {
+		posts = filterOutEmptyImages(posts)
+	}
+
+	// Filter out posts that exceed the max_daily_posts criteria
+	posts = filterByMaxDailyPosts(posts, feed)
+
+	// Sort into display order
+	sort.Sort(byTimeSortedId(posts))
+	return posts
+}
+
+func getTimeBoundary(now int64) int64 {
+	// Determine when the next "3am" from now is.
+	t := time.Unix(now, 0)
+	day := t.Day()
+	if t.Hour() >= 3 {
+		day++
+	}
+	// The unix time considered to be the start of "0 days old".
+	return time.Date(t.Year(), t.Month(), day, 3, 0, 0, 0, t.Location()).Unix()
+}
+
+func decoratePostAge(timeBoundary int64, posts []Post) {
+	const oneDay = 24 * 60 * 60
+	for i, _ := range posts {
+		delta := (timeBoundary - posts[i].TimeAged)
+		if delta < 0 {
+			delta -= oneDay
+		}
+		posts[i].AgeInDays = delta / oneDay
+	}
+}
+
+func decoratePostsWithMediaLinks(posts []Post) {
+	var err error
+	for i, _ := range posts {
+		if posts[i].Url != "" {
+			if posts[i].MediaLink, err = medialink.UrlToMediaLink(posts[i].Url); err != nil {
+				log.Error("Error trying to convert post URL to MediaLink", err)
+			}
+		}
+	}
+}
+
+// dedupePosts removes posts whose DuplicateKey appears more than once (e.g. because they are in
+// several sources). The remaining copy is the one from the source that appears first in the feed
+// config.
+func dedupePosts(posts []Post) (results []Post) {
+	var postIdxByKey = make(map[string]int) // DuplicateKey -> index within results
+	for _, post := range posts {
+		if post.DuplicateKey == "" {
+			results = append(results, post)
+			continue
+		}
+		idx, ok := postIdxByKey[post.DuplicateKey]
+		if !ok {
+			postIdxByKey[post.DuplicateKey] = len(results)
+			results = append(results, post)
+		} else if post.SourceIdx < results[idx].SourceIdx {
+			results[idx] = post
+		}
+	}
+	return
+}
+
+func filterOutEmptyImages(posts []Post) (results []Post) {
+	for _, post := range posts {
+		if post.MediaLink != nil {
+			results = append(results, post)
+		}
+	}
+	return
+}
+
+type bySourceAgeRank []Post
+
+func (a bySourceAgeRank) Len() int      { return len(a) }
+func (a bySourceAgeRank) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a bySourceAgeRank) Less(i, j int) bool {
+	if a[i].Source == a[j].Source {
+		if a[i].AgeInDays == a[j].AgeInDays {
+			// Rank DESC
+			return a[i].Rank > a[j].Rank
+		} else {
+			// AgeInDays ASC
+			return a[i].AgeInDays < a[j].AgeInDays
+		}
+	} else {
+		// Source ASC
+		return a[i].Source < a[j].Source
+	}
+}
+
+type byTimeSortedId []Post
+
+func (a byTimeSortedId) Len() int      { return len(a) }
+func (a byTimeSortedId) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byTimeSortedId) Less(i, j int) bool {
+	if a[i].TimeSorted == a[j].TimeSorted {
+		// Id ASC
+		return a[i].Id < a[j].Id
+	} else {
+		// TimeSorted DESC
+		return a[i].TimeSorted > a[j].TimeSorted
+	}
+}
+
+// filterByMaxDailyPosts keeps (at most) the highest ranked MaxDailyPosts posts per source, per day.
+func filterByMaxDailyPosts(posts []Post, feed *Feed) (results []Post) {
+	// Sort posts by Source, AgeInDays, Rank(DESC)
+	sort.Sort(bySourceAgeRank(posts))
+
+	dailyPostCnt := 0
+	currentSource := ""
+	currentAgeInDays := int64(-1)
+	for _, post := range posts {
+		if currentSource != post.Source || currentAgeInDays != post.AgeInDays {
+			currentSource = post.Source
+			currentAgeInDays = post.AgeInDays
+			dailyPostCnt = 0
+		}
+		dailyPostCnt++
+		if dailyPostCnt <= feed.MaxDailyPosts[currentSource] {
+			results = append(results, post)
+		}
+	}
+	return
+}
@@ -0,0 +1,85 @@
+package feedserver
+
+import (
+	"fmt"
+	"github.com/coverprice/contentscraper/server/syndication"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Verify that SyndicationRequestHandler implements IRequestHandler interface
+var _ IRequestHandler = &SyndicationRequestHandler{}
+
+// The SyndicationRequestHandler handles a request for a feed as an RSS or Atom document.
+// It contains the same posts as the HtmlViewerRequestHandler, in the same order.
+type SyndicationRequestHandler struct {
+	*PostRetriever
+	format string // syndication.FORMAT_RSS or syndication.FORMAT_ATOM
+}
+
+func NewSyndicationRequestHandler(postRetriever *PostRetriever, format string) *SyndicationRequestHandler {
+	return &SyndicationRequestHandler{
+		PostRetriever: postRetriever,
+		format:        format,
+	}
+}
+
+// HandleFeed renders the most recent posts in the feed. Feed readers don't page, so pageNum is ignored.
+func (this *SyndicationRequestHandler) HandleFeed(
+	feed *Feed,
+	pageNum int,
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	posts, err := this.GetPosts(feed)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal error retrieving posts for feed: %s %v", feed.Name, err), 500)
+		return
+	}
+	if len(posts) > syndication.MAX_ENTRIES {
+		posts = posts[:syndication.MAX_ENTRIES]
+	}
+
+	var baseUrlPath = this.adapter.GetBaseUrlPath()
+	doc := syndication.Feed{
+		Title:       feed.Name,
+		Description: feed.Description,
+		Link:        syndication.AbsoluteUrl(r, constructUrl(baseUrlPath, &feed.Name, 0)),
+		SelfLink:    syndication.AbsoluteUrl(r, constructSyndicationUrl(baseUrlPath, feed.Name, this.format)),
+		Updated:     time.Now(),
+	}
+	if len(posts) > 0 {
+		doc.Updated = time.Unix(posts[0].TimeSorted, 0)
+	}
+	for _, post := range posts {
+		doc.Entries = append(doc.Entries, syndication.Entry{
+			Id:        post.Id,
+			Title:     post.Title,
+			Link:      post.Link,
+			Author:    post.Author,
+			Published: time.Unix(post.TimeCreated, 0),
+			Content:   getEntryContent(post),
+		})
+	}
+	doc.Write(w, this.format)
+}
+
+// getEntryContent returns the HTML content of the post's entry: its summary, its media (or a link
+// to its content, if the title links elsewhere), and a link to its comments.
+func getEntryContent(post Post) string {
+	var parts []string
+	if post.Summary != "" {
+		parts = append(parts, html.EscapeString(post.Summary))
+	}
+	if media := syndication.MediaLinkToHtml(post.MediaLink, post.Url); media != "" {
+		parts = append(parts, media)
+	} else if post.Url != "" && post.Url != post.Link {
+		parts = append(parts, fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(post.Url), html.EscapeString(post.Url)))
+	}
+	if post.CommentsLink != "" {
+		parts = append(parts, fmt.Sprintf(`<a href="%s">%d comments</a>`, html.EscapeString(post.CommentsLink), post.NumComments))
+	}
+	return strings.Join(parts, "<br>")
+}
@@ -0,0 +1,80 @@
+package feedserver
+
+// The feedserver serves the feeds of the drivers whose posts are filtered the same way: each
+// feed has several sources (e.g. Twitter accounts, or Hacker News lists), and shows the highest
+// ranked posts from each source, up to the source's max_daily_posts per day. A driver supplies
+// an IAdapter, which retrieves its posts as Posts, and the feedserver does the rest.
+//
+// The server is split into the same layers as the Reddit driver's server:
+// - HttpHandler: understands the HTTP protocol. Parses the URL path to get the
+//   request parameters, and delegates handling the request to a IRequestHandler.
+// - IRequestHandler: understands how to construct a response for a given
+//   presentation protocol, and delegates the information retrieval to the PostRetriever.
+
+import (
+	"github.com/coverprice/contentscraper/drivers"
+	"github.com/coverprice/contentscraper/server/medialink"
+	"net/http"
+)
+
+// IAdapter converts a driver's feeds and posts into those that the feedserver understands.
+type IAdapter interface {
+	// GetName returns the name of the driver's content source, e.g. "Twitter", for page titles.
+	GetName() string
+	// GetBaseUrlPath returns the URL path that the driver's feeds are served under, e.g. "/twitter/".
+	GetBaseUrlPath() string
+	// GetFeed returns the named feed, or an error if the driver has no such feed.
+	GetFeed(feedName string) (*Feed, error)
+	// GetPosts retrieves the feed's posts since minTime, which the driver may have filtered
+	// already (e.g. by percentile). They needn't be sorted.
+	GetPosts(feed *Feed, minTime int64) ([]Post, error)
+	// GetApiPost converts a post into the driver's JSON API representation.
+	GetApiPost(post Post) drivers.IApiPost
+}
+
+type IRequestHandler interface {
+	HandleFeed(
+		feed *Feed,
+		pagenum int,
+		w http.ResponseWriter,
+		r *http.Request,
+	)
+}
+
+// Feed is a driver's feed, as far as the feedserver is concerned.
+type Feed struct {
+	Name          string
+	Description   string
+	Media         string         // config.MEDIA_TYPE_IMAGE if only posts with media should be shown.
+	MaxDailyPosts map[string]int // Post.Source -> the maximum # of the source's posts to show per day
+	Config        interface{}    // The driver's own feed config, e.g. *config.TwitterFeed
+}
+
+// Post is a driver's post (e.g. a tweet), as far as the feedserver is concerned.
+type Post struct {
+	Item interface{} // The driver's own post, e.g. a types.Tweet
+
+	// Filtering & sorting
+	Id           string // A URI that uniquely identifies the post, e.g. its permalink. Posts with the same TimeSorted are sorted by it.
+	DuplicateKey string // Of the posts with the same (non-empty) key, only the one with the lowest SourceIdx is shown.
+	Source       string // The feed's source that the post came from. (See Feed.MaxDailyPosts)
+	SourceIdx    int    // The position of the source in the feed's config
+	Rank         int64  // Each source's highest ranked posts are shown, e.g. its score
+	TimeSorted   int64  // Posts are shown most recent TimeSorted first
+	TimeAged     int64  // AgeInDays is counted from this time
+
+	// Presentation
+	Title        string
+	Link         string // Where the title links to. (May be empty)
+	Url          string // The post's content, which may be an image or video. (May be empty)
+	Summary      string // Plain text shown before the content in RSS/Atom feeds. (May be empty)
+	Author       string // The author in RSS/Atom feeds
+	Byline       string // Shown beside the title, e.g. "by someone"
+	HasScore     bool
+	Score        int64
+	CommentsLink string // Link to the post's comments. (May be empty)
+	NumComments  int64
+	TimeCreated  int64
+	AgeInDays    int64 // how many days old this post is.
+	MediaLink    *medialink.MediaLink
+}
@@ -0,0 +1,33 @@
+package feedserver
+
+import (
+	"fmt"
+	"net/url"
+)
+
+func constructUrl(baseUrlPath string, feedname *string, pagenum int) string {
+	v := url.Values{}
+	if feedname != nil {
+		v.Set("feed", *feedname)
+	}
+	if pagenum != 0 {
+		v.Add("page", fmt.Sprintf("%d", pagenum))
+	}
+	u := url.URL{
+		Path:     baseUrlPath,
+		RawQuery: v.Encode(),
+	}
+	return u.String()
+}
+
+// constructSyndicationUrl returns the URL of the feed rendered in the given
+// format, e.g. syndication.FORMAT_ATOM.
+func constructSyndicationUrl(baseUrlPath string, feedname string, format string) string {
+	v := url.Values{}
+	v.Set("feed", feedname)
+	u := url.URL{
+		Path:     baseUrlPath + format,
+		RawQuery: v.Encode(),
+	}
+	return u.String()
+}
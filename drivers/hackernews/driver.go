@@ -0,0 +1,100 @@
+package hackernews
+
+// Implements the IDriver interface for the Hacker News content source type
+
+import (
+	"context"
+	"database/sql"
+	"github.com/coverprice/contentscraper/config"
+	"github.com/coverprice/contentscraper/drivers"
+	"github.com/coverprice/contentscraper/drivers/feedserver"
+	harvest "github.com/coverprice/contentscraper/drivers/hackernews/harvester"
+	persist "github.com/coverprice/contentscraper/drivers/hackernews/persistence"
+	scrape "github.com/coverprice/contentscraper/drivers/hackernews/scraper"
+	"github.com/coverprice/contentscraper/drivers/hackernews/server"
+	"github.com/coverprice/contentscraper/drivers/hackernews/types"
+	"net/http"
+)
+
+// Verify that HackerNewsDriver satisfies the drivers.IDriver & drivers.IApiDriver interfaces.
+var _ drivers.IDriver = &HackerNewsDriver{}
+var _ drivers.IApiDriver = &HackerNewsDriver{}
+
+// HackerNewsDriver implements drivers.IDriver. It follows the Facade pattern
+// and delegates the work of the interface to subordinate classes.
+type HackerNewsDriver struct {
+	harvester         *harvest.Harvester
+	httpHandler       *feedserver.HttpHandler
+	apiRequestHandler *feedserver.ApiRequestHandler
+}
+
+func NewHackerNewsDriver(
+	harvesterDbconn *sql.DB, // DB connection used to store harvested content
+	viewerDbconn *sql.DB, // DB connection used to retrieve harvested content
+	conf *config.Config,
+) (driver *HackerNewsDriver, err error) {
+	// Setup harvester
+	var scraper = scrape.NewScraper(scrape.DefaultApiBaseUrl)
+
+	var persistenceHarvester *persist.Persistence
+	if persistenceHarvester, err = persist.NewPersistence(harvesterDbconn); err != nil {
+		return
+	}
+
+	var harvester *harvest.Harvester
+	harvester, err = harvest.NewHarvester(
+		scraper,
+		persistenceHarvester,
+	)
+	if err != nil {
+		return
+	}
+
+	// Setup Feed viewer
+	var persistenceViewer *persist.Persistence
+	if persistenceViewer, err = persist.NewPersistence(viewerDbconn); err != nil {
+		return
+	}
+	postRetriever := feedserver.NewPostRetriever(server.NewAdapter(persistenceViewer))
+
+	// Configure Feeds to view
+	for _, feed := range conf.HackerNews.Feeds {
+		types.FeedRegistry.AddItem(&feed)
+	}
+
+	return &HackerNewsDriver{
+		harvester:         harvester,
+		httpHandler:       feedserver.NewHttpHandler(postRetriever),
+		apiRequestHandler: feedserver.NewApiRequestHandler(postRetriever),
+	}, nil
+}
+
+func (this *HackerNewsDriver) GetBaseUrlPath() string {
+	return server.BaseUrlPath
+}
+
+func (this *HackerNewsDriver) GetFeeds() []drivers.Feed {
+	var ret = make([]drivers.Feed, 0)
+	for _, feedregistryitem := range types.FeedRegistry.GetAllItems() {
+		status, timeLastHarvested := feedregistryitem.GetHarvestState()
+		ret = append(ret, drivers.Feed{
+			Name:              feedregistryitem.HackerNewsFeed.Name,
+			Description:       feedregistryitem.HackerNewsFeed.Description,
+			Status:            status,
+			TimeLastHarvested: timeLastHarvested,
+		})
+	}
+	return ret
+}
+
+func (this *HackerNewsDriver) GetHttpHandler() http.Handler {
+	return this.httpHandler
+}
+
+func (this *HackerNewsDriver) Harvest(ctx context.Context) (err error) {
+	return this.harvester.Harvest(ctx)
+}
+
+func (this *HackerNewsDriver) GetApiPosts(feedName string) ([]drivers.IApiPost, error) {
+	return this.apiRequestHandler.GetApiPosts(feedName)
+}
@@ -0,0 +1,96 @@
+package hackernews
+
+import (
+	"context"
+	persist "github.com/coverprice/contentscraper/drivers/hackernews/persistence"
+	scrape "github.com/coverprice/contentscraper/drivers/hackernews/scraper"
+	"github.com/coverprice/contentscraper/drivers/hackernews/types"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// Harvester controls the process of scraping stories from Hacker News' story lists
+// and persisting them.
+// It uses a Scraper client to pull the stories in a specific list,
+// and a Persistence layer to insert/update them. (Stories already stored
+// are updated to reflect any changes in their score, comment count, etc).
+type Harvester struct {
+	scraper           scrape.IScraper
+	persistence       *persist.Persistence
+	MaxStoriesPerList int // Maximum # of stories to retrieve (per list)
+}
+
+// Creates a new Harvester instance
+func NewHarvester(
+	scraper scrape.IScraper,
+	persistence *persist.Persistence,
+) (*Harvester, error) {
+	return &Harvester{
+		scraper:           scraper,
+		persistence:       persistence,
+		MaxStoriesPerList: 100,
+	}, nil
+}
+
+// Harvest pulls stories for every list in every registered feed. Failures to harvest
+// a list are logged and reflected in the feed's status, rather than aborting the
+// whole harvest. If ctx is cancelled, the harvest is abandoned and ctx.Err() is returned.
+func (this *Harvester) Harvest(ctx context.Context) (err error) {
+	for _, feed := range types.FeedRegistry.GetAllItems() {
+		feed.BeginHarvest()
+
+	NextList:
+		for _, listName := range feed.GetListNames() {
+			if err := this.pullSource(ctx, listName); err != nil {
+				if ctx.Err() != nil {
+					// The harvest was cancelled
+					feed.EndHarvest()
+					return ctx.Err()
+				}
+				log.Errorf("Error harvesting Hacker News list '%s': %v", listName, err)
+				feed.SetHarvestError()
+				continue NextList
+			}
+		}
+
+		feed.EndHarvest()
+	}
+
+	return nil
+}
+
+func (this *Harvester) pullSource(ctx context.Context, listName string) (err error) {
+	log.Infof("Pulling from Hacker News list '%s'", listName)
+	var now = int64(time.Now().Unix())
+
+	var ids []int64
+	if ids, err = this.scraper.GetStoryIds(ctx, listName); err != nil {
+		return
+	}
+	if len(ids) > this.MaxStoriesPerList {
+		ids = ids[:this.MaxStoriesPerList]
+	}
+
+	numNewStories := 0
+	for _, id := range ids {
+		var story types.Story
+		var isStory bool
+		if story, isStory, err = this.scraper.GetStory(ctx, id); err != nil {
+			return
+		}
+		if !isStory {
+			continue
+		}
+
+		var result persist.StoreResult
+		story.TimeStored = now
+		if result, err = this.persistence.StoreStory(&story, listName); err != nil {
+			return
+		}
+		if result == persist.StoreResult(persist.STORERESULT_NEW) {
+			numNewStories++
+		}
+	}
+	log.Debugf("Pulled %d stories (%d new) from list '%s'", len(ids), numNewStories, listName)
+	return nil
+}
@@ -0,0 +1,81 @@
+package hackernews
+
+import (
+	"context"
+	"fmt"
+	"github.com/coverprice/contentscraper/config"
+	"github.com/coverprice/contentscraper/database"
+	"github.com/coverprice/contentscraper/drivers"
+	persist "github.com/coverprice/contentscraper/drivers/hackernews/persistence"
+	"github.com/coverprice/contentscraper/drivers/hackernews/types"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// fakeScraper serves the lists in its map. Odd IDs are stories, even IDs are jobs.
+type fakeScraper struct {
+	lists map[string][]int64
+}
+
+func (this *fakeScraper) GetStoryIds(ctx context.Context, listName string) ([]int64, error) {
+	ids, ok := this.lists[listName]
+	if !ok {
+		return nil, fmt.Errorf("Fake failure")
+	}
+	return ids, nil
+}
+
+func (this *fakeScraper) GetStory(ctx context.Context, id int64) (story types.Story, isStory bool, err error) {
+	if id%2 == 0 {
+		return story, false, nil
+	}
+	return types.Story{Id: id, Title: fmt.Sprintf("Story %d", id), Score: id, IsActive: true}, true, nil
+}
+
+func TestHarvesterRetrievesAndStoresStories(t *testing.T) {
+	testDb, err := database.NewTestDatabase()
+	if err != nil {
+		t.Fatal("Could not init database", err)
+	}
+	defer testDb.Cleanup()
+
+	scraper := &fakeScraper{lists: map[string][]int64{
+		"top":  []int64{1, 2, 3, 5, 7, 9},
+		"best": []int64{3, 11},
+	}}
+	persistence, err := persist.NewPersistence(testDb.DbConn)
+	require.Nil(t, err, "Could not initialize persistence layer")
+	harvester, err := NewHarvester(scraper, persistence)
+	require.Nil(t, err, "Could not initialize Harvester")
+	harvester.MaxStoriesPerList = 4
+
+	types.FeedRegistry.AddItem(&config.HackerNewsFeed{
+		Name:  "testfeed",
+		Lists: []config.HackerNewsList{config.HackerNewsList{Name: "top"}, config.HackerNewsList{Name: "best"}},
+	})
+	types.FeedRegistry.AddItem(&config.HackerNewsFeed{
+		Name:  "brokenfeed",
+		Lists: []config.HackerNewsList{config.HackerNewsList{Name: "show"}},
+	})
+	defer delete(types.FeedRegistry, "testfeed")
+	defer delete(types.FeedRegistry, "brokenfeed")
+
+	err = harvester.Harvest(context.Background())
+	require.Nil(t, err, "Harvest() failed")
+
+	// Only the first 4 IDs in "top" are considered, and the job is skipped.
+	var cnt int
+	err = testDb.DbConn.QueryRow(`SELECT COUNT(*) FROM hnstory`).Scan(&cnt)
+	require.Nil(t, err, "Could not retrieve the count of stories")
+	require.Equal(t, 4, cnt) // 1, 3, 5, 11
+	err = testDb.DbConn.QueryRow(`SELECT COUNT(*) FROM hnstorylist`).Scan(&cnt)
+	require.Nil(t, err, "Could not retrieve the count of list memberships")
+	require.Equal(t, 5, cnt) // top: 1, 3, 5. best: 3, 11.
+
+	feed, _ := types.FeedRegistry.GetItemByName("testfeed")
+	status, _ := feed.GetHarvestState()
+	require.Equal(t, drivers.FEEDHARVESTSTATUS_IDLE, status)
+	feed, _ = types.FeedRegistry.GetItemByName("brokenfeed")
+	status, _ = feed.GetHarvestState()
+	require.Equal(t, drivers.FEEDHARVESTSTATUS_ERROR, status)
+}
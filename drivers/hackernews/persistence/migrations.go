@@ -0,0 +1,47 @@
+package persistence
+
+import (
+	"github.com/coverprice/contentscraper/database"
+)
+
+// The name under which this package's migrations are recorded in the schema_version table.
+const MIGRATION_COMPONENT = "hackernews"
+
+// The ordered list of changes to the Hacker News driver's tables.
+var migrations = []database.Migration{
+	database.Migration{
+		Version:     1,
+		Description: "Create hnstory & hnstorylist tables",
+		// A story may appear in several of HN's lists (e.g. both "top" and "best"), so list
+		// membership is stored separately from the story itself.
+		Sql: `
+        CREATE TABLE IF NOT EXISTS hnstory
+            ( id INTEGER PRIMARY KEY
+            , title TEXT NOT NULL
+            , url TEXT NOT NULL
+            , score INTEGER NOT NULL
+            , num_comments INTEGER NOT NULL
+            , author TEXT NOT NULL
+            , time_created INTEGER NOT NULL
+            , time_stored INTEGER NOT NULL
+            , is_active INTEGER NOT NULL
+        )
+        ;
+        CREATE INDEX IF NOT EXISTS
+            hnstory_time_stored ON hnstory(time_stored)
+        ;
+        CREATE TABLE IF NOT EXISTS hnstorylist
+            ( story_id INTEGER NOT NULL
+            , list_name TEXT NOT NULL
+            , PRIMARY KEY (list_name, story_id)
+        ) WITHOUT ROWID
+        ;
+        CREATE INDEX IF NOT EXISTS
+            hnstorylist_story_id ON hnstorylist(story_id)
+    `,
+	},
+}
+
+func init() {
+	database.RegisterMigrations(MIGRATION_COMPONENT, migrations)
+}
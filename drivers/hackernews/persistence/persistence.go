@@ -0,0 +1,289 @@
+package persistence
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/coverprice/contentscraper/database"
+	"github.com/coverprice/contentscraper/drivers/hackernews/types"
+	log "github.com/sirupsen/logrus"
+	"strings"
+)
+
+type Persistence struct {
+	dbconn          *sql.DB
+	searchStoryById *sql.Stmt
+}
+
+func NewPersistence(dbconn *sql.DB) (persistence *Persistence, err error) {
+	persistence = &Persistence{
+		dbconn: dbconn,
+	}
+	if err = persistence.initTables(); err != nil {
+		return
+	}
+
+	persistence.searchStoryById, err = persistence.dbconn.Prepare(`
+        SELECT EXISTS(
+            SELECT 1
+            FROM hnstory
+            WHERE id = $a
+            LIMIT 1
+        )`)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this *Persistence) initTables() (err error) {
+	return database.Migrate(this.dbconn, MIGRATION_COMPONENT)
+}
+
+// Stores/Updates a Story and returns whether it was a store or an
+// update.
+
+type StoreResult int
+
+const (
+	STORERESULT_NEW = iota
+	STORERESULT_UPDATED
+)
+
+// StoreStory stores/updates the story, and records that it appeared in the given list.
+func (this *Persistence) StoreStory(
+	story *types.Story,
+	listName string,
+) (
+	result StoreResult,
+	err error,
+) {
+	// The story is only stored along with its list membership, so that it's never left out of
+	// every list.
+	var tx *sql.Tx
+	if tx, err = this.dbconn.Begin(); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var storyExists int
+	if err = tx.Stmt(this.searchStoryById).QueryRow(story.Id).Scan(&storyExists); err != nil {
+		return
+	}
+	if storyExists == 0 {
+		if err = insertStory(tx, story); err != nil {
+			return
+		}
+		result = STORERESULT_NEW
+	} else {
+		if err = updateStory(tx, story); err != nil {
+			return
+		}
+		result = STORERESULT_UPDATED
+	}
+
+	if _, err = tx.Exec(`
+        INSERT OR IGNORE INTO hnstorylist
+            ( story_id
+            , list_name
+        ) VALUES
+            ( $a
+            , $b
+        )`,
+		story.Id,
+		listName,
+	); err != nil {
+		return
+	}
+	return result, tx.Commit()
+}
+
+func insertStory(tx *sql.Tx, story *types.Story) (err error) {
+	_, err = tx.Exec(`
+        INSERT INTO hnstory
+            ( id
+            , title
+            , url
+            , score
+            , num_comments
+            , author
+            , time_created
+            , time_stored
+            , is_active
+        ) VALUES
+            ( $a
+            , $b
+            , $c
+            , $d
+            , $e
+            , $f
+            , $g
+            , $h
+            , $i
+        )`,
+		story.Id,
+		story.Title,
+		story.Url,
+		story.Score,
+		story.NumComments,
+		story.Author,
+		story.TimeCreated,
+		story.TimeStored,
+		story.IsActive,
+	)
+	return
+}
+
+func updateStory(tx *sql.Tx, story *types.Story) (err error) {
+	_, err = tx.Exec(`
+        UPDATE hnstory SET
+              title = $a
+            , url = $b
+            , score = $c
+            , num_comments = $d
+            , is_active = $e
+        WHERE id = $f
+        `,
+		story.Title,
+		story.Url,
+		story.Score,
+		story.NumComments,
+		story.IsActive,
+
+		story.Id,
+	)
+	return
+}
+
+// ListedStory is a Story, along with the name of a list that it appeared in.
+type ListedStory struct {
+	types.Story
+	ListName string
+}
+
+// GetStories retrieves stories joined with their list membership (aliased as "l"),
+// so a story that appeared in several lists is returned once per list.
+func (this *Persistence) GetStories(
+	where_clause string,
+	params ...interface{},
+) (stories []ListedStory, err error) {
+	var rows *sql.Rows
+	var sql = `
+        SELECT
+            s.id
+            , s.title
+            , s.url
+            , s.score
+            , s.num_comments
+            , s.author
+            , s.time_created
+            , s.time_stored
+            , s.is_active
+            , l.list_name
+        FROM hnstory s
+        JOIN hnstorylist l ON (l.story_id = s.id)
+        ` + where_clause
+	if rows, err = this.dbconn.Query(sql, params...); err != nil {
+		log.Errorf("Error calling SQL %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var story ListedStory
+
+		err = rows.Scan(
+			&story.Id,
+			&story.Title,
+			&story.Url,
+			&story.Score,
+			&story.NumComments,
+			&story.Author,
+			&story.TimeCreated,
+			&story.TimeStored,
+			&story.IsActive,
+			&story.ListName,
+		)
+		if err != nil {
+			return
+		}
+		stories = append(stories, story)
+	}
+	log.Debugf("Retrieved %d stories from the database", len(stories))
+	return stories, nil
+}
+
+// Gets the score of the Story at the given percentile (where 100% means all stories,
+// 90% means 90% of stories, etc.) among the stories that appeared in the given list.
+func (this *Persistence) GetScoreAtPercentile(
+	minTime int64,
+	listName string,
+	percentile float64,
+) (score int, err error) {
+	sql := `
+        SELECT COUNT(*) AS cnt
+        FROM hnstory s
+        JOIN hnstorylist l ON (l.story_id = s.id)
+        WHERE l.list_name = $a
+          AND s.time_stored >= $b
+          AND s.is_active = 1
+    `
+
+	// If getting 100% of stories, then the lowest score is 0.
+	if percentile >= 100.0 {
+		return 0, nil
+	}
+
+	var cnt int
+	if err = this.dbconn.QueryRow(sql, listName, minTime).Scan(&cnt); err != nil {
+		return
+	}
+	if cnt == 0 {
+		// No stories
+		return 0, nil
+	}
+
+	sql = `
+        SELECT s.score
+        FROM hnstory s
+        JOIN hnstorylist l ON (l.story_id = s.id)
+        WHERE l.list_name = $a
+          AND s.time_stored >= $b
+          AND s.is_active = 1
+        ORDER BY s.score DESC
+        LIMIT 1
+        OFFSET $c
+    `
+	var offsetRows = int(percentile * float64(cnt) / 100.0)
+	err = this.dbconn.QueryRow(sql, listName, minTime, offsetRows).Scan(&score)
+	log.Debugf("HN list %s has a %.0f percentile score of %d over %d records", listName, percentile, score, cnt)
+	return
+}
+
+func (this *Persistence) GetStoriesForListScores(
+	minTime int64,
+	listMinScores map[string]int,
+) ([]ListedStory, error) {
+	var criteria []string
+	var params = []interface{}{minTime}
+	for listName, minScore := range listMinScores {
+		criteria = append(criteria, fmt.Sprintf(
+			"(l.list_name = $p%d AND s.score >= $p%d)",
+			len(params), len(params)+1,
+		))
+		params = append(params, listName, minScore)
+	}
+
+	whereClause := `
+        WHERE s.time_stored >= $a
+          AND s.is_active = 1
+          AND (%s)
+        LIMIT 3000
+    `
+	whereClause = fmt.Sprintf(whereClause, strings.Join(criteria, " OR "))
+	log.Debugf("Getting stories in lists with minimum scores: %s", whereClause)
+	return this.GetStories(whereClause, params...)
+}
@@ -0,0 +1,103 @@
+package persistence
+
+import (
+	"github.com/coverprice/contentscraper/database"
+	"github.com/coverprice/contentscraper/drivers/hackernews/types"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCanCreateAndRetrieveStory(t *testing.T) {
+	testDb, err := database.NewTestDatabase()
+	if err != nil {
+		t.Fatal("Could not init database", err)
+	}
+	defer testDb.Cleanup()
+
+	sut, err := NewPersistence(testDb.DbConn)
+	require.Nil(t, err, "Could not create persistence layer")
+
+	var story = &types.Story{
+		Id:          1001,
+		Title:       "A fake story",
+		Url:         "https://example.com/story",
+		Score:       100,
+		NumComments: 5,
+		Author:      "someone",
+		TimeCreated: 1234,
+		TimeStored:  1235,
+		IsActive:    true,
+	}
+
+	var result StoreResult
+	result, err = sut.StoreStory(story, "top")
+	require.Nil(t, err, "Could not store story")
+	require.Equal(t, StoreResult(STORERESULT_NEW), result, "Unexpected StoreResult")
+
+	// Same story, appearing in a different list. It should be updated.
+	story.Score = 200
+	result, err = sut.StoreStory(story, "best")
+	require.Nil(t, err, "Could not update story")
+	require.Equal(t, StoreResult(STORERESULT_UPDATED), result, "Unexpected StoreResult")
+
+	// Same story & list again, should not create a duplicate list membership
+	result, err = sut.StoreStory(story, "best")
+	require.Nil(t, err, "Could not update story")
+	require.Equal(t, StoreResult(STORERESULT_UPDATED), result, "Unexpected StoreResult")
+
+	stories, err := sut.GetStories("ORDER BY l.list_name")
+	require.Nil(t, err, "Could not retrieve stories")
+	require.Equal(t, 2, len(stories), "Expected the story once per list")
+	require.Equal(t, "best", stories[0].ListName)
+	require.Equal(t, "top", stories[1].ListName)
+	require.Equal(t, int64(200), stories[1].Score, "Score was not updated")
+	require.Equal(t, "someone", stories[1].Author)
+	require.Equal(t, int64(5), stories[1].NumComments)
+}
+
+func TestGetStoriesForListScores(t *testing.T) {
+	testDb, err := database.NewTestDatabase()
+	if err != nil {
+		t.Fatal("Could not init database", err)
+	}
+	defer testDb.Cleanup()
+
+	sut, err := NewPersistence(testDb.DbConn)
+	require.Nil(t, err, "Could not create persistence layer")
+
+	// 100 stories scored 2,4,6,...200 in "top", of which the 50 highest are also in "best"
+	for i := 1; i <= 100; i++ {
+		story := &types.Story{Id: int64(i), Score: int64(i * 2), TimeStored: 1235, IsActive: true}
+		_, err = sut.StoreStory(story, "top")
+		require.Nil(t, err, "Could not store story")
+		if i > 50 {
+			_, err = sut.StoreStory(story, "best")
+			require.Nil(t, err, "Could not store story")
+		}
+	}
+
+	score, err := sut.GetScoreAtPercentile(0, "top", 70.0)
+	require.Nil(t, err, "Could not retrieve percentile score")
+	require.Equal(t, 60, score)
+
+	score, err = sut.GetScoreAtPercentile(0, "best", 50.0)
+	require.Nil(t, err, "Could not retrieve percentile score")
+	require.Equal(t, 150, score)
+
+	stories, err := sut.GetStoriesForListScores(0, map[string]int{
+		"top":  198,
+		"best": 196,
+	})
+	require.Nil(t, err, "Could not retrieve stories")
+	require.Equal(t, 5, len(stories), "Unexpected number of stories")
+	for _, story := range stories {
+		switch story.ListName {
+		case "top":
+			require.True(t, story.Score >= 198)
+		case "best":
+			require.True(t, story.Score >= 196)
+		default:
+			require.Fail(t, "Unexpected list name", story.ListName)
+		}
+	}
+}
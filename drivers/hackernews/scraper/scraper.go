@@ -0,0 +1,119 @@
+package hackernews
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/coverprice/contentscraper/drivers/hackernews/types"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	useragent = "Fedora:github.com/coverprice/contentscraper:0.1.0"
+
+	// DefaultApiBaseUrl is the root of Hacker News' (Firebase-hosted) API.
+	DefaultApiBaseUrl = "https://hacker-news.firebaseio.com"
+)
+
+// IScraper is the interface the Harvester uses to retrieve stories. It exists
+// so that the harvester can be driven by something other than the live API.
+type IScraper interface {
+	// GetStoryIds returns the IDs of the stories in the given list (e.g. "top"), in list order.
+	GetStoryIds(ctx context.Context, listName string) ([]int64, error)
+	// GetStory retrieves a single story.
+	GetStory(ctx context.Context, id int64) (story types.Story, isStory bool, err error)
+}
+
+// Verify that Scraper implements the IScraper interface
+var _ IScraper = &Scraper{}
+
+// Scraper is a minimal client for the Hacker News API. The API is public, and
+// does not require any credentials.
+type Scraper struct {
+	client  *http.Client
+	baseUrl string
+}
+
+// NewScraper creates a Scraper that talks to the API rooted at baseUrl, e.g. DefaultApiBaseUrl.
+func NewScraper(baseUrl string) *Scraper {
+	return &Scraper{
+		client:  &http.Client{Timeout: 30 * time.Second},
+		baseUrl: strings.TrimRight(baseUrl, "/"),
+	}
+}
+
+// The parts of the API's "item" response that we care about.
+type apiItem struct {
+	Id          int64  `json:"id"`
+	Type        string `json:"type"`
+	By          string `json:"by"`
+	Time        int64  `json:"time"`
+	Title       string `json:"title"`
+	Url         string `json:"url"`
+	Score       int64  `json:"score"`
+	Descendants int64  `json:"descendants"`
+	Dead        bool   `json:"dead"`
+	Deleted     bool   `json:"deleted"`
+}
+
+func (this *Scraper) GetStoryIds(ctx context.Context, listName string) (ids []int64, err error) {
+	if err = this.get(ctx, fmt.Sprintf("/v0/%sstories.json", listName), &ids); err != nil {
+		return nil, fmt.Errorf("Failed to fetch story list '%s': %v", listName, err)
+	}
+	return ids, nil
+}
+
+// GetStory retrieves an item by ID. The API uses the same endpoint for stories, comments,
+// polls, etc, so isStory is false if the item turned out not to be a story.
+func (this *Scraper) GetStory(ctx context.Context, id int64) (story types.Story, isStory bool, err error) {
+	var item *apiItem
+	if err = this.get(ctx, fmt.Sprintf("/v0/item/%d.json", id), &item); err != nil {
+		return story, false, fmt.Errorf("Failed to fetch story %d: %v", id, err)
+	}
+	if item == nil || item.Type != "story" {
+		// The API returns "null" for unknown items.
+		return story, false, nil
+	}
+	return newStoryFromApiItem(item), true, nil
+}
+
+// get performs a GET request against the API and decodes the JSON response.
+// The request is abandoned if ctx is cancelled.
+func (this *Scraper) get(ctx context.Context, path string, response interface{}) (err error) {
+	req, err := http.NewRequest("GET", this.baseUrl+path, nil)
+	if err != nil {
+		return
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", useragent)
+
+	resp, err := this.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Unexpected HTTP status: %s", resp.Status)
+	}
+	if err = json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return fmt.Errorf("Could not decode response: %v", err)
+	}
+	return nil
+}
+
+// Create a new Story object from the API's format
+func newStoryFromApiItem(item *apiItem) (s types.Story) {
+	s.Id = item.Id
+	s.Title = item.Title
+	s.Url = item.Url
+	s.Score = item.Score
+	s.NumComments = item.Descendants
+	s.Author = item.By
+	s.TimeCreated = item.Time
+	s.TimeStored = item.Time
+	s.IsActive = !(item.Dead || item.Deleted)
+	return
+}
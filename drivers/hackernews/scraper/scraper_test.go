@@ -0,0 +1,78 @@
+package hackernews
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newFakeApiServer returns a local server that mimics the subset of the Hacker News API
+// used by the Scraper.
+func newFakeApiServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v0/topstories.json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[101, 102, 103]`)
+	})
+	mux.HandleFunc("/v0/item/101.json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id": 101, "type": "story", "by": "someone", "time": 1508230800,
+            "title": "A story", "url": "https://example.com/story", "score": 55, "descendants": 12}`)
+	})
+	mux.HandleFunc("/v0/item/102.json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id": 102, "type": "job", "by": "acme", "time": 1508230800, "title": "Acme is hiring"}`)
+	})
+	mux.HandleFunc("/v0/item/103.json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `null`)
+	})
+	mux.HandleFunc("/v0/item/104.json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id": 104, "type": "story", "by": "someone", "time": 1508230800,
+            "title": "Ask HN: A question", "score": 3, "dead": true}`)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestCanScrapeStoryList(t *testing.T) {
+	server := newFakeApiServer(t)
+	defer server.Close()
+	scraper := NewScraper(server.URL)
+
+	ids, err := scraper.GetStoryIds(context.Background(), "top")
+	require.Nil(t, err, "Non nil error from scraper")
+	require.Equal(t, []int64{101, 102, 103}, ids)
+
+	_, err = scraper.GetStoryIds(context.Background(), "nosuchlist")
+	require.NotNil(t, err, "Expected an error for an unknown list")
+}
+
+func TestCanScrapeStories(t *testing.T) {
+	server := newFakeApiServer(t)
+	defer server.Close()
+	scraper := NewScraper(server.URL)
+
+	story, isStory, err := scraper.GetStory(context.Background(), 101)
+	require.Nil(t, err, "Non nil error from scraper")
+	require.True(t, isStory)
+	require.Equal(t, int64(101), story.Id)
+	require.Equal(t, "A story", story.Title)
+	require.Equal(t, "https://example.com/story", story.Url)
+	require.Equal(t, int64(55), story.Score)
+	require.Equal(t, int64(12), story.NumComments)
+	require.Equal(t, "someone", story.Author)
+	require.Equal(t, int64(1508230800), story.TimeCreated)
+	require.True(t, story.IsActive)
+
+	// Jobs and missing items are not stories
+	_, isStory, err = scraper.GetStory(context.Background(), 102)
+	require.Nil(t, err, "Non nil error from scraper")
+	require.False(t, isStory)
+	_, isStory, err = scraper.GetStory(context.Background(), 103)
+	require.Nil(t, err, "Non nil error from scraper")
+	require.False(t, isStory)
+
+	story, isStory, err = scraper.GetStory(context.Background(), 104)
+	require.Nil(t, err, "Non nil error from scraper")
+	require.True(t, isStory)
+	require.False(t, story.IsActive, "Dead stories should be inactive")
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"fmt"
+	"github.com/coverprice/contentscraper/config"
+	"github.com/coverprice/contentscraper/drivers"
+	"github.com/coverprice/contentscraper/drivers/feedserver"
+	persist "github.com/coverprice/contentscraper/drivers/hackernews/persistence"
+	"github.com/coverprice/contentscraper/drivers/hackernews/types"
+)
+
+// Verify that Adapter implements the feedserver.IAdapter interface
+var _ feedserver.IAdapter = &Adapter{}
+
+// Adapter serves the Hacker News feeds through the feedserver. Each of a feed's lists is a
+// source, whose stories are ranked by score. A story in several lists is shown in the first.
+type Adapter struct {
+	persistence *persist.Persistence
+}
+
+func NewAdapter(persistence *persist.Persistence) *Adapter {
+	return &Adapter{
+		persistence: persistence,
+	}
+}
+
+func (this *Adapter) GetName() string {
+	return "Hacker News"
+}
+
+func (this *Adapter) GetBaseUrlPath() string {
+	return BaseUrlPath
+}
+
+func (this *Adapter) GetFeed(feedName string) (*feedserver.Feed, error) {
+	item, err := types.FeedRegistry.GetItemByName(feedName)
+	if err != nil {
+		return nil, err
+	}
+	return newFeed(&item.HackerNewsFeed), nil
+}
+
+func newFeed(feed *config.HackerNewsFeed) *feedserver.Feed {
+	var maxDailyPosts = make(map[string]int) // List name -> Max daily posts
+	for _, list := range feed.Lists {
+		maxDailyPosts[list.Name] = list.MaxDailyPosts
+	}
+	return &feedserver.Feed{
+		Name:          feed.Name,
+		Description:   feed.Description,
+		MaxDailyPosts: maxDailyPosts,
+		Config:        feed,
+	}
+}
+
+// GetPosts retrieves stories for the feed, applying per-list percentile filters.
+func (this *Adapter) GetPosts(feed *feedserver.Feed, minTime int64) (posts []feedserver.Post, err error) {
+	var hackerNewsFeed = feed.Config.(*config.HackerNewsFeed)
+	var listMinScore = make(map[string]int)
+	var listIdx = make(map[string]int)
+	for idx, list := range hackerNewsFeed.Lists {
+		listIdx[list.Name] = idx
+		if list.Percentile > 0.0 {
+			listMinScore[list.Name], err = this.persistence.GetScoreAtPercentile(
+				minTime,
+				list.Name,
+				list.Percentile,
+			)
+			if err != nil {
+				return
+			}
+		}
+	}
+	if len(listMinScore) == 0 {
+		return
+	}
+
+	var listedStories []persist.ListedStory
+	if listedStories, err = this.persistence.GetStoriesForListScores(minTime, listMinScore); err != nil {
+		return
+	}
+	for _, story := range listedStories {
+		posts = append(posts, newPost(story, listIdx[story.ListName]))
+	}
+	return
+}
+
+func newPost(story persist.ListedStory, listIdx int) feedserver.Post {
+	var link = story.Url
+	if link == "" {
+		link = story.Permalink()
+	}
+	return feedserver.Post{
+		Item:         story,
+		Id:           story.Permalink(),
+		DuplicateKey: fmt.Sprintf("%d", story.Id),
+		Source:       story.ListName,
+		SourceIdx:    listIdx,
+		Rank:         story.Score,
+		TimeSorted:   story.TimeStored,
+		TimeAged:     story.TimeStored,
+		Title:        story.Title,
+		Link:         link,
+		Url:          story.Url,
+		Author:       story.Author,
+		Byline:       fmt.Sprintf("by %s (%s)", story.Author, story.ListName),
+		HasScore:     true,
+		Score:        story.Score,
+		CommentsLink: story.Permalink(),
+		NumComments:  story.NumComments,
+		TimeCreated:  story.TimeCreated,
+	}
+}
+
+func (this *Adapter) GetApiPost(post feedserver.Post) drivers.IApiPost {
+	var story = post.Item.(persist.ListedStory)
+	return ApiStory{
+		Id:          story.Id,
+		Title:       story.Title,
+		Url:         story.Url,
+		Permalink:   story.Permalink(),
+		List:        story.ListName,
+		Author:      story.Author,
+		Score:       story.Score,
+		NumComments: story.NumComments,
+		TimeCreated: story.TimeCreated,
+		TimeStored:  story.TimeStored,
+		AgeInDays:   post.AgeInDays,
+		MediaLink:   post.MediaLink,
+	}
+}
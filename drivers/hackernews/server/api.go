@@ -0,0 +1,31 @@
+package server
+
+import (
+	"fmt"
+	"github.com/coverprice/contentscraper/drivers"
+	"github.com/coverprice/contentscraper/server/medialink"
+)
+
+// ApiStory is the JSON representation of a Hacker News story, as served by the /api/v1/ endpoints.
+// (See API.md for the schema).
+type ApiStory struct {
+	Id          int64                `json:"id"`
+	Title       string               `json:"title"`
+	Url         string               `json:"url"`
+	Permalink   string               `json:"permalink"`
+	List        string               `json:"list"`
+	Author      string               `json:"author"`
+	Score       int64                `json:"score"`
+	NumComments int64                `json:"num_comments"`
+	TimeCreated int64                `json:"time_created"`
+	TimeStored  int64                `json:"time_stored"`
+	AgeInDays   int64                `json:"age_in_days"`
+	MediaLink   *medialink.MediaLink `json:"media_link"`
+}
+
+// Verify that ApiStory implements the drivers.IApiPost interface
+var _ drivers.IApiPost = ApiStory{}
+
+func (this ApiStory) GetApiId() string {
+	return fmt.Sprintf("%d", this.Id)
+}
@@ -0,0 +1,5 @@
+package server
+
+const (
+	BaseUrlPath = "/hackernews/"
+)
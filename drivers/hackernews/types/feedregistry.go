@@ -0,0 +1,49 @@
+package types
+
+import (
+	"fmt"
+	"github.com/coverprice/contentscraper/config"
+	"github.com/coverprice/contentscraper/drivers"
+)
+
+type FeedRegistryItem struct {
+	config.HackerNewsFeed
+	drivers.FeedHarvestState
+}
+
+type TFeedRegistry map[string]*FeedRegistryItem
+
+// The FeedRegistry is a simple map (with some functions to assist with adding/retrieving)
+// that maps a Feed name to the Hacker News configuration.
+var FeedRegistry = make(TFeedRegistry)
+
+func (this *TFeedRegistry) AddItem(feed *config.HackerNewsFeed) {
+	fri := FeedRegistryItem{
+		HackerNewsFeed: *feed,
+	}
+
+	(*this)[fri.HackerNewsFeed.Name] = &fri
+}
+
+func (this *TFeedRegistry) GetItemByName(feedname string) (*FeedRegistryItem, error) {
+	item, ok := (*this)[feedname]
+	if !ok {
+		return nil, fmt.Errorf("Unknown feed name %s", feedname)
+	}
+	return item, nil
+}
+
+func (this *TFeedRegistry) GetAllItems() (ret []*FeedRegistryItem) {
+	for _, val := range *this {
+		ret = append(ret, val)
+	}
+	return ret
+}
+
+// GetListNames returns the names of the story lists referenced by the feed.
+func (this *FeedRegistryItem) GetListNames() (listNames []string) {
+	for _, list := range this.HackerNewsFeed.Lists {
+		listNames = append(listNames, list.Name)
+	}
+	return
+}
@@ -0,0 +1,22 @@
+package types
+
+import (
+	"fmt"
+)
+
+type Story struct {
+	Id          int64
+	Title       string
+	Url         string // Link to the story's content. Empty for text posts such as "Ask HN".
+	Score       int64
+	NumComments int64
+	Author      string
+	TimeCreated int64
+	TimeStored  int64
+	IsActive    bool // False if the story has been deleted or killed ("dead")
+}
+
+// Permalink returns the URL of the story's discussion page on Hacker News.
+func (this Story) Permalink() string {
+	return fmt.Sprintf("https://news.ycombinator.com/item?id=%d", this.Id)
+}
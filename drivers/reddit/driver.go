@@ -3,6 +3,7 @@ package reddit
 // Implements the IDriver interface for the Reddit content source type
 
 import (
+	"context"
 	"database/sql"
 	"github.com/coverprice/contentscraper/config"
 	"github.com/coverprice/contentscraper/drivers"
@@ -11,17 +12,35 @@ import (
 	scrape "github.com/coverprice/contentscraper/drivers/reddit/scraper"
 	"github.com/coverprice/contentscraper/drivers/reddit/server"
 	"github.com/coverprice/contentscraper/drivers/reddit/types"
+	"github.com/coverprice/contentscraper/server/syndication"
+	"github.com/coverprice/contentscraper/toolbox"
 	"net/http"
+	"time"
 )
 
-// Verify that RedditDriver satisfies the drivers.IDriver interface.
+// Verify that RedditDriver satisfies the drivers.IDriver, drivers.IApiDriver, drivers.IStatsDriver,
+// drivers.ISeenDriver, drivers.IStarDriver, drivers.IPruneDriver, drivers.ISearchDriver &
+// drivers.IReloadDriver interfaces.
 var _ drivers.IDriver = &RedditDriver{}
+var _ drivers.IApiDriver = &RedditDriver{}
+var _ drivers.IStatsDriver = &RedditDriver{}
+var _ drivers.ISeenDriver = &RedditDriver{}
+var _ drivers.IStarDriver = &RedditDriver{}
+var _ drivers.IPruneDriver = &RedditDriver{}
+var _ drivers.ISearchDriver = &RedditDriver{}
+var _ drivers.IReloadDriver = &RedditDriver{}
 
 // RedditDriver implements drivers.IDriver. It follows the Facade pattern
 // and delegates the work of the interface to subordinate classes.
 type RedditDriver struct {
-	harvester   *harvest.Harvester
-	httpHandler *server.HttpHandler
+	harvester          *harvest.Harvester
+	pruner             *harvest.Pruner
+	httpHandler        *server.HttpHandler
+	apiRequestHandler  *server.ApiRequestHandler
+	seenRequestHandler *server.SeenRequestHandler
+	starRequestHandler *server.StarRequestHandler
+	searchHandler      *server.SearchRequestHandler
+	viewerPersistence  *persist.Persistence
 }
 
 func NewRedditDriver(
@@ -30,8 +49,10 @@ func NewRedditDriver(
 	conf *config.Config,
 ) (driver *RedditDriver, err error) {
 	// Setup harvester
-	var scraper *scrape.Scraper
-	if scraper, err = scrape.NewScraperFromConfig(conf); err != nil {
+	// A single limiter is shared by all of the harvester's requests.
+	var rateLimiter = toolbox.NewTokenBucket(conf.Reddit.RequestsPerMinute, conf.Reddit.RequestBurst)
+	var scraper scrape.IScraper
+	if scraper, err = scrape.NewScraperFromConfig(conf, rateLimiter); err != nil {
 		return
 	}
 
@@ -48,14 +69,27 @@ func NewRedditDriver(
 	if err != nil {
 		return
 	}
+	harvester.Concurrency = conf.Reddit.Concurrency
+	harvester.RateLimiter = rateLimiter
+	harvester.MaxRetries = conf.Reddit.MaxRetries
+	// The pruner deletes posts, so shares the harvester's DB connection.
+	pruner := harvest.NewPruner(persistenceHarvester, conf.RetentionDays)
 
 	// Setup Feed viewer
 	var persistenceViewer *persist.Persistence
 	if persistenceViewer, err = persist.NewPersistence(viewerDbconn); err != nil {
 		return
 	}
-	htmlViewerRequestHandler := server.NewHtmlViewerRequestHandler(persistenceViewer)
-	httpHandler := server.NewHttpHandler(htmlViewerRequestHandler)
+	seenRequestHandler := server.NewSeenRequestHandler(persistenceViewer)
+	starRequestHandler := server.NewStarRequestHandler(persistenceViewer)
+	httpHandler := server.NewHttpHandler(map[string]server.IRequestHandler{
+		syndication.FORMAT_HTML: server.NewHtmlViewerRequestHandler(persistenceViewer),
+		syndication.FORMAT_RSS:  server.NewSyndicationRequestHandler(persistenceViewer, syndication.FORMAT_RSS),
+		syndication.FORMAT_ATOM: server.NewSyndicationRequestHandler(persistenceViewer, syndication.FORMAT_ATOM),
+	}, map[string]http.Handler{
+		server.SeenUrlPath: seenRequestHandler,
+		server.StarUrlPath: starRequestHandler,
+	})
 
 	// Configure Feeds to view
 	for _, feed := range conf.Reddit.Feeds {
@@ -63,8 +97,14 @@ func NewRedditDriver(
 	}
 
 	return &RedditDriver{
-		harvester:   harvester,
-		httpHandler: httpHandler,
+		harvester:          harvester,
+		pruner:             pruner,
+		httpHandler:        httpHandler,
+		apiRequestHandler:  server.NewApiRequestHandler(persistenceViewer),
+		seenRequestHandler: seenRequestHandler,
+		starRequestHandler: starRequestHandler,
+		searchHandler:      server.NewSearchRequestHandler(persistenceViewer),
+		viewerPersistence:  persistenceViewer,
 	}, nil
 }
 
@@ -75,11 +115,12 @@ func (this *RedditDriver) GetBaseUrlPath() string {
 func (this *RedditDriver) GetFeeds() []drivers.Feed {
 	var ret = make([]drivers.Feed, 0)
 	for _, feedregistryitem := range types.FeedRegistry.GetAllItems() {
+		status, timeLastHarvested := feedregistryitem.GetHarvestState()
 		ret = append(ret, drivers.Feed{
 			Name:              feedregistryitem.RedditFeed.Name,
 			Description:       feedregistryitem.RedditFeed.Description,
-			Status:            feedregistryitem.Status,
-			TimeLastHarvested: feedregistryitem.TimeLastHarvested,
+			Status:            status,
+			TimeLastHarvested: timeLastHarvested,
 		})
 	}
 	return ret
@@ -89,6 +130,54 @@ func (this *RedditDriver) GetHttpHandler() http.Handler {
 	return this.httpHandler
 }
 
-func (this *RedditDriver) Harvest() (err error) {
-	return this.harvester.Harvest()
+func (this *RedditDriver) Harvest(ctx context.Context) (err error) {
+	return this.harvester.Harvest(ctx)
+}
+
+// Reload applies the config's Reddit feeds and retention period. (Other settings, e.g. the
+// credentials and rate limits, only take effect when the program is restarted).
+func (this *RedditDriver) Reload(conf *config.Config) {
+	var changedFeedNames = types.FeedRegistry.ReplaceItems(conf.Reddit.Feeds)
+	server.InvalidatePostCache(changedFeedNames)
+	this.pruner.SetRetentionDays(conf.RetentionDays)
+}
+
+func (this *RedditDriver) Prune(dryRun bool) (drivers.PruneReport, error) {
+	return this.pruner.Prune(time.Now().Unix(), dryRun)
+}
+
+func (this *RedditDriver) GetApiPosts(feedName string) ([]drivers.IApiPost, error) {
+	feed, err := types.FeedRegistry.GetItemByName(feedName)
+	if err != nil {
+		return nil, err
+	}
+	return this.apiRequestHandler.GetPosts(&feed.RedditFeed)
+}
+
+func (this *RedditDriver) GetUnreadCount(feedName string, viewerId string) (int, error) {
+	feed, err := types.FeedRegistry.GetItemByName(feedName)
+	if err != nil {
+		return 0, err
+	}
+	return this.seenRequestHandler.GetUnreadCount(&feed.RedditFeed, viewerId)
+}
+
+func (this *RedditDriver) GetStarredPosts(viewerId string) ([]drivers.StarredPost, error) {
+	return this.starRequestHandler.GetStarredPosts(viewerId)
+}
+
+func (this *RedditDriver) SearchPosts(query drivers.SearchQuery) ([]drivers.SearchResult, error) {
+	return this.searchHandler.SearchPosts(query)
+}
+
+func (this *RedditDriver) GetHarvestRuns(feedName string, minTime int64) ([]drivers.HarvestRun, error) {
+	feed, err := types.FeedRegistry.GetItemByName(feedName)
+	if err != nil {
+		return nil, err
+	}
+	var sourceKeys []string
+	for _, subreddit := range feed.RedditFeed.Subreddits {
+		sourceKeys = append(sourceKeys, subreddit.SourceKey())
+	}
+	return this.viewerPersistence.GetHarvestRuns(minTime, sourceKeys)
 }
@@ -1,31 +1,46 @@
 package reddit
 
 import (
+	"context"
+	"github.com/coverprice/contentscraper/config"
 	"github.com/coverprice/contentscraper/drivers"
 	persist "github.com/coverprice/contentscraper/drivers/reddit/persistence"
 	scrape "github.com/coverprice/contentscraper/drivers/reddit/scraper"
 	"github.com/coverprice/contentscraper/drivers/reddit/types"
+	"github.com/coverprice/contentscraper/toolbox"
 	log "github.com/sirupsen/logrus"
+	"sync"
 	"time"
 )
 
 // Harvester controls the process of scraping posts from Reddit sources
 // and persisting them.
-// It uses a Scraper client to pull posts from a specific source,
+// It uses a scraper (see scrape.IScraper) to pull posts from a specific source,
 // and a Persistence layer to insert/update them. (Posts already stored
 // are updated to reflect any changes in their score, deleted status,
 // etc).
+// Sources are pulled by a pool of workers. The requests they make to Reddit
+// are spaced out by a RateLimiter shared between them, while storing the posts is
+// serialized since SQLite only permits a single writer at a time. Requests that fail
+// for a transient reason (e.g. Reddit being overloaded) are retried with an exponential
+// backoff, rather than failing the whole source.
 type Harvester struct {
-	scraper           *scrape.Scraper
+	scraper           scrape.IScraper
 	persistence       *persist.Persistence
+	storeLock         sync.Mutex
 	MaxPagesToScrape  int     // Maximum # of pages to scrape (per source)
 	MinPostsPerScrape int     // Min posts in scrape result to continue
 	MinNewPostPercent float64 // Min new posts in scrape result to continue.
+	Concurrency       int     // # of sources to pull simultaneously
+	RateLimiter       *toolbox.RateLimiter
+	MaxRetries        int           // Max # of times a failed request is retried
+	RetryBaseDelay    time.Duration // Delay before the 1st retry. It doubles for each subsequent one...
+	RetryMaxDelay     time.Duration // ...up to this maximum
 }
 
 // Creates a new Harvester instance
 func NewHarvester(
-	scraper *scrape.Scraper,
+	scraper scrape.IScraper,
 	persistence *persist.Persistence,
 ) (*Harvester, error) {
 	return &Harvester{
@@ -34,54 +49,148 @@ func NewHarvester(
 		MaxPagesToScrape:  10,
 		MinPostsPerScrape: 10,
 		MinNewPostPercent: 20.0,
+		Concurrency:       4,
+		RateLimiter:       toolbox.NewRateLimiter(60),
+		MaxRetries:        3,
+		RetryBaseDelay:    2 * time.Second,
+		RetryMaxDelay:     time.Minute,
 	}, nil
 }
 
-func (this *Harvester) Harvest() (err error) {
-	for _, feed := range types.FeedRegistry.GetAllItems() {
-		// TODO: Not super essential, but it's more correct to put a mutex around updating these feed fields.
-		feed.Status = drivers.FEEDHARVESTSTATUS_HARVESTING
-		feed.TimeLastHarvested = int64(time.Now().Unix())
+// Harvest pulls posts for every source (i.e. subreddit, multireddit, etc) in every registered
+// feed. Failures to harvest a source are logged and reflected in the status of the feeds that
+// include it, rather than aborting the whole harvest. The outcome of each source's harvest is
+// recorded in the database. If ctx is cancelled, the workers abandon their requests in progress
+// and ctx.Err() is returned.
+func (this *Harvester) Harvest(ctx context.Context) error {
+	var timeRunStarted = int64(time.Now().Unix())
 
-	NextSubreddit:
+	// Each source is only pulled once, even if several feeds include it.
+	var feedsBySource = make(map[string][]*types.FeedRegistryItem)
+	var commentSettingsBySource = make(map[string]commentSettings)
+	var listingsBySource = make(map[string][]string)
+	var sourceKeys []string
+	for _, feed := range types.FeedRegistry.GetAllItems() {
+		feed.BeginHarvest()
 		for _, subreddit := range feed.RedditFeed.Subreddits {
-			if err = this.pullSource(subreddit.Name); err != nil {
-				feed.Status = drivers.FEEDHARVESTSTATUS_ERROR
-				continue NextSubreddit
+			var sourceKey = subreddit.SourceKey()
+			if _, ok := feedsBySource[sourceKey]; !ok {
+				sourceKeys = append(sourceKeys, sourceKey)
+			}
+			feedsBySource[sourceKey] = append(feedsBySource[sourceKey], feed)
+			commentSettingsBySource[sourceKey] = commentSettingsBySource[sourceKey].merge(subreddit)
+			for _, listing := range subreddit.Listings {
+				if !toolbox.ContainsStr(listingsBySource[sourceKey], listing) {
+					listingsBySource[sourceKey] = append(listingsBySource[sourceKey], listing)
+				}
 			}
 		}
+	}
+
+	var concurrency = this.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var jobs = make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for sourceKey := range jobs {
+				var run = drivers.HarvestRun{
+					Source:         sourceKey,
+					TimeRunStarted: timeRunStarted,
+					TimeStarted:    int64(time.Now().Unix()),
+				}
+				err := this.pullSource(ctx, sourceKey, listingsBySource[sourceKey], &run)
+				if err == nil {
+					err = this.pullComments(ctx, sourceKey, commentSettingsBySource[sourceKey])
+				}
+				if ctx.Err() != nil {
+					// Errors caused by the harvest being cancelled aren't the source's fault,
+					// and a partial harvest isn't worth recording.
+					continue
+				}
+				if err != nil {
+					log.Errorf("Error harvesting source '%s': %v", sourceKey, err)
+					run.Error = err.Error()
+					for _, feed := range feedsBySource[sourceKey] {
+						feed.SetHarvestError()
+					}
+				}
+				run.TimeEnded = int64(time.Now().Unix())
+				this.recordHarvestRun(&run)
+			}
+		}()
+	}
 
-		feed.Status = drivers.FEEDHARVESTSTATUS_IDLE
+Dispatch:
+	for _, sourceKey := range sourceKeys {
+		select {
+		case jobs <- sourceKey:
+		case <-ctx.Done():
+			break Dispatch
+		}
 	}
+	close(jobs)
+	wg.Wait()
 
-	return
+	for _, feed := range types.FeedRegistry.GetAllItems() {
+		feed.EndHarvest()
+	}
+	return ctx.Err()
 }
 
-func (this *Harvester) pullSource(subredditName string) (err error) {
-	log.Infof("Pulling from source '%s'", subredditName)
+// pullSource pulls each of the source's listings in turn. A post that appears in several
+// listings is only stored once. The pages scraped and the posts stored are tallied in run.
+func (this *Harvester) pullSource(
+	ctx context.Context,
+	sourceKey string,
+	listings []string,
+	run *drivers.HarvestRun,
+) (err error) {
 	var now = int64(time.Now().Unix())
+	var seenPosts = make(map[string]bool) // Post ID -> whether it was new to the database
+	for _, listing := range listings {
+		log.Infof("Pulling from source '%s' listing '%s'", sourceKey, listing)
+		var scrapeContext scrape.Context
+		if scrapeContext, err = scrape.NewContextForSource(sourceKey, listing); err != nil {
+			return
+		}
+		if err = this.pullListing(ctx, &scrapeContext, now, seenPosts, run); err != nil {
+			return
+		}
+	}
+	return nil
+}
 
-	var context = scrape.NewContextForHot(subredditName)
+// pullListing pulls pages of posts from a listing until it decides that going further
+// back is pointless. Cancelling ctx abandons the request in progress.
+func (this *Harvester) pullListing(
+	ctx context.Context,
+	scrapeContext *scrape.Context,
+	now int64,
+	seenPosts map[string]bool,
+	run *drivers.HarvestRun,
+) (err error) {
 	numPagesScraped := 0
 	for {
 		var posts []types.RedditPost
-		posts, err = this.scraper.GetNextResults(&context)
+		err = this.withRetries(ctx, func() (err error) {
+			posts, err = this.scraper.GetNextResults(ctx, scrapeContext)
+			return
+		})
 		if err != nil {
 			return
 		}
-		log.Debugf("Pulled %d posts from source '%s'", len(posts), subredditName)
+		log.Debugf("Pulled %d posts from '%s'", len(posts), scrapeContext.UrlPath)
 		numPagesScraped++
+		run.PagesScraped++
 
-		numNewPosts := 0
-		for _, post := range posts {
-			var result persist.StoreResult
-			post.TimeStored = now
-			if result, err = this.persistence.StorePost(&post); err != nil {
-				return
-			}
-			if result == persist.StoreResult(persist.STORERESULT_NEW) {
-				numNewPosts++
-			}
+		var numNewPosts int
+		if numNewPosts, err = this.storePosts(scrapeContext.Source, posts, now, seenPosts, run); err != nil {
+			return
 		}
 
 		// Decide when to break out of the loop
@@ -105,3 +214,169 @@ func (this *Harvester) pullSource(subredditName string) (err error) {
 	}
 	return nil
 }
+
+// commentSettings describes which of a subreddit's posts have their top comments harvested.
+type commentSettings struct {
+	TopComments  int     // The # of comments to harvest per post. 0 means none.
+	RefreshHours int     // Comments are refreshed for this long after the post was created
+	Percentile   float64 // Only posts that pass this percentile filter have their comments harvested
+}
+
+// merge returns the settings that satisfy both these settings and the subreddit's configuration,
+// for when a subreddit is included in several feeds.
+func (this commentSettings) merge(subreddit config.Subreddit) commentSettings {
+	if subreddit.TopComments > this.TopComments {
+		this.TopComments = subreddit.TopComments
+	}
+	if subreddit.TopComments > 0 {
+		if subreddit.CommentRefreshHours > this.RefreshHours {
+			this.RefreshHours = subreddit.CommentRefreshHours
+		}
+		// A higher percentile includes more posts.
+		if subreddit.Percentile > this.Percentile {
+			this.Percentile = subreddit.Percentile
+		}
+	}
+	return this
+}
+
+// pullComments refreshes the top comments of the source's recent posts that pass the
+// percentile filter.
+func (this *Harvester) pullComments(ctx context.Context, sourceKey string, settings commentSettings) (err error) {
+	if settings.TopComments == 0 {
+		return nil
+	}
+	var now = int64(time.Now().Unix())
+
+	// The reads are serialized along with the writes, so that SQLite doesn't report the
+	// database as being locked.
+	this.storeLock.Lock()
+	var posts []types.RedditPost
+	var minScore int
+	// The same period the viewer uses to calculate percentiles
+	minScore, err = this.persistence.GetScoreAtPercentile(now-7*24*60*60, sourceKey, settings.Percentile)
+	if err == nil {
+		posts, err = this.persistence.GetPostsNeedingComments(sourceKey, now-int64(settings.RefreshHours)*60*60, minScore)
+	}
+	this.storeLock.Unlock()
+	if err != nil {
+		return
+	}
+
+	log.Infof("Pulling comments for %d posts from source '%s'", len(posts), sourceKey)
+	for _, post := range posts {
+		var comments []types.RedditComment
+		err = this.withRetries(ctx, func() (err error) {
+			comments, err = this.scraper.GetTopComments(ctx, &post, settings.TopComments)
+			return
+		})
+		if err != nil {
+			return
+		}
+		for idx := range comments {
+			comments[idx].TimeStored = now
+		}
+		this.storeLock.Lock()
+		err = this.persistence.ReplaceComments(post.Id, comments)
+		this.storeLock.Unlock()
+		if err != nil {
+			return
+		}
+	}
+	return nil
+}
+
+// storePosts persists a page of the source's posts, and returns how many of them were new. Posts
+// already stored from another of the source's listings during this harvest (i.e. those in seenPosts)
+// aren't stored again, but are still counted as new if they were new then, so that the
+// listings' stopping rules aren't affected by the order in which they're pulled.
+func (this *Harvester) storePosts(
+	sourceKey string,
+	posts []types.RedditPost,
+	now int64,
+	seenPosts map[string]bool,
+	run *drivers.HarvestRun,
+) (numNewPosts int, err error) {
+	this.storeLock.Lock()
+	defer this.storeLock.Unlock()
+
+	for _, post := range posts {
+		if wasNew, ok := seenPosts[post.Id]; ok {
+			if wasNew {
+				numNewPosts++
+			}
+			continue
+		}
+		var result persist.StoreResult
+		post.TimeStored = now
+		if result, err = this.persistence.StorePost(&post); err != nil {
+			return
+		}
+		seenPosts[post.Id] = (result == persist.STORERESULT_NEW)
+		if result == persist.STORERESULT_SKIPPED {
+			// The post duplicates one that's already stored, which should appear in this source too.
+			if err = this.persistence.AddDuplicatePostToSource(sourceKey, &post); err != nil {
+				return
+			}
+		} else {
+			// Keep a history of the post's score, to see how quickly it's rising.
+			if err = this.persistence.RecordScore(&post, now); err != nil {
+				return
+			}
+			if post.SubredditName != sourceKey {
+				// Subreddits' posts belong to them implicitly, but other sources need to record theirs.
+				if err = this.persistence.AddPostToSource(sourceKey, &post); err != nil {
+					return
+				}
+			}
+		}
+		switch result {
+		case persist.STORERESULT_NEW:
+			numNewPosts++
+			run.PostsNew++
+		case persist.STORERESULT_UPDATED:
+			run.PostsUpdated++
+		case persist.STORERESULT_SKIPPED:
+			run.PostsSkipped++
+		}
+	}
+	return numNewPosts, nil
+}
+
+// withRetries makes a request to Reddit by calling fetch, once the RateLimiter allows it.
+// Requests that fail for a transient reason (see scrape.IsTransient) are retried up to
+// MaxRetries times, after an exponentially increasing delay.
+func (this *Harvester) withRetries(ctx context.Context, fetch func() error) (err error) {
+	for attempt := 1; ; attempt++ {
+		if err = this.RateLimiter.Wait(ctx); err != nil {
+			return
+		}
+		if err = fetch(); err != nil && ctx.Err() != nil {
+			// The request was abandoned, rather than having failed.
+			return ctx.Err()
+		}
+		if err == nil || !scrape.IsTransient(err) || attempt > this.MaxRetries {
+			return
+		}
+		var delay = toolbox.Backoff(attempt, this.RetryBaseDelay, this.RetryMaxDelay)
+		log.Warnf("%v. Retrying in %v", err, delay)
+		var timer = time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// recordHarvestRun stores the outcome of harvesting a source. Failing to do so isn't
+// worth aborting the harvest for, so errors are only logged.
+func (this *Harvester) recordHarvestRun(run *drivers.HarvestRun) {
+	this.storeLock.Lock()
+	defer this.storeLock.Unlock()
+
+	if err := this.persistence.StoreHarvestRun(run); err != nil {
+		log.Errorf("Could not record the harvest of source '%s': %v", run.Source, err)
+	}
+}
@@ -1,16 +1,21 @@
 package reddit
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"github.com/coverprice/contentscraper/config"
 	"github.com/coverprice/contentscraper/database"
+	"github.com/coverprice/contentscraper/drivers"
 	persist "github.com/coverprice/contentscraper/drivers/reddit/persistence"
 	scrape "github.com/coverprice/contentscraper/drivers/reddit/scraper"
 	"github.com/coverprice/contentscraper/drivers/reddit/types"
+	"github.com/coverprice/contentscraper/toolbox"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
 	"testing"
+	"time"
 )
 
 func TestHarvesterRetrievesAndStoresPosts(t *testing.T) {
@@ -29,15 +34,18 @@ func TestHarvesterRetrievesAndStoresPosts(t *testing.T) {
 		Subreddits: []config.Subreddit{
 			config.Subreddit{
 				Name:          "funny",
+				Listings:      []string{"hot"},
 				Percentile:    100.0,
 				MaxDailyPosts: 20,
 			},
 		},
 	})
 
+	defer delete(types.FeedRegistry, "test feed")
+
 	// run the harvester
 	t.Log("Beginning harvest")
-	if err := harvester.Harvest(); err != nil {
+	if err := harvester.Harvest(context.Background()); err != nil {
 		t.Error("Harvest() failed: ", err)
 	}
 	t.Log("Harvest complete")
@@ -53,6 +61,200 @@ func TestHarvesterRetrievesAndStoresPosts(t *testing.T) {
 	}
 	require.NotEqual(t, 0, cnt, "Gathered no posts")
 	t.Logf("Harvested %d posts", cnt)
+
+	// verify that the harvest was recorded
+	err = testDb.DbConn.QueryRow(
+		`SELECT COUNT(*)
+         FROM redditharvest
+         WHERE subreddit_name = 'funny'
+           AND error = ''`,
+	).Scan(&cnt)
+	require.Nil(t, err, "Could not retrieve the count of harvest runs")
+	require.Equal(t, 1, cnt, "Harvest was not recorded")
+}
+
+// Harvests recorded responses from a fake Reddit server, so it runs without a network connection
+// or Reddit credentials.
+func TestHarvesterHarvestsFromFakeReddit(t *testing.T) {
+	testDb, err := database.NewTestDatabase()
+	if err != nil {
+		t.Fatal("Could not init database", err)
+	}
+	defer testDb.Cleanup()
+
+	fakeReddit := scrape.NewFakeRedditServer("../scraper/testdata")
+	defer fakeReddit.Close()
+
+	persistence, err := persist.NewPersistence(testDb.DbConn)
+	require.Nil(t, err, "Could not initialize persistence layer")
+	harvester, err := NewHarvester(scrape.NewJsonScraper(fakeReddit.URL), persistence)
+	require.Nil(t, err, "Could not initialize Harvester")
+	harvester.MinPostsPerScrape = 2
+	harvester.RateLimiter = toolbox.NewRateLimiter(0)
+
+	types.FeedRegistry.AddItem(&config.RedditFeed{
+		Name: "offline feed",
+		Subreddits: []config.Subreddit{
+			config.Subreddit{
+				Name:          "testsub",
+				Listings:      []string{"new"},
+				Percentile:    100.0,
+				MaxDailyPosts: 20,
+			},
+		},
+	})
+	defer delete(types.FeedRegistry, "offline feed")
+
+	// The listing's 3 pages are pulled. The last has fewer than MinPostsPerScrape posts.
+	err = harvester.Harvest(context.Background())
+	require.Nil(t, err, "Harvest() failed")
+
+	var cnt int
+	err = testDb.DbConn.QueryRow(`SELECT COUNT(*) FROM redditpost WHERE subreddit_name = 'testsub'`).Scan(&cnt)
+	require.Nil(t, err, "Could not retrieve the count of posts")
+	require.Equal(t, 6, cnt)
+
+	posts, err := persistence.GetPostsForSource(0, "testsub", 0)
+	require.Nil(t, err, "Could not retrieve posts")
+	require.Equal(t, 6, len(posts))
+
+	// Each post's score was recorded
+	history, err := persistence.GetScoreHistory("p2", "t5_test")
+	require.Nil(t, err, "Could not retrieve score history")
+	require.Equal(t, 1, len(history))
+	require.Equal(t, int64(20), history[0].Score)
+	require.Equal(t, int64(3), history[0].NumComments)
+
+	feed, _ := types.FeedRegistry.GetItemByName("offline feed")
+	status, _ := feed.GetHarvestState()
+	require.Equal(t, drivers.FEEDHARVESTSTATUS_IDLE, status)
+
+	// Harvesting again only pulls the 1st page, since none of its posts are new.
+	err = harvester.Harvest(context.Background())
+	require.Nil(t, err, "Harvest() failed")
+
+	var pagesScraped, postsNew, postsUpdated int
+	rows, err := testDb.DbConn.Query(
+		`SELECT pages_scraped, posts_new, posts_updated
+         FROM redditharvest
+         WHERE subreddit_name = 'testsub'
+           AND error = ''
+         ORDER BY time_started, rowid`,
+	)
+	require.Nil(t, err, "Could not retrieve the harvest runs")
+	defer rows.Close()
+	var runs [][]int
+	for rows.Next() {
+		require.Nil(t, rows.Scan(&pagesScraped, &postsNew, &postsUpdated))
+		runs = append(runs, []int{pagesScraped, postsNew, postsUpdated})
+	}
+	require.Equal(t, [][]int{{3, 6, 0}, {1, 0, 3}}, runs)
+}
+
+// flakyScraper fails the first numFailures requests with the given error.
+type flakyScraper struct {
+	scrape.IScraper
+	numFailures int
+	err         error
+	numRequests int
+}
+
+func (this *flakyScraper) GetNextResults(ctx context.Context, scrapeContext *scrape.Context) ([]types.RedditPost, error) {
+	this.numRequests++
+	if this.numRequests <= this.numFailures {
+		return nil, this.err
+	}
+	return this.IScraper.GetNextResults(ctx, scrapeContext)
+}
+
+func TestHarvesterRetriesTransientFailures(t *testing.T) {
+	testDb, err := database.NewTestDatabase()
+	if err != nil {
+		t.Fatal("Could not init database", err)
+	}
+	defer testDb.Cleanup()
+
+	fakeReddit := scrape.NewFakeRedditServer("../scraper/testdata")
+	defer fakeReddit.Close()
+
+	persistence, err := persist.NewPersistence(testDb.DbConn)
+	require.Nil(t, err, "Could not initialize persistence layer")
+	scraper := &flakyScraper{
+		IScraper:    scrape.NewJsonScraper(fakeReddit.URL),
+		numFailures: 2,
+		err:         &scrape.TransientError{Err: fmt.Errorf("503 Service Unavailable")},
+	}
+	harvester, err := NewHarvester(scraper, persistence)
+	require.Nil(t, err, "Could not initialize Harvester")
+	harvester.MinPostsPerScrape = 2
+	harvester.RateLimiter = toolbox.NewRateLimiter(0)
+	harvester.MaxRetries = 2
+	harvester.RetryBaseDelay = time.Millisecond
+
+	types.FeedRegistry.AddItem(&config.RedditFeed{
+		Name: "flaky feed",
+		Subreddits: []config.Subreddit{
+			config.Subreddit{Name: "testsub", Listings: []string{"new"}, Percentile: 100.0},
+		},
+	})
+	defer delete(types.FeedRegistry, "flaky feed")
+	feed, _ := types.FeedRegistry.GetItemByName("flaky feed")
+
+	// The 2 failures are retried, and the harvest succeeds.
+	err = harvester.Harvest(context.Background())
+	require.Nil(t, err, "Harvest() failed")
+	require.Equal(t, 5, scraper.numRequests) // 2 failures + 3 pages
+	status, _ := feed.GetHarvestState()
+	require.Equal(t, drivers.FEEDHARVESTSTATUS_IDLE, status)
+
+	// Giving up after MaxRetries
+	scraper.numRequests = 0
+	harvester.MaxRetries = 1
+	err = harvester.Harvest(context.Background())
+	require.Nil(t, err, "Harvest() failed")
+	require.Equal(t, 2, scraper.numRequests)
+	status, _ = feed.GetHarvestState()
+	require.Equal(t, drivers.FEEDHARVESTSTATUS_ERROR, status)
+
+	// Other failures aren't retried
+	scraper.numRequests = 0
+	scraper.err = fmt.Errorf("404 Not Found")
+	err = harvester.Harvest(context.Background())
+	require.Nil(t, err, "Harvest() failed")
+	require.Equal(t, 1, scraper.numRequests)
+}
+
+func TestHarvesterAbandonsRequestsWhenCancelled(t *testing.T) {
+	testDb, err := database.NewTestDatabase()
+	if err != nil {
+		t.Fatal("Could not init database", err)
+	}
+	defer testDb.Cleanup()
+
+	// Reddit never responds in time
+	fakeReddit := scrape.NewSlowFakeRedditServer("../scraper/testdata", time.Minute)
+	defer fakeReddit.Close()
+
+	persistence, err := persist.NewPersistence(testDb.DbConn)
+	require.Nil(t, err, "Could not initialize persistence layer")
+	harvester, err := NewHarvester(scrape.NewJsonScraper(fakeReddit.URL), persistence)
+	require.Nil(t, err, "Could not initialize Harvester")
+	harvester.RateLimiter = toolbox.NewRateLimiter(0)
+
+	types.FeedRegistry.AddItem(&config.RedditFeed{
+		Name: "slow feed",
+		Subreddits: []config.Subreddit{
+			config.Subreddit{Name: "testsub", Listings: []string{"new"}, Percentile: 100.0},
+		},
+	})
+	defer delete(types.FeedRegistry, "slow feed")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	var started = time.Now()
+	err = harvester.Harvest(ctx)
+	require.Equal(t, context.Canceled, err)
+	require.True(t, time.Since(started) < 10*time.Second, "Harvest() waited for the request to finish")
 }
 
 func getSut(t *testing.T, dbconn *sql.DB) *Harvester {
@@ -60,11 +262,11 @@ func getSut(t *testing.T, dbconn *sql.DB) *Harvester {
 	var err error
 
 	if conf, err = config.GetConfig(); err != nil {
-		t.Error("Could not load/parse config file: ", err)
+		t.Fatal("Could not load/parse config file: ", err)
 	}
 
-	var scraper *scrape.Scraper
-	if scraper, err = scrape.NewScraperFromConfig(conf); err != nil {
+	var scraper scrape.IScraper
+	if scraper, err = scrape.NewScraperFromConfig(conf, nil); err != nil {
 		t.Error("Could not initialize scraper: ", err)
 	}
 
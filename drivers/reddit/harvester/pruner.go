@@ -0,0 +1,120 @@
+package reddit
+
+import (
+	"github.com/coverprice/contentscraper/drivers"
+	persist "github.com/coverprice/contentscraper/drivers/reddit/persistence"
+	"github.com/coverprice/contentscraper/drivers/reddit/types"
+	log "github.com/sirupsen/logrus"
+	"sync"
+)
+
+const secondsPerDay = 24 * 60 * 60
+
+// Pruner deletes the posts that haven't been harvested within the retention period of the feeds
+// they belong to (see config.RedditFeed.RetentionDays), so that the database doesn't grow forever.
+// A post that belongs to several feeds is kept for the longest of their retention periods. Posts
+// that are stickied, or that have been starred, are never deleted.
+type Pruner struct {
+	persistence *persist.Persistence
+	// The retention period of posts that don't belong to any feed, e.g. from a subreddit that was
+	// removed from the config. (0 = forever). It's guarded by the lock, since the config can be
+	// reloaded during a harvest.
+	retentionDays int
+	lock          sync.RWMutex
+}
+
+func NewPruner(persistence *persist.Persistence, retentionDays int) *Pruner {
+	return &Pruner{
+		persistence:   persistence,
+		retentionDays: retentionDays,
+	}
+}
+
+// SetRetentionDays changes the retention period of posts that don't belong to any feed.
+func (this *Pruner) SetRetentionDays(retentionDays int) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.retentionDays = retentionDays
+}
+
+func (this *Pruner) getRetentionDays() int {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	return this.retentionDays
+}
+
+// longerRetention returns the longer of two retention periods, where 0 means forever.
+func longerRetention(a, b int) int {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Prune deletes the posts whose retention period has passed, as of now. If dryRun is true, nothing
+// is deleted, but the report describes what would have been.
+func (this *Pruner) Prune(now int64, dryRun bool) (report drivers.PruneReport, err error) {
+	report.PostsBySource = make(map[string]int)
+
+	// Source key -> the longest retention period of the feeds that include it
+	var retentionBySource = make(map[string]int)
+	var retentionDays = this.getRetentionDays()
+	var minRetentionDays = retentionDays
+	for _, feed := range types.FeedRegistry.GetAllItems() {
+		var days = feed.RedditFeed.RetentionDays
+		for _, subreddit := range feed.RedditFeed.Subreddits {
+			var sourceKey = subreddit.SourceKey()
+			if sourceDays, ok := retentionBySource[sourceKey]; ok {
+				retentionBySource[sourceKey] = longerRetention(sourceDays, days)
+			} else {
+				retentionBySource[sourceKey] = days
+			}
+		}
+		if minRetentionDays == 0 || (days != 0 && days < minRetentionDays) {
+			minRetentionDays = days
+		}
+	}
+	if minRetentionDays == 0 {
+		// Everything is kept forever.
+		return
+	}
+
+	// Only the posts older than the shortest retention period can be deleted.
+	var candidates []types.PruneCandidate
+	if candidates, err = this.persistence.GetPruneCandidates(now - int64(minRetentionDays)*secondsPerDay); err != nil {
+		return
+	}
+	var keys []types.PostKey
+	for _, candidate := range candidates {
+		var days, isInFeed = retentionBySource[candidate.SubredditName]
+		for _, sourceKey := range candidate.SourceKeys {
+			if sourceDays, ok := retentionBySource[sourceKey]; ok {
+				if isInFeed {
+					days = longerRetention(days, sourceDays)
+				} else {
+					days = sourceDays
+				}
+				isInFeed = true
+			}
+		}
+		if !isInFeed {
+			days = retentionDays
+		}
+		if days == 0 || candidate.TimeLastSeen >= now-int64(days)*secondsPerDay {
+			continue
+		}
+		keys = append(keys, candidate.PostKey)
+		report.NumPosts++
+		report.PostsBySource[candidate.SubredditName]++
+	}
+
+	if dryRun || len(keys) == 0 {
+		return
+	}
+	log.Infof("Pruning %d Reddit posts", len(keys))
+	err = this.persistence.DeletePosts(keys)
+	return
+}
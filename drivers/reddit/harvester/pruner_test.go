@@ -0,0 +1,99 @@
+package reddit
+
+import (
+	"github.com/coverprice/contentscraper/config"
+	"github.com/coverprice/contentscraper/database"
+	"github.com/coverprice/contentscraper/drivers"
+	persist "github.com/coverprice/contentscraper/drivers/reddit/persistence"
+	"github.com/coverprice/contentscraper/drivers/reddit/types"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestPrunerHonoursFeedRetention(t *testing.T) {
+	testDb, err := database.NewTestDatabase()
+	if err != nil {
+		t.Fatal("Could not init database", err)
+	}
+	defer testDb.Cleanup()
+
+	persistence, err := persist.NewPersistence(testDb.DbConn)
+	require.Nil(t, err, "Could not create persistence layer")
+
+	const now = int64(100 * secondsPerDay)
+	storePost := func(id, subredditName string, daysOld int64) types.RedditPost {
+		post := types.RedditPost{
+			Id:            id,
+			Name:          "t3_" + id,
+			TimeCreated:   now - daysOld*secondsPerDay,
+			TimeStored:    now - daysOld*secondsPerDay,
+			Permalink:     "/r/" + subredditName + "/" + id,
+			IsActive:      true,
+			Title:         "Post " + id,
+			SubredditName: subredditName,
+			SubredditId:   "t5_" + subredditName,
+		}
+		_, err := persistence.StorePost(&post)
+		require.Nil(t, err, "Could not store post")
+		return post
+	}
+	storePost("short_old", "shortlived", 10)
+	storePost("short_new", "shortlived", 5)
+	storePost("long_old", "longlived", 10)
+	// Kept for the longer retention of the multireddit's feed
+	multiPost := storePost("multi_old", "shortlived", 10)
+	require.Nil(t, persistence.AddPostToSource("multireddit:someone/multi", &multiPost))
+	storePost("forever_old", "forever", 60)
+	// Not in any feed, so the global retention period applies
+	storePost("orphan_old", "removed", 40)
+	storePost("orphan_new", "removed", 20)
+
+	for _, feed := range []*config.RedditFeed{
+		&config.RedditFeed{
+			Name:          "prune short",
+			RetentionDays: 7,
+			Subreddits:    []config.Subreddit{config.Subreddit{Name: "shortlived"}},
+		},
+		&config.RedditFeed{
+			Name:          "prune long",
+			RetentionDays: 30,
+			Subreddits: []config.Subreddit{
+				config.Subreddit{Name: "longlived"},
+				config.Subreddit{Multireddit: "someone/multi"},
+			},
+		},
+		&config.RedditFeed{
+			Name:       "prune forever",
+			Subreddits: []config.Subreddit{config.Subreddit{Name: "forever"}},
+		},
+	} {
+		types.FeedRegistry.AddItem(feed)
+		defer delete(types.FeedRegistry, feed.Name)
+	}
+
+	pruner := NewPruner(persistence, 30)
+	expected := drivers.PruneReport{
+		NumPosts:      2,
+		PostsBySource: map[string]int{"shortlived": 1, "removed": 1},
+	}
+	report, err := pruner.Prune(now, true)
+	require.Nil(t, err, "Could not prune posts")
+	require.Equal(t, expected, report)
+	post, err := persistence.GetPost(types.PostKey{Id: "short_old", SubredditId: "t5_shortlived"})
+	require.Nil(t, err, "Could not retrieve post")
+	require.NotNil(t, post, "A dry run should not delete posts")
+
+	report, err = pruner.Prune(now, false)
+	require.Nil(t, err, "Could not prune posts")
+	require.Equal(t, expected, report)
+	var ids []string
+	rows, err := testDb.DbConn.Query(`SELECT id FROM redditpost ORDER BY id`)
+	require.Nil(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		require.Nil(t, rows.Scan(&id))
+		ids = append(ids, id)
+	}
+	require.Equal(t, []string{"forever_old", "long_old", "multi_old", "orphan_new", "short_new"}, ids)
+}
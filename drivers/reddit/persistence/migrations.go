@@ -0,0 +1,208 @@
+package persistence
+
+import (
+	"github.com/coverprice/contentscraper/database"
+)
+
+// The name under which this package's migrations are recorded in the schema_version table.
+const MIGRATION_COMPONENT = "reddit"
+
+// The ordered list of changes to the Reddit driver's tables.
+var migrations = []database.Migration{
+	database.Migration{
+		Version:     1,
+		Description: "Create redditpost table",
+		Sql: `
+        CREATE TABLE IF NOT EXISTS redditpost
+            ( id TEXT
+            , name TEXT NOT NULL
+            , permalink TEXT NOT NULL
+            , time_created INTEGER NOT NULL
+            , time_stored INTEGER NOT NULL
+            , is_active INTEGER NOT NULL
+            , is_sticky INTEGER NOT NULL
+            , score INTEGER NOT NULL
+            , title TEXT NOT NULL
+            , url TEXT
+            , subreddit_name TEXT NOT NULL
+            , subreddit_id TEXT NOT NULL
+            , PRIMARY KEY (id, subreddit_id)
+        ) WITHOUT ROWID
+        ;
+        CREATE INDEX IF NOT EXISTS
+            reddit_subreddit_name ON redditpost(subreddit_name)
+        ;
+        CREATE INDEX IF NOT EXISTS
+            reddit_url ON redditpost(url)
+        ;
+        CREATE INDEX IF NOT EXISTS
+            reddit_time_created ON redditpost(time_created)
+        ;
+        CREATE INDEX IF NOT EXISTS
+            reddit_time_stored ON redditpost(time_stored)
+        ;
+        CREATE INDEX IF NOT EXISTS
+            reddit_id ON redditpost(id)
+    `,
+	},
+	database.Migration{
+		Version:     2,
+		Description: "Create redditharvest table",
+		// One row per subreddit per harvest run.
+		Sql: `
+        CREATE TABLE redditharvest
+            ( time_run_started INTEGER NOT NULL
+            , subreddit_name TEXT NOT NULL
+            , time_started INTEGER NOT NULL
+            , time_ended INTEGER NOT NULL
+            , pages_scraped INTEGER NOT NULL
+            , posts_new INTEGER NOT NULL
+            , posts_updated INTEGER NOT NULL
+            , posts_skipped INTEGER NOT NULL
+            , error TEXT NOT NULL
+        )
+        ;
+        CREATE INDEX
+            redditharvest_subreddit_time ON redditharvest(subreddit_name, time_started)
+    `,
+	},
+	database.Migration{
+		Version:     3,
+		Description: "Create redditcomment table",
+		Sql: `
+        CREATE TABLE redditcomment
+            ( post_id TEXT NOT NULL
+            , id TEXT NOT NULL
+            , rank INTEGER NOT NULL
+            , author TEXT NOT NULL
+            , body TEXT NOT NULL
+            , score INTEGER NOT NULL
+            , time_created INTEGER NOT NULL
+            , time_stored INTEGER NOT NULL
+            , PRIMARY KEY (post_id, id)
+        ) WITHOUT ROWID
+    `,
+	},
+	database.Migration{
+		Version:     4,
+		Description: "Add self post text to redditpost",
+		Sql: `
+        ALTER TABLE redditpost ADD COLUMN selftext TEXT NOT NULL DEFAULT ''
+        ;
+        ALTER TABLE redditpost ADD COLUMN selftext_html TEXT NOT NULL DEFAULT ''
+    `,
+	},
+	database.Migration{
+		Version:     5,
+		Description: "Create redditpostsource table",
+		// Records which multireddits, users & searches each post was harvested from. (Posts
+		// belong to their subreddit's source implicitly).
+		Sql: `
+        CREATE TABLE redditpostsource
+            ( source_key TEXT NOT NULL
+            , post_id TEXT NOT NULL
+            , subreddit_id TEXT NOT NULL
+            , PRIMARY KEY (source_key, post_id, subreddit_id)
+        ) WITHOUT ROWID
+    `,
+	},
+	database.Migration{
+		Version:     6,
+		Description: "Create redditpostscore table",
+		// A snapshot of a post's score (and # of comments) each time it was harvested, so that
+		// how quickly it gained upvotes can be calculated.
+		Sql: `
+        ALTER TABLE redditpost ADD COLUMN num_comments INTEGER NOT NULL DEFAULT 0
+        ;
+        CREATE TABLE redditpostscore
+            ( post_id TEXT NOT NULL
+            , subreddit_id TEXT NOT NULL
+            , time_observed INTEGER NOT NULL
+            , score INTEGER NOT NULL
+            , num_comments INTEGER NOT NULL
+            , PRIMARY KEY (post_id, subreddit_id, time_observed)
+        ) WITHOUT ROWID
+    `,
+	},
+	database.Migration{
+		Version:     7,
+		Description: "Add author, link_flair, is_nsfw & is_spoiler columns to redditpost",
+		// Used by the feeds' include & exclude rules.
+		Sql: `
+        ALTER TABLE redditpost ADD COLUMN author TEXT NOT NULL DEFAULT ''
+        ;
+        ALTER TABLE redditpost ADD COLUMN link_flair TEXT NOT NULL DEFAULT ''
+        ;
+        ALTER TABLE redditpost ADD COLUMN is_nsfw INTEGER NOT NULL DEFAULT 0
+        ;
+        ALTER TABLE redditpost ADD COLUMN is_spoiler INTEGER NOT NULL DEFAULT 0
+    `,
+	},
+	database.Migration{
+		Version:     8,
+		Description: "Create redditseen table",
+		// Which posts each viewer has been shown, and which they've read.
+		Sql: `
+        CREATE TABLE redditseen
+            ( viewer_id TEXT NOT NULL
+            , post_id TEXT NOT NULL
+            , subreddit_id TEXT NOT NULL
+            , time_shown INTEGER NOT NULL
+            , time_read INTEGER NOT NULL
+            , PRIMARY KEY (viewer_id, post_id, subreddit_id)
+        ) WITHOUT ROWID
+    `,
+	},
+	database.Migration{
+		Version:     9,
+		Description: "Create redditstar table",
+		// The posts each viewer has starred. The posts are copied, so that they're kept after
+		// they've been removed from the redditpost table.
+		Sql: `
+        CREATE TABLE redditstar
+            ( viewer_id TEXT NOT NULL
+            , post_id TEXT NOT NULL
+            , subreddit_id TEXT NOT NULL
+            , feed_name TEXT NOT NULL
+            , title TEXT NOT NULL
+            , url TEXT NOT NULL
+            , permalink TEXT NOT NULL
+            , subreddit_name TEXT NOT NULL
+            , score INTEGER NOT NULL
+            , time_created INTEGER NOT NULL
+            , time_starred INTEGER NOT NULL
+            , PRIMARY KEY (viewer_id, post_id, subreddit_id)
+        ) WITHOUT ROWID
+    `,
+	},
+	database.Migration{
+		Version:     10,
+		Description: "Create redditpostsearch full-text index",
+		// A full-text index of the posts' titles and text. (The bundled SQLite predates FTS5,
+		// so it's an FTS4 table). FTS tables are keyed by an integer docid, so
+		// redditpostsearchkey maps each post to its docid.
+		Sql: `
+        CREATE TABLE redditpostsearchkey
+            ( docid INTEGER PRIMARY KEY
+            , post_id TEXT NOT NULL
+            , subreddit_id TEXT NOT NULL
+            , UNIQUE (post_id, subreddit_id)
+        )
+        ;
+        INSERT INTO redditpostsearchkey (post_id, subreddit_id)
+            SELECT id, subreddit_id
+            FROM redditpost
+        ;
+        CREATE VIRTUAL TABLE redditpostsearch USING fts4(title, body, tokenize=porter)
+        ;
+        INSERT INTO redditpostsearch (docid, title, body)
+            SELECT k.docid, p.title, p.selftext
+            FROM redditpostsearchkey k
+            JOIN redditpost p ON p.id = k.post_id AND p.subreddit_id = k.subreddit_id
+    `,
+	},
+}
+
+func init() {
+	database.RegisterMigrations(MIGRATION_COMPONENT, migrations)
+}
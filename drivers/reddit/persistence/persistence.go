@@ -3,8 +3,11 @@ package persistence
 import (
 	"database/sql"
 	"fmt"
+	"github.com/coverprice/contentscraper/database"
+	"github.com/coverprice/contentscraper/drivers"
 	"github.com/coverprice/contentscraper/drivers/reddit/types"
 	log "github.com/sirupsen/logrus"
+	"math"
 	"strings"
 )
 
@@ -47,39 +50,7 @@ func NewPersistence(dbconn *sql.DB) (persistence *Persistence, err error) {
 // TODO: Investigate whether this code could be replaced by an ORM framework
 
 func (this *Persistence) initTables() (err error) {
-	_, err = this.dbconn.Exec(`
-        CREATE TABLE IF NOT EXISTS redditpost
-            ( id TEXT
-            , name TEXT NOT NULL
-            , permalink TEXT NOT NULL
-            , time_created INTEGER NOT NULL
-            , time_stored INTEGER NOT NULL
-            , is_active INTEGER NOT NULL
-            , is_sticky INTEGER NOT NULL
-            , score INTEGER NOT NULL
-            , title TEXT NOT NULL
-            , url TEXT
-            , subreddit_name TEXT NOT NULL
-            , subreddit_id TEXT NOT NULL
-            , PRIMARY KEY (id, subreddit_id)
-        ) WITHOUT ROWID
-        ;
-        CREATE INDEX IF NOT EXISTS
-            reddit_subreddit_name ON redditpost(subreddit_name)
-        ;
-        CREATE INDEX IF NOT EXISTS
-            reddit_url ON redditpost(url)
-        ;
-        CREATE INDEX IF NOT EXISTS
-            reddit_time_created ON redditpost(time_created)
-        ;
-        CREATE INDEX IF NOT EXISTS
-            reddit_time_stored ON redditpost(time_stored)
-        ;
-        CREATE INDEX IF NOT EXISTS
-            reddit_id ON redditpost(id)
-    `)
-	return
+	return database.Migrate(this.dbconn, MIGRATION_COMPONENT)
 }
 
 // Stores/Updates a RedditPost and returns whether it was a store or an
@@ -130,7 +101,17 @@ func (this *Persistence) StorePost(
 }
 
 func (this *Persistence) insertPost(post *types.RedditPost) (err error) {
-	_, err = this.dbconn.Exec(`
+	var tx *sql.Tx
+	if tx, err = this.dbconn.Begin(); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	_, err = tx.Exec(`
         INSERT INTO redditpost
             ( id
             , name
@@ -140,10 +121,17 @@ func (this *Persistence) insertPost(post *types.RedditPost) (err error) {
             , is_active
             , is_sticky
             , score
+            , num_comments
             , title
             , url
+            , selftext
+            , selftext_html
             , subreddit_name
             , subreddit_id
+            , author
+            , link_flair
+            , is_nsfw
+            , is_spoiler
         ) VALUES
             ( $a
             , $b
@@ -157,6 +145,13 @@ func (this *Persistence) insertPost(post *types.RedditPost) (err error) {
             , $j
             , $k
             , $l
+            , $m
+            , $n
+            , $o
+            , $p
+            , $q
+            , $r
+            , $s
         )`,
 		post.Id,
 		post.Name,
@@ -166,41 +161,326 @@ func (this *Persistence) insertPost(post *types.RedditPost) (err error) {
 		post.IsActive,
 		post.IsSticky,
 		post.Score,
+		post.NumComments,
 		post.Title,
 		post.Url,
+		post.SelfText,
+		post.SelfTextHtml,
 		post.SubredditName,
 		post.SubredditId,
+		post.Author,
+		post.LinkFlair,
+		post.IsNsfw,
+		post.IsSpoiler,
 	)
-	return
+	if err != nil {
+		return
+	}
+	if err = indexPost(tx, post); err != nil {
+		return
+	}
+	return tx.Commit()
 }
 
 func (this *Persistence) updatePost(post *types.RedditPost) (err error) {
-	_, err = this.dbconn.Exec(`
+	var tx *sql.Tx
+	if tx, err = this.dbconn.Begin(); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// The post is only reindexed if its text has been edited.
+	var oldTitle, oldSelfText string
+	if err = tx.QueryRow(`
+        SELECT title, selftext
+        FROM redditpost
+        WHERE id = $a
+          AND subreddit_id = $b
+        `,
+		post.Id,
+		post.SubredditId,
+	).Scan(&oldTitle, &oldSelfText); err != nil {
+		return
+	}
+	_, err = tx.Exec(`
         UPDATE redditpost SET
              name = $a
             , permalink = $b
             , is_active = $c
             , is_sticky = $d
             , score = $e
-            , title = $f
-            , url = $g
-        WHERE id = $i
-          AND subreddit_id = $j
+            , num_comments = $f
+            , title = $g
+            , url = $h
+            , selftext = $i
+            , selftext_html = $j
+            , author = $k
+            , link_flair = $l
+            , is_nsfw = $m
+            , is_spoiler = $n
+        WHERE id = $o
+          AND subreddit_id = $p
         `,
 		post.Name,
 		post.Permalink,
 		post.IsActive,
 		post.IsSticky,
 		post.Score,
+		post.NumComments,
+		post.Title,
+		post.Url,
+		post.SelfText,
+		post.SelfTextHtml,
+		post.Author,
+		post.LinkFlair,
+		post.IsNsfw,
+		post.IsSpoiler,
+
+		post.Id,
+		post.SubredditId,
+	)
+	if err != nil {
+		return
+	}
+	if post.Title != oldTitle || post.SelfText != oldSelfText {
+		if err = indexPost(tx, post); err != nil {
+			return
+		}
+	}
+	return tx.Commit()
+}
+
+// indexPost adds the post's title and text to the redditpostsearch full-text index, replacing
+// any previous entry for the post. It's run in the same transaction as the change to the post.
+func indexPost(tx *sql.Tx, post *types.RedditPost) (err error) {
+	if _, err = tx.Exec(`
+        INSERT OR IGNORE INTO redditpostsearchkey (post_id, subreddit_id)
+        VALUES ($a, $b)
+        `,
+		post.Id,
+		post.SubredditId,
+	); err != nil {
+		return
+	}
+	// FTS tables don't enforce uniqueness, so the old entry is deleted first.
+	if _, err = tx.Exec(`
+        DELETE FROM redditpostsearch
+        WHERE docid = (`+searchDocIdQuery+`)
+        `,
+		post.Id,
+		post.SubredditId,
+	); err != nil {
+		return
+	}
+	_, err = tx.Exec(`
+        INSERT INTO redditpostsearch (docid, title, body)
+        VALUES ((`+searchDocIdQuery+`), $c, $d)
+        `,
+		post.Id,
+		post.SubredditId,
 		post.Title,
+		post.SelfText,
+	)
+	return
+}
+
+// searchDocIdQuery selects the redditpostsearch docid of the post given by the $a (ID) and $b
+// (subreddit ID) parameters.
+const searchDocIdQuery = `
+            SELECT docid
+            FROM redditpostsearchkey
+            WHERE post_id = $a
+              AND subreddit_id = $b`
+
+// AddPostToSource records that the (already stored) post was harvested from the source, which
+// must not be the post's own subreddit. (See config.Subreddit.SourceKey).
+func (this *Persistence) AddPostToSource(sourceKey string, post *types.RedditPost) (err error) {
+	_, err = this.dbconn.Exec(`
+        INSERT OR IGNORE INTO redditpostsource
+            ( source_key
+            , post_id
+            , subreddit_id
+        ) VALUES
+            ( $a
+            , $b
+            , $c
+        )`,
+		sourceKey,
+		post.Id,
+		post.SubredditId,
+	)
+	return
+}
+
+// AddDuplicatePostToSource records that the post was harvested from the source, but was skipped
+// because another post with the same URL was already stored. (See StorePost). The stored post is
+// linked to the source instead, unless it already belongs to it through its subreddit.
+func (this *Persistence) AddDuplicatePostToSource(sourceKey string, post *types.RedditPost) (err error) {
+	_, err = this.dbconn.Exec(`
+        INSERT OR IGNORE INTO redditpostsource
+            ( source_key
+            , post_id
+            , subreddit_id
+        )
+        SELECT $a, id, subreddit_id
+        FROM redditpost
+        WHERE url = $b
+          AND subreddit_name != $a
+        LIMIT 1`,
+		sourceKey,
 		post.Url,
+	)
+	return
+}
 
+// RecordScore adds the post's current score and # of comments to its score history.
+func (this *Persistence) RecordScore(post *types.RedditPost, timeObserved int64) (err error) {
+	_, err = this.dbconn.Exec(`
+        INSERT OR REPLACE INTO redditpostscore
+            ( post_id
+            , subreddit_id
+            , time_observed
+            , score
+            , num_comments
+        ) VALUES
+            ( $a
+            , $b
+            , $c
+            , $d
+            , $e
+        )`,
 		post.Id,
 		post.SubredditId,
+		timeObserved,
+		post.Score,
+		post.NumComments,
 	)
 	return
 }
 
+// GetScoreHistory returns the snapshots of the post's score, oldest first.
+func (this *Persistence) GetScoreHistory(postId, subredditId string) (history []types.ScoreSnapshot, err error) {
+	var rows *sql.Rows
+	rows, err = this.dbconn.Query(`
+        SELECT
+            post_id
+            , subreddit_id
+            , time_observed
+            , score
+            , num_comments
+        FROM redditpostscore
+        WHERE post_id = $a
+          AND subreddit_id = $b
+        ORDER BY time_observed
+        `,
+		postId,
+		subredditId,
+	)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var snapshot types.ScoreSnapshot
+		if err = rows.Scan(
+			&snapshot.PostId,
+			&snapshot.SubredditId,
+			&snapshot.TimeObserved,
+			&snapshot.Score,
+			&snapshot.NumComments,
+		); err != nil {
+			return
+		}
+		history = append(history, snapshot)
+	}
+	return history, rows.Err()
+}
+
+// A post's velocity is never measured over less than this many seconds, so that a post that
+// happened to be observed minutes after it was created doesn't get an extreme velocity.
+const MIN_VELOCITY_PERIOD = 60 * 60
+
+// GetScoreVelocities returns the score velocity (upvotes per hour) of the source's active posts
+// stored at or after minTime, keyed by post ID. A post's velocity is measured from the last
+// snapshot of its score within the first windowSeconds after it was created, so it reflects how
+// quickly the post took off rather than how popular it eventually became. Posts that weren't
+// observed within that window are omitted.
+func (this *Persistence) GetScoreVelocities(
+	minTime int64,
+	sourceKey string,
+	windowSeconds int64,
+) (velocities map[string]float64, err error) {
+	var rows *sql.Rows
+	rows, err = this.dbconn.Query(`
+        SELECT
+            redditpost.id
+            , redditpostscore.time_observed - redditpost.time_created
+            , redditpostscore.score
+        FROM redditpost
+        JOIN redditpostscore
+            ON redditpostscore.post_id = redditpost.id
+           AND redditpostscore.subreddit_id = redditpost.subreddit_id
+        WHERE `+sourceCriteria("$a")+`
+          AND redditpost.time_stored >= $b
+          AND redditpost.is_active = 1
+          AND redditpostscore.time_observed = (
+            SELECT MAX(time_observed)
+            FROM redditpostscore AS earlier
+            WHERE earlier.post_id = redditpost.id
+              AND earlier.subreddit_id = redditpost.subreddit_id
+              AND earlier.time_observed <= redditpost.time_created + $c
+          )
+        `,
+		sourceKey,
+		minTime,
+		windowSeconds,
+	)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	velocities = make(map[string]float64)
+	for rows.Next() {
+		var postId string
+		var age, score int64
+		if err = rows.Scan(&postId, &age, &score); err != nil {
+			return
+		}
+		if age < MIN_VELOCITY_PERIOD {
+			age = MIN_VELOCITY_PERIOD
+		}
+		velocities[postId] = float64(score) * 60 * 60 / float64(age)
+	}
+	return velocities, rows.Err()
+}
+
+// sourceCriteria is an SQL condition that selects the redditposts that belong to the source
+// given by the parameter. A post belongs to its subreddit's source, and to any other sources
+// it was harvested from. Both sides of the OR are indexed lookups on redditpost, so SQLite
+// evaluates it as the union of the two rather than scanning the table.
+func sourceCriteria(param string) string {
+	return fmt.Sprintf(`
+        (redditpost.subreddit_name = %[1]s
+         OR (redditpost.id IN (
+                SELECT post_id
+                FROM redditpostsource
+                WHERE source_key = %[1]s
+             )
+             AND redditpost.subreddit_id IN (
+                SELECT subreddit_id
+                FROM redditpostsource
+                WHERE source_key = %[1]s
+                  AND post_id = redditpost.id
+             )
+        ))`, param)
+}
+
 // TODO: this should not be public.
 func (this *Persistence) GetPosts(
 	where_clause string,
@@ -217,10 +497,17 @@ func (this *Persistence) GetPosts(
             , is_active
             , is_sticky
             , score
+            , num_comments
             , title
             , url
+            , selftext
+            , selftext_html
             , subreddit_name
             , subreddit_id
+            , author
+            , link_flair
+            , is_nsfw
+            , is_spoiler
         FROM redditpost
         ` + where_clause
 	if rows, err = this.dbconn.Query(sql, params...); err != nil {
@@ -241,10 +528,17 @@ func (this *Persistence) GetPosts(
 			&redditPost.IsActive,
 			&redditPost.IsSticky,
 			&redditPost.Score,
+			&redditPost.NumComments,
 			&redditPost.Title,
 			&redditPost.Url,
+			&redditPost.SelfText,
+			&redditPost.SelfTextHtml,
 			&redditPost.SubredditName,
 			&redditPost.SubredditId,
+			&redditPost.Author,
+			&redditPost.LinkFlair,
+			&redditPost.IsNsfw,
+			&redditPost.IsSpoiler,
 		)
 		if err != nil {
 			return
@@ -262,17 +556,17 @@ func (this *Persistence) GetPosts(
 	return posts, nil
 }
 
-// Gets the score of the Redditpost at the given percentile (where 100% means all posts,
-// 90% means 90% of posts, etc.
+// Gets the score of the source's Redditposts at the given percentile (where 100% means all
+// posts, 90% means 90% of posts, etc.
 func (this *Persistence) GetScoreAtPercentile(
 	minTime int64,
-	subredditName string,
+	sourceKey string,
 	percentile float64,
 ) (score int, err error) {
 	sql := `
         SELECT COUNT(*) AS cnt
         FROM redditpost
-        WHERE subreddit_name = $a
+        WHERE ` + sourceCriteria("$a") + `
           AND time_stored >= $b
           AND is_active = 1
     `
@@ -284,7 +578,7 @@ func (this *Persistence) GetScoreAtPercentile(
 	}
 
 	var cnt int
-	if err = this.dbconn.QueryRow(sql, subredditName, minTime).Scan(&cnt); err != nil {
+	if err = this.dbconn.QueryRow(sql, sourceKey, minTime).Scan(&cnt); err != nil {
 		return
 	}
 	if cnt == 0 {
@@ -295,7 +589,7 @@ func (this *Persistence) GetScoreAtPercentile(
 	sql = `
         SELECT score
         FROM redditpost
-        WHERE subreddit_name = $a
+        WHERE ` + sourceCriteria("$a") + `
           AND time_stored >= $b
           AND is_active = 1
         ORDER BY score DESC
@@ -303,28 +597,689 @@ func (this *Persistence) GetScoreAtPercentile(
         OFFSET $c
     `
 	var offsetRows = int(percentile * float64(cnt) / 100.0)
-	err = this.dbconn.QueryRow(sql, subredditName, minTime, offsetRows).Scan(&score)
-	log.Debugf("Subreddit %s has a %.0f percentile score of %d over %d records", subredditName, percentile, score, cnt)
+	err = this.dbconn.QueryRow(sql, sourceKey, minTime, offsetRows).Scan(&score)
+	log.Debugf("Source %s has a %.0f percentile score of %d over %d records", sourceKey, percentile, score, cnt)
 	return
 }
 
-func (this *Persistence) GetPostsForSubredditScores(
+// GetPostsForSource returns the source's active posts stored at or after minTime, that have a
+// score of at least minScore.
+func (this *Persistence) GetPostsForSource(
 	minTime int64,
-	subredditMinScores map[string]int,
+	sourceKey string,
+	minScore int,
 ) ([]types.RedditPost, error) {
-	var criteria []string
-	for subredditName, minScore := range subredditMinScores {
-		criteria = append(criteria,
-			fmt.Sprintf("(subreddit_name = '%s' AND score >= %d)", subredditName, minScore))
+	log.Debugf("Getting posts in source '%s' with minimum score: %d", sourceKey, minScore)
+	return this.GetPosts(`
+        WHERE `+sourceCriteria("$a")+`
+          AND score >= $b
+          AND time_stored >= $c
+          AND is_active = 1
+        LIMIT 3000
+        `,
+		sourceKey,
+		minScore,
+		minTime,
+	)
+}
+
+// StoreHarvestRun records the outcome of harvesting a subreddit.
+func (this *Persistence) StoreHarvestRun(run *drivers.HarvestRun) (err error) {
+	_, err = this.dbconn.Exec(`
+        INSERT INTO redditharvest
+            ( time_run_started
+            , subreddit_name
+            , time_started
+            , time_ended
+            , pages_scraped
+            , posts_new
+            , posts_updated
+            , posts_skipped
+            , error
+        ) VALUES
+            ( $a
+            , $b
+            , $c
+            , $d
+            , $e
+            , $f
+            , $g
+            , $h
+            , $i
+        )`,
+		run.TimeRunStarted,
+		run.Source,
+		run.TimeStarted,
+		run.TimeEnded,
+		run.PagesScraped,
+		run.PostsNew,
+		run.PostsUpdated,
+		run.PostsSkipped,
+		run.Error,
+	)
+	return
+}
+
+// GetHarvestRuns returns the harvests of the given subreddits that started at or after minTime,
+// most recent first.
+func (this *Persistence) GetHarvestRuns(
+	minTime int64,
+	subredditNames []string,
+) (runs []drivers.HarvestRun, err error) {
+	if len(subredditNames) == 0 {
+		return nil, nil
+	}
+	var rows *sql.Rows
+	var placeholders []string
+	var params = []interface{}{minTime}
+	for _, subredditName := range subredditNames {
+		placeholders = append(placeholders, fmt.Sprintf("$p%d", len(params)))
+		params = append(params, subredditName)
 	}
 
-	whereClause := `
-        WHERE (%s)
-          AND time_stored >= $a
+	var sql = fmt.Sprintf(`
+        SELECT
+            time_run_started
+            , subreddit_name
+            , time_started
+            , time_ended
+            , pages_scraped
+            , posts_new
+            , posts_updated
+            , posts_skipped
+            , error
+        FROM redditharvest
+        WHERE time_started >= $a
+          AND subreddit_name IN (%s)
+        ORDER BY time_started DESC
+    `, strings.Join(placeholders, ", "))
+	if rows, err = this.dbconn.Query(sql, params...); err != nil {
+		log.Errorf("Error calling SQL %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var run drivers.HarvestRun
+		err = rows.Scan(
+			&run.TimeRunStarted,
+			&run.Source,
+			&run.TimeStarted,
+			&run.TimeEnded,
+			&run.PagesScraped,
+			&run.PostsNew,
+			&run.PostsUpdated,
+			&run.PostsSkipped,
+			&run.Error,
+		)
+		if err != nil {
+			return
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// GetPostsNeedingComments returns the active posts in the source that were created at or after
+// minTimeCreated, and have a score of at least minScore.
+func (this *Persistence) GetPostsNeedingComments(
+	sourceKey string,
+	minTimeCreated int64,
+	minScore int,
+) ([]types.RedditPost, error) {
+	return this.GetPosts(`
+        WHERE `+sourceCriteria("$a")+`
+          AND time_created >= $b
+          AND score >= $c
           AND is_active = 1
-        LIMIT 3000
-    `
-	whereClause = fmt.Sprintf(whereClause, strings.Join(criteria, " OR "))
-	log.Debugf("Getting posts in subreddits with minimum scores: %s", whereClause)
-	return this.GetPosts(whereClause, minTime)
+        `,
+		sourceKey,
+		minTimeCreated,
+		minScore,
+	)
+}
+
+// ReplaceComments replaces the stored top comments of the post with the given ones.
+func (this *Persistence) ReplaceComments(postId string, comments []types.RedditComment) (err error) {
+	var tx *sql.Tx
+	if tx, err = this.dbconn.Begin(); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.Exec(`DELETE FROM redditcomment WHERE post_id = $a`, postId); err != nil {
+		return
+	}
+	for _, comment := range comments {
+		_, err = tx.Exec(`
+            INSERT INTO redditcomment
+                ( post_id
+                , id
+                , rank
+                , author
+                , body
+                , score
+                , time_created
+                , time_stored
+            ) VALUES
+                ( $a
+                , $b
+                , $c
+                , $d
+                , $e
+                , $f
+                , $g
+                , $h
+            )`,
+			postId,
+			comment.Id,
+			comment.Rank,
+			comment.Author,
+			comment.Body,
+			comment.Score,
+			comment.TimeCreated,
+			comment.TimeStored,
+		)
+		if err != nil {
+			return
+		}
+	}
+	return tx.Commit()
+}
+
+// GetCommentsForPosts returns the stored top comments of the given posts, as a map of
+// post ID -> comments in rank order.
+func (this *Persistence) GetCommentsForPosts(postIds []string) (comments map[string][]types.RedditComment, err error) {
+	comments = make(map[string][]types.RedditComment)
+	if len(postIds) == 0 {
+		return
+	}
+	var rows *sql.Rows
+	var placeholders []string
+	var params []interface{}
+	for _, postId := range postIds {
+		placeholders = append(placeholders, fmt.Sprintf("$p%d", len(params)))
+		params = append(params, postId)
+	}
+
+	var sql = fmt.Sprintf(`
+        SELECT
+            post_id
+            , id
+            , rank
+            , author
+            , body
+            , score
+            , time_created
+            , time_stored
+        FROM redditcomment
+        WHERE post_id IN (%s)
+        ORDER BY post_id, rank
+    `, strings.Join(placeholders, ", "))
+	if rows, err = this.dbconn.Query(sql, params...); err != nil {
+		log.Errorf("Error calling SQL %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var comment types.RedditComment
+		err = rows.Scan(
+			&comment.PostId,
+			&comment.Id,
+			&comment.Rank,
+			&comment.Author,
+			&comment.Body,
+			&comment.Score,
+			&comment.TimeCreated,
+			&comment.TimeStored,
+		)
+		if err != nil {
+			return nil, err
+		}
+		comments[comment.PostId] = append(comments[comment.PostId], comment)
+	}
+	return comments, rows.Err()
+}
+
+// MarkPostsShown records that the viewer was shown the posts at timeShown, unless they've been
+// shown them before.
+func (this *Persistence) MarkPostsShown(viewerId string, keys []types.PostKey, timeShown int64) (err error) {
+	return this.markPostsSeen(viewerId, keys, timeShown, false)
+}
+
+// MarkPostsRead records that the viewer read the posts at timeRead, unless they've read them
+// before.
+func (this *Persistence) MarkPostsRead(viewerId string, keys []types.PostKey, timeRead int64) (err error) {
+	return this.markPostsSeen(viewerId, keys, timeRead, true)
+}
+
+func (this *Persistence) markPostsSeen(viewerId string, keys []types.PostKey, now int64, isRead bool) (err error) {
+	var tx *sql.Tx
+	if tx, err = this.dbconn.Begin(); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var timeRead int64
+	if isRead {
+		timeRead = now
+	}
+	for _, key := range keys {
+		// (SQLite doesn't support UPSERT until v3.24)
+		if _, err = tx.Exec(`
+            INSERT OR IGNORE INTO redditseen
+                ( viewer_id
+                , post_id
+                , subreddit_id
+                , time_shown
+                , time_read
+            ) VALUES
+                ( $a
+                , $b
+                , $c
+                , $d
+                , $e
+            )`,
+			viewerId,
+			key.Id,
+			key.SubredditId,
+			now,
+			timeRead,
+		); err != nil {
+			return
+		}
+		if !isRead {
+			continue
+		}
+		if _, err = tx.Exec(`
+            UPDATE redditseen SET
+                time_read = $a
+            WHERE viewer_id = $b
+              AND post_id = $c
+              AND subreddit_id = $d
+              AND time_read = 0
+            `,
+			timeRead,
+			viewerId,
+			key.Id,
+			key.SubredditId,
+		); err != nil {
+			return
+		}
+	}
+	return tx.Commit()
+}
+
+// GetSeenPosts returns the posts that the viewer was first shown at or after minTime, as a map of
+// PostKey.String() -> SeenPost.
+func (this *Persistence) GetSeenPosts(viewerId string, minTime int64) (seenPosts map[string]types.SeenPost, err error) {
+	seenPosts = make(map[string]types.SeenPost)
+	var rows *sql.Rows
+	if rows, err = this.dbconn.Query(`
+        SELECT
+            post_id
+            , subreddit_id
+            , time_shown
+            , time_read
+        FROM redditseen
+        WHERE viewer_id = $a
+          AND time_shown >= $b
+        `,
+		viewerId,
+		minTime,
+	); err != nil {
+		log.Errorf("Error calling SQL %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var seenPost types.SeenPost
+		if err = rows.Scan(
+			&seenPost.Id,
+			&seenPost.SubredditId,
+			&seenPost.TimeShown,
+			&seenPost.TimeRead,
+		); err != nil {
+			return
+		}
+		seenPosts[seenPost.PostKey.String()] = seenPost
+	}
+	return seenPosts, rows.Err()
+}
+
+// GetPost returns the post with the given key, or nil if there isn't one.
+func (this *Persistence) GetPost(key types.PostKey) (post *types.RedditPost, err error) {
+	var posts []types.RedditPost
+	if posts, err = this.GetPosts(`
+        WHERE id = $a
+          AND subreddit_id = $b
+        `,
+		key.Id,
+		key.SubredditId,
+	); err != nil || len(posts) == 0 {
+		return
+	}
+	return &posts[0], nil
+}
+
+// GetPostInSources returns the post with the given key if it belongs to one of the sources, or nil
+// if it doesn't exist or belongs to none of them.
+func (this *Persistence) GetPostInSources(key types.PostKey, sourceKeys []string) (post *types.RedditPost, err error) {
+	if len(sourceKeys) == 0 {
+		return nil, nil
+	}
+	var params = []interface{}{key.Id, key.SubredditId}
+	var criteria []string
+	for _, sourceKey := range sourceKeys {
+		criteria = append(criteria, sourceCriteria(fmt.Sprintf("$p%d", len(params))))
+		params = append(params, sourceKey)
+	}
+	var posts []types.RedditPost
+	if posts, err = this.GetPosts(`
+        WHERE id = $a
+          AND subreddit_id = $b
+          AND (`+strings.Join(criteria, " OR ")+`)
+        `,
+		params...,
+	); err != nil || len(posts) == 0 {
+		return
+	}
+	return &posts[0], nil
+}
+
+// StarPost records that the viewer starred the post in the given feed. Starring a post that's
+// already starred has no effect.
+func (this *Persistence) StarPost(viewerId string, feedName string, post *types.RedditPost, timeStarred int64) (err error) {
+	_, err = this.dbconn.Exec(`
+        INSERT OR IGNORE INTO redditstar
+            ( viewer_id
+            , post_id
+            , subreddit_id
+            , feed_name
+            , title
+            , url
+            , permalink
+            , subreddit_name
+            , score
+            , time_created
+            , time_starred
+        ) VALUES
+            ( $a
+            , $b
+            , $c
+            , $d
+            , $e
+            , $f
+            , $g
+            , $h
+            , $i
+            , $j
+            , $k
+        )`,
+		viewerId,
+		post.Id,
+		post.SubredditId,
+		feedName,
+		post.Title,
+		post.Url,
+		post.Permalink,
+		post.SubredditName,
+		post.Score,
+		post.TimeCreated,
+		timeStarred,
+	)
+	return
+}
+
+// UnstarPost removes the viewer's star from the post, if it has one.
+func (this *Persistence) UnstarPost(viewerId string, key types.PostKey) (err error) {
+	_, err = this.dbconn.Exec(`
+        DELETE FROM redditstar
+        WHERE viewer_id = $a
+          AND post_id = $b
+          AND subreddit_id = $c
+        `,
+		viewerId,
+		key.Id,
+		key.SubredditId,
+	)
+	return
+}
+
+// GetStarredPosts returns the posts the viewer has starred, most recently starred first.
+func (this *Persistence) GetStarredPosts(viewerId string) (starredPosts []types.StarredPost, err error) {
+	var rows *sql.Rows
+	if rows, err = this.dbconn.Query(`
+        SELECT
+            post_id
+            , subreddit_id
+            , feed_name
+            , title
+            , url
+            , permalink
+            , subreddit_name
+            , score
+            , time_created
+            , time_starred
+        FROM redditstar
+        WHERE viewer_id = $a
+        ORDER BY time_starred DESC, post_id
+        `,
+		viewerId,
+	); err != nil {
+		log.Errorf("Error calling SQL %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var starredPost types.StarredPost
+		if err = rows.Scan(
+			&starredPost.Id,
+			&starredPost.SubredditId,
+			&starredPost.FeedName,
+			&starredPost.Title,
+			&starredPost.Url,
+			&starredPost.Permalink,
+			&starredPost.SubredditName,
+			&starredPost.Score,
+			&starredPost.TimeCreated,
+			&starredPost.TimeStarred,
+		); err != nil {
+			return
+		}
+		starredPosts = append(starredPosts, starredPost)
+	}
+	return starredPosts, rows.Err()
+}
+
+// GetPruneCandidates returns the posts that haven't been stored or harvested since minTime. Posts
+// that are stickied, or that have been starred by any viewer, are never returned, so that they
+// are never pruned.
+func (this *Persistence) GetPruneCandidates(minTime int64) (candidates []types.PruneCandidate, err error) {
+	var rows *sql.Rows
+	if rows, err = this.dbconn.Query(`
+        SELECT
+            p.id
+            , p.subreddit_id
+            , p.subreddit_name
+            , MAX(p.time_stored, COALESCE(
+                (SELECT MAX(s.time_observed)
+                 FROM redditpostscore s
+                 WHERE s.post_id = p.id
+                   AND s.subreddit_id = p.subreddit_id
+                ), 0))
+            , COALESCE(ps.source_key, '')
+        FROM redditpost p
+        LEFT JOIN redditpostsource ps
+            ON ps.post_id = p.id
+           AND ps.subreddit_id = p.subreddit_id
+        WHERE p.time_stored < $a
+          AND p.is_sticky = 0
+          AND NOT EXISTS (
+            SELECT 1
+            FROM redditpostscore s
+            WHERE s.post_id = p.id
+              AND s.subreddit_id = p.subreddit_id
+              AND s.time_observed >= $a
+          )
+          AND NOT EXISTS (
+            SELECT 1
+            FROM redditstar st
+            WHERE st.post_id = p.id
+              AND st.subreddit_id = p.subreddit_id
+          )
+        ORDER BY p.id, p.subreddit_id, ps.source_key
+        `,
+		minTime,
+	); err != nil {
+		log.Errorf("Error calling SQL %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Posts harvested from several sources are returned once per source.
+	for rows.Next() {
+		var candidate types.PruneCandidate
+		var sourceKey string
+		if err = rows.Scan(
+			&candidate.Id,
+			&candidate.SubredditId,
+			&candidate.SubredditName,
+			&candidate.TimeLastSeen,
+			&sourceKey,
+		); err != nil {
+			return
+		}
+		var last = len(candidates) - 1
+		if last < 0 || candidates[last].PostKey != candidate.PostKey {
+			candidates = append(candidates, candidate)
+			last++
+		}
+		if sourceKey != "" {
+			candidates[last].SourceKeys = append(candidates[last].SourceKeys, sourceKey)
+		}
+	}
+	return candidates, rows.Err()
+}
+
+// DeletePosts deletes the posts, along with their comments, score history, sources, and the
+// viewers' records of having seen them. (Starred copies of the posts are kept).
+func (this *Persistence) DeletePosts(keys []types.PostKey) (err error) {
+	var tx *sql.Tx
+	if tx, err = this.dbconn.Begin(); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for _, key := range keys {
+		if _, err = tx.Exec(`
+            DELETE FROM redditpostsearch
+            WHERE docid = (`+searchDocIdQuery+`)
+            `,
+			key.Id,
+			key.SubredditId,
+		); err != nil {
+			return
+		}
+		for _, table := range []string{"redditpostsearchkey", "redditpostscore", "redditpostsource", "redditseen"} {
+			if _, err = tx.Exec(`
+                DELETE FROM `+table+`
+                WHERE post_id = $a
+                  AND subreddit_id = $b
+                `,
+				key.Id,
+				key.SubredditId,
+			); err != nil {
+				return
+			}
+		}
+		if _, err = tx.Exec(`
+            DELETE FROM redditpost
+            WHERE id = $a
+              AND subreddit_id = $b
+            `,
+			key.Id,
+			key.SubredditId,
+		); err != nil {
+			return
+		}
+		// Comments are only keyed by the post's ID, so are kept while a post with that ID remains.
+		if _, err = tx.Exec(`
+            DELETE FROM redditcomment
+            WHERE post_id = $a
+              AND NOT EXISTS (SELECT 1 FROM redditpost WHERE id = $a)
+            `,
+			key.Id,
+		); err != nil {
+			return
+		}
+	}
+	return tx.Commit()
+}
+
+// SearchPosts returns the posts whose title or text match the full-text query, most recently
+// created first. The query uses SQLite's full-text query syntax, e.g. `cat OR dog`,
+// `"exact phrase"`, `title:cat`, or `cat*`. If sourceKeys isn't empty, only posts that belong to
+// one of those sources are returned. Only posts created at or after minTime, and before maxTime
+// (if it's not 0), are returned. If the query's syntax is invalid, a drivers.SearchQueryError is
+// returned.
+func (this *Persistence) SearchPosts(
+	query string,
+	sourceKeys []string,
+	minTime int64,
+	maxTime int64,
+	limit int,
+) (posts []types.RedditPost, err error) {
+	if maxTime == 0 {
+		maxTime = math.MaxInt64
+	}
+	var params = []interface{}{query, minTime, maxTime}
+	var criteria []string
+	for _, sourceKey := range sourceKeys {
+		criteria = append(criteria, sourceCriteria(fmt.Sprintf("$p%d", len(params))))
+		params = append(params, sourceKey)
+	}
+	var sourceClause = ""
+	if len(criteria) != 0 {
+		sourceClause = "AND (" + strings.Join(criteria, " OR ") + ")"
+	}
+	params = append(params, limit)
+
+	posts, err = this.GetPosts(fmt.Sprintf(`
+        JOIN (
+            SELECT
+                k.post_id AS match_post_id
+                , k.subreddit_id AS match_subreddit_id
+            FROM redditpostsearch
+            JOIN redditpostsearchkey k ON k.docid = redditpostsearch.docid
+            WHERE redditpostsearch MATCH $a
+        ) ON match_post_id = redditpost.id
+          AND match_subreddit_id = redditpost.subreddit_id
+        WHERE time_created >= $b
+          AND time_created < $c
+          %s
+        ORDER BY time_created DESC
+        LIMIT $p%d
+        `, sourceClause, len(params)-1),
+		params...,
+	)
+	if err != nil && strings.Contains(err.Error(), "malformed MATCH expression") {
+		return nil, &drivers.SearchQueryError{Query: query}
+	}
+	return
 }
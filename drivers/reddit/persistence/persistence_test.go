@@ -3,6 +3,7 @@ package persistence
 import (
 	"fmt"
 	"github.com/coverprice/contentscraper/database"
+	"github.com/coverprice/contentscraper/drivers"
 	"github.com/coverprice/contentscraper/drivers/reddit/types"
 	"github.com/stretchr/testify/require"
 	"testing"
@@ -39,6 +40,12 @@ func TestCanCreateAndRetrieveRedditPost(t *testing.T) {
 
 	// Same post again, should be updated
 	post.Score = 9999
+	post.NumComments = 42
+	post.SelfText = "Edited *text*"
+	post.SelfTextHtml = "<p>Edited <em>text</em></p>"
+	post.Author = "someuser"
+	post.LinkFlair = "OC"
+	post.IsNsfw = true
 	result, err = sut.StorePost(post)
 	require.Nil(t, err, "Could not update 1st post")
 	require.Equal(t, StoreResult(STORERESULT_UPDATED), result, "Unexpected StoreResult")
@@ -66,6 +73,13 @@ func TestCanCreateAndRetrieveRedditPost(t *testing.T) {
 
 	require.Equal(t, 2, len(posts), "Expected length of results")
 	require.Equal(t, "some_id", posts[0].Id, "Incorrect 1st post ID")
+	require.Equal(t, int64(42), posts[0].NumComments, "# of comments was not updated")
+	require.Equal(t, "Edited *text*", posts[0].SelfText, "Self text was not updated")
+	require.Equal(t, "<p>Edited <em>text</em></p>", posts[0].SelfTextHtml, "Self text HTML was not updated")
+	require.Equal(t, "someuser", posts[0].Author, "Author was not updated")
+	require.Equal(t, "OC", posts[0].LinkFlair, "Link flair was not updated")
+	require.True(t, posts[0].IsNsfw, "NSFW flag was not updated")
+	require.False(t, posts[0].IsSpoiler, "Unexpected spoiler flag")
 	require.Equal(t, "another_id", posts[1].Id, "Incorrect 2nd post ID")
 }
 
@@ -134,7 +148,7 @@ func TestGetScoreAtPercentile(t *testing.T) {
 	}
 }
 
-func TestGetPostsForSubredditScores(t *testing.T) {
+func TestGetPostsForSource(t *testing.T) {
 	testDb, err := database.NewTestDatabase()
 	if err != nil {
 		t.Fatal("Could not init database", err)
@@ -149,31 +163,442 @@ func TestGetPostsForSubredditScores(t *testing.T) {
 
 	minTime := int64(0)
 
-	posts, err := sut.GetPostsForSubredditScores(
-		minTime,
-		map[string]int{
-			"funny": 198,
-			"gifs":  200,
-		})
+	posts, err := sut.GetPostsForSource(minTime, "funny", 198)
 	require.Nil(t, err, "Could not retrieve posts")
-	require.Equal(t, 3, len(posts), "Unexpected number of posts")
+	require.Equal(t, 2, len(posts), "Unexpected number of posts")
+	for _, post := range posts {
+		require.Equal(t, "funny", post.SubredditName)
+		require.True(t, post.Score >= 198, "Expected score %d to be >= 198", post.Score)
+	}
+
+	// A multireddit that includes some of the posts from gifs & pics
+	var multiredditKey = "multireddit:someone/animals"
+	for _, subredditName := range []string{"gifs", "pics"} {
+		for _, id := range []string{"id_100", "id_99", "id_1"} {
+			err = sut.AddPostToSource(multiredditKey, &types.RedditPost{Id: id, SubredditId: subredditName + "ppp9999"})
+			require.Nil(t, err, "Could not add post to source")
+		}
+	}
+	// Adding a post twice is harmless
+	err = sut.AddPostToSource(multiredditKey, &types.RedditPost{Id: "id_1", SubredditId: "picsppp9999"})
+	require.Nil(t, err, "Could not add post to source")
 
-	funnyCnt := 0
-	gifsCnt := 0
+	posts, err = sut.GetPostsForSource(minTime, multiredditKey, 198)
+	require.Nil(t, err, "Could not retrieve posts")
+	require.Equal(t, 4, len(posts), "Unexpected number of posts")
 	for _, post := range posts {
-		switch post.SubredditName {
-		case "funny":
-			require.Equal(t, true, post.Score >= 198,
-				"Expected score %d to be >= 198", post.Score)
-			funnyCnt++
-		case "gifs":
-			require.Equal(t, int64(200), post.Score)
-			gifsCnt++
-		default:
-			require.Fail(t, "Unexpected subreddit name", post.SubredditName)
+		require.True(t, post.Score >= 198, "Expected score %d to be >= 198", post.Score)
+	}
+
+	score, err := sut.GetScoreAtPercentile(minTime, multiredditKey, 50.0)
+	require.Nil(t, err, "Could not retrieve percentile score")
+	require.Equal(t, 198, score, "The percentile should only consider the source's posts")
+
+	// The subreddit's own posts are unaffected.
+	posts, err = sut.GetPostsForSource(minTime, "gifs", 0)
+	require.Nil(t, err, "Could not retrieve posts")
+	require.Equal(t, 100, len(posts), "Unexpected number of posts")
+}
+
+func TestDuplicatePostsAreLinkedToTheirSource(t *testing.T) {
+	testDb, err := database.NewTestDatabase()
+	if err != nil {
+		t.Fatal("Could not init database", err)
+	}
+	defer testDb.Cleanup()
+
+	sut, err := NewPersistence(testDb.DbConn)
+
+	var post = types.RedditPost{
+		Id:            "id_1",
+		Name:          "id_1",
+		Permalink:     "/r/funny/id_1",
+		IsActive:      true,
+		Score:         10,
+		Title:         "A fake post",
+		Url:           "https://example.com/cat.gif",
+		SubredditName: "funny",
+		SubredditId:   "funnyppp9999",
+	}
+	result, err := sut.StorePost(&post)
+	require.Nil(t, err, "Could not store post")
+	require.Equal(t, StoreResult(STORERESULT_NEW), result)
+
+	// The same URL crossposted to another subreddit is skipped...
+	var duplicate = post
+	duplicate.Id = "id_2"
+	duplicate.Name = "id_2"
+	duplicate.SubredditName = "gifs"
+	duplicate.SubredditId = "gifsppp9999"
+	result, err = sut.StorePost(&duplicate)
+	require.Nil(t, err, "Could not store post")
+	require.Equal(t, StoreResult(STORERESULT_SKIPPED), result)
+
+	// ...but once linked, the original appears in the other subreddit's source.
+	require.Nil(t, sut.AddDuplicatePostToSource("gifs", &duplicate))
+	posts, err := sut.GetPostsForSource(0, "gifs", 0)
+	require.Nil(t, err, "Could not retrieve posts")
+	require.Equal(t, 1, len(posts))
+	require.Equal(t, "id_1", posts[0].Id)
+
+	// Linking it to its own subreddit is a no-op.
+	require.Nil(t, sut.AddDuplicatePostToSource("funny", &post))
+	posts, err = sut.GetPostsForSource(0, "funny", 0)
+	require.Nil(t, err, "Could not retrieve posts")
+	require.Equal(t, 1, len(posts))
+}
+
+func TestScoreHistoryAndVelocity(t *testing.T) {
+	testDb, err := database.NewTestDatabase()
+	if err != nil {
+		t.Fatal("Could not init database", err)
+	}
+	defer testDb.Cleanup()
 
+	sut, err := NewPersistence(testDb.DbConn)
+	require.Nil(t, err, "Could not init persistence")
+
+	const hour = 60 * 60
+	var created = int64(100000)
+	var snapshots = []struct {
+		id           string
+		timeObserved int64
+		score        int64
+	}{
+		// Rose quickly: 300 in its first 3 hours, then plateaued.
+		{"fast", created + 1*hour, 100},
+		{"fast", created + 3*hour, 300},
+		{"fast", created + 24*hour, 400},
+		// Rose slowly, but eventually overtook "fast".
+		{"slow", created + 2*hour, 20},
+		{"slow", created + 24*hour, 1000},
+		// Observed soon after creation, so its age is rounded up to MIN_VELOCITY_PERIOD
+		{"new", created + 60, 30},
+		// Not observed during the window.
+		{"late", created + 24*hour, 5000},
+	}
+	for _, snapshot := range snapshots {
+		var post = types.RedditPost{
+			Id:            snapshot.id,
+			Name:          "t3_" + snapshot.id,
+			TimeCreated:   created,
+			TimeStored:    created,
+			IsActive:      true,
+			Score:         snapshot.score,
+			NumComments:   snapshot.score / 10,
+			SubredditName: "funny",
+			SubredditId:   "t5_funny",
 		}
+		_, err = sut.StorePost(&post)
+		require.Nil(t, err, "Could not store post")
+		require.Nil(t, sut.RecordScore(&post, snapshot.timeObserved), "Could not record score")
+	}
+
+	history, err := sut.GetScoreHistory("fast", "t5_funny")
+	require.Nil(t, err, "Could not retrieve score history")
+	require.Equal(t, []types.ScoreSnapshot{
+		{PostId: "fast", SubredditId: "t5_funny", TimeObserved: created + 1*hour, Score: 100, NumComments: 10},
+		{PostId: "fast", SubredditId: "t5_funny", TimeObserved: created + 3*hour, Score: 300, NumComments: 30},
+		{PostId: "fast", SubredditId: "t5_funny", TimeObserved: created + 24*hour, Score: 400, NumComments: 40},
+	}, history)
+
+	velocities, err := sut.GetScoreVelocities(0, "funny", 6*hour)
+	require.Nil(t, err, "Could not retrieve velocities")
+	require.Equal(t, map[string]float64{"fast": 100, "slow": 10, "new": 30}, velocities)
+}
+
+func TestStoreAndRetrieveHarvestRuns(t *testing.T) {
+	testDb, err := database.NewTestDatabase()
+	if err != nil {
+		t.Fatal("Could not init database", err)
+	}
+	defer testDb.Cleanup()
+
+	sut, err := NewPersistence(testDb.DbConn)
+	require.Nil(t, err, "Could not initialize persistence layer")
+
+	runs := []drivers.HarvestRun{
+		drivers.HarvestRun{Source: "funny", TimeRunStarted: 100, TimeStarted: 100, TimeEnded: 110, PagesScraped: 2, PostsNew: 150, PostsUpdated: 40, PostsSkipped: 10},
+		drivers.HarvestRun{Source: "gifs", TimeRunStarted: 100, TimeStarted: 101, TimeEnded: 102, Error: "Failed to fetch listing"},
+		drivers.HarvestRun{Source: "funny", TimeRunStarted: 200, TimeStarted: 200, TimeEnded: 205, PagesScraped: 1, PostsNew: 5, PostsUpdated: 95},
+		drivers.HarvestRun{Source: "pics", TimeRunStarted: 200, TimeStarted: 201, TimeEnded: 205, PagesScraped: 1},
+	}
+	for _, run := range runs {
+		require.Nil(t, sut.StoreHarvestRun(&run), "Could not store harvest run")
+	}
+
+	retrieved, err := sut.GetHarvestRuns(101, []string{"funny", "gifs"})
+	require.Nil(t, err, "Could not retrieve harvest runs")
+	require.Equal(t, []drivers.HarvestRun{runs[2], runs[1]}, retrieved)
+}
+
+func TestStoreAndRetrieveComments(t *testing.T) {
+	testDb, err := database.NewTestDatabase()
+	if err != nil {
+		t.Fatal("Could not init database", err)
+	}
+	defer testDb.Cleanup()
+
+	sut, err := NewPersistence(testDb.DbConn)
+	require.Nil(t, err, "Could not initialize persistence layer")
+
+	createFakePosts(t, sut, "funny")
+	posts, err := sut.GetPostsNeedingComments("funny", 1234, 195)
+	require.Nil(t, err, "Could not retrieve posts")
+	require.Equal(t, 3, len(posts)) // Scores 196, 198, 200
+	posts, err = sut.GetPostsNeedingComments("funny", 1235, 0)
+	require.Nil(t, err, "Could not retrieve posts")
+	require.Equal(t, 0, len(posts), "Posts created too long ago should be excluded")
+
+	err = sut.ReplaceComments("id_1", []types.RedditComment{
+		types.RedditComment{Id: "c1", PostId: "id_1", Rank: 1, Author: "someone", Body: "First!", Score: 10},
+		types.RedditComment{Id: "c2", PostId: "id_1", Rank: 2, Author: "another", Body: "Second", Score: 5},
+	})
+	require.Nil(t, err, "Could not store comments")
+	err = sut.ReplaceComments("id_2", []types.RedditComment{
+		types.RedditComment{Id: "c3", PostId: "id_2", Rank: 1, Author: "someone", Body: "Hmm", Score: 1},
+	})
+	require.Nil(t, err, "Could not store comments")
+
+	// Refreshed comments replace the old ones
+	newComments := []types.RedditComment{
+		types.RedditComment{Id: "c2", PostId: "id_1", Rank: 1, Author: "another", Body: "Second", Score: 50},
+		types.RedditComment{Id: "c4", PostId: "id_1", Rank: 2, Author: "third", Body: "Late", Score: 20},
 	}
-	require.Equal(t, funnyCnt, 2)
-	require.Equal(t, gifsCnt, 1)
+	err = sut.ReplaceComments("id_1", newComments)
+	require.Nil(t, err, "Could not replace comments")
+
+	comments, err := sut.GetCommentsForPosts([]string{"id_1", "id_3"})
+	require.Nil(t, err, "Could not retrieve comments")
+	require.Equal(t, map[string][]types.RedditComment{"id_1": newComments}, comments)
+}
+
+func TestSeenPosts(t *testing.T) {
+	testDb, err := database.NewTestDatabase()
+	if err != nil {
+		t.Fatal("Could not init database", err)
+	}
+	defer testDb.Cleanup()
+
+	sut, err := NewPersistence(testDb.DbConn)
+	require.Nil(t, err, "Could not initialize persistence layer")
+
+	var post1 = types.PostKey{Id: "id_1", SubredditId: "t5_a"}
+	var post2 = types.PostKey{Id: "id_2", SubredditId: "t5_a"}
+	var post3 = types.PostKey{Id: "id_3", SubredditId: "t5_a"}
+
+	require.Nil(t, sut.MarkPostsShown("viewer1", []types.PostKey{post1, post2}, 100))
+	// Being shown again doesn't change when the post was first shown
+	require.Nil(t, sut.MarkPostsShown("viewer1", []types.PostKey{post1}, 200))
+	// Posts can be read without having been shown (e.g. via the API)
+	require.Nil(t, sut.MarkPostsRead("viewer1", []types.PostKey{post2, post3}, 300))
+	require.Nil(t, sut.MarkPostsRead("viewer1", []types.PostKey{post2}, 400))
+	require.Nil(t, sut.MarkPostsShown("viewer2", []types.PostKey{post1}, 500))
+
+	seen, err := sut.GetSeenPosts("viewer1", 0)
+	require.Nil(t, err, "Could not retrieve seen posts")
+	require.Equal(t, map[string]types.SeenPost{
+		"id_1/t5_a": types.SeenPost{PostKey: post1, TimeShown: 100, TimeRead: 0},
+		"id_2/t5_a": types.SeenPost{PostKey: post2, TimeShown: 100, TimeRead: 300},
+		"id_3/t5_a": types.SeenPost{PostKey: post3, TimeShown: 300, TimeRead: 300},
+	}, seen)
+
+	seen, err = sut.GetSeenPosts("viewer1", 200)
+	require.Nil(t, err, "Could not retrieve seen posts")
+	require.Equal(t, 1, len(seen), "Posts shown before minTime should be excluded")
+}
+
+func TestStarredPosts(t *testing.T) {
+	testDb, err := database.NewTestDatabase()
+	if err != nil {
+		t.Fatal("Could not init database", err)
+	}
+	defer testDb.Cleanup()
+
+	sut, err := NewPersistence(testDb.DbConn)
+	require.Nil(t, err, "Could not initialize persistence layer")
+
+	createFakePosts(t, sut, "funny")
+	post1, err := sut.GetPost(types.PostKey{Id: "id_1", SubredditId: "funnyppp9999"})
+	require.Nil(t, err, "Could not retrieve post")
+	require.NotNil(t, post1, "Post not found")
+	post2, err := sut.GetPost(types.PostKey{Id: "id_2", SubredditId: "funnyppp9999"})
+	require.Nil(t, err, "Could not retrieve post")
+	missing, err := sut.GetPost(types.PostKey{Id: "id_1", SubredditId: "another_id"})
+	require.Nil(t, err, "Could not retrieve post")
+	require.Nil(t, missing)
+
+	require.Nil(t, sut.StarPost("viewer1", "feed1", post1, 100))
+	require.Nil(t, sut.StarPost("viewer1", "feed2", post2, 200))
+	// Starring again doesn't change anything
+	require.Nil(t, sut.StarPost("viewer1", "feed1", post1, 300))
+	require.Nil(t, sut.StarPost("viewer2", "feed1", post2, 400))
+
+	starred, err := sut.GetStarredPosts("viewer1")
+	require.Nil(t, err, "Could not retrieve starred posts")
+	require.Equal(t, 2, len(starred))
+	require.Equal(t, types.StarredPost{
+		PostKey:       post2.Key(),
+		FeedName:      "feed2",
+		Title:         post2.Title,
+		Url:           post2.Url,
+		Permalink:     post2.Permalink,
+		SubredditName: "funny",
+		Score:         post2.Score,
+		TimeCreated:   post2.TimeCreated,
+		TimeStarred:   200,
+	}, starred[0])
+	require.Equal(t, int64(100), starred[1].TimeStarred)
+
+	require.Nil(t, sut.UnstarPost("viewer1", post2.Key()))
+	starred, err = sut.GetStarredPosts("viewer1")
+	require.Nil(t, err, "Could not retrieve starred posts")
+	require.Equal(t, 1, len(starred))
+	require.Equal(t, "id_1", starred[0].Id)
+}
+
+func TestPrunePosts(t *testing.T) {
+	testDb, err := database.NewTestDatabase()
+	if err != nil {
+		t.Fatal("Could not init database", err)
+	}
+	defer testDb.Cleanup()
+
+	sut, err := NewPersistence(testDb.DbConn)
+	require.Nil(t, err, "Could not initialize persistence layer")
+
+	storePost := func(id string, timeStored int64, setup func(post *types.RedditPost)) *types.RedditPost {
+		post := &types.RedditPost{
+			Id:            id,
+			Name:          "t3_" + id,
+			TimeCreated:   timeStored,
+			TimeStored:    timeStored,
+			Permalink:     "/r/funny/" + id,
+			IsActive:      true,
+			Title:         "Post " + id,
+			SubredditName: "funny",
+			SubredditId:   "t5_funny",
+		}
+		if setup != nil {
+			setup(post)
+		}
+		_, err := sut.StorePost(post)
+		require.Nil(t, err, "Could not store post")
+		return post
+	}
+	oldPost := storePost("old", 100, nil)
+	storePost("observed_long_ago", 100, func(post *types.RedditPost) {
+		require.Nil(t, sut.RecordScore(post, 150))
+	})
+	storePost("observed_recently", 100, func(post *types.RedditPost) {
+		require.Nil(t, sut.RecordScore(post, 2000))
+	})
+	storePost("new", 2000, nil)
+	storePost("sticky", 100, func(post *types.RedditPost) { post.IsSticky = true })
+	starredPost := storePost("starred", 100, nil)
+	require.Nil(t, sut.StarPost("viewer1", "feed1", starredPost, 200))
+	multiPost := storePost("multi", 100, nil)
+	require.Nil(t, sut.AddPostToSource("user:someone", multiPost))
+	require.Nil(t, sut.AddPostToSource("search:something", multiPost))
+
+	candidates, err := sut.GetPruneCandidates(1000)
+	require.Nil(t, err, "Could not retrieve prune candidates")
+	require.Equal(t, []types.PruneCandidate{
+		types.PruneCandidate{PostKey: multiPost.Key(), SubredditName: "funny", SourceKeys: []string{"search:something", "user:someone"}, TimeLastSeen: 100},
+		types.PruneCandidate{PostKey: types.PostKey{Id: "observed_long_ago", SubredditId: "t5_funny"}, SubredditName: "funny", TimeLastSeen: 150},
+		types.PruneCandidate{PostKey: oldPost.Key(), SubredditName: "funny", TimeLastSeen: 100},
+	}, candidates)
+
+	require.Nil(t, sut.ReplaceComments("old", []types.RedditComment{
+		types.RedditComment{Id: "c1", PostId: "old", Rank: 1, Author: "someone", Body: "A comment"},
+	}))
+	require.Nil(t, sut.MarkPostsRead("viewer1", []types.PostKey{oldPost.Key(), starredPost.Key()}, 300))
+
+	require.Nil(t, sut.DeletePosts([]types.PostKey{oldPost.Key(), multiPost.Key()}))
+	post, err := sut.GetPost(oldPost.Key())
+	require.Nil(t, err, "Could not retrieve post")
+	require.Nil(t, post, "Post was not deleted")
+	comments, err := sut.GetCommentsForPosts([]string{"old"})
+	require.Nil(t, err, "Could not retrieve comments")
+	require.Empty(t, comments["old"], "Comments were not deleted")
+	seen, err := sut.GetSeenPosts("viewer1", 0)
+	require.Nil(t, err, "Could not retrieve seen posts")
+	require.Equal(t, 1, len(seen), "Seen records were not deleted")
+	post, err = sut.GetPost(starredPost.Key())
+	require.Nil(t, err, "Could not retrieve post")
+	require.NotNil(t, post, "Wrong post was deleted")
+
+	candidates, err = sut.GetPruneCandidates(1000)
+	require.Nil(t, err, "Could not retrieve prune candidates")
+	require.Equal(t, 1, len(candidates))
+}
+
+func TestSearchPosts(t *testing.T) {
+	testDb, err := database.NewTestDatabase()
+	if err != nil {
+		t.Fatal("Could not init database", err)
+	}
+	defer testDb.Cleanup()
+
+	sut, err := NewPersistence(testDb.DbConn)
+	require.Nil(t, err, "Could not initialize persistence layer")
+
+	storePost := func(id string, subreddit string, timeCreated int64, title string, selfText string) *types.RedditPost {
+		post := &types.RedditPost{
+			Id:            id,
+			Name:          "t3_" + id,
+			TimeCreated:   timeCreated,
+			TimeStored:    timeCreated,
+			Permalink:     "/r/" + subreddit + "/" + id,
+			IsActive:      true,
+			Title:         title,
+			SelfText:      selfText,
+			SubredditName: subreddit,
+			SubredditId:   "t5_" + subreddit,
+		}
+		_, err := sut.StorePost(post)
+		require.Nil(t, err, "Could not store post")
+		return post
+	}
+	search := func(query string, sourceKeys []string, minTime int64, maxTime int64) (ids []string) {
+		posts, err := sut.SearchPosts(query, sourceKeys, minTime, maxTime, 10)
+		require.Nil(t, err, "Could not search posts for '%s'", query)
+		for _, post := range posts {
+			ids = append(ids, post.Id)
+		}
+		return
+	}
+
+	storePost("cat", "pets", 100, "My cat is asleep", "")
+	dogPost := storePost("dog", "pets", 200, "A dog", "It is chasing a cat")
+	storePost("catnews", "news", 300, "Cats are in the news", "")
+	multiPost := storePost("multi", "other", 400, "Unrelated", "The cat came back")
+	require.Nil(t, sut.AddPostToSource("user:someone", multiPost))
+
+	require.Equal(t, []string{"multi", "catnews", "dog", "cat"}, search("cat", nil, 0, 0), "Stemmed matches, most recent first")
+	require.Equal(t, []string{"catnews", "cat"}, search("title:cat", nil, 0, 0))
+	require.Equal(t, []string{"dog"}, search(`"chasing a cat"`, nil, 0, 0))
+	require.Equal(t, []string{"dog", "cat"}, search("cat", []string{"pets"}, 0, 0))
+	require.Equal(t, []string{"multi", "catnews"}, search("cat", []string{"news", "user:someone"}, 0, 0))
+	require.Equal(t, []string{"catnews", "dog"}, search("cat", nil, 200, 400))
+	require.Empty(t, search("parrot", nil, 0, 0))
+
+	// The index is updated along with the post
+	dogPost.Title = "A parrot"
+	dogPost.SelfText = "It is asleep"
+	_, err = sut.StorePost(dogPost)
+	require.Nil(t, err, "Could not update post")
+	require.Equal(t, []string{"dog"}, search("parrot", nil, 0, 0))
+	require.Equal(t, []string{"multi", "catnews", "cat"}, search("cat", nil, 0, 0))
+	// Updates that don't edit the text leave the index entry as it was
+	dogPost.Score = 50
+	_, err = sut.StorePost(dogPost)
+	require.Nil(t, err, "Could not update post")
+	require.Equal(t, []string{"dog"}, search("parrot", nil, 0, 0))
+
+	require.Nil(t, sut.DeletePosts([]types.PostKey{dogPost.Key()}))
+	require.Empty(t, search("parrot", nil, 0, 0))
+
+	_, err = sut.SearchPosts(`"unterminated`, nil, 0, 0, 10)
+	require.IsType(t, &drivers.SearchQueryError{}, err)
 }
@@ -0,0 +1,44 @@
+package reddit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+// NewFakeRedditServer returns a local server that responds to requests for Reddit's .json
+// endpoints with the responses recorded in fixtureDir (see NewRecordingScraper), or a 404 if there
+// isn't one. Point a JsonScraper at its URL to test the harvester, persistence and viewer
+// end-to-end without a network connection. The caller must Close() it.
+func NewFakeRedditServer(fixtureDir string) *httptest.Server {
+	return NewSlowFakeRedditServer(fixtureDir, 0)
+}
+
+// NewSlowFakeRedditServer returns a NewFakeRedditServer that waits for delay before responding to
+// each request, e.g. to test that requests in progress are abandoned when a harvest is cancelled.
+// If the client gives up on a request first, it's not responded to.
+func NewSlowFakeRedditServer(fixtureDir string, delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if delay > 0 {
+			var timer = time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-r.Context().Done():
+				timer.Stop()
+				return
+			}
+		}
+		body, err := readFixture(fixtureDir, r.URL)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if body == nil {
+			http.Error(w, fmt.Sprintf("No fixture named '%s'", FixtureName(r.URL)), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}))
+}
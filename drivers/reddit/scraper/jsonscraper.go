@@ -0,0 +1,294 @@
+package reddit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/coverprice/contentscraper/drivers/reddit/types"
+	log "github.com/sirupsen/logrus"
+	"github.com/turnage/graw/reddit"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultBaseUrl is the root of Reddit's public (logged out) .json endpoints.
+const DefaultBaseUrl = "https://www.reddit.com"
+
+// Verify that JsonScraper implements the IScraper interface
+var _ IScraper = &JsonScraper{}
+
+// JsonScraper reads Reddit's public .json endpoints directly, rather than through graw. Since
+// it can be pointed at any server, or replay responses recorded earlier, it allows the harvester
+// to be tested without a network connection. (See NewRecordingScraper, NewReplayScraper and
+// NewFakeRedditServer).
+type JsonScraper struct {
+	client    *http.Client
+	baseUrl   string
+	recordDir string // If set, every response is saved in this directory
+}
+
+// NewJsonScraper creates a JsonScraper that talks to the server rooted at baseUrl, e.g. DefaultBaseUrl.
+func NewJsonScraper(baseUrl string) *JsonScraper {
+	return &JsonScraper{
+		client:  &http.Client{Timeout: requestTimeout},
+		baseUrl: strings.TrimRight(baseUrl, "/"),
+	}
+}
+
+// NewRecordingScraper creates a JsonScraper that saves every response it receives in recordDir,
+// named by FixtureName, so that they can be replayed later.
+func NewRecordingScraper(baseUrl, recordDir string) *JsonScraper {
+	scraper := NewJsonScraper(baseUrl)
+	scraper.recordDir = recordDir
+	return scraper
+}
+
+// NewReplayScraper creates a JsonScraper that never touches the network. Instead it responds to
+// each request with the response recorded in fixtureDir, or a 404 if there isn't one.
+func NewReplayScraper(fixtureDir string) *JsonScraper {
+	return &JsonScraper{
+		client:  &http.Client{Transport: fixtureTransport{fixtureDir: fixtureDir}},
+		baseUrl: "http://replay.invalid",
+	}
+}
+
+// FixtureName returns the name of the file that the response to the given URL is recorded in.
+// It's derived from the path and the (sorted) query parameters, so it doesn't depend on the host.
+func FixtureName(u *url.URL) string {
+	var name = strings.Replace(strings.Trim(u.Path, "/"), "/", "_", -1)
+	if query := u.Query().Encode(); query != "" {
+		name += "--" + url.QueryEscape(query)
+	}
+	return name
+}
+
+// readFixture returns the response recorded for the URL, or nil if there isn't one.
+func readFixture(fixtureDir string, u *url.URL) (body []byte, err error) {
+	body, err = ioutil.ReadFile(filepath.Join(fixtureDir, FixtureName(u)))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return
+}
+
+// fixtureTransport is an http.RoundTripper that responds with recorded responses.
+type fixtureTransport struct {
+	fixtureDir string
+}
+
+func (this fixtureTransport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	if err = req.Context().Err(); err != nil {
+		return
+	}
+	var body []byte
+	if body, err = readFixture(this.fixtureDir, req.URL); err != nil {
+		return
+	}
+	resp = &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Request:    req,
+	}
+	if body == nil {
+		resp.Status = "404 Not Found"
+		resp.StatusCode = http.StatusNotFound
+		body = []byte(fmt.Sprintf("No fixture named '%s'", FixtureName(req.URL)))
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// The parts of Reddit's JSON responses that we care about. A "listing" is a page of "things",
+// each of which has a kind (e.g. "t3" for posts, "t1" for comments) and its data.
+type jsonListing struct {
+	Data struct {
+		After    string      `json:"after"`
+		Children []jsonThing `json:"children"`
+	} `json:"data"`
+}
+
+type jsonThing struct {
+	Kind string          `json:"kind"`
+	Data json.RawMessage `json:"data"`
+}
+
+type jsonPost struct {
+	Id            string  `json:"id"`
+	Name          string  `json:"name"`
+	Permalink     string  `json:"permalink"`
+	CreatedUtc    float64 `json:"created_utc"`
+	Author        string  `json:"author"`
+	Title         string  `json:"title"`
+	Score         int32   `json:"score"`
+	NumComments   int32   `json:"num_comments"`
+	Url           string  `json:"url"`
+	Subreddit     string  `json:"subreddit"`
+	SubredditId   string  `json:"subreddit_id"`
+	IsSelf        bool    `json:"is_self"`
+	SelfText      string  `json:"selftext"`
+	SelfTextHtml  string  `json:"selftext_html"`
+	Stickied      bool    `json:"stickied"`
+	LinkFlairText string  `json:"link_flair_text"`
+	Over18        bool    `json:"over_18"`
+	Spoiler       bool    `json:"spoiler"`
+}
+
+type jsonComment struct {
+	Id         string  `json:"id"`
+	Author     string  `json:"author"`
+	Body       string  `json:"body"`
+	Ups        int32   `json:"ups"`
+	Downs      int32   `json:"downs"`
+	CreatedUtc float64 `json:"created_utc"`
+	Stickied   bool    `json:"stickied"`
+}
+
+// The JSON is converted to graw's types so that the posts & comments are identical to the ones
+// the (graw-based) Scraper produces.
+func (this *jsonPost) toBotPost() *reddit.Post {
+	return &reddit.Post{
+		ID:            this.Id,
+		Name:          this.Name,
+		Permalink:     this.Permalink,
+		CreatedUTC:    uint64(this.CreatedUtc),
+		Deleted:       this.Author == "[deleted]",
+		Author:        this.Author,
+		Title:         this.Title,
+		Score:         this.Score,
+		NumComments:   this.NumComments,
+		URL:           this.Url,
+		Subreddit:     this.Subreddit,
+		SubredditID:   this.SubredditId,
+		IsSelf:        this.IsSelf,
+		SelfText:      this.SelfText,
+		SelfTextHTML:  this.SelfTextHtml,
+		Stickied:      this.Stickied,
+		LinkFlairText: this.LinkFlairText,
+		NSFW:          this.Over18,
+	}
+}
+
+func (this *jsonComment) toBotComment() *reddit.Comment {
+	return &reddit.Comment{
+		ID:         this.Id,
+		Author:     this.Author,
+		Body:       this.Body,
+		Ups:        this.Ups,
+		Downs:      this.Downs,
+		CreatedUTC: uint64(this.CreatedUtc),
+		Deleted:    this.Author == "[deleted]",
+	}
+}
+
+func (this *JsonScraper) GetNextResults(ctx context.Context, scrapeContext *Context) (posts []types.RedditPost, err error) {
+	var params = url.Values{}
+	params.Set("limit", fmt.Sprintf("%d", scrapeContext.NumPostsPerScrape))
+	for name, value := range map[string]string{
+		"after": scrapeContext.After,
+		"t":     scrapeContext.TimeWindow,
+		"sort":  scrapeContext.Sort,
+		"q":     scrapeContext.Query,
+	} {
+		if value != "" {
+			params.Set(name, value)
+		}
+	}
+
+	var listing jsonListing
+	if err = this.get(ctx, scrapeContext.UrlPath, params, &listing); err != nil {
+		return nil, newScrapeError(err, "Failed to fetch listing for source '%s': %v", scrapeContext.Source, err)
+	}
+	for _, child := range listing.Data.Children {
+		if child.Kind != "t3" {
+			continue
+		}
+		var post jsonPost
+		if err = json.Unmarshal(child.Data, &post); err != nil {
+			return nil, fmt.Errorf("Could not decode post in listing for source '%s': %v", scrapeContext.Source, err)
+		}
+		redditPost := newRedditPostFromBotPost(post.toBotPost())
+		// graw's Post has no spoiler field, so it's copied across from the JSON directly.
+		redditPost.IsSpoiler = post.Spoiler
+		if redditPost.IsSticky {
+			// Skip Sticky posts because they tend to be non-useful posts like rules or announcements.
+			continue
+		}
+		posts = append(posts, redditPost)
+		scrapeContext.After = redditPost.Name
+	}
+	return posts, nil
+}
+
+func (this *JsonScraper) GetTopComments(ctx context.Context, post *types.RedditPost, maxComments int) (comments []types.RedditComment, err error) {
+	// The response is a pair of listings: the post itself, then its comments.
+	var listings []jsonListing
+	if err = this.get(ctx, strings.TrimRight(post.Permalink, "/"), url.Values{}, &listings); err != nil {
+		return nil, newScrapeError(err, "Failed to fetch comments for post '%s': %v", post.Id, err)
+	}
+	if len(listings) != 2 {
+		return nil, fmt.Errorf("Unexpected response for comments of post '%s'", post.Id)
+	}
+	var thread = &reddit.Post{}
+	for _, child := range listings[1].Data.Children {
+		if child.Kind != "t1" {
+			// e.g. "more", a placeholder for comments that weren't included
+			continue
+		}
+		var comment jsonComment
+		if err = json.Unmarshal(child.Data, &comment); err != nil {
+			return nil, fmt.Errorf("Could not decode comment of post '%s': %v", post.Id, err)
+		}
+		if comment.Stickied {
+			// graw's Comment has no stickied field, so moderator comments are dropped here.
+			continue
+		}
+		thread.Replies = append(thread.Replies, comment.toBotComment())
+	}
+	return topCommentsFromThread(post, thread, maxComments), nil
+}
+
+// get performs a GET request for path's .json endpoint and decodes the JSON response. Cancelling
+// ctx aborts the request.
+func (this *JsonScraper) get(ctx context.Context, path string, params url.Values, response interface{}) (err error) {
+	var u = this.baseUrl + path + ".json"
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", useragent)
+
+	resp, err := this.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &httpStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	var body []byte
+	if body, err = ioutil.ReadAll(resp.Body); err != nil {
+		return
+	}
+	if this.recordDir != "" {
+		var fixturePath = filepath.Join(this.recordDir, FixtureName(req.URL))
+		if err = ioutil.WriteFile(fixturePath, body, 0644); err != nil {
+			return fmt.Errorf("Could not record response: %v", err)
+		}
+		log.Debugf("Recorded response to %s", fixturePath)
+	}
+	if err = json.Unmarshal(body, response); err != nil {
+		return fmt.Errorf("Could not decode response: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package reddit
+
+import (
+	"context"
+	"fmt"
+	"github.com/coverprice/contentscraper/drivers/reddit/types"
+	"github.com/coverprice/contentscraper/toolbox"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const fixtureDir = "testdata"
+
+func TestReplayScraperPaginatesListings(t *testing.T) {
+	var scraper = NewReplayScraper(fixtureDir)
+	var scrapeContext = NewContextForNew("testsub")
+
+	posts, err := scraper.GetNextResults(context.Background(), &scrapeContext)
+	require.Nil(t, err, "Non nil error from scraper")
+	// The sticky post is skipped
+	require.Equal(t, 3, len(posts))
+	require.Equal(t, "t3_p3", scrapeContext.After)
+
+	var post = posts[1]
+	require.Equal(t, "p2", post.Id)
+	require.Equal(t, "t3_p2", post.Name)
+	require.Equal(t, "testsub", post.SubredditName)
+	require.Equal(t, "t5_test", post.SubredditId)
+	require.Equal(t, int64(20), post.Score)
+	require.Equal(t, int64(1508230920), post.TimeCreated)
+	require.Equal(t, "/r/testsub/comments/p2/post_2/", post.Permalink)
+	require.Equal(t, "Hello world", post.SelfText)
+	require.Equal(t, `<!-- SC_OFF --><div class="md"><p>Hello world</p></div><!-- SC_ON -->`, post.SelfTextHtml)
+	require.True(t, post.IsActive)
+	require.Equal(t, "user2", post.Author)
+	require.Equal(t, "Discussion", post.LinkFlair)
+	require.False(t, post.IsNsfw)
+	require.True(t, posts[2].IsNsfw)
+	require.False(t, post.IsSpoiler)
+	require.True(t, posts[2].IsSpoiler)
+
+	posts, err = scraper.GetNextResults(context.Background(), &scrapeContext)
+	require.Nil(t, err, "Non nil error from scraper")
+	require.Equal(t, 2, len(posts))
+	posts, err = scraper.GetNextResults(context.Background(), &scrapeContext)
+	require.Nil(t, err, "Non nil error from scraper")
+	require.Equal(t, 1, len(posts))
+
+	// There's no fixture for a 4th page
+	_, err = scraper.GetNextResults(context.Background(), &scrapeContext)
+	require.NotNil(t, err, "Expected an error for a missing fixture")
+}
+
+func TestReplayScraperRetrievesTopComments(t *testing.T) {
+	var scraper = NewReplayScraper(fixtureDir)
+	var post = types.RedditPost{Id: "p2", Permalink: "/r/testsub/comments/p2/post_2/"}
+
+	comments, err := scraper.GetTopComments(context.Background(), &post, 2)
+	require.Nil(t, err, "Non nil error from scraper")
+	// The sticky & deleted comments are skipped
+	require.Equal(t, 2, len(comments))
+	require.Equal(t, "c2", comments[0].Id)
+	require.Equal(t, "p2", comments[0].PostId)
+	require.Equal(t, int64(50), comments[0].Score)
+	require.Equal(t, 1, comments[0].Rank)
+	require.Equal(t, "c5", comments[1].Id)
+	require.Equal(t, 2, comments[1].Rank)
+}
+
+func TestReplayScraperHonoursCancellation(t *testing.T) {
+	var scraper = NewReplayScraper(fixtureDir)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var scrapeContext = NewContextForNew("testsub")
+	_, err := scraper.GetNextResults(ctx, &scrapeContext)
+	require.NotNil(t, err, "Expected an error for a cancelled request")
+	require.Equal(t, "", scrapeContext.After)
+}
+
+func TestRecordedResponsesCanBeReplayed(t *testing.T) {
+	server := NewFakeRedditServer(fixtureDir)
+	defer server.Close()
+
+	recordDir, err := ioutil.TempDir("", "redditfixtures")
+	require.Nil(t, err, "Could not create temp dir")
+	defer os.RemoveAll(recordDir)
+
+	var scrapeContext = NewContextForNew("testsub")
+	recorded, err := NewRecordingScraper(server.URL, recordDir).GetNextResults(context.Background(), &scrapeContext)
+	require.Nil(t, err, "Non nil error from recording scraper")
+
+	files, err := filepath.Glob(filepath.Join(recordDir, "*"))
+	require.Nil(t, err)
+	require.Equal(t, []string{filepath.Join(recordDir, "r_testsub_new.json--limit%3D100")}, files)
+
+	scrapeContext = NewContextForNew("testsub")
+	replayed, err := NewReplayScraper(recordDir).GetNextResults(context.Background(), &scrapeContext)
+	require.Nil(t, err, "Non nil error from replay scraper")
+	require.Equal(t, recorded, replayed)
+}
+
+func TestServerErrorsAreTransient(t *testing.T) {
+	var statusCode int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statusCode)
+	}))
+	defer server.Close()
+	var scraper = NewJsonScraper(server.URL)
+
+	for _, statusCode = range []int{http.StatusServiceUnavailable, http.StatusTooManyRequests} {
+		var scrapeContext = NewContextForNew("testsub")
+		_, err := scraper.GetNextResults(context.Background(), &scrapeContext)
+		require.True(t, IsTransient(err), "Expected a transient error for status %d: %v", statusCode, err)
+	}
+	for _, statusCode = range []int{http.StatusNotFound, http.StatusForbidden} {
+		var scrapeContext = NewContextForNew("testsub")
+		_, err := scraper.GetNextResults(context.Background(), &scrapeContext)
+		require.NotNil(t, err, "Expected an error for status %d", statusCode)
+		require.False(t, IsTransient(err), "Expected a permanent error for status %d: %v", statusCode, err)
+	}
+}
+
+func TestRateLimitHeadersPauseTheLimiter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Ratelimit-Remaining", "0.0")
+		w.Header().Set("X-Ratelimit-Reset", "1")
+		fmt.Fprint(w, `{"kind": "Listing", "data": {"children": []}}`)
+	}))
+	defer server.Close()
+	var limiter = toolbox.NewRateLimiter(0)
+	var scraper = NewJsonScraper(server.URL)
+	scraper.client = newRateLimitedHttpClient(limiter, requestTimeout)
+
+	var scrapeContext = NewContextForNew("testsub")
+	_, err := scraper.GetNextResults(context.Background(), &scrapeContext)
+	require.Nil(t, err, "Non nil error from scraper")
+
+	// The limiter won't allow another request until the limit is reset.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	require.Equal(t, context.DeadlineExceeded, limiter.Wait(ctx))
+}
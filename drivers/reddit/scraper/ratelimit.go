@@ -0,0 +1,98 @@
+package reddit
+
+import (
+	"fmt"
+	"github.com/coverprice/contentscraper/toolbox"
+	log "github.com/sirupsen/logrus"
+	"github.com/turnage/graw/reddit"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// TransientError is returned by scrapers when a request failed for a reason that's likely to
+// go away if it's retried later, e.g. Reddit being overloaded or a network timeout.
+type TransientError struct {
+	Err error
+}
+
+func (this *TransientError) Error() string {
+	return this.Err.Error()
+}
+
+// IsTransient returns true if it's worth retrying the request that failed with the given error.
+func IsTransient(err error) bool {
+	_, ok := err.(*TransientError)
+	return ok
+}
+
+// httpStatusError is returned by JsonScraper when Reddit responds with something other than 200.
+type httpStatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (this *httpStatusError) Error() string {
+	return fmt.Sprintf("Unexpected HTTP status: %s", this.Status)
+}
+
+// newScrapeError returns an error with the given message, which is a TransientError if the
+// underlying cause is transient.
+func newScrapeError(cause error, format string, args ...interface{}) error {
+	var err = fmt.Errorf(format, args...)
+	if isTransientCause(cause) {
+		return &TransientError{Err: err}
+	}
+	return err
+}
+
+func isTransientCause(err error) bool {
+	switch err {
+	case reddit.BusyErr, reddit.RateLimitErr, reddit.GatewayErr, reddit.GatewayTimeoutErr:
+		return true
+	}
+	switch err := err.(type) {
+	case *httpStatusError:
+		return err.StatusCode == http.StatusTooManyRequests || err.StatusCode >= 500
+	case net.Error:
+		// Includes timeouts, refused connections, DNS failures, etc.
+		return true
+	}
+	return false
+}
+
+// rateLimitTransport is an http.RoundTripper that reads the rate limit headers of Reddit's
+// responses. When the limit is (nearly) used up, the RateLimiter is paused until it's reset.
+// (Reddit counts requests in 10 minute windows, so the RateLimiter's steady rate can still
+// exceed it, e.g. if other clients share the account).
+type rateLimitTransport struct {
+	base    http.RoundTripper
+	limiter *toolbox.RateLimiter
+}
+
+func newRateLimitedHttpClient(limiter *toolbox.RateLimiter, timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: &rateLimitTransport{base: http.DefaultTransport, limiter: limiter},
+		Timeout:   timeout,
+	}
+}
+
+func (this *rateLimitTransport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	if resp, err = this.base.RoundTrip(req); err != nil {
+		return
+	}
+	// Both are decimal numbers, e.g. "598.0" and "341".
+	remaining, errRemaining := strconv.ParseFloat(resp.Header.Get("X-Ratelimit-Remaining"), 64)
+	reset, errReset := strconv.ParseFloat(resp.Header.Get("X-Ratelimit-Reset"), 64)
+	if errRemaining == nil && errReset == nil && remaining < 1 {
+		var until = time.Now().Add(time.Duration(reset * float64(time.Second)))
+		log.Warnf("Reddit's rate limit has been reached, pausing requests until %s", until.Format(time.Kitchen))
+		this.limiter.PauseUntil(until)
+	} else if resp.StatusCode == http.StatusTooManyRequests {
+		if retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			this.limiter.PauseUntil(time.Now().Add(time.Duration(retryAfter) * time.Second))
+		}
+	}
+	return resp, nil
+}
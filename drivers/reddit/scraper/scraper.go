@@ -1,33 +1,60 @@
 package reddit
 
 import (
+	"context"
 	"fmt"
 	"github.com/coverprice/contentscraper/config"
 	"github.com/coverprice/contentscraper/drivers/reddit/types"
-	// log "github.com/sirupsen/logrus"
+	"github.com/coverprice/contentscraper/toolbox"
+	log "github.com/sirupsen/logrus"
 	"github.com/turnage/graw/reddit"
+	"html"
+	"sort"
 	"strings"
+	"time"
 )
 
 const (
-	useragent = "Fedora:github.com/coverprice/contentscraper:0.1.0 (by /u/jayzefrashe)"
+	useragent      = "Fedora:github.com/coverprice/contentscraper:0.1.0 (by /u/jayzefrashe)"
+	requestTimeout = 30 * time.Second
 )
 
+// redditClient is the part of graw's API that the Scraper uses. It's implemented by both
+// graw's (authenticated) Bot and its anonymous Script.
+type redditClient interface {
+	reddit.Lurker
+	ListingWithParams(path string, params map[string]string) (reddit.Harvest, error)
+}
+
+// IScraper is the interface the Harvester uses to retrieve posts & comments. It exists so that the
+// harvester can be driven by something other than the live API, e.g. recorded responses.
+// Cancelling ctx aborts a request that's in progress, and ctx.Err() is returned.
+type IScraper interface {
+	GetNextResults(ctx context.Context, scrapeContext *Context) ([]types.RedditPost, error)
+	GetTopComments(ctx context.Context, post *types.RedditPost, maxComments int) ([]types.RedditComment, error)
+}
+
+// Verify that Scraper implements the IScraper interface
+var _ IScraper = &Scraper{}
+
 type Scraper struct {
-	bot reddit.Bot
+	bot redditClient
 }
 
 // Parameters for a scrape request
 type Context struct {
-	Subreddit         string // Name of the subreddit to scrape
+	Source            string // Key of the source to scrape, e.g. the subreddit name (see config.Subreddit.SourceKey)
 	UrlPath           string // listing URL path, e.g. "/r/somereddit/new"
 	After             string // used for pagination
+	TimeWindow        string // For the "top" listing, the period it covers, e.g. "week"
+	Sort              string // For user & search listings, the listing's order, e.g. "new"
+	Query             string // For search listings, the search query
 	NumPostsPerScrape int
 }
 
 func NewDefaultContext(subreddit string) Context {
 	return Context{
-		Subreddit:         subreddit,
+		Source:            subreddit,
 		UrlPath:           fmt.Sprintf("/r/%s", subreddit),
 		After:             "",
 		NumPostsPerScrape: 100,
@@ -52,7 +79,92 @@ func NewContextForHot(subreddit string) Context {
 	return NewDefaultContext(subreddit)
 }
 
-func NewScraper(clientid, clientsecret, username, password string) (scraper *Scraper, err error) {
+// Scrapes the subreddit's "rising" listing. These are recent posts that are
+// quickly gaining votes.
+func NewContextForRising(subreddit string) Context {
+	context := NewDefaultContext(subreddit)
+	context.UrlPath = fmt.Sprintf("/r/%s/rising", subreddit)
+	return context
+}
+
+// Scrapes the subreddit's "top" listing, i.e. the highest scoring posts
+// created within the time window ("day", "week", etc).
+func NewContextForTop(subreddit, timeWindow string) Context {
+	context := NewDefaultContext(subreddit)
+	context.UrlPath = fmt.Sprintf("/r/%s/top", subreddit)
+	context.TimeWindow = timeWindow
+	return context
+}
+
+// NewContextForSource scrapes one of the listings in config.RedditListingNames (e.g. "top:week")
+// of the source with the given key (see config.Subreddit.SourceKey).
+func NewContextForSource(sourceKey, listing string) (context Context, err error) {
+	name, window := config.ParseRedditListing(listing)
+	sourceType, value := config.ParseRedditSourceKey(sourceKey)
+
+	switch sourceType {
+	case config.REDDIT_SOURCE_SUBREDDIT:
+		switch name {
+		case config.REDDIT_LISTING_NEW:
+			return NewContextForNew(value), nil
+		case config.REDDIT_LISTING_HOT:
+			return NewContextForHot(value), nil
+		case config.REDDIT_LISTING_RISING:
+			return NewContextForRising(value), nil
+		case config.REDDIT_LISTING_TOP:
+			return NewContextForTop(value, window), nil
+		}
+		return context, fmt.Errorf("Unknown listing: '%s'", listing)
+
+	case config.REDDIT_SOURCE_MULTIREDDIT:
+		// Multireddits have the same listings as subreddits, under /user/<user>/m/<name>
+		var parts = strings.SplitN(value, "/", 2)
+		if len(parts) != 2 {
+			return context, fmt.Errorf("Invalid multireddit: '%s'", value)
+		}
+		context = NewDefaultContext(sourceKey)
+		context.UrlPath = fmt.Sprintf("/user/%s/m/%s", parts[0], parts[1])
+		switch name {
+		case config.REDDIT_LISTING_HOT:
+		case config.REDDIT_LISTING_NEW, config.REDDIT_LISTING_RISING:
+			context.UrlPath += "/" + name
+		case config.REDDIT_LISTING_TOP:
+			context.UrlPath += "/top"
+			context.TimeWindow = window
+		default:
+			return context, fmt.Errorf("Unknown listing: '%s'", listing)
+		}
+		return context, nil
+
+	case config.REDDIT_SOURCE_USER:
+		context = NewDefaultContext(sourceKey)
+		context.UrlPath = fmt.Sprintf("/user/%s/submitted", value)
+
+	case config.REDDIT_SOURCE_SEARCH:
+		context = NewDefaultContext(sourceKey)
+		context.UrlPath = "/search"
+		context.Query = value
+
+	default:
+		return context, fmt.Errorf("Unknown source type: '%s'", sourceType)
+	}
+
+	// User & search listings are ordered by a parameter instead.
+	switch name {
+	case config.REDDIT_LISTING_NEW, config.REDDIT_LISTING_HOT:
+		context.Sort = name
+	case config.REDDIT_LISTING_TOP:
+		context.Sort = name
+		context.TimeWindow = window
+	default:
+		return context, fmt.Errorf("The '%s' listing is not available for source '%s'", listing, sourceKey)
+	}
+	return context, nil
+}
+
+// NewScraper creates a Scraper that logs in with the given credentials. If limiter is not nil,
+// it's paused whenever Reddit's responses say that the account's rate limit has been reached.
+func NewScraper(clientid, clientsecret, username, password string, limiter *toolbox.RateLimiter) (scraper *Scraper, err error) {
 	cfg := reddit.BotConfig{
 		Agent: useragent,
 		App: reddit.App{
@@ -62,6 +174,9 @@ func NewScraper(clientid, clientsecret, username, password string) (scraper *Scr
 			Password: password,
 		},
 	}
+	if limiter != nil {
+		cfg.Client = newRateLimitedHttpClient(limiter, requestTimeout)
+	}
 
 	scraper = &Scraper{}
 	if scraper.bot, err = reddit.NewBot(cfg); err != nil {
@@ -71,14 +186,48 @@ func NewScraper(clientid, clientsecret, username, password string) (scraper *Scr
 	return
 }
 
-func NewScraperFromConfig(conf *config.Config) (scraper *Scraper, err error) {
-	scraper, err = NewScraper(
+// NewAnonymousScraper creates a Scraper that needs no credentials. It reads Reddit's public
+// .json endpoints, making at most 1 request per requestInterval. Reddit limits anonymous
+// clients much more strictly than authenticated ones, and some sources (e.g. private
+// multireddits) are unavailable. graw doesn't expose the responses of anonymous requests, so
+// their rate limit headers are ignored.
+func NewAnonymousScraper(requestInterval time.Duration) (scraper *Scraper, err error) {
+	scraper = &Scraper{}
+	if scraper.bot, err = reddit.NewScript(useragent, requestInterval); err != nil {
+		err = fmt.Errorf("Could not create anonymous reddit client: %v", err)
+		return nil, err
+	}
+	return
+}
+
+// NewScraperFromConfig creates a Scraper that logs in with the configured credentials, or an
+// anonymous one if there aren't any. If record_responses_dir is configured, it instead creates a
+// JsonScraper that records every response in that directory, for use as test fixtures. limiter is
+// the RateLimiter the harvester uses, which is paused if Reddit says its limit has been reached.
+func NewScraperFromConfig(conf *config.Config, limiter *toolbox.RateLimiter) (scraper IScraper, err error) {
+	if conf.Reddit.RecordResponsesDir != "" {
+		log.Infof("Reddit responses will be recorded in %s", conf.Reddit.RecordResponsesDir)
+		var jsonScraper = NewRecordingScraper(DefaultBaseUrl, conf.Reddit.RecordResponsesDir)
+		if limiter != nil {
+			jsonScraper.client = newRateLimitedHttpClient(limiter, requestTimeout)
+		}
+		return jsonScraper, nil
+	}
+	if conf.Reddit.Secrets.IsEmpty() {
+		log.Info("No Reddit credentials configured, so Reddit will be harvested anonymously")
+		var requestInterval time.Duration
+		if conf.Reddit.RequestsPerMinute > 0 {
+			requestInterval = time.Minute / time.Duration(conf.Reddit.RequestsPerMinute)
+		}
+		return NewAnonymousScraper(requestInterval)
+	}
+	return NewScraper(
 		conf.Reddit.Secrets.ClientId,
 		conf.Reddit.Secrets.ClientSecret,
 		conf.Reddit.Secrets.Username,
 		conf.Reddit.Secrets.Password,
+		limiter,
 	)
-	return
 }
 
 // GetNextResults scrapes the current "page" of results and returns
@@ -88,17 +237,28 @@ func NewScraperFromConfig(conf *config.Config) (scraper *Scraper, err error) {
 // that the responses should immediately follow (according to whatever
 // ordering scheme is implicit in the scrape request. E.g. /new will be by
 // date, and /hot (the default) is an opaque combination of date + score.)
-func (this *Scraper) GetNextResults(context *Context) (posts []types.RedditPost, err error) {
+func (this *Scraper) GetNextResults(ctx context.Context, scrapeContext *Context) (posts []types.RedditPost, err error) {
 	// Get listing (~100 posts from that subreddit)
-	harvest, err := this.bot.ListingWithParams(
-		context.UrlPath,
-		map[string]string{
-			"limit": fmt.Sprintf("%d", context.NumPostsPerScrape),
-			"after": context.After,
-		},
-	)
+	var params = map[string]string{
+		"limit": fmt.Sprintf("%d", scrapeContext.NumPostsPerScrape),
+		"after": scrapeContext.After,
+	}
+	if scrapeContext.TimeWindow != "" {
+		params["t"] = scrapeContext.TimeWindow
+	}
+	if scrapeContext.Sort != "" {
+		params["sort"] = scrapeContext.Sort
+	}
+	if scrapeContext.Query != "" {
+		params["q"] = scrapeContext.Query
+	}
+	var harvest reddit.Harvest
+	err = withContext(ctx, func() (err error) {
+		harvest, err = this.bot.ListingWithParams(scrapeContext.UrlPath, params)
+		return
+	})
 	if err != nil {
-		return nil, fmt.Errorf("Failed to fetch listing for subreddit '%s': %v", context.Subreddit, err)
+		return nil, newScrapeError(err, "Failed to fetch listing for source '%s': %v", scrapeContext.Source, err)
 	}
 
 	for _, botpost := range harvest.Posts {
@@ -108,17 +268,87 @@ func (this *Scraper) GetNextResults(context *Context) (posts []types.RedditPost,
 			continue
 		}
 		posts = append(posts, redditPost)
-		context.After = redditPost.Name
+		scrapeContext.After = redditPost.Name
 	}
 	return posts, nil
 }
 
-// Create a new RedditPost object from the scraper client's format
+// GetTopComments retrieves the post's comment thread, and returns (at most) maxComments of its
+// top-level comments, highest score first. Deleted and removed comments are skipped.
+func (this *Scraper) GetTopComments(ctx context.Context, post *types.RedditPost, maxComments int) (comments []types.RedditComment, err error) {
+	var thread *reddit.Post
+	err = withContext(ctx, func() (err error) {
+		thread, err = this.bot.Thread(post.Permalink)
+		return
+	})
+	if err != nil {
+		return nil, newScrapeError(err, "Failed to fetch comments for post '%s': %v", post.Id, err)
+	}
+	return topCommentsFromThread(post, thread, maxComments), nil
+}
+
+// withContext calls request, which is one of graw's requests, and returns its error. graw's
+// requests can't be cancelled, so if ctx is cancelled first, ctx.Err() is returned straight away
+// and the request is left to finish in the background. (Its results are discarded).
+func withContext(ctx context.Context, request func() error) error {
+	var done = make(chan error, 1)
+	go func() {
+		done <- request()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// topCommentsFromThread picks the top comments out of a post's comment thread. (See GetTopComments)
+func topCommentsFromThread(post *types.RedditPost, thread *reddit.Post, maxComments int) (comments []types.RedditComment) {
+	for _, botcomment := range thread.Replies {
+		if botcomment.Deleted || botcomment.Body == "[deleted]" || botcomment.Body == "[removed]" {
+			continue
+		}
+		comments = append(comments, newRedditCommentFromBotComment(post.Id, botcomment))
+	}
+	sort.Stable(byCommentScore(comments))
+	if len(comments) > maxComments {
+		comments = comments[:maxComments]
+	}
+	for idx := range comments {
+		comments[idx].Rank = idx + 1
+	}
+	return comments
+}
+
+type byCommentScore []types.RedditComment
+
+func (a byCommentScore) Len() int      { return len(a) }
+func (a byCommentScore) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byCommentScore) Less(i, j int) bool {
+	return a[i].Score > a[j].Score
+}
+
+// Create a new RedditComment object from the scraper client's format
+func newRedditCommentFromBotComment(postId string, bc *reddit.Comment) (c types.RedditComment) {
+	c.Id = bc.ID
+	c.PostId = postId
+	c.Author = bc.Author
+	c.Body = bc.Body
+	c.Score = int64(bc.Ups) - int64(bc.Downs)
+	c.TimeCreated = int64(bc.CreatedUTC)
+	c.TimeStored = int64(bc.CreatedUTC)
+	return
+}
+
+// Create a new RedditPost object from the scraper client's format. graw's Post doesn't carry the
+// spoiler flag, so IsSpoiler is left false here.
 func newRedditPostFromBotPost(bp *reddit.Post) (p types.RedditPost) {
 	// Populate drivers.Post fields
 	p.Id = bp.ID
 	p.Name = bp.Name
 	p.Score = int64(bp.Score)
+	p.NumComments = int64(bp.NumComments)
 	p.TimeCreated = int64(bp.CreatedUTC)
 	p.TimeStored = int64(bp.CreatedUTC)
 	p.Permalink = bp.Permalink
@@ -126,6 +356,12 @@ func newRedditPostFromBotPost(bp *reddit.Post) (p types.RedditPost) {
 	p.IsSticky = bp.Stickied
 	p.Title = bp.Title
 	p.Url = bp.URL
+	p.Author = bp.Author
+	p.LinkFlair = bp.LinkFlairText
+	p.IsNsfw = bp.NSFW
+	p.SelfText = bp.SelfText
+	// Reddit's API HTML-escapes the rendered HTML.
+	p.SelfTextHtml = html.UnescapeString(bp.SelfTextHTML)
 	p.SubredditName = strings.ToLower(bp.Subreddit)
 	p.SubredditId = bp.SubredditID
 	return
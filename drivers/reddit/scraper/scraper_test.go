@@ -1,6 +1,7 @@
 package reddit
 
 import (
+	"context"
 	"testing"
 
 	"github.com/coverprice/contentscraper/config"
@@ -9,16 +10,16 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func initTestScraper(t *testing.T) *Scraper {
+func initTestScraper(t *testing.T) IScraper {
 	var conf *config.Config
 	var err error
 
 	if conf, err = config.GetConfig(); err != nil {
-		t.Errorf("Could not load/parse config file: %v", err)
+		t.Fatalf("Could not load/parse config file: %v", err)
 	}
 
-	var scraper *Scraper
-	if scraper, err = NewScraperFromConfig(conf); err != nil {
+	var scraper IScraper
+	if scraper, err = NewScraperFromConfig(conf, nil); err != nil {
 		t.Errorf("Could not initialize Scraper: %v", err)
 	}
 	return scraper
@@ -28,23 +29,23 @@ func initTestScraper(t *testing.T) *Scraper {
 // because only 1 scrape HTTP request is necessary)
 func TestCanScrape(t *testing.T) {
 	var (
-		scraper = initTestScraper(t)
-		context = NewContextForHot("bestoflegaladvice")
-		err     error
-		posts1  []types.RedditPost
-		posts2  []types.RedditPost
+		scraper       = initTestScraper(t)
+		scrapeContext = NewContextForHot("bestoflegaladvice")
+		err           error
+		posts1        []types.RedditPost
+		posts2        []types.RedditPost
 	)
 
-	context.NumPostsPerScrape = 10
+	scrapeContext.NumPostsPerScrape = 10
 
-	posts1, err = scraper.GetNextResults(&context)
+	posts1, err = scraper.GetNextResults(context.Background(), &scrapeContext)
 	require.Nil(t, err, "Non nil error from scraper")
 
 	t.Logf("Retrieved %d posts for 1st request", len(posts1))
 	require.NotEqual(t, 0, len(posts1), "Something went wrong, retrieved 0 posts for 1st request")
 
-	t.Logf("Scraper 'after' context is '%s'", context.After)
-	posts2, err = scraper.GetNextResults(&context)
+	t.Logf("Scraper 'after' context is '%s'", scrapeContext.After)
+	posts2, err = scraper.GetNextResults(context.Background(), &scrapeContext)
 	require.Nil(t, err, "Non nil error from scraper")
 
 	t.Logf("Retrieved %d posts for 2nd request", len(posts2))
@@ -60,3 +61,28 @@ func TestCanScrape(t *testing.T) {
 		t.Logf(toolbox.TruncateStr(post.Title, 40))
 	}
 }
+
+func TestNewContextForSource(t *testing.T) {
+	var tests = []struct {
+		sourceKey string
+		listing   string
+		expected  Context
+	}{
+		{"funny", "hot", Context{UrlPath: "/r/funny"}},
+		{"funny", "top:week", Context{UrlPath: "/r/funny/top", TimeWindow: "week"}},
+		{"multireddit:someone/animals", "new", Context{UrlPath: "/user/someone/m/animals/new"}},
+		{"multireddit:someone/animals", "hot", Context{UrlPath: "/user/someone/m/animals"}},
+		{"user:someone", "top:all", Context{UrlPath: "/user/someone/submitted", Sort: "top", TimeWindow: "all"}},
+		{"search:subreddit:golang self:yes", "new", Context{UrlPath: "/search", Sort: "new", Query: "subreddit:golang self:yes"}},
+	}
+	for _, test := range tests {
+		context, err := NewContextForSource(test.sourceKey, test.listing)
+		require.Nil(t, err, "Could not create context for %s %s", test.sourceKey, test.listing)
+		test.expected.Source = test.sourceKey
+		test.expected.NumPostsPerScrape = 100
+		require.Equal(t, test.expected, context)
+	}
+
+	_, err := NewContextForSource("user:someone", "rising")
+	require.NotNil(t, err, "Users have no rising listing")
+}
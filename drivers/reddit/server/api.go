@@ -0,0 +1,82 @@
+package server
+
+import (
+	"github.com/coverprice/contentscraper/config"
+	"github.com/coverprice/contentscraper/drivers"
+	persist "github.com/coverprice/contentscraper/drivers/reddit/persistence"
+	"github.com/coverprice/contentscraper/server/medialink"
+	"html/template"
+)
+
+// ApiPost is the JSON representation of a Reddit post, as served by the /api/v1/ endpoints.
+// (See API.md for the schema).
+type ApiPost struct {
+	Id           string               `json:"id"`
+	Name         string               `json:"name"`
+	Title        string               `json:"title"`
+	Url          string               `json:"url"`
+	Permalink    string               `json:"permalink"`
+	Subreddit    string               `json:"subreddit"`
+	SubredditId  string               `json:"subreddit_id"`
+	Score        int64                `json:"score"`
+	NumComments  int64                `json:"num_comments"`
+	Velocity     float64              `json:"velocity"`
+	IsActive     bool                 `json:"is_active"`
+	IsSticky     bool                 `json:"is_sticky"`
+	TimeCreated  int64                `json:"time_created"`
+	TimeStored   int64                `json:"time_stored"`
+	AgeInDays    int64                `json:"age_in_days"`
+	MediaLink    *medialink.MediaLink `json:"media_link"`
+	SelfText     string               `json:"selftext"`
+	SelfTextHtml template.HTML        `json:"selftext_html"`
+}
+
+// Verify that ApiPost implements the drivers.IApiPost interface
+var _ drivers.IApiPost = ApiPost{}
+
+func (this ApiPost) GetApiId() string {
+	return this.Id
+}
+
+// The ApiRequestHandler retrieves a feed's posts for the JSON API. They are the same
+// posts as the HtmlViewerRequestHandler shows, in the same order.
+type ApiRequestHandler struct {
+	postRetriever
+}
+
+func NewApiRequestHandler(persistence *persist.Persistence) *ApiRequestHandler {
+	return &ApiRequestHandler{
+		postRetriever: postRetriever{persistence: persistence},
+	}
+}
+
+func (this *ApiRequestHandler) GetPosts(feed *config.RedditFeed) (apiPosts []drivers.IApiPost, err error) {
+	var posts []annotatedPost
+	if posts, _, err = this.getPosts(feed); err != nil {
+		return
+	}
+	apiPosts = make([]drivers.IApiPost, 0, len(posts))
+	for _, post := range posts {
+		apiPosts = append(apiPosts, ApiPost{
+			Id:           post.Id,
+			Name:         post.Name,
+			Title:        post.Title,
+			Url:          post.Url,
+			Permalink:    "https://www.reddit.com" + post.Permalink,
+			Subreddit:    post.SubredditName,
+			SubredditId:  post.SubredditId,
+			Score:        post.Score,
+			NumComments:  post.NumComments,
+			Velocity:     post.Velocity,
+			IsActive:     post.IsActive,
+			IsSticky:     post.IsSticky,
+			TimeCreated:  post.TimeCreated,
+			TimeStored:   post.TimeStored,
+			AgeInDays:    post.AgeInDays,
+			MediaLink:    post.MediaLink,
+			SelfText:     post.SelfText,
+			SelfTextHtml: post.Body,
+		})
+	}
+	return apiPosts, nil
+}
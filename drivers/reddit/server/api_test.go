@@ -0,0 +1,75 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/coverprice/contentscraper/config"
+	"github.com/coverprice/contentscraper/database"
+	persist "github.com/coverprice/contentscraper/drivers/reddit/persistence"
+	"github.com/coverprice/contentscraper/drivers/reddit/types"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestApiPostsMatchAnnotatedPosts(t *testing.T) {
+	testDb, err := database.NewTestDatabase()
+	if err != nil {
+		t.Fatal("Could not init database", err)
+	}
+	defer testDb.Cleanup()
+
+	persistence, err := persist.NewPersistence(testDb.DbConn)
+	require.Nil(t, err, "Could not create persistence layer")
+
+	now := time.Now().Unix()
+	for i := 1; i <= 3; i++ {
+		_, err = persistence.StorePost(&types.RedditPost{
+			Id:            fmt.Sprintf("id_%d", i),
+			Name:          fmt.Sprintf("t3_id_%d", i),
+			TimeCreated:   now - int64(i),
+			TimeStored:    now - int64(i),
+			Permalink:     fmt.Sprintf("/r/apitest/comments/id_%d/", i),
+			IsActive:      true,
+			Score:         int64(i),
+			Title:         fmt.Sprintf("Post %d", i),
+			Url:           fmt.Sprintf("https://i.imgur.com/image%d.jpg", i),
+			SubredditName: "apitest",
+			SubredditId:   "t5_test",
+		})
+		require.Nil(t, err, "Could not store post")
+	}
+
+	feed := config.RedditFeed{
+		Name:  "apitest",
+		Media: config.MEDIA_TYPE_IMAGE,
+		Subreddits: []config.Subreddit{
+			config.Subreddit{Name: "apitest", Percentile: 100.0, MaxDailyPosts: 100},
+		},
+	}
+	sut := NewApiRequestHandler(persistence)
+	posts, err := sut.GetPosts(&feed)
+	require.Nil(t, err, "Could not get posts")
+	require.Equal(t, 3, len(posts))
+
+	// Same order as the HTML viewer, i.e. most recent first.
+	post := posts[0].(ApiPost)
+	require.Equal(t, "id_1", post.GetApiId())
+	require.Equal(t, "https://www.reddit.com/r/apitest/comments/id_1/", post.Permalink)
+	require.Equal(t, int64(0), post.AgeInDays)
+	require.NotNil(t, post.MediaLink, "The image link should have been resolved")
+	require.Equal(t, "https://i.imgur.com/image1.jpg", post.MediaLink.Url)
+
+	// Check the documented schema.
+	var decoded map[string]interface{}
+	blob, err := json.Marshal(posts[0])
+	require.Nil(t, err, "Could not encode post")
+	require.Nil(t, json.Unmarshal(blob, &decoded), "Could not decode post")
+	for _, key := range []string{
+		"id", "name", "title", "url", "permalink", "subreddit", "subreddit_id", "score",
+		"is_active", "is_sticky", "time_created", "time_stored", "age_in_days", "media_link",
+	} {
+		require.Contains(t, decoded, key)
+	}
+	require.Equal(t, "https://i.imgur.com/image1.jpg", decoded["media_link"].(map[string]interface{})["url"])
+}
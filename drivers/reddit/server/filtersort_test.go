@@ -5,6 +5,7 @@ import (
 	"github.com/coverprice/contentscraper/config"
 	"github.com/coverprice/contentscraper/drivers/reddit/types"
 	"github.com/stretchr/testify/require"
+	"math"
 	"testing"
 )
 
@@ -18,6 +19,7 @@ func TestFilterByMaxDailyPost(t *testing.T) {
 				SubredditName: subreddit,
 				Score:         score,
 			},
+			SourceKey: subreddit,
 			AgeInDays: ageInDays,
 		}
 	}
@@ -100,3 +102,11 @@ func TestFilterByMaxDailyPost(t *testing.T) {
 		require.Equal(t, er.ExpectedCnt, er.SeenCnt, "Expected to see subreddit %s with age %d", er.SubredditName, er.AgeInDays)
 	}
 }
+
+func TestGetVelocityAtPercentile(t *testing.T) {
+	var velocities = map[string]float64{"a": 10, "b": 40, "c": 20, "d": 30}
+	require.Equal(t, 40.0, getVelocityAtPercentile(velocities, 10.0))
+	require.Equal(t, 20.0, getVelocityAtPercentile(velocities, 50.0))
+	require.Equal(t, 10.0, getVelocityAtPercentile(velocities, 100.0))
+	require.True(t, math.IsInf(getVelocityAtPercentile(nil, 50.0), 1), "No velocities should pass nothing")
+}
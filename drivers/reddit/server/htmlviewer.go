@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"github.com/coverprice/contentscraper/config"
 	persist "github.com/coverprice/contentscraper/drivers/reddit/persistence"
+	"github.com/coverprice/contentscraper/drivers/reddit/types"
 	"github.com/coverprice/contentscraper/server/htmlutil"
-	// log "github.com/sirupsen/logrus"
+	log "github.com/sirupsen/logrus"
 	"net/http"
+	"time"
 )
 
 const (
@@ -19,17 +21,34 @@ var _ IRequestHandler = &HtmlViewerRequestHandler{}
 // The HtmlViewerRequestHandler handles a single request for a page within a specific
 // feed. It requests the posts from a separate class, and converts them to an HTML response.
 type HtmlViewerRequestHandler struct {
-	persistence *persist.Persistence
+	postRetriever
 }
 
 func NewHtmlViewerRequestHandler(persistence *persist.Persistence) *HtmlViewerRequestHandler {
 	return &HtmlViewerRequestHandler{
-		persistence: persistence,
+		postRetriever: postRetriever{persistence: persistence},
 	}
 }
 
 var htmlImageTemplateStr = `
     {{define "title"}}Reddit Feed - {{.Title}}{{end}}
+    {{define "style"}}
+    <style>
+    .commentbody {
+        white-space: pre-wrap;
+    }
+    .selftext.folded {
+        max-height: 15em;
+        overflow: hidden;
+    }
+    .feeditem.read .alert {
+        opacity: 0.6;
+    }
+    .star {
+        text-decoration: none;
+    }
+    </style>
+    {{end}}
     {{define "js"}}
     <script src="/static/imagesloaded.pkgd.min.js"></script>
     <script>
@@ -38,6 +57,10 @@ var htmlImageTemplateStr = `
         currentPageNum: {{.PageNum}},
         previousPageLink: '{{.PreviousPagelink.Link}}',
         nextPageLink: '{{.NextPagelink.Link}}',
+        seenUrl: '{{.SeenUrl}}',
+        hideSeen: {{.HideSeen}},
+        starUrl: '{{.StarUrl}}',
+        feedName: '{{.Title}}',
     };
     </script>
     <script src="/static/viewer.js"></script>
@@ -57,23 +80,35 @@ var htmlImageTemplateStr = `
     <h4>
         Reddit Feed: {{.Title}}
         <small class="text-muted">{{.Description}}</small>
+        <small><a href="{{.HideSeenToggle}}">{{if .HideSeen}}Show read posts{{else}}Hide read posts{{end}}</a></small>
     </h4>
 
     {{template "pagination" .}}
 
     <div class="container-fluid">
         {{range $itemIndex, $post := .Posts}}
-        <div class="row feeditem">
+        <div class="row feeditem{{if .IsRead}} read{{end}}" data-post="{{.Key}}" data-starred="{{.IsStarred}}">
             <div class="col">
                 <div class="container-fluid">
                     <div class="row">
                         <div class="col alert alert-info">
+                            <a href="#" class="star" title="Star this post">{{if .IsStarred}}&#9733;{{else}}&#9734;{{end}}</a>
+                            {{if .IsNew}}<span class="badge badge-primary">New</span>{{end}}
                             <a href="https://www.reddit.com{{.Permalink}}">{{.Title}}</a>
                             <small>Score: {{.Score}}</small>
+                            {{if .Velocity}}<small>Rising: {{printf "%.0f" .Velocity}}/hour</small>{{end}}
                             <small>Days old: {{.AgeInDays}}</small>
                             <small class="text-muted">{{.SubredditName}}</small>
                         </div>
                     </div>
+                    {{if .Body}}
+                    <div class="row">
+                        <div class="col">
+                            <div class="selftext{{if .IsLongBody}} folded{{end}}">{{.Body}}</div>
+                            {{if .IsLongBody}}<a href="#" class="showmore"><small>Show more</small></a>{{end}}
+                        </div>
+                    </div>
+                    {{end}}
                     {{if .MediaLink}}
                     <div class="row">
                         <div class="col">
@@ -98,6 +133,21 @@ var htmlImageTemplateStr = `
                         </div>
                     </div>
                     {{end}}
+                    {{if .Comments}}
+                    <div class="row">
+                        <div class="col">
+                            <details>
+                                <summary><small>Top {{len .Comments}} comments</small></summary>
+                                {{range .Comments}}
+                                <div class="card card-body">
+                                    <small class="text-muted">{{.Author}} &middot; Score: {{.Score}}</small>
+                                    <div class="commentbody">{{.Body}}</div>
+                                </div>
+                                {{end}}
+                            </details>
+                        </div>
+                    </div>
+                    {{end}}
                 </div>
             </div>
         </div>
@@ -106,6 +156,17 @@ var htmlImageTemplateStr = `
 
     {{template "pagination" .}}
 
+    {{if .RuleRemovals}}
+    <details>
+        <summary><small>Posts removed by the feed's rules</small></summary>
+        <table class="table table-sm">
+            {{range .RuleRemovals}}
+            <tr><td><small>{{.Rule}}</small></td><td><small>{{.NumRemoved}}</small></td></tr>
+            {{end}}
+        </table>
+    </details>
+    {{end}}
+
     {{end}}
 `
 var htmlImageTempl = htmlutil.ParseTemplate(htmlImageTemplateStr)
@@ -121,29 +182,71 @@ func (this *HtmlViewerRequestHandler) HandleFeed(
 	feed *config.RedditFeed,
 	pageNum int,
 	w http.ResponseWriter,
+	r *http.Request,
 ) {
 	if pageNum == 0 {
 		pageNum = 1
 	}
 
-	posts, err := this.getPosts(feed)
+	// These set cookies, so must precede writing the response.
+	viewerId := htmlutil.GetViewerId(w, r)
+	hideSeen := getHideSeen(feed.Name, w, r)
+
+	now := time.Now().Unix()
+	posts, removals, err := this.getPosts(feed)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Internal error retrieving posts for feed: %s %v", feed.Name, err), 500)
 		return
 	}
+	seenPosts, err := this.getSeenPosts(viewerId, now)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal error retrieving seen posts for feed: %s %v", feed.Name, err), 500)
+		return
+	}
+	if hideSeen {
+		posts = filterOutReadPosts(posts, seenPosts)
+	}
 
 	itemsPerPage := NUM_ITEMS_PER_PAGE
 	numPages := (len(posts) + NUM_ITEMS_PER_PAGE - 1) / NUM_ITEMS_PER_PAGE
 	startIdx := itemsPerPage * (pageNum - 1)
 
-	if startIdx >= len(posts) || startIdx+itemsPerPage >= len(posts) {
+	if startIdx >= len(posts) {
 		// Out of bounds.
 		posts = []annotatedPost{}
 	} else {
-		posts = posts[startIdx : startIdx+itemsPerPage]
+		endIdx := startIdx + itemsPerPage
+		if endIdx > len(posts) {
+			endIdx = len(posts)
+		}
+		// Copied, so that decorating them doesn't modify the cached posts.
+		posts = append([]annotatedPost(nil), posts[startIdx:endIdx]...)
+	}
+	if err = this.decoratePostsWithComments(posts); err != nil {
+		http.Error(w, fmt.Sprintf("Internal error retrieving comments for feed: %s %v", feed.Name, err), 500)
+		return
+	}
+	decoratePostsWithSeen(posts, seenPosts)
+	starredKeys, err := this.getStarredKeys(viewerId)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal error retrieving starred posts for feed: %s %v", feed.Name, err), 500)
+		return
+	}
+	for i, _ := range posts {
+		posts[i].IsStarred = starredKeys[posts[i].Key().String()]
+	}
+	var shownKeys []types.PostKey
+	for _, post := range posts {
+		shownKeys = append(shownKeys, post.Key())
+	}
+	if err = this.persistence.MarkPostsShown(viewerId, shownKeys, now); err != nil {
+		// Not worth failing the request over.
+		log.Errorf("Could not record the posts shown for feed: %s %v", feed.Name, err)
 	}
 
-	pagelinks := getPagelinks(feed.Name, pageNum, numPages)
+	// Keep any sort order chosen by the viewer when changing page.
+	sortName := r.URL.Query().Get("sort")
+	pagelinks := getPagelinks(feed.Name, sortName, pageNum, numPages)
 	data := struct {
 		Title       string
 		Description string
@@ -154,6 +257,11 @@ func (this *HtmlViewerRequestHandler) HandleFeed(
 		NextPagelink     pagelink
 		NumPages         int
 		PageNum          int
+		RuleRemovals     []ruleRemoval
+		HideSeen         bool
+		HideSeenToggle   string
+		SeenUrl          string
+		StarUrl          string
 	}{
 		Title:       feed.Name,
 		Description: feed.Description,
@@ -167,14 +275,19 @@ func (this *HtmlViewerRequestHandler) HandleFeed(
 		NextPagelink:     pagelinks[len(pagelinks)-1],
 		NumPages:         numPages,
 		PageNum:          pageNum,
+		RuleRemovals:     removals,
+		HideSeen:         hideSeen,
+		HideSeenToggle:   constructHideSeenUrl(feed.Name, sortName, !hideSeen),
+		SeenUrl:          constructSeenUrl(),
+		StarUrl:          constructStarUrl(),
 	}
 	htmlutil.RenderTemplate(w, htmlImageTempl, data)
 }
 
-func getPagelinks(feedname string, pageNum, numPages int) (links []pagelink) {
+func getPagelinks(feedname string, sortName string, pageNum, numPages int) (links []pagelink) {
 	link := pagelink{
 		Text:          "Previous",
-		Link:          constructUrl(&feedname, pageNum-1),
+		Link:          constructUrl(&feedname, sortName, pageNum-1),
 		IsEnabled:     true,
 		IsHighlighted: false,
 	}
@@ -186,7 +299,7 @@ func getPagelinks(feedname string, pageNum, numPages int) (links []pagelink) {
 	for pn := 1; pn <= numPages; pn++ {
 		link = pagelink{
 			Text:          fmt.Sprintf("%d", pn),
-			Link:          constructUrl(&feedname, pn),
+			Link:          constructUrl(&feedname, sortName, pn),
 			IsEnabled:     true,
 			IsHighlighted: (pageNum == pn),
 		}
@@ -194,7 +307,7 @@ func getPagelinks(feedname string, pageNum, numPages int) (links []pagelink) {
 	}
 	link = pagelink{
 		Text:          "Next",
-		Link:          constructUrl(&feedname, pageNum+1),
+		Link:          constructUrl(&feedname, sortName, pageNum+1),
 		IsEnabled:     true,
 		IsHighlighted: false,
 	}
@@ -0,0 +1,179 @@
+package server
+
+import (
+	"fmt"
+	"github.com/coverprice/contentscraper/config"
+	"github.com/coverprice/contentscraper/database"
+	persist "github.com/coverprice/contentscraper/drivers/reddit/persistence"
+	"github.com/coverprice/contentscraper/drivers/reddit/types"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTopCommentsAreShownUnderPosts(t *testing.T) {
+	testDb, err := database.NewTestDatabase()
+	if err != nil {
+		t.Fatal("Could not init database", err)
+	}
+	defer testDb.Cleanup()
+
+	persistence, err := persist.NewPersistence(testDb.DbConn)
+	require.Nil(t, err, "Could not create persistence layer")
+
+	now := time.Now().Unix()
+	for i := 1; i <= NUM_ITEMS_PER_PAGE+1; i++ {
+		_, err = persistence.StorePost(&types.RedditPost{
+			Id:            fmt.Sprintf("id_%d", i),
+			Name:          fmt.Sprintf("t3_id_%d", i),
+			TimeCreated:   now - int64(i),
+			TimeStored:    now - int64(i),
+			Permalink:     fmt.Sprintf("/r/commenttest/comments/id_%d/", i),
+			IsActive:      true,
+			Score:         int64(i),
+			Title:         fmt.Sprintf("Post %d", i),
+			SubredditName: "commenttest",
+			SubredditId:   "t5_test",
+		})
+		require.Nil(t, err, "Could not store post")
+	}
+	err = persistence.ReplaceComments("id_1", []types.RedditComment{
+		types.RedditComment{Id: "c1", PostId: "id_1", Rank: 1, Author: "someone", Body: "<b>Not bold</b>", Score: 42},
+	})
+	require.Nil(t, err, "Could not store comments")
+
+	feed := &config.RedditFeed{
+		Name:        "commenttest",
+		Description: "A test feed",
+		Media:       config.MEDIA_TYPE_TEXT,
+		Subreddits: []config.Subreddit{
+			config.Subreddit{Name: "commenttest", Percentile: 100.0, MaxDailyPosts: 100},
+		},
+	}
+	w := httptest.NewRecorder()
+	NewHtmlViewerRequestHandler(persistence).HandleFeed(feed, 1, w, httptest.NewRequest("GET", "/", nil))
+	require.Equal(t, 200, w.Code)
+	require.Contains(t, w.Body.String(), "Top 1 comments")
+	require.Contains(t, w.Body.String(), "Score: 42")
+	// Comment bodies are escaped
+	require.Contains(t, w.Body.String(), "&lt;b&gt;Not bold&lt;/b&gt;")
+}
+
+func TestSelfPostBodiesAreSanitizedAndFolded(t *testing.T) {
+	testDb, err := database.NewTestDatabase()
+	if err != nil {
+		t.Fatal("Could not init database", err)
+	}
+	defer testDb.Cleanup()
+
+	persistence, err := persist.NewPersistence(testDb.DbConn)
+	require.Nil(t, err, "Could not create persistence layer")
+
+	now := time.Now().Unix()
+	for i := 1; i <= NUM_ITEMS_PER_PAGE+1; i++ {
+		var post = types.RedditPost{
+			Id:            fmt.Sprintf("id_%d", i),
+			Name:          fmt.Sprintf("t3_id_%d", i),
+			TimeCreated:   now - int64(i),
+			TimeStored:    now - int64(i),
+			Permalink:     fmt.Sprintf("/r/selftexttest/comments/id_%d/", i),
+			IsActive:      true,
+			Score:         int64(i),
+			Title:         fmt.Sprintf("Post %d", i),
+			SubredditName: "selftexttest",
+			SubredditId:   "t5_test",
+		}
+		switch i {
+		case 1:
+			post.SelfText = "Short *post*"
+			post.SelfTextHtml = `<div class="md"><p>Short <em>post</em><script>alert(1)</script></p></div>`
+		case 2:
+			post.SelfText = strings.Repeat("Long post. ", 200)
+			post.SelfTextHtml = "<p>" + post.SelfText + "</p>"
+		}
+		_, err = persistence.StorePost(&post)
+		require.Nil(t, err, "Could not store post")
+	}
+
+	feed := &config.RedditFeed{
+		Name:        "selftexttest",
+		Description: "A test feed",
+		Media:       config.MEDIA_TYPE_TEXT,
+		Subreddits: []config.Subreddit{
+			config.Subreddit{Name: "selftexttest", Percentile: 100.0, MaxDailyPosts: 100},
+		},
+	}
+	w := httptest.NewRecorder()
+	NewHtmlViewerRequestHandler(persistence).HandleFeed(feed, 1, w, httptest.NewRequest("GET", "/", nil))
+	require.Equal(t, 200, w.Code)
+	var body = w.Body.String()
+	require.Contains(t, body, `<div class="selftext"><div><p>Short <em>post</em></p></div></div>`)
+	require.NotContains(t, body, "alert(1)")
+	require.Equal(t, 1, strings.Count(body, `class="selftext folded"`), "Only the long post should be folded")
+	require.Equal(t, 1, strings.Count(body, "Show more"))
+}
+
+func TestRisingPostsAreIncludedInVelocityFeeds(t *testing.T) {
+	testDb, err := database.NewTestDatabase()
+	if err != nil {
+		t.Fatal("Could not init database", err)
+	}
+	defer testDb.Cleanup()
+
+	persistence, err := persist.NewPersistence(testDb.DbConn)
+	require.Nil(t, err, "Could not create persistence layer")
+
+	const hour = 60 * 60
+	created := time.Now().Unix() - 30*hour
+	storePost := func(id string, score int64, timeObserved int64) {
+		var post = types.RedditPost{
+			Id:            id,
+			Name:          "t3_" + id,
+			TimeCreated:   created,
+			TimeStored:    created,
+			Permalink:     "/r/velocitytest/comments/" + id + "/",
+			IsActive:      true,
+			Score:         score,
+			Title:         "Post " + id,
+			SubredditName: "velocitytest",
+			SubredditId:   "t5_test",
+		}
+		_, err := persistence.StorePost(&post)
+		require.Nil(t, err, "Could not store post")
+		require.Nil(t, persistence.RecordScore(&post, timeObserved), "Could not record score")
+	}
+	// Popular posts, first observed after they'd been around for a day
+	for i := 1; i <= 10; i++ {
+		storePost(fmt.Sprintf("popular_%d", i), int64(i*100), created+24*hour)
+	}
+	// A post that gained 50 upvotes in its first hour, but hasn't passed the percentile (yet)
+	storePost("rising", 50, created+hour)
+
+	getPostIds := func(ranking string) (ids []string) {
+		feed := &config.RedditFeed{
+			Name:          "velocitytest",
+			Ranking:       ranking,
+			VelocityHours: 12,
+			Subreddits: []config.Subreddit{
+				config.Subreddit{Name: "velocitytest", Percentile: 20.0, MaxDailyPosts: 100},
+			},
+		}
+		retriever := &postRetriever{persistence: persistence}
+		posts, _, err := retriever.getPostsImpl(time.Now().Unix(), feed)
+		require.Nil(t, err, "Could not retrieve posts")
+		for _, post := range posts {
+			ids = append(ids, post.Id)
+			if post.Id == "rising" {
+				require.Equal(t, 50.0, post.Velocity)
+			}
+		}
+		return
+	}
+
+	// The top 20% by score (in display order, i.e. by time stored, then ID)
+	require.Equal(t, []string{"popular_10", "popular_8", "popular_9"}, getPostIds(config.REDDIT_RANKING_PERCENTILE))
+	// ...plus the top 20% by velocity.
+	require.Equal(t, []string{"popular_10", "popular_8", "popular_9", "rising"}, getPostIds(config.REDDIT_RANKING_VELOCITY))
+}
@@ -1,11 +1,16 @@
 package server
 
 import (
+	"fmt"
+	"github.com/coverprice/contentscraper/config"
 	"github.com/coverprice/contentscraper/drivers/reddit/types"
+	"github.com/coverprice/contentscraper/server/syndication"
+	"github.com/coverprice/contentscraper/toolbox"
 	log "github.com/sirupsen/logrus"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 )
 
 // Verify that HttpHandler implements http.Handler interface
@@ -13,24 +18,46 @@ var _ http.Handler = &HttpHandler{}
 
 // HttpHandler is attached to the standard "http" server, bound to a base URL.
 // It knows the base URL and understands HTTP. It parses the URL and delegates
-// the response to the HtmlViewerRequestHandler.
+// the response to the IRequestHandler for the requested format, e.g. the
+// HtmlViewerRequestHandler. The viewer's actions (e.g. marking posts read) are
+// POSTed to their own paths, and handled by the action's handler.
 type HttpHandler struct {
-	requestHandler IRequestHandler
+	requestHandlers map[string]IRequestHandler // Format (e.g. syndication.FORMAT_HTML) -> handler
+	actionHandlers  map[string]http.Handler    // Path (e.g. SeenUrlPath) -> handler
 }
 
-func NewHttpHandler(requestHandler IRequestHandler) *HttpHandler {
+func NewHttpHandler(
+	requestHandlers map[string]IRequestHandler,
+	actionHandlers map[string]http.Handler,
+) *HttpHandler {
 	handler := HttpHandler{
-		requestHandler: requestHandler,
+		requestHandlers: requestHandlers,
+		actionHandlers:  actionHandlers,
 	}
 	return &handler
 }
 
 func (this HttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if actionHandler, ok := this.actionHandlers[r.URL.Path]; ok {
+		actionHandler.ServeHTTP(w, r)
+		return
+	}
 	values, err := url.ParseQuery(r.URL.RawQuery)
 	if err != nil {
 		http.Error(w, "Invalid request. Cannot parse URL query", 500)
 		return
 	}
+	// The base URL has been stripped from the path, so all that's left is the (optional) format.
+	format, err := syndication.GetRequestedFormat(r, r.URL.Path)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request. %v", err), 400)
+		return
+	}
+	requestHandler, ok := this.requestHandlers[format]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
 	feedname := values.Get("feed")
 	feed, err := types.FeedRegistry.GetItemByName(feedname)
 	if err != nil {
@@ -48,5 +75,15 @@ func (this HttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request. Cannot parse page number", 500)
 		return
 	}
-	this.requestHandler.HandleFeed(&feed.RedditFeed, pagenum, w)
+
+	// The viewer may override the feed's sort order.
+	var redditFeed = feed.RedditFeed
+	if sortName := strings.ToLower(values.Get("sort")); sortName != "" {
+		if !toolbox.ContainsStr(config.RedditSortNames, sortName) {
+			http.Error(w, fmt.Sprintf("Invalid request. Unknown sort: '%s'", sortName), 400)
+			return
+		}
+		redditFeed.Sort = sortName
+	}
+	requestHandler.HandleFeed(&redditFeed, pagenum, w, r)
 }
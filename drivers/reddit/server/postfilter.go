@@ -2,55 +2,118 @@ package server
 
 import (
 	"github.com/coverprice/contentscraper/config"
+	persist "github.com/coverprice/contentscraper/drivers/reddit/persistence"
 	"github.com/coverprice/contentscraper/drivers/reddit/types"
+	"github.com/coverprice/contentscraper/server/htmlutil"
 	"github.com/coverprice/contentscraper/server/medialink"
 	log "github.com/sirupsen/logrus"
+	"html/template"
+	"math"
 	"sort"
+	"sync"
 	"time"
 )
 
+// postRetriever retrieves a feed's posts, filtered and sorted into display order. It's
+// shared by the IRequestHandlers so that every presentation format shows the same posts.
+type postRetriever struct {
+	persistence *persist.Persistence
+}
+
 type annotatedPost struct {
 	types.RedditPost
-	AgeInDays int64 // how many days old this post is.
-	MediaLink *medialink.MediaLink
+	SourceKey string  // The source the post was retrieved from. (See config.Subreddit.SourceKey)
+	AgeInDays int64   // how many days old this post is.
+	Velocity  float64 // For feeds ranked by velocity, the upvotes/hour soon after the post was created.
+	// The score at the source's percentile cutoff. (Used by the normalized sort)
+	PercentileScore int64
+	MediaLink       *medialink.MediaLink
+	Body            template.HTML         // The sanitized body of a self post.
+	IsLongBody      bool                  // Whether the body is long enough to be folded by default.
+	Comments        []types.RedditComment // The post's top comments. Only populated for display.
+	IsNew           bool                  // Whether the viewer hasn't been shown the post before. Only populated for display.
+	IsRead          bool                  // Whether the viewer has read the post. Only populated for display.
+	IsStarred       bool                  // Whether the viewer has starred the post. Only populated for display.
 }
 
+// Feeds show posts stored within this many seconds.
+const MAX_POST_AGE = 7 * 24 * 60 * 60
+
+// Self post bodies longer than this many characters of Markdown are folded by default.
+const LONG_BODY_LENGTH = 1000
+
 type cachedPosts struct {
-	Posts       []annotatedPost
-	TimeCreated int64
+	Posts        []annotatedPost
+	RuleRemovals []ruleRemoval
+	TimeCreated  int64
 }
 
 var postCache = make(map[string]cachedPosts)
 
+// The web server's requests, and config reloads, may access the postCache concurrently.
+var postCacheLock sync.Mutex
+
+// InvalidatePostCache drops the named feeds' cached posts, e.g. because their config changed.
+func InvalidatePostCache(feedNames []string) {
+	postCacheLock.Lock()
+	defer postCacheLock.Unlock()
+	for _, feedName := range feedNames {
+		delete(postCache, feedName)
+	}
+}
+
 // getPosts retrieves all the posts for the given feed, and sorts them in
-// display order.
-// (The result may be large, and is expected to be cached).
-func (this *HtmlViewerRequestHandler) getPosts(
+// display order using the feed's ranker. It also returns the # of posts removed
+// by each of the feed's include/exclude rules.
+// (The filtered posts may be large, and are cached. The ranking is not, since it can be
+// overridden per-request, so each call returns a fresh copy).
+func (this *postRetriever) getPosts(
 	feed *config.RedditFeed,
-) (posts []annotatedPost, err error) {
+) (posts []annotatedPost, removals []ruleRemoval, err error) {
 	now := int64(time.Now().Unix())
 
+	var cache cachedPosts
+	if cache, err = this.getCachedPosts(now, feed); err != nil {
+		return
+	}
+	posts = append([]annotatedPost(nil), cache.Posts...)
+	if ranker := getRanker(feed.Sort); ranker != nil {
+		ranker.Rank(posts, now)
+	}
+	return posts, cache.RuleRemovals, nil
+}
+
+// getCachedPosts returns the feed's cached posts, retrieving them again if they're over an hour
+// old. The lock is held throughout, so that a config reload's InvalidatePostCache can't be
+// overwritten by posts retrieved with the old config.
+func (this *postRetriever) getCachedPosts(now int64, feed *config.RedditFeed) (cache cachedPosts, err error) {
+	postCacheLock.Lock()
+	defer postCacheLock.Unlock()
+
 	cache, ok := postCache[feed.Name]
-	if !ok || (cache.TimeCreated+1*60*60 < now) {
-		posts, err = this.getPostsImpl(now, feed)
-		if err != nil {
-			return
-		}
-		cache = cachedPosts{
-			Posts:       posts,
-			TimeCreated: now,
-		}
-		postCache[feed.Name] = cache
+	if ok && cache.TimeCreated+1*60*60 >= now {
+		return cache, nil
 	}
-	return cache.Posts, nil
+	var posts []annotatedPost
+	var removals []ruleRemoval
+	if posts, removals, err = this.getPostsImpl(now, feed); err != nil {
+		return
+	}
+	cache = cachedPosts{
+		Posts:        posts,
+		RuleRemovals: removals,
+		TimeCreated:  now,
+	}
+	postCache[feed.Name] = cache
+	return cache, nil
 }
 
-func (this *HtmlViewerRequestHandler) getPostsImpl(
+func (this *postRetriever) getPostsImpl(
 	now int64,
 	feed *config.RedditFeed,
-) (posts []annotatedPost, err error) {
+) (posts []annotatedPost, removals []ruleRemoval, err error) {
 
-	minTime := int64(now - 7*24*60*60)
+	minTime := int64(now - MAX_POST_AGE)
 	if posts, err = this.getPostsFilteredByPercentile(minTime, feed); err != nil {
 		return
 	}
@@ -61,6 +124,12 @@ func (this *HtmlViewerRequestHandler) getPostsImpl(
 	// Convert image links into embedded links
 	decoratePostsWithMediaLinks(posts)
 
+	// Sanitize the bodies of self posts
+	decoratePostsWithBody(posts)
+
+	// Filter out posts that fail the feed's (or their subreddit's) include/exclude rules
+	posts, removals = filterByRules(posts, feed)
+
 	if feed.Media == config.MEDIA_TYPE_IMAGE {
 		// Filter out posts with images that can't be embedded
 		posts = filterOutEmptyImages(posts)
@@ -69,7 +138,7 @@ func (this *HtmlViewerRequestHandler) getPostsImpl(
 	// Filter out posts that exceed the max_daily_posts criteria
 	posts = filterByMaxDailyPosts(posts, feed)
 
-	// Sort into display order
+	// Sort into the default (chronological) display order
 	sortPostsIntoDisplayOrder(posts)
 	return
 }
@@ -107,12 +176,42 @@ func decoratePostsWithMediaLinks(posts []annotatedPost) {
 	}
 }
 
+func decoratePostsWithBody(posts []annotatedPost) {
+	for i, _ := range posts {
+		if posts[i].SelfTextHtml != "" {
+			posts[i].Body = htmlutil.SanitizeHtml(posts[i].SelfTextHtml)
+		} else if posts[i].SelfText != "" {
+			// No rendered HTML, so show the Markdown as-is.
+			posts[i].Body = template.HTML(`<p style="white-space: pre-wrap">` + template.HTMLEscapeString(posts[i].SelfText) + "</p>")
+		}
+		posts[i].IsLongBody = len(posts[i].SelfText) > LONG_BODY_LENGTH
+	}
+}
+
+// decoratePostsWithComments attaches the stored top comments to the posts. Since the comments
+// are refreshed more frequently than the cached posts, this is done only for the posts that are
+// about to be displayed.
+func (this *postRetriever) decoratePostsWithComments(posts []annotatedPost) (err error) {
+	var postIds []string
+	for _, post := range posts {
+		postIds = append(postIds, post.Id)
+	}
+	var comments map[string][]types.RedditComment
+	if comments, err = this.persistence.GetCommentsForPosts(postIds); err != nil {
+		return
+	}
+	for i, _ := range posts {
+		posts[i].Comments = comments[posts[i].Id]
+	}
+	return nil
+}
+
 type ByFeedAgeScore []annotatedPost
 
 func (a ByFeedAgeScore) Len() int      { return len(a) }
 func (a ByFeedAgeScore) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
 func (a ByFeedAgeScore) Less(i, j int) bool {
-	if a[i].SubredditName == a[j].SubredditName {
+	if a[i].SourceKey == a[j].SourceKey {
 		if a[i].AgeInDays == a[j].AgeInDays {
 			// Score DESC
 			return a[i].Score >= a[j].Score
@@ -121,11 +220,24 @@ func (a ByFeedAgeScore) Less(i, j int) bool {
 			return a[i].AgeInDays < a[j].AgeInDays
 		}
 	} else {
-		// SubredditName ASC
-		return a[i].SubredditName < a[j].SubredditName
+		// SourceKey ASC
+		return a[i].SourceKey < a[j].SourceKey
 	}
 }
 
+// byFeedAgeVelocity is ByFeedAgeScore for feeds ranked by velocity.
+type byFeedAgeVelocity []annotatedPost
+
+func (a byFeedAgeVelocity) Len() int      { return len(a) }
+func (a byFeedAgeVelocity) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byFeedAgeVelocity) Less(i, j int) bool {
+	if a[i].SourceKey == a[j].SourceKey && a[i].AgeInDays == a[j].AgeInDays && a[i].Velocity != a[j].Velocity {
+		// Velocity DESC
+		return a[i].Velocity > a[j].Velocity
+	}
+	return ByFeedAgeScore(a).Less(i, j)
+}
+
 type byTimeStoredId []annotatedPost
 
 func (a byTimeStoredId) Len() int      { return len(a) }
@@ -153,61 +265,105 @@ func filterOutEmptyImages(posts []annotatedPost) (results []annotatedPost) {
 }
 
 func filterByMaxDailyPosts(posts []annotatedPost, feed *config.RedditFeed) (results []annotatedPost) {
-	var subredditToMaxDailyPosts = make(map[string]int) // Subreddit name -> Max daily posts
+	var sourceToMaxDailyPosts = make(map[string]int) // Source key -> Max daily posts
 	for _, subreddit := range feed.Subreddits {
-		subredditToMaxDailyPosts[subreddit.Name] = subreddit.MaxDailyPosts
+		sourceToMaxDailyPosts[subreddit.SourceKey()] = subreddit.MaxDailyPosts
 	}
 
-	// Sort posts by Subreddit, AgeInDays, Score(DESC)
-	sort.Sort(ByFeedAgeScore(posts))
+	// Sort posts by Source, AgeInDays, Score(DESC) (or Velocity(DESC))
+	if feed.Ranking == config.REDDIT_RANKING_VELOCITY {
+		sort.Sort(byFeedAgeVelocity(posts))
+	} else {
+		sort.Sort(ByFeedAgeScore(posts))
+	}
 
-	// posts are expected to be sorted by Subreddit / AgeInDays / Score (DESC)
+	// posts are expected to be sorted by Source / AgeInDays / Score (DESC)
 	dailyPostCnt := 0
-	currentSubreddit := ""
+	currentSource := ""
 	currentAgeInDays := int64(-1)
 	for _, post := range posts {
-		if currentSubreddit != post.SubredditName || currentAgeInDays != post.AgeInDays {
-			currentSubreddit = post.SubredditName
+		if currentSource != post.SourceKey || currentAgeInDays != post.AgeInDays {
+			currentSource = post.SourceKey
 			currentAgeInDays = post.AgeInDays
 			dailyPostCnt = 0
 		}
 		dailyPostCnt++
-		if dailyPostCnt <= subredditToMaxDailyPosts[currentSubreddit] {
+		if dailyPostCnt <= sourceToMaxDailyPosts[currentSource] {
 			results = append(results, post)
 		}
 	}
 	return
 }
 
-// Retrieves posts for the feed, applying per-subreddit percentile filters.
+// Retrieves posts for the feed, applying per-source percentile filters. For feeds ranked by
+// velocity, posts whose velocity is in the source's top percentile are also included. A post
+// included in several of the feed's sources is only retrieved for the first of them.
 // Returned posts are NOT sorted.
-func (this *HtmlViewerRequestHandler) getPostsFilteredByPercentile(
+func (this *postRetriever) getPostsFilteredByPercentile(
 	minTime int64,
 	feed *config.RedditFeed,
 ) (posts []annotatedPost, err error) {
-	var subredditMinScore = make(map[string]int)
+	var seenPosts = make(map[string]bool) // Post ID + subreddit ID -> true
 	for _, subreddit := range feed.Subreddits {
-		if subreddit.Percentile > 0.0 {
-			subredditMinScore[subreddit.Name], err = this.persistence.GetScoreAtPercentile(
-				minTime,
-				subreddit.Name,
-				subreddit.Percentile,
-			)
-			if err != nil {
+		if subreddit.Percentile <= 0.0 {
+			continue
+		}
+		var sourceKey = subreddit.SourceKey()
+		var minScore int
+		if minScore, err = this.persistence.GetScoreAtPercentile(minTime, sourceKey, subreddit.Percentile); err != nil {
+			return
+		}
+		var velocities map[string]float64 // Post ID -> velocity
+		var minVelocity = math.Inf(1)
+		var minScoreToRetrieve = minScore
+		if feed.Ranking == config.REDDIT_RANKING_VELOCITY {
+			var windowSeconds = int64(feed.VelocityHours) * 60 * 60
+			if velocities, err = this.persistence.GetScoreVelocities(minTime, sourceKey, windowSeconds); err != nil {
 				return
 			}
+			minVelocity = getVelocityAtPercentile(velocities, subreddit.Percentile)
+			// Rising posts may not have passed the score percentile yet.
+			minScoreToRetrieve = 0
+		}
+		var redditPosts []types.RedditPost
+		if redditPosts, err = this.persistence.GetPostsForSource(minTime, sourceKey, minScoreToRetrieve); err != nil {
+			return
+		}
+		for _, redditPost := range redditPosts {
+			velocity, hasVelocity := velocities[redditPost.Id]
+			if redditPost.Score < int64(minScore) && !(hasVelocity && velocity >= minVelocity) {
+				continue
+			}
+			var key = redditPost.Id + "/" + redditPost.SubredditId
+			if seenPosts[key] {
+				continue
+			}
+			seenPosts[key] = true
+			posts = append(posts, annotatedPost{
+				RedditPost:      redditPost,
+				SourceKey:       sourceKey,
+				Velocity:        velocity,
+				PercentileScore: int64(minScore),
+			})
 		}
 	}
-	if len(subredditMinScore) == 0 {
-		return
-	}
+	return
+}
 
-	var redditPosts []types.RedditPost
-	if redditPosts, err = this.persistence.GetPostsForSubredditScores(minTime, subredditMinScore); err != nil {
-		return
+// getVelocityAtPercentile returns the velocity at the given percentile of the velocities, where
+// 100% means all of them. (See persist.GetScoreAtPercentile)
+func getVelocityAtPercentile(velocities map[string]float64, percentile float64) float64 {
+	var sorted []float64
+	for _, velocity := range velocities {
+		sorted = append(sorted, velocity)
 	}
-	for _, redditPost := range redditPosts {
-		posts = append(posts, annotatedPost{RedditPost: redditPost})
+	if len(sorted) == 0 {
+		return math.Inf(1)
 	}
-	return
+	sort.Sort(sort.Reverse(sort.Float64Slice(sorted)))
+	var idx = int(percentile * float64(len(sorted)) / 100.0)
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
 }
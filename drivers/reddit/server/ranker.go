@@ -0,0 +1,129 @@
+package server
+
+import (
+	"github.com/coverprice/contentscraper/config"
+	"math"
+	"sort"
+)
+
+// IRanker sorts a feed's (already filtered) posts into display order. A feed's ranker is chosen
+// by its `sort` option, and can be overridden by the viewer's `sort` query parameter.
+type IRanker interface {
+	Rank(posts []annotatedPost, now int64)
+}
+
+var rankers = map[string]IRanker{
+	config.REDDIT_SORT_CHRONOLOGICAL: chronologicalRanker{},
+	config.REDDIT_SORT_SCORE:         scoreRanker{},
+	config.REDDIT_SORT_GRAVITY:       gravityRanker{Gravity: 1.8},
+	config.REDDIT_SORT_NORMALIZED:    normalizedScoreRanker{},
+	config.REDDIT_SORT_ROUNDROBIN:    roundRobinRanker{},
+}
+
+// getRanker returns the ranker with the given name (one of config.RedditSortNames), or nil if
+// there isn't one.
+func getRanker(name string) IRanker {
+	return rankers[name]
+}
+
+// chronologicalRanker shows the most recently harvested posts first.
+type chronologicalRanker struct{}
+
+func (this chronologicalRanker) Rank(posts []annotatedPost, now int64) {
+	sort.Sort(byTimeStoredId(posts))
+}
+
+// scoreRanker shows the posts with the highest scores first.
+type scoreRanker struct{}
+
+func (this scoreRanker) Rank(posts []annotatedPost, now int64) {
+	rankByKey(posts, func(post *annotatedPost) float64 {
+		return float64(post.Score)
+	})
+}
+
+// gravityRanker is the ranking of Hacker News' front page: a post's score is divided by its age
+// (in hours) raised to the power of Gravity, so that new posts can outrank older ones with higher
+// scores.
+type gravityRanker struct {
+	Gravity float64
+}
+
+func (this gravityRanker) Rank(posts []annotatedPost, now int64) {
+	rankByKey(posts, func(post *annotatedPost) float64 {
+		var ageInHours = float64(now-post.TimeCreated) / (60 * 60)
+		if ageInHours < 0 {
+			ageInHours = 0
+		}
+		return float64(post.Score) / math.Pow(ageInHours+2, this.Gravity)
+	})
+}
+
+// normalizedScoreRanker divides each post's score by the percentile cutoff score of the source it
+// was retrieved from, so that a feed that combines large and small subreddits isn't dominated by
+// the large ones.
+type normalizedScoreRanker struct{}
+
+func (this normalizedScoreRanker) Rank(posts []annotatedPost, now int64) {
+	rankByKey(posts, func(post *annotatedPost) float64 {
+		var cutoff = post.PercentileScore
+		if cutoff < 1 {
+			cutoff = 1
+		}
+		return float64(post.Score) / float64(cutoff)
+	})
+}
+
+// roundRobinRanker interleaves the sources' posts, taking the most recent remaining post from each
+// source in turn. The sources take turns in order of their most recent post.
+type roundRobinRanker struct{}
+
+func (this roundRobinRanker) Rank(posts []annotatedPost, now int64) {
+	sort.Sort(byTimeStoredId(posts))
+
+	var sourceKeys []string
+	var postsBySource = make(map[string][]annotatedPost)
+	for _, post := range posts {
+		if _, ok := postsBySource[post.SourceKey]; !ok {
+			sourceKeys = append(sourceKeys, post.SourceKey)
+		}
+		postsBySource[post.SourceKey] = append(postsBySource[post.SourceKey], post)
+	}
+
+	var results = make([]annotatedPost, 0, len(posts))
+	for round := 0; len(results) < len(posts); round++ {
+		for _, sourceKey := range sourceKeys {
+			if round < len(postsBySource[sourceKey]) {
+				results = append(results, postsBySource[sourceKey][round])
+			}
+		}
+	}
+	copy(posts, results)
+}
+
+// rankByKey sorts the posts by the given key, highest first. Ties are broken chronologically.
+func rankByKey(posts []annotatedPost, key func(post *annotatedPost) float64) {
+	var keyed = byRankKey{posts: posts, keys: make([]float64, len(posts))}
+	for i := range posts {
+		keyed.keys[i] = key(&posts[i])
+	}
+	sort.Sort(keyed)
+}
+
+type byRankKey struct {
+	posts []annotatedPost
+	keys  []float64
+}
+
+func (a byRankKey) Len() int { return len(a.posts) }
+func (a byRankKey) Swap(i, j int) {
+	a.posts[i], a.posts[j] = a.posts[j], a.posts[i]
+	a.keys[i], a.keys[j] = a.keys[j], a.keys[i]
+}
+func (a byRankKey) Less(i, j int) bool {
+	if a.keys[i] == a.keys[j] {
+		return byTimeStoredId(a.posts).Less(i, j)
+	}
+	// Key DESC
+	return a.keys[i] > a.keys[j]
+}
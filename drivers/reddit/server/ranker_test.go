@@ -0,0 +1,73 @@
+package server
+
+import (
+	"github.com/coverprice/contentscraper/config"
+	"github.com/coverprice/contentscraper/drivers/reddit/types"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+const rankerNow = int64(1508400000)
+
+func fakeRankerPost(id, sourceKey string, hoursOld, score, percentileScore int64) annotatedPost {
+	return annotatedPost{
+		RedditPost: types.RedditPost{
+			Id:          id,
+			Score:       score,
+			TimeCreated: rankerNow - hoursOld*60*60,
+			TimeStored:  rankerNow - hoursOld*60*60,
+		},
+		SourceKey:       sourceKey,
+		PercentileScore: percentileScore,
+	}
+}
+
+func getRankedIds(sortName string, posts []annotatedPost) (ids []string) {
+	getRanker(sortName).Rank(posts, rankerNow)
+	for _, post := range posts {
+		ids = append(ids, post.Id)
+	}
+	return
+}
+
+func getRankerTestPosts() []annotatedPost {
+	return []annotatedPost{
+		fakeRankerPost("old_popular", "big", 20, 5000, 1000),
+		fakeRankerPost("new_popular", "big", 1, 1500, 1000),
+		fakeRankerPost("big_newest", "big", 0, 1000, 1000),
+		fakeRankerPost("small_old", "small", 10, 100, 10),
+		fakeRankerPost("small_new", "small", 2, 30, 10),
+	}
+}
+
+func TestAllSortsHaveRankers(t *testing.T) {
+	for _, sortName := range config.RedditSortNames {
+		require.NotNil(t, getRanker(sortName), "No ranker for sort '%s'", sortName)
+	}
+	require.Nil(t, getRanker("unknown"))
+}
+
+func TestRankers(t *testing.T) {
+	require.Equal(t,
+		[]string{"big_newest", "new_popular", "small_new", "small_old", "old_popular"},
+		getRankedIds(config.REDDIT_SORT_CHRONOLOGICAL, getRankerTestPosts()),
+	)
+	require.Equal(t,
+		[]string{"old_popular", "new_popular", "big_newest", "small_old", "small_new"},
+		getRankedIds(config.REDDIT_SORT_SCORE, getRankerTestPosts()),
+	)
+	// The recent popular posts outrank the older, more popular one.
+	require.Equal(t,
+		[]string{"big_newest", "new_popular", "old_popular", "small_new", "small_old"},
+		getRankedIds(config.REDDIT_SORT_GRAVITY, getRankerTestPosts()),
+	)
+	// The small subreddit's posts are popular relative to its percentile score.
+	require.Equal(t,
+		[]string{"small_old", "old_popular", "small_new", "new_popular", "big_newest"},
+		getRankedIds(config.REDDIT_SORT_NORMALIZED, getRankerTestPosts()),
+	)
+	require.Equal(t,
+		[]string{"big_newest", "small_new", "new_popular", "small_old", "old_popular"},
+		getRankedIds(config.REDDIT_SORT_ROUNDROBIN, getRankerTestPosts()),
+	)
+}
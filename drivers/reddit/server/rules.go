@@ -0,0 +1,190 @@
+package server
+
+import (
+	"fmt"
+	"github.com/coverprice/contentscraper/config"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// postRule is one of a feed's (or subreddit's) include or exclude rules.
+type postRule struct {
+	Description string // Describes the rule to the viewer, e.g. `domain "imgur.com"`
+	Matches     func(post *annotatedPost) bool
+}
+
+// ruleRemoval is the # of posts that a rule removed from a feed. It's shown by the viewer, to help
+// tune the rules.
+type ruleRemoval struct {
+	Rule       string
+	NumRemoved int
+}
+
+// ruleSet is the include & exclude rules of a feed, or of one of its subreddits.
+type ruleSet struct {
+	Scope    string // Prefix for the rule descriptions, e.g. "funny: ". Empty for the feed's rules.
+	Includes []postRule
+	Excludes []postRule
+}
+
+func newRuleSet(scope string, include, exclude config.RedditFilterRules) ruleSet {
+	return ruleSet{
+		Scope:    scope,
+		Includes: newPostRules(include),
+		Excludes: newPostRules(exclude),
+	}
+}
+
+func (this ruleSet) includeDescription() string {
+	return this.Scope + "not matched by any include rule"
+}
+
+func (this ruleSet) excludeDescription(rule postRule) string {
+	return this.Scope + "exclude " + rule.Description
+}
+
+// apply returns the description of the rule that removes the post, or "" if the post should be
+// kept.
+func (this ruleSet) apply(post *annotatedPost) string {
+	if len(this.Includes) > 0 && !matchesAny(this.Includes, post) {
+		return this.includeDescription()
+	}
+	for _, rule := range this.Excludes {
+		if rule.Matches(post) {
+			return this.excludeDescription(rule)
+		}
+	}
+	return ""
+}
+
+// removalDescriptions lists the descriptions apply() can return, in the order of the config.
+func (this ruleSet) removalDescriptions() (descriptions []string) {
+	if len(this.Includes) > 0 {
+		descriptions = append(descriptions, this.includeDescription())
+	}
+	for _, rule := range this.Excludes {
+		descriptions = append(descriptions, this.excludeDescription(rule))
+	}
+	return
+}
+
+func matchesAny(rules []postRule, post *annotatedPost) bool {
+	for _, rule := range rules {
+		if rule.Matches(post) {
+			return true
+		}
+	}
+	return false
+}
+
+// newPostRules converts the (validated) rules from the config into postRules.
+func newPostRules(rules config.RedditFilterRules) (postRules []postRule) {
+	for _, keyword := range rules.TitleKeywords {
+		// Keywords match whole words, so that e.g. "cat" doesn't match "category".
+		var re = regexp.MustCompile(`(?i)(^|\W)` + regexp.QuoteMeta(keyword) + `($|\W)`)
+		postRules = append(postRules, postRule{
+			Description: fmt.Sprintf("title keyword %q", keyword),
+			Matches: func(post *annotatedPost) bool {
+				return re.MatchString(post.Title)
+			},
+		})
+	}
+	for _, expr := range rules.TitleRegexes {
+		var re = regexp.MustCompile(expr)
+		postRules = append(postRules, postRule{
+			Description: fmt.Sprintf("title regex %q", expr),
+			Matches: func(post *annotatedPost) bool {
+				return re.MatchString(post.Title)
+			},
+		})
+	}
+	for _, domain := range rules.Domains {
+		var domain = domain
+		postRules = append(postRules, postRule{
+			Description: fmt.Sprintf("domain %q", domain),
+			Matches: func(post *annotatedPost) bool {
+				var host = getPostDomain(post)
+				return host == domain || strings.HasSuffix(host, "."+domain)
+			},
+		})
+	}
+	for _, flair := range rules.Flairs {
+		var flair = flair
+		postRules = append(postRules, postRule{
+			Description: fmt.Sprintf("flair %q", flair),
+			Matches: func(post *annotatedPost) bool {
+				return strings.EqualFold(post.LinkFlair, flair)
+			},
+		})
+	}
+	for _, author := range rules.Authors {
+		var author = author
+		postRules = append(postRules, postRule{
+			Description: fmt.Sprintf("author %q", author),
+			Matches: func(post *annotatedPost) bool {
+				return strings.EqualFold(post.Author, author)
+			},
+		})
+	}
+	if rules.Nsfw {
+		postRules = append(postRules, postRule{
+			Description: "NSFW",
+			Matches: func(post *annotatedPost) bool {
+				return post.IsNsfw
+			},
+		})
+	}
+	if rules.Spoiler {
+		postRules = append(postRules, postRule{
+			Description: "spoiler",
+			Matches: func(post *annotatedPost) bool {
+				return post.IsSpoiler
+			},
+		})
+	}
+	return
+}
+
+// getPostDomain returns the (lowercase) domain of the post's link, without any "www." prefix.
+func getPostDomain(post *annotatedPost) string {
+	u, err := url.Parse(post.Url)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// filterByRules removes the posts that fail the feed's rules, or the rules of the subreddit they
+// were retrieved from. It also returns the # of posts each rule removed. A post that fails several
+// rules is only counted against the first of them.
+func filterByRules(posts []annotatedPost, feed *config.RedditFeed) (results []annotatedPost, removals []ruleRemoval) {
+	var feedRules = newRuleSet("", feed.Include, feed.Exclude)
+	var sourceRules = make(map[string]ruleSet) // Source key -> rules
+	var ruleSets = []ruleSet{feedRules}
+	for _, subreddit := range feed.Subreddits {
+		var rules = newRuleSet(subreddit.SourceKey()+": ", subreddit.Include, subreddit.Exclude)
+		sourceRules[subreddit.SourceKey()] = rules
+		ruleSets = append(ruleSets, rules)
+	}
+
+	var numRemoved = make(map[string]int) // Rule description -> # of posts removed
+	for i, _ := range posts {
+		var removedBy = feedRules.apply(&posts[i])
+		if removedBy == "" {
+			removedBy = sourceRules[posts[i].SourceKey].apply(&posts[i])
+		}
+		if removedBy != "" {
+			numRemoved[removedBy]++
+			continue
+		}
+		results = append(results, posts[i])
+	}
+
+	for _, rules := range ruleSets {
+		for _, description := range rules.removalDescriptions() {
+			removals = append(removals, ruleRemoval{Rule: description, NumRemoved: numRemoved[description]})
+		}
+	}
+	return
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"github.com/coverprice/contentscraper/config"
+	"github.com/coverprice/contentscraper/drivers/reddit/types"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestFilterByRules(t *testing.T) {
+	fakePost := func(id, sourceKey string, setup func(post *types.RedditPost)) annotatedPost {
+		var post = types.RedditPost{
+			Id:     id,
+			Title:  "Post " + id,
+			Url:    "https://example.com/" + id,
+			Author: "someone",
+		}
+		if setup != nil {
+			setup(&post)
+		}
+		return annotatedPost{RedditPost: post, SourceKey: sourceKey}
+	}
+	posts := []annotatedPost{
+		fakePost("plain", "pics", nil),
+		fakePost("keyword", "pics", func(p *types.RedditPost) { p.Title = "Huge SPOILERS inside" }),
+		fakePost("not_keyword", "pics", func(p *types.RedditPost) { p.Title = "Spoilersaurus" }),
+		fakePost("regex", "pics", func(p *types.RedditPost) { p.Title = "[Meta] About the rules" }),
+		fakePost("subdomain", "pics", func(p *types.RedditPost) { p.Url = "https://i.imgur.com/abc.jpg" }),
+		fakePost("not_subdomain", "pics", func(p *types.RedditPost) { p.Url = "https://notimgur.com/abc.jpg" }),
+		fakePost("nsfw", "pics", func(p *types.RedditPost) { p.IsNsfw = true }),
+		fakePost("nsfw_spoiler", "pics", func(p *types.RedditPost) { p.IsNsfw = true; p.IsSpoiler = true }),
+		fakePost("author", "pics", func(p *types.RedditPost) { p.Author = "SpamBot" }),
+		// The "news" subreddit only shows posts with "Analysis" flair
+		fakePost("news_flair", "news", func(p *types.RedditPost) { p.LinkFlair = "analysis" }),
+		fakePost("news_no_flair", "news", nil),
+		fakePost("news_wrong_flair", "news", func(p *types.RedditPost) { p.LinkFlair = "Breaking" }),
+	}
+	feed := &config.RedditFeed{
+		Exclude: config.RedditFilterRules{
+			TitleKeywords: []string{"spoilers"},
+			TitleRegexes:  []string{`^\[(?i:meta)\]`},
+			Domains:       []string{"imgur.com"},
+			Nsfw:          true,
+			Spoiler:       true,
+		},
+		Subreddits: []config.Subreddit{
+			config.Subreddit{
+				Name:    "pics",
+				Exclude: config.RedditFilterRules{Authors: []string{"spambot"}},
+			},
+			config.Subreddit{
+				Name:    "news",
+				Include: config.RedditFilterRules{Flairs: []string{"Analysis"}},
+			},
+		},
+	}
+
+	results, removals := filterByRules(posts, feed)
+	var ids []string
+	for _, post := range results {
+		ids = append(ids, post.Id)
+	}
+	require.Equal(t, []string{"plain", "not_keyword", "not_subdomain", "news_flair"}, ids)
+	require.Equal(t, []ruleRemoval{
+		ruleRemoval{Rule: `exclude title keyword "spoilers"`, NumRemoved: 1},
+		ruleRemoval{Rule: `exclude title regex "^\\[(?i:meta)\\]"`, NumRemoved: 1},
+		ruleRemoval{Rule: `exclude domain "imgur.com"`, NumRemoved: 1},
+		// Posts are only counted against the first rule that removes them.
+		ruleRemoval{Rule: `exclude NSFW`, NumRemoved: 2},
+		ruleRemoval{Rule: `exclude spoiler`, NumRemoved: 0},
+		ruleRemoval{Rule: `pics: exclude author "spambot"`, NumRemoved: 1},
+		ruleRemoval{Rule: `news: not matched by any include rule`, NumRemoved: 2},
+	}, removals)
+}
+
+func TestFeedsWithoutRulesKeepAllPosts(t *testing.T) {
+	posts := []annotatedPost{
+		annotatedPost{RedditPost: types.RedditPost{Id: "a"}, SourceKey: "pics"},
+		annotatedPost{RedditPost: types.RedditPost{Id: "b"}, SourceKey: "pics"},
+	}
+	feed := &config.RedditFeed{
+		Subreddits: []config.Subreddit{config.Subreddit{Name: "pics"}},
+	}
+	results, removals := filterByRules(posts, feed)
+	require.Equal(t, posts, results)
+	require.Empty(t, removals)
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"github.com/coverprice/contentscraper/config"
+	"github.com/coverprice/contentscraper/drivers"
+	persist "github.com/coverprice/contentscraper/drivers/reddit/persistence"
+	"github.com/coverprice/contentscraper/drivers/reddit/types"
+	"github.com/coverprice/contentscraper/server/medialink"
+	log "github.com/sirupsen/logrus"
+	"sort"
+)
+
+// The SearchRequestHandler searches the titles & text of all stored posts, including those that
+// are too old to be shown in the feeds.
+type SearchRequestHandler struct {
+	persistence *persist.Persistence
+}
+
+func NewSearchRequestHandler(persistence *persist.Persistence) *SearchRequestHandler {
+	return &SearchRequestHandler{
+		persistence: persistence,
+	}
+}
+
+// SearchPosts returns the posts matching the query, most recently created first. (See
+// persist.Persistence.SearchPosts for the query syntax).
+func (this *SearchRequestHandler) SearchPosts(query drivers.SearchQuery) (results []drivers.SearchResult, err error) {
+	var feeds []*config.RedditFeed
+	var sourceKeys []string
+	if query.FeedName != "" {
+		var item *types.FeedRegistryItem
+		if item, err = types.FeedRegistry.GetItemByName(query.FeedName); err != nil {
+			return
+		}
+		feeds = append(feeds, &item.RedditFeed)
+		for _, subreddit := range item.RedditFeed.Subreddits {
+			sourceKeys = append(sourceKeys, subreddit.SourceKey())
+		}
+	} else {
+		for _, item := range types.FeedRegistry.GetAllItems() {
+			feeds = append(feeds, &item.RedditFeed)
+		}
+		sort.Sort(feedsByName(feeds))
+	}
+
+	var posts []types.RedditPost
+	if posts, err = this.persistence.SearchPosts(query.Query, sourceKeys, query.MinTime, query.MaxTime, query.Limit); err != nil {
+		return
+	}
+	results = make([]drivers.SearchResult, 0, len(posts))
+	for _, post := range posts {
+		var result = drivers.SearchResult{
+			Title:       post.Title,
+			Url:         post.Url,
+			Permalink:   "https://www.reddit.com" + post.Permalink,
+			Source:      post.SubredditName,
+			Score:       post.Score,
+			TimeCreated: post.TimeCreated,
+		}
+		var feed *config.RedditFeed
+		if query.FeedName != "" {
+			feed = feeds[0]
+		} else {
+			feed = findSubredditFeed(feeds, post.SubredditName)
+		}
+		if feed != nil {
+			result.FeedName = feed.Name
+			if feed.Media == config.MEDIA_TYPE_IMAGE && post.Url != "" {
+				if result.MediaLink, err = medialink.UrlToMediaLink(post.Url); err != nil {
+					log.Error("Error trying to convert post URL to MediaLink", err)
+					err = nil
+				}
+			}
+		}
+		results = append(results, result)
+	}
+	return
+}
+
+// findSubredditFeed returns the first feed that includes the subreddit, or nil if none does.
+func findSubredditFeed(feeds []*config.RedditFeed, subredditName string) *config.RedditFeed {
+	for _, feed := range feeds {
+		for _, subreddit := range feed.Subreddits {
+			if subreddit.SourceKey() == subredditName {
+				return feed
+			}
+		}
+	}
+	return nil
+}
+
+type feedsByName []*config.RedditFeed
+
+func (a feedsByName) Len() int           { return len(a) }
+func (a feedsByName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a feedsByName) Less(i, j int) bool { return a[i].Name < a[j].Name }
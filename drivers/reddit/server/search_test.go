@@ -0,0 +1,73 @@
+package server
+
+import (
+	"github.com/coverprice/contentscraper/config"
+	"github.com/coverprice/contentscraper/database"
+	"github.com/coverprice/contentscraper/drivers"
+	persist "github.com/coverprice/contentscraper/drivers/reddit/persistence"
+	"github.com/coverprice/contentscraper/drivers/reddit/types"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSearchPosts(t *testing.T) {
+	testDb, err := database.NewTestDatabase()
+	if err != nil {
+		t.Fatal("Could not init database", err)
+	}
+	defer testDb.Cleanup()
+
+	persistence, err := persist.NewPersistence(testDb.DbConn)
+	require.Nil(t, err, "Could not create persistence layer")
+
+	// Posts older than the feeds' viewing window are searched too.
+	for _, post := range []types.RedditPost{
+		types.RedditPost{Id: "id_1", Title: "A cat picture", Url: "https://example.com/cat.jpg", SubredditName: "searchpics", TimeCreated: 100},
+		types.RedditPost{Id: "id_2", Title: "A cat story", SubredditName: "searchtext", TimeCreated: 200},
+		types.RedditPost{Id: "id_3", Title: "A cat elsewhere", SubredditName: "searchnofeed", TimeCreated: 300},
+	} {
+		post.Name = "t3_" + post.Id
+		post.SubredditId = "t5_" + post.SubredditName
+		post.Permalink = "/r/" + post.SubredditName + "/comments/" + post.Id + "/"
+		post.TimeStored = post.TimeCreated
+		post.IsActive = true
+		_, err = persistence.StorePost(&post)
+		require.Nil(t, err, "Could not store post")
+	}
+	for _, feed := range []*config.RedditFeed{
+		&config.RedditFeed{
+			Name:       "searchpics",
+			Media:      config.MEDIA_TYPE_IMAGE,
+			Subreddits: []config.Subreddit{config.Subreddit{Name: "searchpics"}},
+		},
+		&config.RedditFeed{
+			Name:       "searchtext",
+			Media:      config.MEDIA_TYPE_TEXT,
+			Subreddits: []config.Subreddit{config.Subreddit{Name: "searchtext"}},
+		},
+	} {
+		types.FeedRegistry.AddItem(feed)
+		defer delete(types.FeedRegistry, feed.Name)
+	}
+
+	sut := NewSearchRequestHandler(persistence)
+	results, err := sut.SearchPosts(drivers.SearchQuery{Query: "cat", Limit: 10})
+	require.Nil(t, err, "Could not search posts")
+	require.Equal(t, 3, len(results))
+	require.Equal(t, "A cat elsewhere", results[0].Title)
+	require.Equal(t, "", results[0].FeedName, "Posts in no feed should still be found")
+	require.Equal(t, "searchtext", results[1].FeedName)
+	require.Nil(t, results[1].MediaLink, "Text feeds' posts should not have media")
+	require.Equal(t, "searchpics", results[2].FeedName)
+	require.Equal(t, "https://www.reddit.com/r/searchpics/comments/id_1/", results[2].Permalink)
+	require.NotNil(t, results[2].MediaLink)
+	require.Equal(t, "https://example.com/cat.jpg", results[2].MediaLink.Url)
+
+	results, err = sut.SearchPosts(drivers.SearchQuery{Query: "cat", FeedName: "searchpics", Limit: 10})
+	require.Nil(t, err, "Could not search posts")
+	require.Equal(t, 1, len(results))
+	require.Equal(t, "A cat picture", results[0].Title)
+
+	_, err = sut.SearchPosts(drivers.SearchQuery{Query: "cat", FeedName: "unknown", Limit: 10})
+	require.NotNil(t, err, "Searching an unknown feed should fail")
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"fmt"
+	"github.com/coverprice/contentscraper/config"
+	persist "github.com/coverprice/contentscraper/drivers/reddit/persistence"
+	"github.com/coverprice/contentscraper/drivers/reddit/types"
+	"github.com/coverprice/contentscraper/server/htmlutil"
+	"net/http"
+	"time"
+)
+
+// The path (relative to BaseUrlPath) that viewer.js POSTs the posts the viewer has read to.
+const SeenUrlPath = "seen"
+
+// Verify that SeenRequestHandler implements http.Handler interface
+var _ http.Handler = &SeenRequestHandler{}
+
+// The SeenRequestHandler records which posts each viewer has read, and counts the feeds' unread
+// posts. (The HtmlViewerRequestHandler records which posts were shown).
+type SeenRequestHandler struct {
+	postRetriever
+}
+
+func NewSeenRequestHandler(persistence *persist.Persistence) *SeenRequestHandler {
+	return &SeenRequestHandler{
+		postRetriever: postRetriever{persistence: persistence},
+	}
+}
+
+// ServeHTTP marks the posts in the request's "post" form values (see types.PostKey.String) as
+// read by the viewer.
+func (this *SeenRequestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	keys, ok := parseActionRequest(w, r)
+	if !ok {
+		return
+	}
+	viewerId := htmlutil.GetViewerId(w, r)
+	if err := this.persistence.MarkPostsRead(viewerId, keys, time.Now().Unix()); err != nil {
+		http.Error(w, fmt.Sprintf("Internal error marking posts read: %v", err), 500)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetUnreadCount returns the # of the feed's posts that the viewer hasn't read.
+func (this *SeenRequestHandler) GetUnreadCount(feed *config.RedditFeed, viewerId string) (count int, err error) {
+	var posts []annotatedPost
+	if posts, _, err = this.getPosts(feed); err != nil {
+		return
+	}
+	var seenPosts map[string]types.SeenPost
+	if seenPosts, err = this.getSeenPosts(viewerId, time.Now().Unix()); err != nil {
+		return
+	}
+	return len(filterOutReadPosts(posts, seenPosts)), nil
+}
+
+// parseActionRequest checks that the request is a POST, and returns the keys of the posts in its
+// "post" form values (see types.PostKey.String). If the request is invalid, the error response is
+// written and ok is false.
+func parseActionRequest(w http.ResponseWriter, r *http.Request) (keys []types.PostKey, ok bool) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed", 405)
+		return nil, false
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid request. Cannot parse form", 400)
+		return nil, false
+	}
+	for _, value := range r.PostForm["post"] {
+		key, err := types.ParsePostKey(value)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request. %v", err), 400)
+			return nil, false
+		}
+		keys = append(keys, key)
+	}
+	return keys, true
+}
+
+// getSeenPosts returns the posts the viewer has been shown, or has read, that may still be in a
+// feed.
+func (this *postRetriever) getSeenPosts(viewerId string, now int64) (map[string]types.SeenPost, error) {
+	return this.persistence.GetSeenPosts(viewerId, now-MAX_POST_AGE)
+}
+
+func filterOutReadPosts(posts []annotatedPost, seenPosts map[string]types.SeenPost) (results []annotatedPost) {
+	for _, post := range posts {
+		if seenPosts[post.Key().String()].TimeRead == 0 {
+			results = append(results, post)
+		}
+	}
+	return
+}
+
+func decoratePostsWithSeen(posts []annotatedPost, seenPosts map[string]types.SeenPost) {
+	for i, _ := range posts {
+		seenPost, ok := seenPosts[posts[i].Key().String()]
+		posts[i].IsNew = !ok
+		posts[i].IsRead = seenPost.TimeRead != 0
+	}
+}
+
+// The "hide read posts" toggle is set per feed with the hide_seen query parameter, and remembered
+// in a cookie.
+const hideSeenCookiePrefix = "hide_seen_"
+
+func getHideSeen(feedName string, w http.ResponseWriter, r *http.Request) bool {
+	if value := r.URL.Query().Get("hide_seen"); value != "" {
+		var hideSeen = value == "1"
+		var cookie = http.Cookie{
+			Name:  hideSeenCookiePrefix + feedName,
+			Value: "1",
+			Path:  BaseUrlPath,
+		}
+		if hideSeen {
+			cookie.Expires = time.Now().Add(365 * 24 * time.Hour)
+		} else {
+			cookie.MaxAge = -1
+		}
+		http.SetCookie(w, &cookie)
+		return hideSeen
+	}
+	cookie, err := r.Cookie(hideSeenCookiePrefix + feedName)
+	return err == nil && cookie.Value == "1"
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"fmt"
+	"github.com/coverprice/contentscraper/config"
+	"github.com/coverprice/contentscraper/database"
+	persist "github.com/coverprice/contentscraper/drivers/reddit/persistence"
+	"github.com/coverprice/contentscraper/drivers/reddit/types"
+	"github.com/coverprice/contentscraper/server/htmlutil"
+	"github.com/coverprice/contentscraper/server/syndication"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadPostsAreTrackedPerViewer(t *testing.T) {
+	testDb, err := database.NewTestDatabase()
+	if err != nil {
+		t.Fatal("Could not init database", err)
+	}
+	defer testDb.Cleanup()
+
+	persistence, err := persist.NewPersistence(testDb.DbConn)
+	require.Nil(t, err, "Could not create persistence layer")
+
+	now := time.Now().Unix()
+	for i := 1; i <= 3; i++ {
+		_, err = persistence.StorePost(&types.RedditPost{
+			Id:            fmt.Sprintf("id_%d", i),
+			Name:          fmt.Sprintf("t3_id_%d", i),
+			TimeCreated:   now - int64(i),
+			TimeStored:    now - int64(i),
+			Permalink:     fmt.Sprintf("/r/seentest/comments/id_%d/", i),
+			IsActive:      true,
+			Score:         int64(i),
+			Title:         fmt.Sprintf("Post %d", i),
+			SubredditName: "seentest",
+			SubredditId:   "t5_test",
+		})
+		require.Nil(t, err, "Could not store post")
+	}
+	feed := &config.RedditFeed{
+		Name:        "seentest",
+		Description: "A test feed",
+		Media:       config.MEDIA_TYPE_TEXT,
+		Subreddits: []config.Subreddit{
+			config.Subreddit{Name: "seentest", Percentile: 100.0, MaxDailyPosts: 100},
+		},
+	}
+	types.FeedRegistry.AddItem(feed)
+	defer delete(types.FeedRegistry, "seentest")
+
+	seenHandler := NewSeenRequestHandler(persistence)
+	handler := http.StripPrefix(BaseUrlPath, NewHttpHandler(map[string]IRequestHandler{
+		syndication.FORMAT_HTML: NewHtmlViewerRequestHandler(persistence),
+	}, map[string]http.Handler{
+		SeenUrlPath: seenHandler,
+	}))
+	viewer := &http.Cookie{Name: htmlutil.VIEWER_COOKIE_NAME, Value: strings.Repeat("a", 32)}
+	do := func(r *http.Request) *httptest.ResponseRecorder {
+		r.AddCookie(viewer)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	markRead := func(postKeys ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/reddit/seen", strings.NewReader(url.Values{"post": postKeys}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return do(r)
+	}
+	unreadCount := func() int {
+		count, err := seenHandler.GetUnreadCount(feed, viewer.Value)
+		require.Nil(t, err, "Could not count unread posts")
+		return count
+	}
+
+	// Posts are new until they've been shown.
+	w := do(httptest.NewRequest("GET", "/reddit/?feed=seentest", nil))
+	require.Equal(t, 200, w.Code)
+	require.Contains(t, w.Body.String(), `data-post="id_1/t5_test"`)
+	require.Equal(t, 3, strings.Count(w.Body.String(), ">New</span>"))
+	w = do(httptest.NewRequest("GET", "/reddit/?feed=seentest", nil))
+	require.Equal(t, 0, strings.Count(w.Body.String(), ">New</span>"))
+	require.Equal(t, 3, unreadCount(), "Being shown a post doesn't mark it read")
+
+	require.Equal(t, http.StatusNoContent, markRead("id_1/t5_test", "id_3/t5_test").Code)
+	require.Equal(t, 1, unreadCount())
+	other, err := seenHandler.GetUnreadCount(feed, strings.Repeat("b", 32))
+	require.Nil(t, err, "Could not count unread posts")
+	require.Equal(t, 3, other, "Other viewers' read posts should not count")
+
+	w = do(httptest.NewRequest("GET", "/reddit/?feed=seentest", nil))
+	require.Equal(t, 2, strings.Count(w.Body.String(), `feeditem read"`))
+
+	// Hiding read posts is remembered for the feed.
+	w = do(httptest.NewRequest("GET", "/reddit/?feed=seentest&hide_seen=1", nil))
+	require.Equal(t, 200, w.Code)
+	require.Equal(t, 1, strings.Count(w.Body.String(), "data-post="))
+	require.Contains(t, w.Body.String(), `data-post="id_2/t5_test"`)
+	cookies := w.Result().Cookies()
+	require.Equal(t, 1, len(cookies))
+	r := httptest.NewRequest("GET", "/reddit/?feed=seentest", nil)
+	r.AddCookie(cookies[0])
+	w = do(r)
+	require.Equal(t, 1, strings.Count(w.Body.String(), "data-post="))
+
+	require.Equal(t, http.StatusMethodNotAllowed, do(httptest.NewRequest("GET", "/reddit/seen", nil)).Code)
+	require.Equal(t, 400, markRead("no_subreddit").Code)
+}
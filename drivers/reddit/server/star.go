@@ -0,0 +1,113 @@
+package server
+
+import (
+	"fmt"
+	"github.com/coverprice/contentscraper/drivers"
+	persist "github.com/coverprice/contentscraper/drivers/reddit/persistence"
+	"github.com/coverprice/contentscraper/drivers/reddit/types"
+	"github.com/coverprice/contentscraper/server/htmlutil"
+	"net/http"
+	"time"
+)
+
+// The path (relative to BaseUrlPath) that viewer.js POSTs starred & unstarred posts to.
+const StarUrlPath = "star"
+
+// Verify that StarRequestHandler implements http.Handler interface
+var _ http.Handler = &StarRequestHandler{}
+
+// The StarRequestHandler stars & unstars posts for each viewer, and retrieves the posts they've
+// starred.
+type StarRequestHandler struct {
+	persistence *persist.Persistence
+}
+
+func NewStarRequestHandler(persistence *persist.Persistence) *StarRequestHandler {
+	return &StarRequestHandler{
+		persistence: persistence,
+	}
+}
+
+// ServeHTTP stars the posts in the request's "post" form values (see types.PostKey.String), which
+// were shown in the feed given by the "feed" form value. If the "starred" form value is "0", the
+// posts are unstarred instead.
+func (this *StarRequestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	keys, ok := parseActionRequest(w, r)
+	if !ok {
+		return
+	}
+	var isStarred = r.PostForm.Get("starred") != "0"
+	var feedName = r.PostForm.Get("feed")
+	var sourceKeys []string
+	if isStarred {
+		item, err := types.FeedRegistry.GetItemByName(feedName)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request. Unknown feed: '%s'", feedName), 400)
+			return
+		}
+		for _, subreddit := range item.RedditFeed.Subreddits {
+			sourceKeys = append(sourceKeys, subreddit.SourceKey())
+		}
+	}
+
+	viewerId := htmlutil.GetViewerId(w, r)
+	now := time.Now().Unix()
+	for _, key := range keys {
+		if !isStarred {
+			if err := this.persistence.UnstarPost(viewerId, key); err != nil {
+				http.Error(w, fmt.Sprintf("Internal error unstarring post: %v", err), 500)
+				return
+			}
+			continue
+		}
+		// Only posts from the feed's sources can be starred in it.
+		post, err := this.persistence.GetPostInSources(key, sourceKeys)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Internal error retrieving post: %v", err), 500)
+			return
+		}
+		if post == nil {
+			http.Error(w, fmt.Sprintf("Unknown post in feed '%s': '%s'", feedName, key), 404)
+			return
+		}
+		if err = this.persistence.StarPost(viewerId, feedName, post, now); err != nil {
+			http.Error(w, fmt.Sprintf("Internal error starring post: %v", err), 500)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetStarredPosts returns the posts the viewer has starred, most recently starred first.
+func (this *StarRequestHandler) GetStarredPosts(viewerId string) (starredPosts []drivers.StarredPost, err error) {
+	var posts []types.StarredPost
+	if posts, err = this.persistence.GetStarredPosts(viewerId); err != nil {
+		return
+	}
+	starredPosts = make([]drivers.StarredPost, 0, len(posts))
+	for _, post := range posts {
+		starredPosts = append(starredPosts, drivers.StarredPost{
+			FeedName:    post.FeedName,
+			Title:       post.Title,
+			Url:         post.Url,
+			Permalink:   "https://www.reddit.com" + post.Permalink,
+			Source:      post.SubredditName,
+			TimeCreated: post.TimeCreated,
+			TimeStarred: post.TimeStarred,
+		})
+	}
+	return
+}
+
+// getStarredKeys returns the keys (see types.PostKey.String) of the posts the viewer has starred.
+func (this *postRetriever) getStarredKeys(viewerId string) (keys map[string]bool, err error) {
+	var posts []types.StarredPost
+	if posts, err = this.persistence.GetStarredPosts(viewerId); err != nil {
+		return
+	}
+	keys = make(map[string]bool)
+	for _, post := range posts {
+		keys[post.PostKey.String()] = true
+	}
+	return
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"github.com/coverprice/contentscraper/config"
+	"github.com/coverprice/contentscraper/database"
+	persist "github.com/coverprice/contentscraper/drivers/reddit/persistence"
+	"github.com/coverprice/contentscraper/drivers/reddit/types"
+	"github.com/coverprice/contentscraper/server/htmlutil"
+	"github.com/coverprice/contentscraper/server/syndication"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStarredPosts(t *testing.T) {
+	testDb, err := database.NewTestDatabase()
+	if err != nil {
+		t.Fatal("Could not init database", err)
+	}
+	defer testDb.Cleanup()
+
+	persistence, err := persist.NewPersistence(testDb.DbConn)
+	require.Nil(t, err, "Could not create persistence layer")
+
+	now := time.Now().Unix()
+	_, err = persistence.StorePost(&types.RedditPost{
+		Id:            "id_1",
+		Name:          "t3_id_1",
+		TimeCreated:   now,
+		TimeStored:    now,
+		Permalink:     "/r/startest/comments/id_1/",
+		Url:           "https://example.com/1",
+		IsActive:      true,
+		Score:         10,
+		Title:         "Post 1",
+		SubredditName: "startest",
+		SubredditId:   "t5_test",
+	})
+	require.Nil(t, err, "Could not store post")
+	_, err = persistence.StorePost(&types.RedditPost{
+		Id:            "id_2",
+		Name:          "t3_id_2",
+		TimeCreated:   now,
+		TimeStored:    now,
+		Permalink:     "/r/othersub/comments/id_2/",
+		Url:           "https://example.com/2",
+		IsActive:      true,
+		Score:         10,
+		Title:         "Post 2",
+		SubredditName: "othersub",
+		SubredditId:   "t5_other",
+	})
+	require.Nil(t, err, "Could not store post")
+	feed := &config.RedditFeed{
+		Name:        "startest",
+		Description: "A test feed",
+		Media:       config.MEDIA_TYPE_TEXT,
+		Subreddits: []config.Subreddit{
+			config.Subreddit{Name: "startest", Percentile: 100.0, MaxDailyPosts: 100},
+		},
+	}
+	types.FeedRegistry.AddItem(feed)
+	defer delete(types.FeedRegistry, "startest")
+
+	starHandler := NewStarRequestHandler(persistence)
+	handler := http.StripPrefix(BaseUrlPath, NewHttpHandler(map[string]IRequestHandler{
+		syndication.FORMAT_HTML: NewHtmlViewerRequestHandler(persistence),
+	}, map[string]http.Handler{
+		StarUrlPath: starHandler,
+	}))
+	viewer := &http.Cookie{Name: htmlutil.VIEWER_COOKIE_NAME, Value: strings.Repeat("a", 32)}
+	do := func(r *http.Request) *httptest.ResponseRecorder {
+		r.AddCookie(viewer)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	star := func(postKey string, feedName string, starred string) *httptest.ResponseRecorder {
+		values := url.Values{"post": []string{postKey}, "feed": []string{feedName}, "starred": []string{starred}}
+		r := httptest.NewRequest("POST", "/reddit/star", strings.NewReader(values.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return do(r)
+	}
+
+	w := do(httptest.NewRequest("GET", "/reddit/?feed=startest", nil))
+	require.Contains(t, w.Body.String(), `data-starred="false"`)
+
+	require.Equal(t, http.StatusNoContent, star("id_1/t5_test", "startest", "1").Code)
+	w = do(httptest.NewRequest("GET", "/reddit/?feed=startest", nil))
+	require.Contains(t, w.Body.String(), `data-starred="true"`)
+
+	starredPosts, err := starHandler.GetStarredPosts(viewer.Value)
+	require.Nil(t, err, "Could not retrieve starred posts")
+	require.Equal(t, 1, len(starredPosts))
+	require.Equal(t, "startest", starredPosts[0].FeedName)
+	require.Equal(t, "Post 1", starredPosts[0].Title)
+	require.Equal(t, "https://example.com/1", starredPosts[0].Url)
+	require.Equal(t, "https://www.reddit.com/r/startest/comments/id_1/", starredPosts[0].Permalink)
+	starredPosts, err = starHandler.GetStarredPosts(strings.Repeat("b", 32))
+	require.Nil(t, err, "Could not retrieve starred posts")
+	require.Equal(t, 0, len(starredPosts), "Other viewers' starred posts should not be returned")
+
+	require.Equal(t, http.StatusNoContent, star("id_1/t5_test", "", "0").Code)
+	starredPosts, err = starHandler.GetStarredPosts(viewer.Value)
+	require.Nil(t, err, "Could not retrieve starred posts")
+	require.Equal(t, 0, len(starredPosts))
+
+	require.Equal(t, 404, star("unknown/t5_test", "startest", "1").Code)
+	require.Equal(t, 404, star("id_2/t5_other", "startest", "1").Code, "Posts outside the feed's sources can't be starred in it")
+	require.Equal(t, 400, star("id_1/t5_test", "unknown", "1").Code)
+	require.Equal(t, http.StatusMethodNotAllowed, do(httptest.NewRequest("GET", "/reddit/star", nil)).Code)
+}
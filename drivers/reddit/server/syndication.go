@@ -0,0 +1,73 @@
+package server
+
+import (
+	"fmt"
+	"github.com/coverprice/contentscraper/config"
+	persist "github.com/coverprice/contentscraper/drivers/reddit/persistence"
+	"github.com/coverprice/contentscraper/server/syndication"
+	"html"
+	"net/http"
+	"time"
+)
+
+// Verify that SyndicationRequestHandler implements IRequestHandler interface
+var _ IRequestHandler = &SyndicationRequestHandler{}
+
+// The SyndicationRequestHandler handles a request for a feed as an RSS or Atom document.
+// It contains the same posts as the HtmlViewerRequestHandler, in the same order.
+type SyndicationRequestHandler struct {
+	postRetriever
+	format string // syndication.FORMAT_RSS or syndication.FORMAT_ATOM
+}
+
+func NewSyndicationRequestHandler(persistence *persist.Persistence, format string) *SyndicationRequestHandler {
+	return &SyndicationRequestHandler{
+		postRetriever: postRetriever{persistence: persistence},
+		format:        format,
+	}
+}
+
+// HandleFeed renders the most recent posts in the feed. Feed readers don't page, so pageNum is ignored.
+func (this *SyndicationRequestHandler) HandleFeed(
+	feed *config.RedditFeed,
+	pageNum int,
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	posts, _, err := this.getPosts(feed)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal error retrieving posts for feed: %s %v", feed.Name, err), 500)
+		return
+	}
+	if len(posts) > syndication.MAX_ENTRIES {
+		posts = posts[:syndication.MAX_ENTRIES]
+	}
+
+	doc := syndication.Feed{
+		Title:       feed.Name,
+		Description: feed.Description,
+		Link:        syndication.AbsoluteUrl(r, constructUrl(&feed.Name, "", 0)),
+		SelfLink:    syndication.AbsoluteUrl(r, constructSyndicationUrl(feed.Name, this.format)),
+		Updated:     time.Now(),
+	}
+	if len(posts) > 0 {
+		doc.Updated = time.Unix(posts[0].TimeStored, 0)
+	}
+	for _, post := range posts {
+		permalink := "https://www.reddit.com" + post.Permalink
+		content := syndication.MediaLinkToHtml(post.MediaLink, post.Url)
+		if content == "" && post.Url != "" && post.Url != permalink {
+			content = fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(post.Url), html.EscapeString(post.Url))
+		}
+		content += string(post.Body)
+		doc.Entries = append(doc.Entries, syndication.Entry{
+			Id:        permalink,
+			Title:     post.Title,
+			Link:      permalink,
+			Author:    "/r/" + post.SubredditName,
+			Published: time.Unix(post.TimeCreated, 0),
+			Content:   content,
+		})
+	}
+	doc.Write(w, this.format)
+}
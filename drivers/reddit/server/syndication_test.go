@@ -0,0 +1,103 @@
+package server
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/coverprice/contentscraper/config"
+	"github.com/coverprice/contentscraper/database"
+	persist "github.com/coverprice/contentscraper/drivers/reddit/persistence"
+	"github.com/coverprice/contentscraper/drivers/reddit/types"
+	"github.com/coverprice/contentscraper/server/syndication"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFeedIsServedInEachFormat(t *testing.T) {
+	testDb, err := database.NewTestDatabase()
+	if err != nil {
+		t.Fatal("Could not init database", err)
+	}
+	defer testDb.Cleanup()
+
+	persistence, err := persist.NewPersistence(testDb.DbConn)
+	require.Nil(t, err, "Could not create persistence layer")
+
+	now := time.Now().Unix()
+	for i := 1; i <= 3; i++ {
+		_, err = persistence.StorePost(&types.RedditPost{
+			Id:            fmt.Sprintf("id_%d", i),
+			Name:          fmt.Sprintf("t3_id_%d", i),
+			TimeCreated:   now - int64(i),
+			TimeStored:    now - int64(i),
+			Permalink:     fmt.Sprintf("/r/syndicationtest/comments/id_%d/", i),
+			IsActive:      true,
+			Score:         int64(i),
+			Title:         fmt.Sprintf("Post %d", i),
+			Url:           fmt.Sprintf("https://example.com/%d", i),
+			SubredditName: "syndicationtest",
+			SubredditId:   "t5_test",
+		})
+		require.Nil(t, err, "Could not store post")
+	}
+
+	types.FeedRegistry.AddItem(&config.RedditFeed{
+		Name:        "syndicationtest",
+		Description: "A test feed",
+		Media:       config.MEDIA_TYPE_TEXT,
+		Subreddits: []config.Subreddit{
+			config.Subreddit{Name: "syndicationtest", Percentile: 100.0, MaxDailyPosts: 100},
+		},
+	})
+	defer delete(types.FeedRegistry, "syndicationtest")
+
+	handler := http.StripPrefix(BaseUrlPath, NewHttpHandler(map[string]IRequestHandler{
+		syndication.FORMAT_HTML: NewHtmlViewerRequestHandler(persistence),
+		syndication.FORMAT_RSS:  NewSyndicationRequestHandler(persistence, syndication.FORMAT_RSS),
+		syndication.FORMAT_ATOM: NewSyndicationRequestHandler(persistence, syndication.FORMAT_ATOM),
+	}, map[string]http.Handler{
+		SeenUrlPath: NewSeenRequestHandler(persistence),
+	}))
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		return w
+	}
+
+	w := get("/reddit/?feed=syndicationtest")
+	require.Equal(t, 200, w.Code)
+	require.Contains(t, w.Header().Get("Content-Type"), "text/html")
+
+	w = get("/reddit/rss?feed=syndicationtest")
+	require.Equal(t, 200, w.Code)
+	require.Contains(t, w.Header().Get("Content-Type"), "application/rss+xml")
+	var rss struct {
+		Channel struct {
+			Items []struct {
+				Title string `xml:"title"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	require.Nil(t, xml.Unmarshal(w.Body.Bytes(), &rss), "Could not parse RSS")
+	require.Contains(t, w.Body.String(), "<link>http://example.com/reddit/?feed=syndicationtest</link>")
+	require.Equal(t, 3, len(rss.Channel.Items))
+	// Same order as the HTML viewer; most recent first.
+	require.Equal(t, "Post 1", rss.Channel.Items[0].Title)
+
+	w = get("/reddit/?feed=syndicationtest&format=atom")
+	require.Equal(t, 200, w.Code)
+	require.Contains(t, w.Header().Get("Content-Type"), "application/atom+xml")
+	var atom struct {
+		Entries []struct {
+			Id string `xml:"id"`
+		} `xml:"entry"`
+	}
+	require.Nil(t, xml.Unmarshal(w.Body.Bytes(), &atom), "Could not parse Atom")
+	require.Equal(t, 3, len(atom.Entries))
+	require.Equal(t, "https://www.reddit.com/r/syndicationtest/comments/id_1/", atom.Entries[0].Id)
+
+	w = get("/reddit/?feed=syndicationtest&format=json")
+	require.Equal(t, 400, w.Code)
+}
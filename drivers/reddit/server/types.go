@@ -19,5 +19,6 @@ type IRequestHandler interface {
 		feed *config.RedditFeed,
 		pagenum int,
 		w http.ResponseWriter,
+		r *http.Request,
 	)
 }
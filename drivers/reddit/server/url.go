@@ -9,11 +9,16 @@ const (
 	BaseUrlPath = "/reddit/"
 )
 
-func constructUrl(feedname *string, pagenum int) string {
+// constructUrl returns the URL of the given page of the feed. If sortName is
+// empty the feed's configured sort order is used.
+func constructUrl(feedname *string, sortName string, pagenum int) string {
 	v := url.Values{}
 	if feedname != nil {
 		v.Set("feed", *feedname)
 	}
+	if sortName != "" {
+		v.Set("sort", sortName)
+	}
 	if pagenum != 0 {
 		v.Add("page", fmt.Sprintf("%d", pagenum))
 	}
@@ -23,3 +28,39 @@ func constructUrl(feedname *string, pagenum int) string {
 	}
 	return u.String()
 }
+
+// constructSyndicationUrl returns the URL of the feed rendered in the given
+// format, e.g. syndication.FORMAT_ATOM.
+func constructSyndicationUrl(feedname string, format string) string {
+	v := url.Values{}
+	v.Set("feed", feedname)
+	u := url.URL{
+		Path:     BaseUrlPath + format,
+		RawQuery: v.Encode(),
+	}
+	return u.String()
+}
+
+// constructHideSeenUrl returns the URL of the feed's 1st page that turns the feed's "hide read
+// posts" toggle on or off.
+func constructHideSeenUrl(feedname string, sortName string, hideSeen bool) string {
+	u, _ := url.Parse(constructUrl(&feedname, sortName, 0))
+	v := u.Query()
+	if hideSeen {
+		v.Set("hide_seen", "1")
+	} else {
+		v.Set("hide_seen", "0")
+	}
+	u.RawQuery = v.Encode()
+	return u.String()
+}
+
+// constructSeenUrl returns the URL that the posts the viewer has read are POSTed to.
+func constructSeenUrl() string {
+	return BaseUrlPath + SeenUrlPath
+}
+
+// constructStarUrl returns the URL that the posts the viewer stars (or unstars) are POSTed to.
+func constructStarUrl() string {
+	return BaseUrlPath + StarUrlPath
+}
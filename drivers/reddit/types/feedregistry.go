@@ -4,12 +4,15 @@ import (
 	"fmt"
 	"github.com/coverprice/contentscraper/config"
 	"github.com/coverprice/contentscraper/drivers"
+	"reflect"
+	"sync"
 )
 
 type FeedRegistryItem struct {
 	config.RedditFeed
-	Status            drivers.FeedHarvestStatus
-	TimeLastHarvested int64
+	// A pointer, so that it's shared with the item that replaces this one when the config is
+	// reloaded. (A harvest in progress may still be updating it).
+	*drivers.FeedHarvestState
 }
 
 type TFeedRegistry map[string]*FeedRegistryItem
@@ -18,17 +21,55 @@ type TFeedRegistry map[string]*FeedRegistryItem
 // that maps a Feed name to the Reddit configuration.
 var FeedRegistry = make(TFeedRegistry)
 
+// The config can be reloaded while the harvester and web server are reading the FeedRegistry.
+var feedRegistryLock sync.RWMutex
+
 func (this *TFeedRegistry) AddItem(feed *config.RedditFeed) {
+	feedRegistryLock.Lock()
+	defer feedRegistryLock.Unlock()
 	fri := FeedRegistryItem{
-		RedditFeed:        *feed,
-		Status:            drivers.FEEDHARVESTSTATUS_IDLE,
-		TimeLastHarvested: 0,
+		RedditFeed:       *feed,
+		FeedHarvestState: &drivers.FeedHarvestState{},
 	}
 
 	(*this)[fri.RedditFeed.Name] = &fri
 }
 
+// ReplaceItems atomically replaces the registered feeds with the given ones. Feeds that are kept
+// keep their harvest state. It returns the names of the feeds that were removed, or whose config
+// changed.
+func (this *TFeedRegistry) ReplaceItems(feeds []config.RedditFeed) (changedFeedNames []string) {
+	feedRegistryLock.Lock()
+	defer feedRegistryLock.Unlock()
+	var items = make(TFeedRegistry)
+	for _, feed := range feeds {
+		var fri = FeedRegistryItem{
+			RedditFeed:       feed,
+			FeedHarvestState: &drivers.FeedHarvestState{},
+		}
+		if oldItem, ok := (*this)[feed.Name]; ok {
+			fri.FeedHarvestState = oldItem.FeedHarvestState
+			if !reflect.DeepEqual(oldItem.RedditFeed, feed) {
+				changedFeedNames = append(changedFeedNames, feed.Name)
+			}
+		}
+		items[feed.Name] = &fri
+	}
+	for name := range *this {
+		if _, ok := items[name]; !ok {
+			changedFeedNames = append(changedFeedNames, name)
+		}
+		delete(*this, name)
+	}
+	for name, item := range items {
+		(*this)[name] = item
+	}
+	return
+}
+
 func (this *TFeedRegistry) GetItemByName(feedname string) (*FeedRegistryItem, error) {
+	feedRegistryLock.RLock()
+	defer feedRegistryLock.RUnlock()
 	item, ok := (*this)[feedname]
 	if !ok {
 		return nil, fmt.Errorf("Unknown feed name %s", feedname)
@@ -37,6 +78,8 @@ func (this *TFeedRegistry) GetItemByName(feedname string) (*FeedRegistryItem, er
 }
 
 func (this *TFeedRegistry) GetAllItems() (ret []*FeedRegistryItem) {
+	feedRegistryLock.RLock()
+	defer feedRegistryLock.RUnlock()
 	for _, val := range *this {
 		ret = append(ret, val)
 	}
@@ -0,0 +1,45 @@
+package types
+
+import (
+	"github.com/coverprice/contentscraper/config"
+	"github.com/coverprice/contentscraper/drivers"
+	"github.com/stretchr/testify/require"
+	"sort"
+	"testing"
+)
+
+func TestReplaceItems(t *testing.T) {
+	var registry = make(TFeedRegistry)
+	registry.AddItem(&config.RedditFeed{Name: "kept", Subreddits: []config.Subreddit{config.Subreddit{Name: "a"}}})
+	registry.AddItem(&config.RedditFeed{Name: "changed", Subreddits: []config.Subreddit{config.Subreddit{Name: "b"}}})
+	registry.AddItem(&config.RedditFeed{Name: "removed", Subreddits: []config.Subreddit{config.Subreddit{Name: "c"}}})
+	keptItem, err := registry.GetItemByName("kept")
+	require.Nil(t, err)
+	keptItem.BeginHarvest()
+	keptItem.SetHarvestError()
+
+	changedFeedNames := registry.ReplaceItems([]config.RedditFeed{
+		config.RedditFeed{Name: "kept", Subreddits: []config.Subreddit{config.Subreddit{Name: "a"}}},
+		config.RedditFeed{Name: "changed", Subreddits: []config.Subreddit{config.Subreddit{Name: "b"}, config.Subreddit{Name: "d"}}},
+		config.RedditFeed{Name: "added", Subreddits: []config.Subreddit{config.Subreddit{Name: "e"}}},
+	})
+	sort.Strings(changedFeedNames)
+	require.Equal(t, []string{"changed", "removed"}, changedFeedNames)
+	require.Equal(t, 3, len(registry.GetAllItems()))
+
+	_, err = registry.GetItemByName("removed")
+	require.NotNil(t, err, "Removed feeds should be dropped")
+	changedItem, err := registry.GetItemByName("changed")
+	require.Nil(t, err)
+	require.Equal(t, 2, len(changedItem.RedditFeed.Subreddits))
+
+	// The kept feed's harvest state is shared with its old item, which a harvest in progress may
+	// still update.
+	newKeptItem, err := registry.GetItemByName("kept")
+	require.Nil(t, err)
+	status, _ := newKeptItem.GetHarvestState()
+	require.Equal(t, drivers.FEEDHARVESTSTATUS_ERROR, status)
+	addedItem, err := registry.GetItemByName("added")
+	require.Nil(t, err)
+	require.NotNil(t, addedItem.FeedHarvestState)
+}
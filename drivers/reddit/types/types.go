@@ -1,5 +1,10 @@
 package types
 
+import (
+	"fmt"
+	"strings"
+)
+
 type RedditPost struct {
 	Id            string
 	Name          string // Note that this more of an ID, used in the "after" parameter of the scraper
@@ -9,8 +14,92 @@ type RedditPost struct {
 	IsActive      bool
 	IsSticky      bool
 	Score         int64
+	NumComments   int64
 	Title         string
 	Url           string
+	Author        string // The user name of the post's author, or "[deleted]"
+	LinkFlair     string `mapstructure:"link_flair"` // The post's link flair text, if any
+	IsNsfw        bool   `mapstructure:"is_nsfw"`
+	IsSpoiler     bool   `mapstructure:"is_spoiler"`
+	SelfText      string // The body of a self (text) post, in Reddit's flavor of Markdown
+	SelfTextHtml  string // The body of a self post, rendered as (unsanitized) HTML
 	SubredditName string `mapstructure:"subreddit_name"`
 	SubredditId   string `mapstructure:"subreddit_id"`
 }
+
+// Key returns the post's primary key.
+func (this RedditPost) Key() PostKey {
+	return PostKey{Id: this.Id, SubredditId: this.SubredditId}
+}
+
+// RedditComment is one of the top-level comments with the highest scores on a RedditPost.
+type RedditComment struct {
+	Id          string
+	PostId      string // The RedditPost's Id
+	Rank        int    // The comment's position among the post's top comments, starting at 1
+	Author      string
+	Body        string // The comment text, in Reddit's flavor of Markdown
+	Score       int64
+	TimeCreated int64
+	TimeStored  int64
+}
+
+// ScoreSnapshot is a RedditPost's score and # of comments at the time it was harvested.
+type ScoreSnapshot struct {
+	PostId       string
+	SubredditId  string
+	TimeObserved int64
+	Score        int64
+	NumComments  int64
+}
+
+// PostKey identifies a RedditPost, i.e. its primary key.
+type PostKey struct {
+	Id          string
+	SubredditId string
+}
+
+// String returns the key in the form "<post ID>/<subreddit ID>", as used by the viewer's pages.
+func (this PostKey) String() string {
+	return this.Id + "/" + this.SubredditId
+}
+
+// ParsePostKey is the inverse of PostKey.String().
+func ParsePostKey(s string) (key PostKey, err error) {
+	var parts = strings.SplitN(s, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return key, fmt.Errorf("Invalid post key: '%s'", s)
+	}
+	return PostKey{Id: parts[0], SubredditId: parts[1]}, nil
+}
+
+// SeenPost records when a viewer (see htmlutil.GetViewerId) was first shown a RedditPost, and
+// when they read it, i.e. opened it or marked it as read.
+type SeenPost struct {
+	PostKey
+	TimeShown int64
+	TimeRead  int64 // 0 if the post hasn't been read
+}
+
+// StarredPost is a copy of a RedditPost that a viewer starred, as it was at the time. It's kept
+// after the RedditPost is no longer shown in the feed.
+type StarredPost struct {
+	PostKey
+	FeedName      string // The feed the post was starred in
+	Title         string
+	Url           string
+	Permalink     string
+	SubredditName string
+	Score         int64
+	TimeCreated   int64
+	TimeStarred   int64
+}
+
+// PruneCandidate is a RedditPost that may be old enough to be deleted from the database, depending
+// on the retention period of the feeds it belongs to.
+type PruneCandidate struct {
+	PostKey
+	SubredditName string
+	SourceKeys    []string // The (non-subreddit) sources the post was also harvested from
+	TimeLastSeen  int64    // When the post was last stored or harvested
+}
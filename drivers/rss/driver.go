@@ -0,0 +1,100 @@
+package rss
+
+// Implements the IDriver interface for the RSS/Atom content source type
+
+import (
+	"context"
+	"database/sql"
+	"github.com/coverprice/contentscraper/config"
+	"github.com/coverprice/contentscraper/drivers"
+	"github.com/coverprice/contentscraper/drivers/feedserver"
+	harvest "github.com/coverprice/contentscraper/drivers/rss/harvester"
+	persist "github.com/coverprice/contentscraper/drivers/rss/persistence"
+	scrape "github.com/coverprice/contentscraper/drivers/rss/scraper"
+	"github.com/coverprice/contentscraper/drivers/rss/server"
+	"github.com/coverprice/contentscraper/drivers/rss/types"
+	"net/http"
+)
+
+// Verify that RssDriver satisfies the drivers.IDriver & drivers.IApiDriver interfaces.
+var _ drivers.IDriver = &RssDriver{}
+var _ drivers.IApiDriver = &RssDriver{}
+
+// RssDriver implements drivers.IDriver. It follows the Facade pattern
+// and delegates the work of the interface to subordinate classes.
+type RssDriver struct {
+	harvester         *harvest.Harvester
+	httpHandler       *feedserver.HttpHandler
+	apiRequestHandler *feedserver.ApiRequestHandler
+}
+
+func NewRssDriver(
+	harvesterDbconn *sql.DB, // DB connection used to store harvested content
+	viewerDbconn *sql.DB, // DB connection used to retrieve harvested content
+	conf *config.Config,
+) (driver *RssDriver, err error) {
+	// Setup harvester
+	var scraper = scrape.NewScraper()
+
+	var persistenceHarvester *persist.Persistence
+	if persistenceHarvester, err = persist.NewPersistence(harvesterDbconn); err != nil {
+		return
+	}
+
+	var harvester *harvest.Harvester
+	harvester, err = harvest.NewHarvester(
+		scraper,
+		persistenceHarvester,
+	)
+	if err != nil {
+		return
+	}
+
+	// Setup Feed viewer
+	var persistenceViewer *persist.Persistence
+	if persistenceViewer, err = persist.NewPersistence(viewerDbconn); err != nil {
+		return
+	}
+	postRetriever := feedserver.NewPostRetriever(server.NewAdapter(persistenceViewer))
+
+	// Configure Feeds to view
+	for _, feed := range conf.Rss.Feeds {
+		types.FeedRegistry.AddItem(&feed)
+	}
+
+	return &RssDriver{
+		harvester:         harvester,
+		httpHandler:       feedserver.NewHttpHandler(postRetriever),
+		apiRequestHandler: feedserver.NewApiRequestHandler(postRetriever),
+	}, nil
+}
+
+func (this *RssDriver) GetBaseUrlPath() string {
+	return server.BaseUrlPath
+}
+
+func (this *RssDriver) GetFeeds() []drivers.Feed {
+	var ret = make([]drivers.Feed, 0)
+	for _, feedregistryitem := range types.FeedRegistry.GetAllItems() {
+		status, timeLastHarvested := feedregistryitem.GetHarvestState()
+		ret = append(ret, drivers.Feed{
+			Name:              feedregistryitem.RssFeed.Name,
+			Description:       feedregistryitem.RssFeed.Description,
+			Status:            status,
+			TimeLastHarvested: timeLastHarvested,
+		})
+	}
+	return ret
+}
+
+func (this *RssDriver) GetHttpHandler() http.Handler {
+	return this.httpHandler
+}
+
+func (this *RssDriver) Harvest(ctx context.Context) (err error) {
+	return this.harvester.Harvest(ctx)
+}
+
+func (this *RssDriver) GetApiPosts(feedName string) ([]drivers.IApiPost, error) {
+	return this.apiRequestHandler.GetApiPosts(feedName)
+}
@@ -0,0 +1,87 @@
+package rss
+
+import (
+	"context"
+	persist "github.com/coverprice/contentscraper/drivers/rss/persistence"
+	scrape "github.com/coverprice/contentscraper/drivers/rss/scraper"
+	"github.com/coverprice/contentscraper/drivers/rss/types"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// Harvester controls the process of fetching RSS/Atom documents and persisting
+// their items.
+// It uses a Scraper to fetch and parse each document, and a Persistence layer to
+// insert/update the items. (Items already stored are updated to reflect any edits
+// to their title or link).
+type Harvester struct {
+	scraper     scrape.IScraper
+	persistence *persist.Persistence
+}
+
+// Creates a new Harvester instance
+func NewHarvester(
+	scraper scrape.IScraper,
+	persistence *persist.Persistence,
+) (*Harvester, error) {
+	return &Harvester{
+		scraper:     scraper,
+		persistence: persistence,
+	}, nil
+}
+
+// Harvest fetches every source in every registered feed. Failures to harvest
+// a source are logged and reflected in the feed's status, rather than aborting the
+// whole harvest. If ctx is cancelled, the harvest is abandoned and ctx.Err() is returned.
+func (this *Harvester) Harvest(ctx context.Context) (err error) {
+	for _, feed := range types.FeedRegistry.GetAllItems() {
+		feed.BeginHarvest()
+
+	NextSource:
+		for _, sourceUrl := range feed.GetSourceUrls() {
+			if err := this.pullSource(ctx, sourceUrl); err != nil {
+				if ctx.Err() != nil {
+					// The harvest was cancelled
+					feed.EndHarvest()
+					return ctx.Err()
+				}
+				log.Errorf("Error harvesting RSS source '%s': %v", sourceUrl, err)
+				feed.SetHarvestError()
+				continue NextSource
+			}
+		}
+
+		feed.EndHarvest()
+	}
+
+	return nil
+}
+
+func (this *Harvester) pullSource(ctx context.Context, sourceUrl string) (err error) {
+	log.Infof("Pulling from RSS source '%s'", sourceUrl)
+	var now = int64(time.Now().Unix())
+
+	var items []types.Item
+	if items, err = this.scraper.GetItems(ctx, sourceUrl); err != nil {
+		return
+	}
+
+	numNewItems := 0
+	for _, item := range items {
+		var result persist.StoreResult
+		item.TimeStored = now
+		if item.TimeCreated == 0 || item.TimeCreated > now {
+			// Undated items (or ones dated in the future) are considered to have
+			// been published when they were first seen.
+			item.TimeCreated = now
+		}
+		if result, err = this.persistence.StoreItem(&item); err != nil {
+			return
+		}
+		if result == persist.StoreResult(persist.STORERESULT_NEW) {
+			numNewItems++
+		}
+	}
+	log.Debugf("Pulled %d items (%d new) from '%s'", len(items), numNewItems, sourceUrl)
+	return nil
+}
@@ -0,0 +1,124 @@
+package rss
+
+import (
+	"context"
+	"fmt"
+	"github.com/coverprice/contentscraper/config"
+	"github.com/coverprice/contentscraper/database"
+	"github.com/coverprice/contentscraper/drivers"
+	persist "github.com/coverprice/contentscraper/drivers/rss/persistence"
+	"github.com/coverprice/contentscraper/drivers/rss/types"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// fakeScraper serves the documents in its map.
+type fakeScraper struct {
+	documents map[string][]types.Item
+}
+
+func (this *fakeScraper) GetItems(ctx context.Context, sourceUrl string) ([]types.Item, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	items, ok := this.documents[sourceUrl]
+	if !ok {
+		return nil, fmt.Errorf("Fake failure")
+	}
+	return items, nil
+}
+
+func TestHarvesterRetrievesAndStoresItems(t *testing.T) {
+	testDb, err := database.NewTestDatabase()
+	if err != nil {
+		t.Fatal("Could not init database", err)
+	}
+	defer testDb.Cleanup()
+
+	scraper := &fakeScraper{documents: map[string][]types.Item{
+		"https://example.com/rss": []types.Item{
+			types.Item{Guid: "1", SourceUrl: "https://example.com/rss", TimeCreated: 1234},
+			types.Item{Guid: "2", SourceUrl: "https://example.com/rss"},
+		},
+		"https://example.org/atom": []types.Item{
+			types.Item{Guid: "1", SourceUrl: "https://example.org/atom", TimeCreated: 1234},
+		},
+	}}
+	persistence, err := persist.NewPersistence(testDb.DbConn)
+	require.Nil(t, err, "Could not initialize persistence layer")
+	harvester, err := NewHarvester(scraper, persistence)
+	require.Nil(t, err, "Could not initialize Harvester")
+
+	types.FeedRegistry.AddItem(&config.RssFeed{
+		Name: "testfeed",
+		Sources: []config.RssSource{
+			config.RssSource{Url: "https://example.com/rss"},
+			config.RssSource{Url: "https://example.org/atom"},
+		},
+	})
+	types.FeedRegistry.AddItem(&config.RssFeed{
+		Name:    "brokenfeed",
+		Sources: []config.RssSource{config.RssSource{Url: "https://example.net/missing"}},
+	})
+	defer delete(types.FeedRegistry, "testfeed")
+	defer delete(types.FeedRegistry, "brokenfeed")
+
+	err = harvester.Harvest(context.Background())
+	require.Nil(t, err, "Harvest() failed")
+
+	var cnt int
+	err = testDb.DbConn.QueryRow(`SELECT COUNT(*) FROM rssitem`).Scan(&cnt)
+	require.Nil(t, err, "Could not retrieve the count of items")
+	require.Equal(t, 3, cnt)
+
+	// The undated item is considered to have been created when it was harvested.
+	err = testDb.DbConn.QueryRow(`SELECT COUNT(*) FROM rssitem WHERE time_created = time_stored`).Scan(&cnt)
+	require.Nil(t, err, "Could not retrieve the count of items")
+	require.Equal(t, 1, cnt)
+
+	feed, _ := types.FeedRegistry.GetItemByName("testfeed")
+	status, _ := feed.GetHarvestState()
+	require.Equal(t, drivers.FEEDHARVESTSTATUS_IDLE, status)
+	feed, _ = types.FeedRegistry.GetItemByName("brokenfeed")
+	status, _ = feed.GetHarvestState()
+	require.Equal(t, drivers.FEEDHARVESTSTATUS_ERROR, status)
+}
+
+func TestCancelledHarvestIsAbandoned(t *testing.T) {
+	testDb, err := database.NewTestDatabase()
+	if err != nil {
+		t.Fatal("Could not init database", err)
+	}
+	defer testDb.Cleanup()
+
+	scraper := &fakeScraper{documents: map[string][]types.Item{
+		"https://example.com/rss": []types.Item{
+			types.Item{Guid: "1", SourceUrl: "https://example.com/rss", TimeCreated: 1234},
+		},
+	}}
+	persistence, err := persist.NewPersistence(testDb.DbConn)
+	require.Nil(t, err, "Could not initialize persistence layer")
+	harvester, err := NewHarvester(scraper, persistence)
+	require.Nil(t, err, "Could not initialize Harvester")
+
+	types.FeedRegistry.AddItem(&config.RssFeed{
+		Name:    "testfeed",
+		Sources: []config.RssSource{config.RssSource{Url: "https://example.com/rss"}},
+	})
+	defer delete(types.FeedRegistry, "testfeed")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = harvester.Harvest(ctx)
+	require.Equal(t, context.Canceled, err)
+
+	var cnt int
+	err = testDb.DbConn.QueryRow(`SELECT COUNT(*) FROM rssitem`).Scan(&cnt)
+	require.Nil(t, err, "Could not retrieve the count of items")
+	require.Equal(t, 0, cnt)
+
+	// Being cancelled is not the feed's fault.
+	feed, _ := types.FeedRegistry.GetItemByName("testfeed")
+	status, _ := feed.GetHarvestState()
+	require.Equal(t, drivers.FEEDHARVESTSTATUS_IDLE, status)
+}
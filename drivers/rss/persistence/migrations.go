@@ -0,0 +1,36 @@
+package persistence
+
+import (
+	"github.com/coverprice/contentscraper/database"
+)
+
+// The name under which this package's migrations are recorded in the schema_version table.
+const MIGRATION_COMPONENT = "rss"
+
+// The ordered list of changes to the RSS driver's tables.
+var migrations = []database.Migration{
+	database.Migration{
+		Version:     1,
+		Description: "Create rssitem table",
+		// GUIDs are only guaranteed to be unique within a single document, so items are
+		// keyed on the document they came from as well.
+		Sql: `
+        CREATE TABLE IF NOT EXISTS rssitem
+            ( source_url TEXT NOT NULL
+            , guid TEXT NOT NULL
+            , title TEXT NOT NULL
+            , url TEXT NOT NULL
+            , time_created INTEGER NOT NULL
+            , time_stored INTEGER NOT NULL
+            , PRIMARY KEY (source_url, guid)
+        ) WITHOUT ROWID
+        ;
+        CREATE INDEX IF NOT EXISTS
+            rssitem_time_created ON rssitem(time_created)
+    `,
+	},
+}
+
+func init() {
+	database.RegisterMigrations(MIGRATION_COMPONENT, migrations)
+}
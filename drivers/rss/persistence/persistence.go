@@ -0,0 +1,180 @@
+package persistence
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/coverprice/contentscraper/database"
+	"github.com/coverprice/contentscraper/drivers/rss/types"
+	log "github.com/sirupsen/logrus"
+	"strings"
+)
+
+type Persistence struct {
+	dbconn         *sql.DB
+	searchItemById *sql.Stmt
+}
+
+func NewPersistence(dbconn *sql.DB) (persistence *Persistence, err error) {
+	persistence = &Persistence{
+		dbconn: dbconn,
+	}
+	if err = persistence.initTables(); err != nil {
+		return
+	}
+
+	persistence.searchItemById, err = persistence.dbconn.Prepare(`
+        SELECT EXISTS(
+            SELECT 1
+            FROM rssitem
+            WHERE source_url = $a
+              AND guid = $b
+            LIMIT 1
+        )`)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this *Persistence) initTables() (err error) {
+	return database.Migrate(this.dbconn, MIGRATION_COMPONENT)
+}
+
+// Stores/Updates an Item and returns whether it was a store or an
+// update.
+
+type StoreResult int
+
+const (
+	STORERESULT_NEW = iota
+	STORERESULT_UPDATED
+)
+
+func (this *Persistence) StoreItem(item *types.Item) (result StoreResult, err error) {
+	var itemExists int
+	if err = this.searchItemById.QueryRow(item.SourceUrl, item.Guid).Scan(&itemExists); err != nil {
+		return
+	}
+	if itemExists == 0 {
+		if err = this.insertItem(item); err != nil {
+			return
+		}
+		return STORERESULT_NEW, nil
+	} else {
+		if err = this.updateItem(item); err != nil {
+			return
+		}
+		return STORERESULT_UPDATED, nil
+	}
+}
+
+func (this *Persistence) insertItem(item *types.Item) (err error) {
+	_, err = this.dbconn.Exec(`
+        INSERT INTO rssitem
+            ( source_url
+            , guid
+            , title
+            , url
+            , time_created
+            , time_stored
+        ) VALUES
+            ( $a
+            , $b
+            , $c
+            , $d
+            , $e
+            , $f
+        )`,
+		item.SourceUrl,
+		item.Guid,
+		item.Title,
+		item.Url,
+		item.TimeCreated,
+		item.TimeStored,
+	)
+	return
+}
+
+// updateItem refreshes the item's title & link, which publishers occasionally edit.
+// The time it was created is left alone, so that undated items don't keep moving.
+func (this *Persistence) updateItem(item *types.Item) (err error) {
+	_, err = this.dbconn.Exec(`
+        UPDATE rssitem SET
+              title = $a
+            , url = $b
+        WHERE source_url = $c
+          AND guid = $d
+        `,
+		item.Title,
+		item.Url,
+
+		item.SourceUrl,
+		item.Guid,
+	)
+	return
+}
+
+func (this *Persistence) GetItems(
+	where_clause string,
+	params ...interface{},
+) (items []types.Item, err error) {
+	var rows *sql.Rows
+	var sql = `
+        SELECT
+            source_url
+            , guid
+            , title
+            , url
+            , time_created
+            , time_stored
+        FROM rssitem
+        ` + where_clause
+	if rows, err = this.dbconn.Query(sql, params...); err != nil {
+		log.Errorf("Error calling SQL %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item types.Item
+
+		err = rows.Scan(
+			&item.SourceUrl,
+			&item.Guid,
+			&item.Title,
+			&item.Url,
+			&item.TimeCreated,
+			&item.TimeStored,
+		)
+		if err != nil {
+			return
+		}
+		items = append(items, item)
+	}
+	log.Debugf("Retrieved %d items from the database", len(items))
+	return items, nil
+}
+
+// GetItemsForSources returns the items created since minTime in any of the given documents.
+func (this *Persistence) GetItemsForSources(
+	minTime int64,
+	sourceUrls []string,
+) ([]types.Item, error) {
+	if len(sourceUrls) == 0 {
+		return nil, nil
+	}
+	var placeholders []string
+	var params = []interface{}{minTime}
+	for _, sourceUrl := range sourceUrls {
+		placeholders = append(placeholders, fmt.Sprintf("$p%d", len(params)))
+		params = append(params, sourceUrl)
+	}
+
+	whereClause := `
+        WHERE time_created >= $a
+          AND source_url IN (%s)
+        LIMIT 3000
+    `
+	whereClause = fmt.Sprintf(whereClause, strings.Join(placeholders, ", "))
+	return this.GetItems(whereClause, params...)
+}
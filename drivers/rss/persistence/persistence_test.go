@@ -0,0 +1,88 @@
+package persistence
+
+import (
+	"fmt"
+	"github.com/coverprice/contentscraper/database"
+	"github.com/coverprice/contentscraper/drivers/rss/types"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCanCreateAndRetrieveItem(t *testing.T) {
+	testDb, err := database.NewTestDatabase()
+	if err != nil {
+		t.Fatal("Could not init database", err)
+	}
+	defer testDb.Cleanup()
+
+	sut, err := NewPersistence(testDb.DbConn)
+	require.Nil(t, err, "Could not create persistence layer")
+
+	var item = &types.Item{
+		Guid:        "post-1",
+		SourceUrl:   "https://example.com/rss",
+		Title:       "A fake item",
+		Url:         "https://example.com/post-1",
+		TimeCreated: 1234,
+		TimeStored:  1235,
+	}
+
+	var result StoreResult
+	result, err = sut.StoreItem(item)
+	require.Nil(t, err, "Could not store item")
+	require.Equal(t, StoreResult(STORERESULT_NEW), result, "Unexpected StoreResult")
+
+	// Re-harvesting an item updates its title, but not when it was created.
+	item.Title = "An edited title"
+	item.TimeCreated = 5678
+	result, err = sut.StoreItem(item)
+	require.Nil(t, err, "Could not update item")
+	require.Equal(t, StoreResult(STORERESULT_UPDATED), result, "Unexpected StoreResult")
+
+	// The same GUID in a different document is a different item.
+	other := *item
+	other.SourceUrl = "https://example.org/atom"
+	result, err = sut.StoreItem(&other)
+	require.Nil(t, err, "Could not store item")
+	require.Equal(t, StoreResult(STORERESULT_NEW), result, "Unexpected StoreResult")
+
+	items, err := sut.GetItems("WHERE source_url = $a", "https://example.com/rss")
+	require.Nil(t, err, "Could not retrieve items")
+	require.Equal(t, 1, len(items))
+	require.Equal(t, "An edited title", items[0].Title, "Title was not updated")
+	require.Equal(t, int64(1234), items[0].TimeCreated, "TimeCreated should not be updated")
+}
+
+func TestGetItemsForSources(t *testing.T) {
+	testDb, err := database.NewTestDatabase()
+	if err != nil {
+		t.Fatal("Could not init database", err)
+	}
+	defer testDb.Cleanup()
+
+	sut, err := NewPersistence(testDb.DbConn)
+	require.Nil(t, err, "Could not create persistence layer")
+
+	for _, sourceUrl := range []string{"https://a.example.com/", "https://b.example.com/", "https://c.example.com/"} {
+		for i := int64(1); i <= 10; i++ {
+			_, err = sut.StoreItem(&types.Item{
+				Guid:        fmt.Sprintf("item-%d", i),
+				SourceUrl:   sourceUrl,
+				TimeCreated: i * 100,
+			})
+			require.Nil(t, err, "Could not store item")
+		}
+	}
+
+	items, err := sut.GetItemsForSources(600, []string{"https://a.example.com/", "https://c.example.com/"})
+	require.Nil(t, err, "Could not retrieve items")
+	require.Equal(t, 10, len(items), "Unexpected number of items")
+	for _, item := range items {
+		require.NotEqual(t, "https://b.example.com/", item.SourceUrl)
+		require.True(t, item.TimeCreated >= 600)
+	}
+
+	items, err = sut.GetItemsForSources(0, nil)
+	require.Nil(t, err, "Could not retrieve items")
+	require.Equal(t, 0, len(items))
+}
@@ -0,0 +1,51 @@
+package rss
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// The characters that windows-1252 encodes as the bytes 0x80-0x9F, where ISO-8859-1 has control
+// characters. (The bytes that windows-1252 leaves undefined are decoded as in ISO-8859-1).
+var windows1252Runes = [32]rune{
+	'€', '\u0081', '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', '\u008d', 'Ž', '\u008f',
+	'\u0090', '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', '\u009d', 'ž', 'Ÿ',
+}
+
+// charsetReader is the xml.Decoder's CharsetReader, which converts a document that declares an
+// encoding other than UTF-8 to UTF-8. Only the encodings that feeds commonly declare are
+// supported. Documents in other encodings are rejected, rather than being misread.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "us-ascii", "ascii", "utf8":
+		// ASCII is a subset of UTF-8.
+		return input, nil
+	case "iso-8859-1", "iso8859-1", "iso_8859-1", "latin1", "l1":
+		return decodeSingleByteCharset(input, nil)
+	case "windows-1252", "cp1252":
+		return decodeSingleByteCharset(input, &windows1252Runes)
+	}
+	return nil, fmt.Errorf("Unsupported charset: '%s'", charset)
+}
+
+// decodeSingleByteCharset converts a document in ISO-8859-1 to UTF-8. Every byte of ISO-8859-1
+// is the character with that code point. If highControlRunes isn't nil, it overrides the
+// characters of the bytes 0x80-0x9F.
+func decodeSingleByteCharset(input io.Reader, highControlRunes *[32]rune) (io.Reader, error) {
+	data, err := ioutil.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	for _, b := range data {
+		if highControlRunes != nil && b >= 0x80 && b <= 0x9F {
+			buf.WriteRune(highControlRunes[b-0x80])
+		} else {
+			buf.WriteRune(rune(b))
+		}
+	}
+	return &buf, nil
+}
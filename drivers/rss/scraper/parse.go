@@ -0,0 +1,150 @@
+package rss
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"github.com/coverprice/contentscraper/drivers/rss/types"
+	"strings"
+	"time"
+)
+
+// The parts of an RSS 2.0 document that we care about.
+type rssDocument struct {
+	Channel struct {
+		Items []struct {
+			Title   string `xml:"title"`
+			Link    string `xml:"link"`
+			Guid    string `xml:"guid"`
+			PubDate string `xml:"pubDate"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+// The parts of an Atom document that we care about.
+type atomDocument struct {
+	Entries []struct {
+		Title string `xml:"title"`
+		Id    string `xml:"id"`
+		Links []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"link"`
+		Published string `xml:"published"`
+		Updated   string `xml:"updated"`
+	} `xml:"entry"`
+}
+
+// Date formats seen in the wild. RSS is supposed to use RFC 822 dates and Atom RFC 3339,
+// but many publishers get this slightly wrong.
+var dateFormats = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC822Z,
+	time.RFC822,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// parseDate returns the unix time of the given date, or 0 if it could not be parsed.
+func parseDate(s string) int64 {
+	s = strings.TrimSpace(s)
+	for _, format := range dateFormats {
+		if t, err := time.Parse(format, s); err == nil {
+			return t.Unix()
+		}
+	}
+	return 0
+}
+
+// ParseDocument parses an RSS 2.0 or Atom document into Items. Items without a
+// publication date are given a TimeCreated of 0, and the caller is expected to fill it in.
+func ParseDocument(sourceUrl string, data []byte) (items []types.Item, err error) {
+	var rootElement string
+	if rootElement, err = getRootElementName(data); err != nil {
+		return nil, fmt.Errorf("Could not parse document from '%s': %v", sourceUrl, err)
+	}
+
+	switch rootElement {
+	case "rss":
+		var doc rssDocument
+		if err = newDecoder(data).Decode(&doc); err != nil {
+			return nil, fmt.Errorf("Could not parse RSS document from '%s': %v", sourceUrl, err)
+		}
+		for _, rssitem := range doc.Channel.Items {
+			item := types.Item{
+				Guid:        strings.TrimSpace(rssitem.Guid),
+				SourceUrl:   sourceUrl,
+				Title:       strings.TrimSpace(rssitem.Title),
+				Url:         strings.TrimSpace(rssitem.Link),
+				TimeCreated: parseDate(rssitem.PubDate),
+			}
+			items = appendItem(items, item)
+		}
+
+	case "feed":
+		var doc atomDocument
+		if err = newDecoder(data).Decode(&doc); err != nil {
+			return nil, fmt.Errorf("Could not parse Atom document from '%s': %v", sourceUrl, err)
+		}
+		for _, entry := range doc.Entries {
+			item := types.Item{
+				Guid:        strings.TrimSpace(entry.Id),
+				SourceUrl:   sourceUrl,
+				Title:       strings.TrimSpace(entry.Title),
+				TimeCreated: parseDate(entry.Published),
+			}
+			if item.TimeCreated == 0 {
+				item.TimeCreated = parseDate(entry.Updated)
+			}
+			for _, link := range entry.Links {
+				// The "alternate" link (the default) points at the content.
+				if link.Rel == "" || link.Rel == "alternate" {
+					item.Url = strings.TrimSpace(link.Href)
+					break
+				}
+			}
+			items = appendItem(items, item)
+		}
+
+	default:
+		return nil, fmt.Errorf("Document from '%s' is neither RSS nor Atom (root element: '%s')", sourceUrl, rootElement)
+	}
+	return items, nil
+}
+
+// appendItem appends the item to the list, provided it can be uniquely identified.
+func appendItem(items []types.Item, item types.Item) []types.Item {
+	if item.Guid == "" {
+		item.Guid = item.Url
+	}
+	if item.Guid == "" {
+		// Nothing to identify this item by, so it can't be de-duplicated.
+		return items
+	}
+	return append(items, item)
+}
+
+// getRootElementName returns the name of the document's outermost XML element.
+func getRootElementName(data []byte) (string, error) {
+	decoder := newDecoder(data)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", err
+		}
+		if element, ok := token.(xml.StartElement); ok {
+			return element.Name.Local, nil
+		}
+	}
+}
+
+func newDecoder(data []byte) *xml.Decoder {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = charsetReader
+	return decoder
+}
@@ -0,0 +1,70 @@
+package rss
+
+import (
+	"context"
+	"fmt"
+	"github.com/coverprice/contentscraper/drivers/rss/types"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const (
+	useragent = "Fedora:github.com/coverprice/contentscraper:0.1.0"
+
+	// Refuse to read documents larger than this, in case a URL points at something
+	// that isn't really a feed.
+	maxDocumentSize = 10 * 1024 * 1024
+)
+
+// IScraper is the interface the Harvester uses to retrieve items. It exists
+// so that the harvester can be driven by something other than the live web.
+type IScraper interface {
+	// GetItems fetches the RSS/Atom document at sourceUrl and returns its items.
+	// The request is abandoned if ctx is cancelled.
+	GetItems(ctx context.Context, sourceUrl string) ([]types.Item, error)
+}
+
+// Verify that Scraper implements the IScraper interface
+var _ IScraper = &Scraper{}
+
+// Scraper fetches RSS 2.0 and Atom documents over HTTP.
+type Scraper struct {
+	client *http.Client
+}
+
+func NewScraper() *Scraper {
+	return &Scraper{
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (this *Scraper) GetItems(ctx context.Context, sourceUrl string) (items []types.Item, err error) {
+	req, err := http.NewRequest("GET", sourceUrl, nil)
+	if err != nil {
+		return
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("User-Agent", useragent)
+
+	resp, err := this.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to fetch '%s': %v", sourceUrl, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Failed to fetch '%s': Unexpected HTTP status: %s", sourceUrl, resp.Status)
+	}
+
+	// (Read one more byte than the maximum, to tell whether the document is larger).
+	var data []byte
+	if data, err = ioutil.ReadAll(io.LimitReader(resp.Body, maxDocumentSize+1)); err != nil {
+		return nil, fmt.Errorf("Failed to read '%s': %v", sourceUrl, err)
+	}
+	if len(data) > maxDocumentSize {
+		return nil, fmt.Errorf("Failed to read '%s': The document is larger than %d bytes", sourceUrl, maxDocumentSize)
+	}
+	return ParseDocument(sourceUrl, data)
+}
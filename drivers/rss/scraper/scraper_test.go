@@ -0,0 +1,140 @@
+package rss
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const sampleRss = `<?xml version="1.0" encoding="ISO-8859-1"?>
+<rss version="2.0">
+  <channel>
+    <title>Example blog</title>
+    <link>https://example.com/</link>
+    <item>
+      <title>First post</title>
+      <link>https://example.com/first</link>
+      <guid isPermaLink="false">post-1</guid>
+      <pubDate>Tue, 17 Oct 2017 09:00:00 +0000</pubDate>
+    </item>
+    <item>
+      <title>Second post</title>
+      <link>https://example.com/second</link>
+      <pubDate>Wed, 18 Oct 2017 09:00:00 GMT</pubDate>
+    </item>
+    <item>
+      <title>Nothing to identify this one by</title>
+    </item>
+  </channel>
+</rss>`
+
+const sampleAtom = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Example site</title>
+  <entry>
+    <title>An entry</title>
+    <id>tag:example.com,2017:entry-1</id>
+    <link rel="self" href="https://example.com/entry-1.atom"/>
+    <link href="https://example.com/entry-1"/>
+    <updated>2017-10-17T09:00:00Z</updated>
+  </entry>
+  <entry>
+    <title>Undated entry</title>
+    <id>tag:example.com,2017:entry-2</id>
+    <link rel="alternate" href="https://example.com/entry-2"/>
+  </entry>
+</feed>`
+
+func TestCanParseRss(t *testing.T) {
+	items, err := ParseDocument("https://example.com/rss", []byte(sampleRss))
+	require.Nil(t, err, "Could not parse RSS")
+	require.Equal(t, 2, len(items), "Items without a guid or link should be skipped")
+
+	require.Equal(t, "post-1", items[0].Guid)
+	require.Equal(t, "https://example.com/rss", items[0].SourceUrl)
+	require.Equal(t, "First post", items[0].Title)
+	require.Equal(t, "https://example.com/first", items[0].Url)
+	require.Equal(t, time.Date(2017, 10, 17, 9, 0, 0, 0, time.UTC).Unix(), items[0].TimeCreated)
+
+	require.Equal(t, "https://example.com/second", items[1].Guid, "The link should be used when there's no guid")
+	require.Equal(t, time.Date(2017, 10, 18, 9, 0, 0, 0, time.UTC).Unix(), items[1].TimeCreated)
+}
+
+func TestCanParseAtom(t *testing.T) {
+	items, err := ParseDocument("https://example.com/atom", []byte(sampleAtom))
+	require.Nil(t, err, "Could not parse Atom")
+	require.Equal(t, 2, len(items))
+
+	require.Equal(t, "tag:example.com,2017:entry-1", items[0].Guid)
+	require.Equal(t, "An entry", items[0].Title)
+	require.Equal(t, "https://example.com/entry-1", items[0].Url, "The 'self' link should be ignored")
+	require.Equal(t, time.Date(2017, 10, 17, 9, 0, 0, 0, time.UTC).Unix(), items[0].TimeCreated)
+
+	require.Equal(t, "https://example.com/entry-2", items[1].Url)
+	require.Equal(t, int64(0), items[1].TimeCreated)
+}
+
+func TestRejectsOtherDocuments(t *testing.T) {
+	_, err := ParseDocument("https://example.com/", []byte(`<html><body>Not a feed</body></html>`))
+	require.NotNil(t, err, "Expected an error for an HTML document")
+
+	_, err = ParseDocument("https://example.com/", []byte(`{"not": "xml"}`))
+	require.NotNil(t, err, "Expected an error for a non-XML document")
+}
+
+func TestCanScrapeItems(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rss", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, sampleRss)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	scraper := NewScraper()
+
+	items, err := scraper.GetItems(context.Background(), server.URL+"/rss")
+	require.Nil(t, err, "Non nil error from scraper")
+	require.Equal(t, 2, len(items))
+	require.Equal(t, server.URL+"/rss", items[0].SourceUrl)
+
+	_, err = scraper.GetItems(context.Background(), server.URL+"/missing")
+	require.NotNil(t, err, "Expected an error for a 404")
+}
+
+func TestDecodesDeclaredCharsets(t *testing.T) {
+	document := func(charset string, title string) []byte {
+		return []byte(`<?xml version="1.0" encoding="` + charset + `"?>
+<rss version="2.0"><channel><item><title>` + title + `</title><guid>post-1</guid></item></channel></rss>`)
+	}
+
+	items, err := ParseDocument("https://example.com/rss", document("ISO-8859-1", "Caf\xe9"))
+	require.Nil(t, err, "Could not parse ISO-8859-1")
+	require.Equal(t, "Café", items[0].Title)
+
+	items, err = ParseDocument("https://example.com/rss", document("windows-1252", "\x93Caf\xe9\x94 \x80"))
+	require.Nil(t, err, "Could not parse windows-1252")
+	require.Equal(t, "“Café” €", items[0].Title)
+
+	items, err = ParseDocument("https://example.com/rss", document("US-ASCII", "Cafe"))
+	require.Nil(t, err, "Could not parse US-ASCII")
+	require.Equal(t, "Cafe", items[0].Title)
+
+	_, err = ParseDocument("https://example.com/rss", document("Shift_JIS", "Cafe"))
+	require.NotNil(t, err, "Expected an error for an unsupported charset")
+}
+
+func TestRejectsDocumentsThatAreTooLarge(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, maxDocumentSize+1))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	_, err := NewScraper().GetItems(context.Background(), server.URL+"/large")
+	require.NotNil(t, err, "Expected an error for a document larger than the maximum")
+	require.Contains(t, err.Error(), "larger than")
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"github.com/coverprice/contentscraper/config"
+	"github.com/coverprice/contentscraper/drivers"
+	"github.com/coverprice/contentscraper/drivers/feedserver"
+	persist "github.com/coverprice/contentscraper/drivers/rss/persistence"
+	"github.com/coverprice/contentscraper/drivers/rss/types"
+	"net/url"
+)
+
+// Verify that Adapter implements the feedserver.IAdapter interface
+var _ feedserver.IAdapter = &Adapter{}
+
+// Adapter serves the RSS feeds through the feedserver. Each of a feed's RSS/Atom documents is
+// a source. RSS items have no score, so unlike the other drivers there is no percentile filter,
+// and items are ranked purely by how recent they are. An item in several sources is shown in
+// the first.
+type Adapter struct {
+	persistence *persist.Persistence
+}
+
+// sourcedItem is an item, along with the configured name of the source it came from.
+type sourcedItem struct {
+	types.Item
+	SourceName string
+}
+
+func NewAdapter(persistence *persist.Persistence) *Adapter {
+	return &Adapter{
+		persistence: persistence,
+	}
+}
+
+func (this *Adapter) GetName() string {
+	return "RSS"
+}
+
+func (this *Adapter) GetBaseUrlPath() string {
+	return BaseUrlPath
+}
+
+func (this *Adapter) GetFeed(feedName string) (*feedserver.Feed, error) {
+	item, err := types.FeedRegistry.GetItemByName(feedName)
+	if err != nil {
+		return nil, err
+	}
+	return newFeed(&item.RssFeed), nil
+}
+
+func newFeed(feed *config.RssFeed) *feedserver.Feed {
+	var maxDailyPosts = make(map[string]int) // Source URL -> Max daily posts
+	for _, source := range feed.Sources {
+		maxDailyPosts[source.Url] = source.MaxDailyPosts
+	}
+	return &feedserver.Feed{
+		Name:          feed.Name,
+		Description:   feed.Description,
+		Media:         feed.Media,
+		MaxDailyPosts: maxDailyPosts,
+		Config:        feed,
+	}
+}
+
+func (this *Adapter) GetPosts(feed *feedserver.Feed, minTime int64) (posts []feedserver.Post, err error) {
+	var rssFeed = feed.Config.(*config.RssFeed)
+	var sourceUrls []string
+	var sourceIdx = make(map[string]int) // Source URL -> index within the feed's sources
+	for idx, source := range rssFeed.Sources {
+		sourceUrls = append(sourceUrls, source.Url)
+		sourceIdx[source.Url] = idx
+	}
+
+	var items []types.Item
+	if items, err = this.persistence.GetItemsForSources(minTime, sourceUrls); err != nil {
+		return
+	}
+	for _, item := range items {
+		var idx = sourceIdx[item.SourceUrl]
+		posts = append(posts, newPost(sourcedItem{Item: item, SourceName: rssFeed.Sources[idx].Name}, idx))
+	}
+	return
+}
+
+func newPost(item sourcedItem, sourceIdx int) feedserver.Post {
+	return feedserver.Post{
+		Item:         item,
+		Id:           getEntryId(item.Item),
+		DuplicateKey: item.Url, // The same article may be syndicated in several sources.
+		Source:       item.SourceUrl,
+		SourceIdx:    sourceIdx,
+		Rank:         item.TimeCreated,
+		TimeSorted:   item.TimeCreated,
+		TimeAged:     item.TimeCreated,
+		Title:        item.Title,
+		Link:         item.Url,
+		Url:          item.Url,
+		Author:       item.SourceName,
+		Byline:       fmt.Sprintf("(%s)", item.SourceName),
+		TimeCreated:  item.TimeCreated,
+	}
+}
+
+// getEntryId returns a URI that uniquely identifies the item. GUIDs are frequently (but not
+// always) URLs. Those that aren't are only unique within their source document.
+func getEntryId(item types.Item) string {
+	if u, err := url.Parse(item.Guid); err == nil && u.IsAbs() {
+		return item.Guid
+	}
+	return fmt.Sprintf("urn:sha1:%x", sha1.Sum([]byte(item.SourceUrl+"\n"+item.Guid)))
+}
+
+func (this *Adapter) GetApiPost(post feedserver.Post) drivers.IApiPost {
+	var item = post.Item.(sourcedItem)
+	return ApiItem{
+		Id:          getEntryId(item.Item),
+		Guid:        item.Guid,
+		Title:       item.Title,
+		Url:         item.Url,
+		Source:      item.SourceName,
+		SourceUrl:   item.SourceUrl,
+		TimeCreated: item.TimeCreated,
+		TimeStored:  item.TimeStored,
+		AgeInDays:   post.AgeInDays,
+		MediaLink:   post.MediaLink,
+	}
+}
@@ -0,0 +1,28 @@
+package server
+
+import (
+	"github.com/coverprice/contentscraper/drivers"
+	"github.com/coverprice/contentscraper/server/medialink"
+)
+
+// ApiItem is the JSON representation of an RSS/Atom item, as served by the /api/v1/ endpoints.
+// (See API.md for the schema).
+type ApiItem struct {
+	Id          string               `json:"id"` // See getEntryId()
+	Guid        string               `json:"guid"`
+	Title       string               `json:"title"`
+	Url         string               `json:"url"`
+	Source      string               `json:"source"`
+	SourceUrl   string               `json:"source_url"`
+	TimeCreated int64                `json:"time_created"`
+	TimeStored  int64                `json:"time_stored"`
+	AgeInDays   int64                `json:"age_in_days"`
+	MediaLink   *medialink.MediaLink `json:"media_link"`
+}
+
+// Verify that ApiItem implements the drivers.IApiPost interface
+var _ drivers.IApiPost = ApiItem{}
+
+func (this ApiItem) GetApiId() string {
+	return this.Id
+}
@@ -0,0 +1,49 @@
+package types
+
+import (
+	"fmt"
+	"github.com/coverprice/contentscraper/config"
+	"github.com/coverprice/contentscraper/drivers"
+)
+
+type FeedRegistryItem struct {
+	config.RssFeed
+	drivers.FeedHarvestState
+}
+
+type TFeedRegistry map[string]*FeedRegistryItem
+
+// The FeedRegistry is a simple map (with some functions to assist with adding/retrieving)
+// that maps a Feed name to the RSS configuration.
+var FeedRegistry = make(TFeedRegistry)
+
+func (this *TFeedRegistry) AddItem(feed *config.RssFeed) {
+	fri := FeedRegistryItem{
+		RssFeed: *feed,
+	}
+
+	(*this)[fri.RssFeed.Name] = &fri
+}
+
+func (this *TFeedRegistry) GetItemByName(feedname string) (*FeedRegistryItem, error) {
+	item, ok := (*this)[feedname]
+	if !ok {
+		return nil, fmt.Errorf("Unknown feed name %s", feedname)
+	}
+	return item, nil
+}
+
+func (this *TFeedRegistry) GetAllItems() (ret []*FeedRegistryItem) {
+	for _, val := range *this {
+		ret = append(ret, val)
+	}
+	return ret
+}
+
+// GetSourceUrls returns the URLs of the RSS/Atom documents referenced by the feed.
+func (this *FeedRegistryItem) GetSourceUrls() (sourceUrls []string) {
+	for _, source := range this.RssFeed.Sources {
+		sourceUrls = append(sourceUrls, source.Url)
+	}
+	return
+}
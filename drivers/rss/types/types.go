@@ -0,0 +1,10 @@
+package types
+
+type Item struct {
+	Guid        string // Unique ID of the item. (The RSS <guid> or Atom <id>, or failing that, the link)
+	SourceUrl   string // URL of the RSS/Atom document this item was harvested from
+	Title       string
+	Url         string // Link to the item's content
+	TimeCreated int64  // The item's publication date, or when it was first seen if it has none.
+	TimeStored  int64
+}
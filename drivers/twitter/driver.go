@@ -0,0 +1,103 @@
+package twitter
+
+// Implements the IDriver interface for the Twitter content source type
+
+import (
+	"context"
+	"database/sql"
+	"github.com/coverprice/contentscraper/config"
+	"github.com/coverprice/contentscraper/drivers"
+	"github.com/coverprice/contentscraper/drivers/feedserver"
+	harvest "github.com/coverprice/contentscraper/drivers/twitter/harvester"
+	persist "github.com/coverprice/contentscraper/drivers/twitter/persistence"
+	scrape "github.com/coverprice/contentscraper/drivers/twitter/scraper"
+	"github.com/coverprice/contentscraper/drivers/twitter/server"
+	"github.com/coverprice/contentscraper/drivers/twitter/types"
+	"net/http"
+)
+
+// Verify that TwitterDriver satisfies the drivers.IDriver & drivers.IApiDriver interfaces.
+var _ drivers.IDriver = &TwitterDriver{}
+var _ drivers.IApiDriver = &TwitterDriver{}
+
+// TwitterDriver implements drivers.IDriver. It follows the Facade pattern
+// and delegates the work of the interface to subordinate classes.
+type TwitterDriver struct {
+	harvester         *harvest.Harvester
+	httpHandler       *feedserver.HttpHandler
+	apiRequestHandler *feedserver.ApiRequestHandler
+}
+
+func NewTwitterDriver(
+	harvesterDbconn *sql.DB, // DB connection used to store harvested content
+	viewerDbconn *sql.DB, // DB connection used to retrieve harvested content
+	conf *config.Config,
+) (driver *TwitterDriver, err error) {
+	// Setup harvester
+	var scraper *scrape.Scraper
+	if scraper, err = scrape.NewScraperFromConfig(conf); err != nil {
+		return
+	}
+
+	var persistenceHarvester *persist.Persistence
+	if persistenceHarvester, err = persist.NewPersistence(harvesterDbconn); err != nil {
+		return
+	}
+
+	var harvester *harvest.Harvester
+	harvester, err = harvest.NewHarvester(
+		scraper,
+		persistenceHarvester,
+	)
+	if err != nil {
+		return
+	}
+
+	// Setup Feed viewer
+	var persistenceViewer *persist.Persistence
+	if persistenceViewer, err = persist.NewPersistence(viewerDbconn); err != nil {
+		return
+	}
+	postRetriever := feedserver.NewPostRetriever(server.NewAdapter(persistenceViewer))
+
+	// Configure Feeds to view
+	for _, feed := range conf.Twitter.Feeds {
+		types.FeedRegistry.AddItem(&feed)
+	}
+
+	return &TwitterDriver{
+		harvester:         harvester,
+		httpHandler:       feedserver.NewHttpHandler(postRetriever),
+		apiRequestHandler: feedserver.NewApiRequestHandler(postRetriever),
+	}, nil
+}
+
+func (this *TwitterDriver) GetBaseUrlPath() string {
+	return server.BaseUrlPath
+}
+
+func (this *TwitterDriver) GetFeeds() []drivers.Feed {
+	var ret = make([]drivers.Feed, 0)
+	for _, feedregistryitem := range types.FeedRegistry.GetAllItems() {
+		status, timeLastHarvested := feedregistryitem.GetHarvestState()
+		ret = append(ret, drivers.Feed{
+			Name:              feedregistryitem.TwitterFeed.Name,
+			Description:       feedregistryitem.TwitterFeed.Description,
+			Status:            status,
+			TimeLastHarvested: timeLastHarvested,
+		})
+	}
+	return ret
+}
+
+func (this *TwitterDriver) GetHttpHandler() http.Handler {
+	return this.httpHandler
+}
+
+func (this *TwitterDriver) Harvest(ctx context.Context) (err error) {
+	return this.harvester.Harvest(ctx)
+}
+
+func (this *TwitterDriver) GetApiPosts(feedName string) ([]drivers.IApiPost, error) {
+	return this.apiRequestHandler.GetApiPosts(feedName)
+}
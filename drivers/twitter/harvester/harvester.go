@@ -0,0 +1,109 @@
+package twitter
+
+import (
+	"context"
+	persist "github.com/coverprice/contentscraper/drivers/twitter/persistence"
+	scrape "github.com/coverprice/contentscraper/drivers/twitter/scraper"
+	"github.com/coverprice/contentscraper/drivers/twitter/types"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// Harvester controls the process of scraping tweets from Twitter accounts
+// and persisting them.
+// It uses a Scraper client to pull tweets from a specific account,
+// and a Persistence layer to insert/update them. (Tweets already stored
+// are updated to reflect any changes in their score)
+type Harvester struct {
+	scraper           scrape.IScraper
+	persistence       *persist.Persistence
+	MaxPagesToScrape  int     // Maximum # of pages to scrape (per account)
+	MinNewPostPercent float64 // Min new tweets in scrape result to continue.
+}
+
+// Creates a new Harvester instance
+func NewHarvester(
+	scraper scrape.IScraper,
+	persistence *persist.Persistence,
+) (*Harvester, error) {
+	return &Harvester{
+		scraper:           scraper,
+		persistence:       persistence,
+		MaxPagesToScrape:  5,
+		MinNewPostPercent: 20.0,
+	}, nil
+}
+
+// Harvest pulls tweets for every account in every registered feed. Failures to harvest
+// an account are logged and reflected in the feed's status, rather than aborting the
+// whole harvest. If ctx is cancelled, the harvest is abandoned and ctx.Err() is returned.
+func (this *Harvester) Harvest(ctx context.Context) (err error) {
+	for _, feed := range types.FeedRegistry.GetAllItems() {
+		feed.BeginHarvest()
+
+	NextAccount:
+		for _, accountName := range feed.GetAccountNames() {
+			if err := this.pullSource(ctx, accountName); err != nil {
+				if ctx.Err() != nil {
+					// The harvest was cancelled
+					feed.EndHarvest()
+					return ctx.Err()
+				}
+				log.Errorf("Error harvesting Twitter account '%s': %v", accountName, err)
+				feed.SetHarvestError()
+				continue NextAccount
+			}
+		}
+
+		feed.EndHarvest()
+	}
+
+	return nil
+}
+
+func (this *Harvester) pullSource(ctx context.Context, accountName string) (err error) {
+	log.Infof("Pulling from Twitter account '%s'", accountName)
+	var now = int64(time.Now().Unix())
+
+	var scrapeContext = scrape.NewContext(accountName)
+	numPagesScraped := 0
+	for {
+		var tweets []types.Tweet
+		tweets, err = this.scraper.GetNextResults(ctx, &scrapeContext)
+		if err != nil {
+			return
+		}
+		log.Debugf("Pulled %d tweets from account '%s'", len(tweets), accountName)
+		numPagesScraped++
+
+		numNewTweets := 0
+		for _, tweet := range tweets {
+			var result persist.StoreResult
+			tweet.TimeStored = now
+			if result, err = this.persistence.StoreTweet(&tweet); err != nil {
+				return
+			}
+			if result == persist.StoreResult(persist.STORERESULT_NEW) {
+				numNewTweets++
+			}
+		}
+
+		// Decide when to break out of the loop
+		if numPagesScraped >= this.MaxPagesToScrape {
+			// Prevents us from going too far back in time.
+			log.Debugf("Breaking out of loop due to max number of pages scraped")
+			break
+		}
+		if scrapeContext.PaginationToken == "" || len(tweets) == 0 {
+			log.Debugf("Breaking out of loop because there are no further pages")
+			break
+		}
+		if 100.0*float64(numNewTweets)/float64(len(tweets)) < this.MinNewPostPercent {
+			// If # of new tweets is < certain % of tweets scraped in this page,
+			// going back further is probably pointless.
+			log.Debugf("Breaking out of loop because there were only %d new results out of %d", numNewTweets, len(tweets))
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package twitter
+
+import (
+	"context"
+	"fmt"
+	"github.com/coverprice/contentscraper/config"
+	"github.com/coverprice/contentscraper/database"
+	"github.com/coverprice/contentscraper/drivers"
+	persist "github.com/coverprice/contentscraper/drivers/twitter/persistence"
+	scrape "github.com/coverprice/contentscraper/drivers/twitter/scraper"
+	"github.com/coverprice/contentscraper/drivers/twitter/types"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// fakeScraper returns numPages pages of tweets for each account, of pageSize tweets each.
+type fakeScraper struct {
+	numPages  int
+	pageSize  int
+	numCalls  map[string]int
+	failForId string
+}
+
+func (this *fakeScraper) GetNextResults(ctx context.Context, scrapeContext *scrape.Context) (tweets []types.Tweet, err error) {
+	if scrapeContext.AccountName == this.failForId {
+		return nil, fmt.Errorf("Fake failure")
+	}
+	this.numCalls[scrapeContext.AccountName]++
+	var page = this.numCalls[scrapeContext.AccountName]
+	for i := 0; i < this.pageSize; i++ {
+		tweets = append(tweets, types.Tweet{
+			Id:          fmt.Sprintf("%s_%d_%d", scrapeContext.AccountName, page, i),
+			AccountName: scrapeContext.AccountName,
+			TimeCreated: 1234,
+			Score:       int64(i),
+		})
+	}
+	scrapeContext.PaginationToken = ""
+	if page < this.numPages {
+		scrapeContext.PaginationToken = fmt.Sprintf("page%d", page+1)
+	}
+	return
+}
+
+func TestHarvesterRetrievesAndStoresTweets(t *testing.T) {
+	testDb, err := database.NewTestDatabase()
+	if err != nil {
+		t.Fatal("Could not init database", err)
+	}
+	defer testDb.Cleanup()
+
+	scraper := &fakeScraper{numPages: 3, pageSize: 10, numCalls: make(map[string]int), failForId: "broken"}
+	persistence, err := persist.NewPersistence(testDb.DbConn)
+	require.Nil(t, err, "Could not initialize persistence layer")
+	harvester, err := NewHarvester(scraper, persistence)
+	require.Nil(t, err, "Could not initialize Harvester")
+	harvester.MaxPagesToScrape = 2
+
+	types.FeedRegistry.AddItem(&config.TwitterFeed{
+		Name: "testfeed",
+		Filters: []config.TwitterFilter{
+			config.TwitterFilter{AccountName: "alice", FilterType: config.TWITTER_FILTERTYPE_ORIGINAL},
+			config.TwitterFilter{AccountName: "alice", FilterType: config.TWITTER_FILTERTYPE_RETWEETS},
+		},
+	})
+	types.FeedRegistry.AddItem(&config.TwitterFeed{
+		Name: "brokenfeed",
+		Filters: []config.TwitterFilter{
+			config.TwitterFilter{AccountName: "broken", FilterType: config.TWITTER_FILTERTYPE_ORIGINAL},
+		},
+	})
+	defer delete(types.FeedRegistry, "testfeed")
+	defer delete(types.FeedRegistry, "brokenfeed")
+
+	err = harvester.Harvest(context.Background())
+	require.Nil(t, err, "Harvest() failed")
+
+	// The account is only harvested once, even though it's referenced by 2 filters,
+	// and scraping stops after MaxPagesToScrape.
+	require.Equal(t, 2, scraper.numCalls["alice"])
+
+	var cnt int
+	err = testDb.DbConn.QueryRow(`SELECT COUNT(*) FROM tweet`).Scan(&cnt)
+	require.Nil(t, err, "Could not retrieve the count of tweets")
+	require.Equal(t, 20, cnt)
+
+	feed, _ := types.FeedRegistry.GetItemByName("testfeed")
+	status, timeLastHarvested := feed.GetHarvestState()
+	require.Equal(t, drivers.FEEDHARVESTSTATUS_IDLE, status)
+	require.NotEqual(t, int64(0), timeLastHarvested)
+	feed, _ = types.FeedRegistry.GetItemByName("brokenfeed")
+	status, _ = feed.GetHarvestState()
+	require.Equal(t, drivers.FEEDHARVESTSTATUS_ERROR, status)
+}
@@ -0,0 +1,39 @@
+package persistence
+
+import (
+	"github.com/coverprice/contentscraper/database"
+)
+
+// The name under which this package's migrations are recorded in the schema_version table.
+const MIGRATION_COMPONENT = "twitter"
+
+// The ordered list of changes to the Twitter driver's tables.
+var migrations = []database.Migration{
+	database.Migration{
+		Version:     1,
+		Description: "Create tweet table",
+		Sql: `
+        CREATE TABLE IF NOT EXISTS tweet
+            ( id TEXT PRIMARY KEY
+            , account_name TEXT NOT NULL
+            , time_created INTEGER NOT NULL
+            , time_stored INTEGER NOT NULL
+            , is_retweet INTEGER NOT NULL
+            , text TEXT NOT NULL
+            , url TEXT NOT NULL
+            , permalink TEXT NOT NULL
+            , score INTEGER NOT NULL
+        ) WITHOUT ROWID
+        ;
+        CREATE INDEX IF NOT EXISTS
+            tweet_account_name ON tweet(account_name, is_retweet)
+        ;
+        CREATE INDEX IF NOT EXISTS
+            tweet_time_stored ON tweet(time_stored)
+    `,
+	},
+}
+
+func init() {
+	database.RegisterMigrations(MIGRATION_COMPONENT, migrations)
+}
@@ -0,0 +1,252 @@
+package persistence
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/coverprice/contentscraper/database"
+	"github.com/coverprice/contentscraper/drivers/twitter/types"
+	log "github.com/sirupsen/logrus"
+	"strings"
+)
+
+type Persistence struct {
+	dbconn          *sql.DB
+	searchTweetById *sql.Stmt
+}
+
+func NewPersistence(dbconn *sql.DB) (persistence *Persistence, err error) {
+	persistence = &Persistence{
+		dbconn: dbconn,
+	}
+	if err = persistence.initTables(); err != nil {
+		return
+	}
+
+	persistence.searchTweetById, err = persistence.dbconn.Prepare(`
+        SELECT EXISTS(
+            SELECT 1
+            FROM tweet
+            WHERE id = $a
+            LIMIT 1
+        )`)
+	if err != nil {
+		return
+	}
+	return
+}
+
+func (this *Persistence) initTables() (err error) {
+	return database.Migrate(this.dbconn, MIGRATION_COMPONENT)
+}
+
+// Stores/Updates a Tweet and returns whether it was a store or an
+// update.
+
+type StoreResult int
+
+const (
+	STORERESULT_NEW = iota
+	STORERESULT_UPDATED
+)
+
+func (this *Persistence) StoreTweet(
+	tweet *types.Tweet,
+) (
+	result StoreResult,
+	err error,
+) {
+	var tweetExists int
+	if err = this.searchTweetById.QueryRow(tweet.Id).Scan(&tweetExists); err != nil {
+		return
+	}
+	if tweetExists == 0 {
+		if err = this.insertTweet(tweet); err != nil {
+			return
+		}
+		return STORERESULT_NEW, nil
+	}
+	// Exists, update
+	if err = this.updateTweet(tweet); err != nil {
+		return
+	}
+	return STORERESULT_UPDATED, nil
+}
+
+func (this *Persistence) insertTweet(tweet *types.Tweet) (err error) {
+	_, err = this.dbconn.Exec(`
+        INSERT INTO tweet
+            ( id
+            , account_name
+            , time_created
+            , time_stored
+            , is_retweet
+            , text
+            , url
+            , permalink
+            , score
+        ) VALUES
+            ( $a
+            , $b
+            , $c
+            , $d
+            , $e
+            , $f
+            , $g
+            , $h
+            , $i
+        )`,
+		tweet.Id,
+		tweet.AccountName,
+		tweet.TimeCreated,
+		tweet.TimeStored,
+		tweet.IsRetweet,
+		tweet.Text,
+		tweet.Url,
+		tweet.Permalink,
+		tweet.Score,
+	)
+	return
+}
+
+func (this *Persistence) updateTweet(tweet *types.Tweet) (err error) {
+	_, err = this.dbconn.Exec(`
+        UPDATE tweet SET
+              text = $a
+            , url = $b
+            , score = $c
+        WHERE id = $d
+        `,
+		tweet.Text,
+		tweet.Url,
+		tweet.Score,
+
+		tweet.Id,
+	)
+	return
+}
+
+func (this *Persistence) GetTweets(
+	where_clause string,
+	params ...interface{},
+) (tweets []types.Tweet, err error) {
+	var rows *sql.Rows
+	var sql = `
+        SELECT
+            id
+            , account_name
+            , time_created
+            , time_stored
+            , is_retweet
+            , text
+            , url
+            , permalink
+            , score
+        FROM tweet
+        ` + where_clause
+	if rows, err = this.dbconn.Query(sql, params...); err != nil {
+		log.Errorf("Error calling SQL %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tweet types.Tweet
+
+		err = rows.Scan(
+			&tweet.Id,
+			&tweet.AccountName,
+			&tweet.TimeCreated,
+			&tweet.TimeStored,
+			&tweet.IsRetweet,
+			&tweet.Text,
+			&tweet.Url,
+			&tweet.Permalink,
+			&tweet.Score,
+		)
+		if err != nil {
+			return
+		}
+		tweets = append(tweets, tweet)
+	}
+	log.Debugf("Retrieved %d tweets from the database", len(tweets))
+	return tweets, nil
+}
+
+// Gets the score of the Tweet at the given percentile (where 100% means all tweets,
+// 90% means 90% of tweets, etc.) for the given account's original tweets or retweets.
+func (this *Persistence) GetScoreAtPercentile(
+	minTime int64,
+	accountName string,
+	isRetweet bool,
+	percentile float64,
+) (score int, err error) {
+	sql := `
+        SELECT COUNT(*) AS cnt
+        FROM tweet
+        WHERE account_name = $a
+          AND is_retweet = $b
+          AND time_stored >= $c
+    `
+
+	// If getting 100% of tweets, then the lowest score is 0.
+	if percentile >= 100.0 {
+		return 0, nil
+	}
+
+	var cnt int
+	if err = this.dbconn.QueryRow(sql, accountName, isRetweet, minTime).Scan(&cnt); err != nil {
+		return
+	}
+	if cnt == 0 {
+		// No tweets
+		return 0, nil
+	}
+
+	sql = `
+        SELECT score
+        FROM tweet
+        WHERE account_name = $a
+          AND is_retweet = $b
+          AND time_stored >= $c
+        ORDER BY score DESC
+        LIMIT 1
+        OFFSET $d
+    `
+	var offsetRows = int(percentile * float64(cnt) / 100.0)
+	err = this.dbconn.QueryRow(sql, accountName, isRetweet, minTime, offsetRows).Scan(&score)
+	log.Debugf("Account %s (retweets: %v) has a %.0f percentile score of %d over %d records",
+		accountName, isRetweet, percentile, score, cnt)
+	return
+}
+
+// FilterMinScore describes the minimum score a tweet must have to be published, for
+// a given account's original tweets or retweets.
+type FilterMinScore struct {
+	AccountName string
+	IsRetweet   bool
+	MinScore    int
+}
+
+func (this *Persistence) GetTweetsForFilterScores(
+	minTime int64,
+	filterMinScores []FilterMinScore,
+) ([]types.Tweet, error) {
+	var criteria []string
+	var params = []interface{}{minTime}
+	for _, f := range filterMinScores {
+		criteria = append(criteria, fmt.Sprintf(
+			"(account_name = $p%d AND is_retweet = $p%d AND score >= $p%d)",
+			len(params), len(params)+1, len(params)+2,
+		))
+		params = append(params, f.AccountName, f.IsRetweet, f.MinScore)
+	}
+
+	whereClause := `
+        WHERE time_stored >= $a
+          AND (%s)
+        LIMIT 3000
+    `
+	whereClause = fmt.Sprintf(whereClause, strings.Join(criteria, " OR "))
+	log.Debugf("Getting tweets with minimum scores: %s", whereClause)
+	return this.GetTweets(whereClause, params...)
+}
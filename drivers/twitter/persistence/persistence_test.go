@@ -0,0 +1,137 @@
+package persistence
+
+import (
+	"fmt"
+	"github.com/coverprice/contentscraper/database"
+	"github.com/coverprice/contentscraper/drivers/twitter/types"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCanCreateAndRetrieveTweet(t *testing.T) {
+	testDb, err := database.NewTestDatabase()
+	if err != nil {
+		t.Fatal("Could not init database", err)
+	}
+	defer testDb.Cleanup()
+
+	sut, err := NewPersistence(testDb.DbConn)
+	require.Nil(t, err, "Could not create persistence layer")
+
+	var tweet = &types.Tweet{
+		Id:          "1001",
+		AccountName: "someaccount",
+		TimeCreated: 1234,
+		TimeStored:  1235,
+		IsRetweet:   false,
+		Text:        "A fake tweet",
+		Url:         "https://imgur.com/foo.jpg",
+		Permalink:   "https://twitter.com/someaccount/status/1001",
+		Score:       5678,
+	}
+
+	var result StoreResult
+	result, err = sut.StoreTweet(tweet)
+	require.Nil(t, err, "Could not store 1st tweet")
+	require.Equal(t, StoreResult(STORERESULT_NEW), result, "Unexpected StoreResult")
+
+	// Same tweet again, should be updated
+	tweet.Score = 9999
+	result, err = sut.StoreTweet(tweet)
+	require.Nil(t, err, "Could not update 1st tweet")
+	require.Equal(t, StoreResult(STORERESULT_UPDATED), result, "Unexpected StoreResult")
+
+	// Different ID, should be new
+	tweet.Id = "1002"
+	tweet.TimeCreated = 5555
+	result, err = sut.StoreTweet(tweet)
+	require.Nil(t, err, "Could not create 2nd tweet")
+	require.Equal(t, StoreResult(STORERESULT_NEW), result, "Unexpected StoreResult")
+
+	var tweets []types.Tweet
+	if tweets, err = sut.GetTweets(
+		"WHERE account_name=$a ORDER BY time_created", "someaccount",
+	); err != nil {
+		t.Error("Could not retrieve tweets:", err)
+	}
+
+	require.Equal(t, 2, len(tweets), "Expected length of results")
+	require.Equal(t, "1001", tweets[0].Id, "Incorrect 1st tweet ID")
+	require.Equal(t, int64(9999), tweets[0].Score, "Score was not updated")
+	require.Equal(t, "1002", tweets[1].Id, "Incorrect 2nd tweet ID")
+}
+
+func createFakeTweets(t *testing.T, sut *Persistence, accountName string, isRetweet bool) {
+	for i := 1; i <= 100; i++ {
+		id := fmt.Sprintf("%s_%v_%d", accountName, isRetweet, i)
+		tweet := types.Tweet{
+			Id:          id,
+			AccountName: accountName,
+			TimeCreated: 1234,
+			TimeStored:  1235,
+			IsRetweet:   isRetweet,
+			Text:        "A fake tweet",
+			Permalink:   "https://twitter.com/" + accountName + "/status/" + id,
+			Score:       int64(i * 2),
+		}
+
+		result, err := sut.StoreTweet(&tweet)
+		require.Nil(t, err, "Could not store tweet")
+		require.Equal(t, StoreResult(STORERESULT_NEW), result, "Unexpected StoreResult")
+	}
+}
+
+func TestGetScoreAtPercentile(t *testing.T) {
+	testDb, err := database.NewTestDatabase()
+	if err != nil {
+		t.Fatal("Could not init database", err)
+	}
+	defer testDb.Cleanup()
+
+	sut, err := NewPersistence(testDb.DbConn)
+	require.Nil(t, err, "Could not create persistence layer")
+
+	createFakeTweets(t, sut, "someaccount", false)
+
+	// 100 tweets scored 2,4,6,...200, so the 70th percentile will be 60,
+	// (Remember scores are ranked in descending order)
+	score, err := sut.GetScoreAtPercentile(0, "someaccount", false, 70.0)
+	require.Nil(t, err, "Could not retrieve percentile score")
+	require.Equal(t, 60, score)
+
+	// No retweets have been stored
+	score, err = sut.GetScoreAtPercentile(0, "someaccount", true, 70.0)
+	require.Nil(t, err, "Could not retrieve percentile score")
+	require.Equal(t, 0, score)
+}
+
+func TestGetTweetsForFilterScores(t *testing.T) {
+	testDb, err := database.NewTestDatabase()
+	if err != nil {
+		t.Fatal("Could not init database", err)
+	}
+	defer testDb.Cleanup()
+
+	sut, err := NewPersistence(testDb.DbConn)
+	require.Nil(t, err, "Could not create persistence layer")
+
+	createFakeTweets(t, sut, "alice", false)
+	createFakeTweets(t, sut, "alice", true)
+	createFakeTweets(t, sut, "bob", false)
+
+	tweets, err := sut.GetTweetsForFilterScores(0, []FilterMinScore{
+		FilterMinScore{AccountName: "alice", IsRetweet: false, MinScore: 198},
+		FilterMinScore{AccountName: "alice", IsRetweet: true, MinScore: 200},
+	})
+	require.Nil(t, err, "Could not retrieve tweets")
+	require.Equal(t, 3, len(tweets), "Unexpected number of tweets")
+
+	for _, tweet := range tweets {
+		require.Equal(t, "alice", tweet.AccountName)
+		if tweet.IsRetweet {
+			require.Equal(t, int64(200), tweet.Score)
+		} else {
+			require.True(t, tweet.Score >= 198, "Expected score %d to be >= 198", tweet.Score)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package twitter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/coverprice/contentscraper/config"
+	"github.com/coverprice/contentscraper/drivers/twitter/types"
+	// log "github.com/sirupsen/logrus"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	useragent = "Fedora:github.com/coverprice/contentscraper:0.1.0"
+
+	// DefaultApiBaseUrl is the root of Twitter's v2 API.
+	DefaultApiBaseUrl = "https://api.twitter.com"
+)
+
+// IScraper is the interface the Harvester uses to retrieve tweets. It exists
+// so that the harvester can be driven by something other than the live API.
+type IScraper interface {
+	// The request is abandoned if ctx is cancelled.
+	GetNextResults(ctx context.Context, scrapeContext *Context) ([]types.Tweet, error)
+}
+
+// Verify that Scraper implements the IScraper interface
+var _ IScraper = &Scraper{}
+
+// Scraper is a minimal client for Twitter's v2 API. It uses app-only
+// authentication (a bearer token), so it can only read public timelines.
+type Scraper struct {
+	client      *http.Client
+	baseUrl     string
+	bearerToken string
+	userIds     map[string]string // account name -> Twitter's user ID
+}
+
+// Parameters for a scrape request
+type Context struct {
+	AccountName       string // Name of the account to scrape, without the leading '@'
+	PaginationToken   string // used for pagination
+	NumPostsPerScrape int
+}
+
+func NewContext(accountName string) Context {
+	return Context{
+		AccountName:       accountName,
+		PaginationToken:   "",
+		NumPostsPerScrape: 100,
+	}
+}
+
+// NewScraper creates a Scraper that talks to the API rooted at baseUrl, e.g. DefaultApiBaseUrl.
+func NewScraper(baseUrl, bearerToken string) (scraper *Scraper, err error) {
+	if bearerToken == "" {
+		return nil, fmt.Errorf("Could not create Twitter scraper: empty bearer token")
+	}
+	return &Scraper{
+		client:      &http.Client{Timeout: 30 * time.Second},
+		baseUrl:     strings.TrimRight(baseUrl, "/"),
+		bearerToken: bearerToken,
+		userIds:     make(map[string]string),
+	}, nil
+}
+
+func NewScraperFromConfig(conf *config.Config) (scraper *Scraper, err error) {
+	return NewScraper(DefaultApiBaseUrl, conf.Twitter.Secrets.ClientSecret)
+}
+
+// Structures that mirror the parts of the API's JSON responses that we care about.
+type apiError struct {
+	Title  string `json:"title"`
+	Detail string `json:"detail"`
+}
+
+type apiUserResponse struct {
+	Data struct {
+		Id       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"data"`
+	Errors []apiError `json:"errors"`
+}
+
+type apiTweet struct {
+	Id            string `json:"id"`
+	Text          string `json:"text"`
+	CreatedAt     string `json:"created_at"`
+	PublicMetrics struct {
+		RetweetCount int64 `json:"retweet_count"`
+		LikeCount    int64 `json:"like_count"`
+	} `json:"public_metrics"`
+	ReferencedTweets []struct {
+		Type string `json:"type"`
+		Id   string `json:"id"`
+	} `json:"referenced_tweets"`
+	Entities struct {
+		Urls []struct {
+			ExpandedUrl string `json:"expanded_url"`
+		} `json:"urls"`
+	} `json:"entities"`
+}
+
+type apiTimelineResponse struct {
+	Data     []apiTweet `json:"data"`
+	Includes struct {
+		Tweets []apiTweet `json:"tweets"`
+	} `json:"includes"`
+	Meta struct {
+		NextToken   string `json:"next_token"`
+		ResultCount int    `json:"result_count"`
+	} `json:"meta"`
+	Errors []apiError `json:"errors"`
+}
+
+// GetNextResults scrapes the current "page" of an account's timeline and returns
+// a set of Tweets. The Context's PaginationToken is updated so that the next call
+// returns the following (older) page. When there are no more pages, the token is
+// set to "" and an empty result is returned.
+func (this *Scraper) GetNextResults(ctx context.Context, scrapeContext *Context) (tweets []types.Tweet, err error) {
+	var userId string
+	if userId, err = this.getUserId(ctx, scrapeContext.AccountName); err != nil {
+		return
+	}
+
+	params := url.Values{}
+	params.Set("max_results", fmt.Sprintf("%d", scrapeContext.NumPostsPerScrape))
+	params.Set("tweet.fields", "created_at,public_metrics,referenced_tweets,entities")
+	params.Set("expansions", "referenced_tweets.id")
+	if scrapeContext.PaginationToken != "" {
+		params.Set("pagination_token", scrapeContext.PaginationToken)
+	}
+
+	var response apiTimelineResponse
+	if err = this.get(ctx, fmt.Sprintf("/2/users/%s/tweets", url.PathEscape(userId)), params, &response); err != nil {
+		return nil, fmt.Errorf("Failed to fetch timeline for account '%s': %v", scrapeContext.AccountName, err)
+	}
+
+	// Retweets carry the metrics of the retweet itself, which are not useful. The
+	// original tweet is returned in the "includes" section, so use that instead.
+	var includedTweets = make(map[string]apiTweet)
+	for _, t := range response.Includes.Tweets {
+		includedTweets[t.Id] = t
+	}
+
+	for _, apitweet := range response.Data {
+		var tweet types.Tweet
+		if tweet, err = newTweetFromApiTweet(scrapeContext.AccountName, apitweet, includedTweets); err != nil {
+			return nil, err
+		}
+		tweets = append(tweets, tweet)
+	}
+	scrapeContext.PaginationToken = response.Meta.NextToken
+	return tweets, nil
+}
+
+// getUserId resolves an account name to Twitter's user ID (which the timeline endpoint
+// requires). Results are cached for the lifetime of the Scraper.
+func (this *Scraper) getUserId(ctx context.Context, accountName string) (userId string, err error) {
+	if userId, ok := this.userIds[accountName]; ok {
+		return userId, nil
+	}
+
+	var response apiUserResponse
+	if err = this.get(ctx, fmt.Sprintf("/2/users/by/username/%s", url.PathEscape(accountName)), nil, &response); err != nil {
+		return "", fmt.Errorf("Failed to look up account '%s': %v", accountName, err)
+	}
+	if response.Data.Id == "" {
+		return "", fmt.Errorf("Failed to look up account '%s': %s", accountName, describeApiErrors(response.Errors))
+	}
+	this.userIds[accountName] = response.Data.Id
+	return response.Data.Id, nil
+}
+
+// get performs an authenticated GET request against the API and decodes the JSON response.
+func (this *Scraper) get(ctx context.Context, path string, params url.Values, response interface{}) (err error) {
+	var u = this.baseUrl + path
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+this.bearerToken)
+	req.Header.Set("User-Agent", useragent)
+
+	resp, err := this.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Unexpected HTTP status: %s", resp.Status)
+	}
+	if err = json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return fmt.Errorf("Could not decode response: %v", err)
+	}
+	return nil
+}
+
+func describeApiErrors(errors []apiError) string {
+	if len(errors) == 0 {
+		return "no data returned"
+	}
+	var msgs []string
+	for _, e := range errors {
+		msgs = append(msgs, fmt.Sprintf("%s (%s)", e.Title, e.Detail))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Create a new Tweet object from the API's format
+func newTweetFromApiTweet(
+	accountName string,
+	at apiTweet,
+	includedTweets map[string]apiTweet,
+) (t types.Tweet, err error) {
+	var timeCreated time.Time
+	if timeCreated, err = time.Parse(time.RFC3339, at.CreatedAt); err != nil {
+		return t, fmt.Errorf("Could not parse creation time of tweet '%s': %v", at.Id, err)
+	}
+
+	t.Id = at.Id
+	t.AccountName = strings.ToLower(accountName)
+	t.TimeCreated = timeCreated.Unix()
+	t.TimeStored = timeCreated.Unix()
+	t.Text = at.Text
+	t.Permalink = fmt.Sprintf("https://twitter.com/%s/status/%s", t.AccountName, at.Id)
+	t.Score = at.PublicMetrics.LikeCount + at.PublicMetrics.RetweetCount
+
+	var source = at
+	for _, ref := range at.ReferencedTweets {
+		if ref.Type == "retweeted" {
+			t.IsRetweet = true
+			if original, ok := includedTweets[ref.Id]; ok {
+				source = original
+				t.Score = original.PublicMetrics.LikeCount + original.PublicMetrics.RetweetCount
+			}
+		}
+	}
+	if len(source.Entities.Urls) > 0 {
+		t.Url = source.Entities.Urls[0].ExpandedUrl
+	}
+	return
+}
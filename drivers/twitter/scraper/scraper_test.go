@@ -0,0 +1,114 @@
+package twitter
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newFakeApiServer returns a local server that mimics the subset of Twitter's v2 API
+// used by the Scraper. The account "someaccount" has 2 pages of tweets.
+func newFakeApiServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/2/users/by/username/someaccount", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sometoken" {
+			http.Error(w, "Unauthorized", 401)
+			return
+		}
+		fmt.Fprint(w, `{"data": {"id": "1234", "username": "SomeAccount"}}`)
+	})
+	mux.HandleFunc("/2/users/by/username/nobody", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"errors": [{"title": "Not Found Error", "detail": "Could not find user"}]}`)
+	})
+	mux.HandleFunc("/2/users/1234/tweets", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("pagination_token") {
+		case "":
+			fmt.Fprint(w, `{
+                "data": [
+                    {"id": "101", "text": "An original tweet https://t.co/xyz", "created_at": "2017-10-17T02:00:00.000Z",
+                     "public_metrics": {"retweet_count": 3, "like_count": 10},
+                     "entities": {"urls": [{"expanded_url": "https://example.com/foo.jpg"}]}},
+                    {"id": "102", "text": "RT @other: A retweet", "created_at": "2017-10-17T01:00:00.000Z",
+                     "public_metrics": {"retweet_count": 40, "like_count": 0},
+                     "referenced_tweets": [{"type": "retweeted", "id": "999"}]}
+                ],
+                "includes": {"tweets": [
+                    {"id": "999", "text": "A retweet", "created_at": "2017-10-16T01:00:00.000Z",
+                     "public_metrics": {"retweet_count": 40, "like_count": 60}}
+                ]},
+                "meta": {"result_count": 2, "next_token": "page2"}
+            }`)
+		case "page2":
+			fmt.Fprint(w, `{
+                "data": [
+                    {"id": "100", "text": "An older tweet", "created_at": "2017-10-16T02:00:00.000Z",
+                     "public_metrics": {"retweet_count": 0, "like_count": 1}}
+                ],
+                "meta": {"result_count": 1}
+            }`)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestCanScrapeWithPagination(t *testing.T) {
+	server := newFakeApiServer(t)
+	defer server.Close()
+
+	scraper, err := NewScraper(server.URL, "sometoken")
+	require.Nil(t, err, "Could not initialize Scraper")
+
+	scrapeContext := NewContext("someaccount")
+	tweets, err := scraper.GetNextResults(context.Background(), &scrapeContext)
+	require.Nil(t, err, "Non nil error from scraper")
+	require.Equal(t, 2, len(tweets), "Unexpected number of tweets for 1st request")
+	require.Equal(t, "page2", scrapeContext.PaginationToken)
+
+	require.Equal(t, "101", tweets[0].Id)
+	require.Equal(t, "someaccount", tweets[0].AccountName)
+	require.False(t, tweets[0].IsRetweet)
+	require.Equal(t, int64(13), tweets[0].Score)
+	require.Equal(t, int64(1508205600), tweets[0].TimeCreated)
+	require.Equal(t, "https://example.com/foo.jpg", tweets[0].Url)
+	require.Equal(t, "https://twitter.com/someaccount/status/101", tweets[0].Permalink)
+
+	// Retweets are scored according to the original tweet.
+	require.Equal(t, "102", tweets[1].Id)
+	require.True(t, tweets[1].IsRetweet)
+	require.Equal(t, int64(100), tweets[1].Score)
+
+	tweets, err = scraper.GetNextResults(context.Background(), &scrapeContext)
+	require.Nil(t, err, "Non nil error from scraper")
+	require.Equal(t, 1, len(tweets), "Unexpected number of tweets for 2nd request")
+	require.Equal(t, "100", tweets[0].Id)
+	require.Equal(t, "", scrapeContext.PaginationToken)
+}
+
+func TestUnknownAccountIsAnError(t *testing.T) {
+	server := newFakeApiServer(t)
+	defer server.Close()
+
+	scraper, err := NewScraper(server.URL, "sometoken")
+	require.Nil(t, err, "Could not initialize Scraper")
+
+	scrapeContext := NewContext("nobody")
+	_, err = scraper.GetNextResults(context.Background(), &scrapeContext)
+	require.NotNil(t, err, "Expected an error for an unknown account")
+}
+
+func TestBadCredentialsIsAnError(t *testing.T) {
+	server := newFakeApiServer(t)
+	defer server.Close()
+
+	scraper, err := NewScraper(server.URL, "wrongtoken")
+	require.Nil(t, err, "Could not initialize Scraper")
+
+	scrapeContext := NewContext("someaccount")
+	_, err = scraper.GetNextResults(context.Background(), &scrapeContext)
+	require.NotNil(t, err, "Expected an error for bad credentials")
+}
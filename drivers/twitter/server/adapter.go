@@ -0,0 +1,140 @@
+package server
+
+import (
+	"github.com/coverprice/contentscraper/config"
+	"github.com/coverprice/contentscraper/drivers"
+	"github.com/coverprice/contentscraper/drivers/feedserver"
+	persist "github.com/coverprice/contentscraper/drivers/twitter/persistence"
+	"github.com/coverprice/contentscraper/drivers/twitter/types"
+)
+
+// Verify that Adapter implements the feedserver.IAdapter interface
+var _ feedserver.IAdapter = &Adapter{}
+
+// Adapter serves the Twitter feeds through the feedserver. Each of a feed's filters is a
+// source, whose tweets are ranked by score.
+type Adapter struct {
+	persistence *persist.Persistence
+}
+
+func NewAdapter(persistence *persist.Persistence) *Adapter {
+	return &Adapter{
+		persistence: persistence,
+	}
+}
+
+func (this *Adapter) GetName() string {
+	return "Twitter"
+}
+
+func (this *Adapter) GetBaseUrlPath() string {
+	return BaseUrlPath
+}
+
+func (this *Adapter) GetFeed(feedName string) (*feedserver.Feed, error) {
+	item, err := types.FeedRegistry.GetItemByName(feedName)
+	if err != nil {
+		return nil, err
+	}
+	return newFeed(&item.TwitterFeed), nil
+}
+
+func newFeed(feed *config.TwitterFeed) *feedserver.Feed {
+	var maxDailyPosts = make(map[string]int) // filter key -> Max daily posts
+	for _, filter := range feed.Filters {
+		maxDailyPosts[filterKey(filter.AccountName, filter.FilterType)] = filter.MaxDailyPosts
+	}
+	return &feedserver.Feed{
+		Name:          feed.Name,
+		Description:   feed.Description,
+		MaxDailyPosts: maxDailyPosts,
+		Config:        feed,
+	}
+}
+
+// filterKey identifies the TwitterFilter that applies to a tweet.
+func filterKey(accountName, filterType string) string {
+	return accountName + "/" + filterType
+}
+
+// GetPosts retrieves tweets for the feed, applying per-filter percentile filters.
+func (this *Adapter) GetPosts(feed *feedserver.Feed, minTime int64) (posts []feedserver.Post, err error) {
+	var twitterFeed = feed.Config.(*config.TwitterFeed)
+	var filterMinScores []persist.FilterMinScore
+	for _, filter := range twitterFeed.Filters {
+		if filter.Percentile <= 0.0 {
+			continue
+		}
+		var isRetweet = (filter.FilterType == config.TWITTER_FILTERTYPE_RETWEETS)
+		var minScore int
+		minScore, err = this.persistence.GetScoreAtPercentile(
+			minTime,
+			filter.AccountName,
+			isRetweet,
+			filter.Percentile,
+		)
+		if err != nil {
+			return
+		}
+		filterMinScores = append(filterMinScores, persist.FilterMinScore{
+			AccountName: filter.AccountName,
+			IsRetweet:   isRetweet,
+			MinScore:    minScore,
+		})
+	}
+	if len(filterMinScores) == 0 {
+		return
+	}
+
+	var tweets []types.Tweet
+	if tweets, err = this.persistence.GetTweetsForFilterScores(minTime, filterMinScores); err != nil {
+		return
+	}
+	for _, tweet := range tweets {
+		posts = append(posts, newPost(tweet))
+	}
+	return
+}
+
+func newPost(tweet types.Tweet) feedserver.Post {
+	var filterType = config.TWITTER_FILTERTYPE_ORIGINAL
+	var byline = "@" + tweet.AccountName
+	if tweet.IsRetweet {
+		filterType = config.TWITTER_FILTERTYPE_RETWEETS
+		byline += " (retweet)"
+	}
+	return feedserver.Post{
+		Item:        tweet,
+		Id:          tweet.Permalink,
+		Source:      filterKey(tweet.AccountName, filterType),
+		Rank:        tweet.Score,
+		TimeSorted:  tweet.TimeCreated,
+		TimeAged:    tweet.TimeStored,
+		Title:       tweet.Text,
+		Link:        tweet.Permalink,
+		Url:         tweet.Url,
+		Summary:     tweet.Text,
+		Author:      "@" + tweet.AccountName,
+		Byline:      byline,
+		HasScore:    true,
+		Score:       tweet.Score,
+		TimeCreated: tweet.TimeCreated,
+	}
+}
+
+func (this *Adapter) GetApiPost(post feedserver.Post) drivers.IApiPost {
+	var tweet = post.Item.(types.Tweet)
+	return ApiTweet{
+		Id:          tweet.Id,
+		Account:     tweet.AccountName,
+		Text:        tweet.Text,
+		Url:         tweet.Url,
+		Permalink:   tweet.Permalink,
+		IsRetweet:   tweet.IsRetweet,
+		Score:       tweet.Score,
+		TimeCreated: tweet.TimeCreated,
+		TimeStored:  tweet.TimeStored,
+		AgeInDays:   post.AgeInDays,
+		MediaLink:   post.MediaLink,
+	}
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"fmt"
+	"github.com/coverprice/contentscraper/config"
+	"github.com/coverprice/contentscraper/database"
+	persist "github.com/coverprice/contentscraper/drivers/twitter/persistence"
+	"github.com/coverprice/contentscraper/drivers/twitter/types"
+	"github.com/stretchr/testify/require"
+	"sort"
+	"testing"
+)
+
+func TestGetPostsAppliesPercentilePerFilter(t *testing.T) {
+	testDb, err := database.NewTestDatabase()
+	if err != nil {
+		t.Fatal("Could not init database", err)
+	}
+	defer testDb.Cleanup()
+
+	persistence, err := persist.NewPersistence(testDb.DbConn)
+	require.Nil(t, err, "Could not create persistence layer")
+
+	now := int64(1508230800)
+	for i := 1; i <= 10; i++ {
+		for _, isRetweet := range []bool{false, true} {
+			_, err = persistence.StoreTweet(&types.Tweet{
+				Id:          fmt.Sprintf("%v_%d", isRetweet, i),
+				AccountName: "alice",
+				TimeCreated: now - int64(i),
+				TimeStored:  now - int64(i),
+				IsRetweet:   isRetweet,
+				Score:       int64(i),
+			})
+			require.Nil(t, err, "Could not store tweet")
+		}
+	}
+
+	feed := newFeed(&config.TwitterFeed{
+		Name: "testfeed",
+		Filters: []config.TwitterFilter{
+			config.TwitterFilter{AccountName: "alice", FilterType: config.TWITTER_FILTERTYPE_ORIGINAL, Percentile: 30.0, MaxDailyPosts: 2},
+			config.TwitterFilter{AccountName: "alice", FilterType: config.TWITTER_FILTERTYPE_RETWEETS, Percentile: 10.0, MaxDailyPosts: 1},
+		},
+	})
+	require.Equal(t, map[string]int{"alice/original": 2, "alice/retweets": 1}, feed.MaxDailyPosts)
+
+	posts, err := NewAdapter(persistence).GetPosts(feed, now-7*24*60*60)
+	require.Nil(t, err, "Could not get posts")
+
+	var ids []string
+	for _, post := range posts {
+		ids = append(ids, fmt.Sprintf("%s:%s", post.Source, post.Item.(types.Tweet).Id))
+	}
+	sort.Strings(ids)
+	// Original tweets scoring >= the 30% cutoff (7), and retweets scoring >= the 10% cutoff (9).
+	require.Equal(t, []string{
+		"alice/original:false_10",
+		"alice/original:false_7",
+		"alice/original:false_8",
+		"alice/original:false_9",
+		"alice/retweets:true_10",
+		"alice/retweets:true_9",
+	}, ids)
+}
@@ -0,0 +1,29 @@
+package server
+
+import (
+	"github.com/coverprice/contentscraper/drivers"
+	"github.com/coverprice/contentscraper/server/medialink"
+)
+
+// ApiTweet is the JSON representation of a tweet, as served by the /api/v1/ endpoints.
+// (See API.md for the schema).
+type ApiTweet struct {
+	Id          string               `json:"id"`
+	Account     string               `json:"account"`
+	Text        string               `json:"text"`
+	Url         string               `json:"url"`
+	Permalink   string               `json:"permalink"`
+	IsRetweet   bool                 `json:"is_retweet"`
+	Score       int64                `json:"score"`
+	TimeCreated int64                `json:"time_created"`
+	TimeStored  int64                `json:"time_stored"`
+	AgeInDays   int64                `json:"age_in_days"`
+	MediaLink   *medialink.MediaLink `json:"media_link"`
+}
+
+// Verify that ApiTweet implements the drivers.IApiPost interface
+var _ drivers.IApiPost = ApiTweet{}
+
+func (this ApiTweet) GetApiId() string {
+	return this.Id
+}
@@ -0,0 +1,53 @@
+package types
+
+import (
+	"fmt"
+	"github.com/coverprice/contentscraper/config"
+	"github.com/coverprice/contentscraper/drivers"
+)
+
+type FeedRegistryItem struct {
+	config.TwitterFeed
+	drivers.FeedHarvestState
+}
+
+type TFeedRegistry map[string]*FeedRegistryItem
+
+// The FeedRegistry is a simple map (with some functions to assist with adding/retrieving)
+// that maps a Feed name to the Twitter configuration.
+var FeedRegistry = make(TFeedRegistry)
+
+func (this *TFeedRegistry) AddItem(feed *config.TwitterFeed) {
+	fri := FeedRegistryItem{
+		TwitterFeed: *feed,
+	}
+
+	(*this)[fri.TwitterFeed.Name] = &fri
+}
+
+func (this *TFeedRegistry) GetItemByName(feedname string) (*FeedRegistryItem, error) {
+	item, ok := (*this)[feedname]
+	if !ok {
+		return nil, fmt.Errorf("Unknown feed name %s", feedname)
+	}
+	return item, nil
+}
+
+func (this *TFeedRegistry) GetAllItems() (ret []*FeedRegistryItem) {
+	for _, val := range *this {
+		ret = append(ret, val)
+	}
+	return ret
+}
+
+// GetAccountNames returns the (unique) account names referenced by the feed's filters.
+func (this *FeedRegistryItem) GetAccountNames() (accountNames []string) {
+	var seen = make(map[string]bool)
+	for _, filter := range this.TwitterFeed.Filters {
+		if !seen[filter.AccountName] {
+			seen[filter.AccountName] = true
+			accountNames = append(accountNames, filter.AccountName)
+		}
+	}
+	return
+}
@@ -0,0 +1,13 @@
+package types
+
+type Tweet struct {
+	Id          string
+	AccountName string // The (lowercased) account that this tweet was harvested from
+	TimeCreated int64
+	TimeStored  int64
+	IsRetweet   bool
+	Text        string
+	Url         string // The first link embedded in the tweet (if any)
+	Permalink   string
+	Score       int64 // # of likes + retweets. (For retweets, this is the score of the original tweet)
+}
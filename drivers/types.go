@@ -1,7 +1,13 @@
 package drivers
 
 import (
+	"context"
+	"fmt"
+	"github.com/coverprice/contentscraper/config"
+	"github.com/coverprice/contentscraper/server/medialink"
 	"net/http"
+	"sync"
+	"time"
 )
 
 // Feed is a data object returned by a Driver to describe a stream of posts,
@@ -25,12 +31,65 @@ const (
 	FEEDHARVESTSTATUS_ERROR      FeedHarvestStatus = 2
 )
 
+func (this FeedHarvestStatus) String() string {
+	switch this {
+	case FEEDHARVESTSTATUS_IDLE:
+		return "idle"
+	case FEEDHARVESTSTATUS_HARVESTING:
+		return "harvesting"
+	case FEEDHARVESTSTATUS_ERROR:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// FeedHarvestState records a Feed's harvest status. Drivers embed it in their FeedRegistry
+// items. It's updated by the harvester while the web server reads it, so it's protected by a lock.
+type FeedHarvestState struct {
+	lock              sync.RWMutex
+	status            FeedHarvestStatus
+	timeLastHarvested int64
+}
+
+// BeginHarvest marks the Feed as being harvested, as of now.
+func (this *FeedHarvestState) BeginHarvest() {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.status = FEEDHARVESTSTATUS_HARVESTING
+	this.timeLastHarvested = int64(time.Now().Unix())
+}
+
+// SetHarvestError marks the Feed as having failed (at least partially) to harvest.
+func (this *FeedHarvestState) SetHarvestError() {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.status = FEEDHARVESTSTATUS_ERROR
+}
+
+// EndHarvest marks the Feed as idle, unless an error was recorded during the harvest.
+func (this *FeedHarvestState) EndHarvest() {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if this.status != FEEDHARVESTSTATUS_ERROR {
+		this.status = FEEDHARVESTSTATUS_IDLE
+	}
+}
+
+// GetHarvestState returns the Feed's status, and the time it was last harvested.
+func (this *FeedHarvestState) GetHarvestState() (status FeedHarvestStatus, timeLastHarvested int64) {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	return this.status, this.timeLastHarvested
+}
+
 // --------------------------------------
 
 // The interface that all content source drivers must implement.
 type IDriver interface {
 	// Scrapes and persists posts from the website. (Run periodically by the mainloop)
-	Harvest() error
+	// When ctx is cancelled, in-flight scrapes are abandoned and ctx.Err() is returned.
+	Harvest(ctx context.Context) error
 
 	// Return the path that the driver's publishing handler will handle, e.g. "/reddit/"
 	GetBaseUrlPath() string
@@ -39,3 +98,121 @@ type IDriver interface {
 	// A method used to render a page for a specific Feed. The handler will be
 	GetHttpHandler() http.Handler
 }
+
+// --------------------------------------
+
+// IApiPost is a post as served by the JSON API. It's encoded as-is, so the json tags
+// of the concrete type define the schema.
+type IApiPost interface {
+	// Return the ID used to retrieve this post individually. Unique within a Feed.
+	GetApiId() string
+}
+
+// IApiDriver is an optional interface for drivers whose posts are available via the JSON API.
+type IApiDriver interface {
+	// Return the named Feed's posts, filtered and in display order (i.e. as the UI shows them).
+	GetApiPosts(feedName string) ([]IApiPost, error)
+}
+
+// ISeenDriver is an optional interface for drivers that track which posts each viewer has read.
+// (See htmlutil.GetViewerId)
+type ISeenDriver interface {
+	// Return the # of the named Feed's posts that the viewer hasn't read.
+	GetUnreadCount(feedName string, viewerId string) (int, error)
+}
+
+// StarredPost is a post that a viewer starred, to keep it after it's gone from its feed. It's a copy
+// of the post as it was when it was starred.
+type StarredPost struct {
+	FeedName    string `json:"feed"`
+	Title       string `json:"title"`
+	Url         string `json:"url"`       // The link the post points at. May be empty.
+	Permalink   string `json:"permalink"` // The post's own page, e.g. its Reddit comments page
+	Source      string `json:"source"`    // Where the post came from, e.g. its subreddit
+	TimeCreated int64  `json:"time_created"`
+	TimeStarred int64  `json:"time_starred"`
+}
+
+// IStarDriver is an optional interface for drivers whose posts can be starred.
+type IStarDriver interface {
+	// Return the posts the viewer has starred, most recently starred first.
+	GetStarredPosts(viewerId string) ([]StarredPost, error)
+}
+
+// PruneReport describes the posts deleted by pruning, or that would have been in a dry run.
+type PruneReport struct {
+	NumPosts      int
+	PostsBySource map[string]int // Source name (e.g. the subreddit) -> # of posts
+}
+
+// IPruneDriver is an optional interface for drivers that delete old posts from the database.
+type IPruneDriver interface {
+	// Delete the posts that are older than their feeds' retention period. If dryRun is true,
+	// nothing is deleted, but the report describes what would have been.
+	Prune(dryRun bool) (PruneReport, error)
+}
+
+// IReloadDriver is an optional interface for drivers that can apply a reloaded config without
+// restarting.
+type IReloadDriver interface {
+	// Apply the (already validated) config's feeds. New feeds are harvested by the next harvest.
+	Reload(conf *config.Config)
+}
+
+// SearchQuery is a full-text search of a driver's stored posts.
+type SearchQuery struct {
+	Query    string // The words to search for, in the driver's query syntax
+	FeedName string // If not empty, only the named Feed's posts are searched
+	MinTime  int64  // Only posts created at or after MinTime are returned
+	MaxTime  int64  // If not 0, only posts created before MaxTime are returned
+	Limit    int    // The maximum # of posts to return
+}
+
+// SearchResult is a post that matched a SearchQuery.
+type SearchResult struct {
+	FeedName    string // The Feed the post belongs to. May be empty if it's in no Feed.
+	Title       string
+	Url         string // The link the post points at. May be empty.
+	Permalink   string // The post's own page, e.g. its Reddit comments page
+	Source      string // Where the post came from, e.g. its subreddit
+	Score       int64
+	TimeCreated int64
+	MediaLink   *medialink.MediaLink // Set if the Feed shows images and the post links to one
+}
+
+// SearchQueryError is returned by ISearchDriver.SearchPosts if the query's syntax is invalid.
+type SearchQueryError struct {
+	Query string
+}
+
+func (this *SearchQueryError) Error() string {
+	return fmt.Sprintf("Invalid search query: '%s'", this.Query)
+}
+
+// ISearchDriver is an optional interface for drivers whose stored posts can be searched.
+type ISearchDriver interface {
+	// Return the posts matching the query, most recently created first.
+	SearchPosts(query SearchQuery) ([]SearchResult, error)
+}
+
+// --------------------------------------
+
+// HarvestRun records the outcome of harvesting a single source (e.g. a subreddit) during one
+// harvest run.
+type HarvestRun struct {
+	Source         string // The name of the source, e.g. the subreddit name
+	TimeRunStarted int64  // Identifies the harvest run. Shared by all sources harvested in the run.
+	TimeStarted    int64
+	TimeEnded      int64
+	PagesScraped   int
+	PostsNew       int
+	PostsUpdated   int
+	PostsSkipped   int
+	Error          string // Empty if the source was harvested successfully
+}
+
+// IStatsDriver is an optional interface for drivers that keep a history of their harvest runs.
+type IStatsDriver interface {
+	// Return the runs for the named Feed's sources that started at or after minTime, most recent first.
+	GetHarvestRuns(feedName string, minTime int64) ([]HarvestRun, error)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"flag"
 	"fmt"
@@ -11,13 +12,18 @@ import (
 	"github.com/coverprice/contentscraper/config"
 	"github.com/coverprice/contentscraper/database"
 	"github.com/coverprice/contentscraper/drivers"
+	"github.com/coverprice/contentscraper/drivers/hackernews"
 	"github.com/coverprice/contentscraper/drivers/reddit"
+	"github.com/coverprice/contentscraper/drivers/rss"
+	"github.com/coverprice/contentscraper/drivers/twitter"
 	"github.com/coverprice/contentscraper/server"
 	"github.com/coverprice/contentscraper/toolbox"
 	//"github.com/davecgh/go-spew/spew"
 
 	"path"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -25,27 +31,34 @@ import (
 )
 
 var (
-	waitgroup        sync.WaitGroup
-	sourceDrivers    []drivers.IDriver
-	quitChannels     []chan bool
-	harvestInterval  int
-	logFilename      string
-	logLevelFlag     string
-	isHarvestEnabled bool
-	webServer        *server.Server
-	port             int
+	waitgroup          sync.WaitGroup
+	sourceDrivers      []drivers.IDriver
+	cancelHarvest      context.CancelFunc
+	cancelWatch        context.CancelFunc
+	harvestInterval    int
+	configPollInterval int
+	logFilename        string
+	logLevelFlag       string
+	isHarvestEnabled   bool
+	isCheckMigrations  bool
+	isPruneDryRun      bool
+	isPruneEnabled     = true
+	webServer          *server.Server
+	port               int
+	vacuumDbconn       *sql.DB // Used to reclaim the space freed by pruning
 )
 
 func init() {
 	flag.IntVar(&harvestInterval, "harvest-interval", 60*6, "Minutes to wait between harvest runs")
+	flag.IntVar(&configPollInterval, "config-poll-interval", 30, "Seconds between checks for changes to the config file (0 to disable)")
 	flag.StringVar(&logFilename, "logfile", "", "Log to the given file. (absolute or relative to storage directory)")
 	flag.BoolVar(&isHarvestEnabled, "enable-harvest", true, "False to disable harvesting posts")
 	flag.IntVar(&port, "port", 8080, "Port to listen on")
+	flag.BoolVar(&isCheckMigrations, "check-migrations", false, "Report pending database migrations and exit, without applying them")
+	flag.BoolVar(&isPruneDryRun, "prune-dry-run", false, "Report the posts that pruning would delete and exit, without deleting them")
 }
 
-func initialize() (err error) {
-	flag.Parse()
-
+func initLogging() {
 	// TODO: a chicken-egg situation exists where the config module defines the storage directory,
 	// but logs need to be configured before that. Really, the modules should be totally independent.
 	// So to fix this, the logFilename should be used as-is if it's not absolute.
@@ -53,14 +66,18 @@ func initialize() (err error) {
 		logFilename = filepath.Join(config.StorageDir(), logFilename)
 	}
 	toolbox.InitLogging(logFilename)
+}
 
-	var conf *config.Config
+// loadConfig loads and validates the config file, and points the database at the file it names.
+func loadConfig() (conf *config.Config, err error) {
 	if conf, err = config.GetConfig(); err != nil {
-		return fmt.Errorf("Could not load/parse config file: %v", err)
+		return nil, fmt.Errorf("Could not load/parse config file: %v", err)
 	}
-
 	database.SetConfig(conf.BackendStorePath)
+	return conf, nil
+}
 
+func initDrivers(conf *config.Config) (err error) {
 	// Init RedditDriver
 	log.Debug("Initializing Reddit driver.")
 	var dbconn1, dbconn2 *sql.DB
@@ -76,20 +93,148 @@ func initialize() (err error) {
 	}
 	sourceDrivers = append(sourceDrivers, redditDriver)
 
-	// init web server
+	// Init TwitterDriver (only if there's something for it to do, since it requires credentials)
+	if len(conf.Twitter.Feeds) > 0 {
+		log.Debug("Initializing Twitter driver.")
+		var dbconn3, dbconn4 *sql.DB
+		var twitterDriver *twitter.TwitterDriver
+		if dbconn3, err = database.NewConnection(); err != nil {
+			return fmt.Errorf("Could not create DB connection [3]: %v", err)
+		}
+		if dbconn4, err = database.NewConnection(); err != nil {
+			return fmt.Errorf("Could not create DB connection [4]: %v", err)
+		}
+		if twitterDriver, err = twitter.NewTwitterDriver(dbconn3, dbconn4, conf); err != nil {
+			return fmt.Errorf("Could not initialize TwitterDriver: %v", err)
+		}
+		sourceDrivers = append(sourceDrivers, twitterDriver)
+	}
+
+	// Init HackerNewsDriver
+	if len(conf.HackerNews.Feeds) > 0 {
+		log.Debug("Initializing Hacker News driver.")
+		var dbconn5, dbconn6 *sql.DB
+		var hackerNewsDriver *hackernews.HackerNewsDriver
+		if dbconn5, err = database.NewConnection(); err != nil {
+			return fmt.Errorf("Could not create DB connection [5]: %v", err)
+		}
+		if dbconn6, err = database.NewConnection(); err != nil {
+			return fmt.Errorf("Could not create DB connection [6]: %v", err)
+		}
+		if hackerNewsDriver, err = hackernews.NewHackerNewsDriver(dbconn5, dbconn6, conf); err != nil {
+			return fmt.Errorf("Could not initialize HackerNewsDriver: %v", err)
+		}
+		sourceDrivers = append(sourceDrivers, hackerNewsDriver)
+	}
+
+	// Init RssDriver
+	if len(conf.Rss.Feeds) > 0 {
+		log.Debug("Initializing RSS driver.")
+		var dbconn7, dbconn8 *sql.DB
+		var rssDriver *rss.RssDriver
+		if dbconn7, err = database.NewConnection(); err != nil {
+			return fmt.Errorf("Could not create DB connection [7]: %v", err)
+		}
+		if dbconn8, err = database.NewConnection(); err != nil {
+			return fmt.Errorf("Could not create DB connection [8]: %v", err)
+		}
+		if rssDriver, err = rss.NewRssDriver(dbconn7, dbconn8, conf); err != nil {
+			return fmt.Errorf("Could not initialize RssDriver: %v", err)
+		}
+		sourceDrivers = append(sourceDrivers, rssDriver)
+	}
+	return nil
+}
+
+// initPruning prepares to prune the drivers' posts after each harvest.
+func initPruning() (err error) {
+	if vacuumDbconn, err = database.NewConnection(); err != nil {
+		return fmt.Errorf("Could not create DB connection [vacuum]: %v", err)
+	}
+	return nil
+}
+
+func initWebServer() {
 	webServer = server.NewServer(port)
 	for _, driver := range sourceDrivers {
 		webServer.AddDriver(driver)
 	}
+}
 
-	log.Debug("Initialization complete.")
+// reportPendingMigrations prints the database migrations that will be applied the next time
+// the program is run normally.
+func reportPendingMigrations() (err error) {
+	var dbconn *sql.DB
+	if dbconn, err = database.NewConnection(); err != nil {
+		return fmt.Errorf("Could not create DB connection: %v", err)
+	}
+	var pending []database.PendingMigration
+	if pending, err = database.GetPendingMigrations(dbconn); err != nil {
+		return fmt.Errorf("Could not determine pending migrations: %v", err)
+	}
+	if len(pending) == 0 {
+		fmt.Println("The database schema is up to date.")
+		return nil
+	}
+	fmt.Printf("%d pending database migration(s):\n", len(pending))
+	for _, migration := range pending {
+		fmt.Printf("  %s #%d: %s\n", migration.Component, migration.Version, migration.Description)
+	}
+	return nil
+}
+
+// reportPrune prints the posts that would be deleted if the drivers pruned their posts now.
+func reportPrune() (err error) {
+	for _, driver := range sourceDrivers {
+		pruneDriver, ok := driver.(drivers.IPruneDriver)
+		if !ok {
+			continue
+		}
+		var report drivers.PruneReport
+		if report, err = pruneDriver.Prune(true); err != nil {
+			return fmt.Errorf("Could not determine the posts to prune: %v", err)
+		}
+		fmt.Printf("%s: %d post(s) would be deleted\n", strings.Trim(driver.GetBaseUrlPath(), "/"), report.NumPosts)
+		var sources []string
+		for source := range report.PostsBySource {
+			sources = append(sources, source)
+		}
+		sort.Strings(sources)
+		for _, source := range sources {
+			fmt.Printf("  %s: %d\n", source, report.PostsBySource[source])
+		}
+	}
 	return nil
 }
 
+// prune deletes the posts that have outlived their retention period, then returns the space
+// they used to the filesystem.
+func prune() {
+	for _, driver := range sourceDrivers {
+		pruneDriver, ok := driver.(drivers.IPruneDriver)
+		if !ok {
+			continue
+		}
+		report, err := pruneDriver.Prune(false)
+		if err != nil {
+			// Not worth stopping the harvests over; the posts will be pruned next time.
+			log.Errorf("Could not prune posts: %v", err)
+			continue
+		}
+		log.Infof("Pruned %d post(s) from %s", report.NumPosts, strings.Trim(driver.GetBaseUrlPath(), "/"))
+	}
+	if err := database.IncrementalVacuum(vacuumDbconn); err != nil {
+		log.Errorf("Could not vacuum the database: %v", err)
+	}
+}
+
 func shutdown() {
 	log.Info("Program shutdown initiated")
-	for _, ch := range quitChannels {
-		ch <- true
+	if cancelHarvest != nil {
+		cancelHarvest()
+	}
+	if cancelWatch != nil {
+		cancelWatch()
 	}
 	log.Debug("Waiting for goroutines to complete...")
 	waitgroup.Wait()
@@ -99,14 +244,31 @@ func shutdown() {
 }
 
 func beginHarvest() {
-	var quitChan = make(chan bool)
-	quitChannels = append(quitChannels, quitChan)
+	var ctx context.Context
+	ctx, cancelHarvest = context.WithCancel(context.Background())
 
 	waitgroup.Add(1)
-	go harvestLoop(quitChan)
+	go harvestLoop(ctx)
+}
+
+// harvestAll harvests every driver once, then prunes their old posts (if enabled). If ctx is
+// cancelled, the harvest is aborted and ctx.Err() is returned.
+func harvestAll(ctx context.Context) error {
+	for _, driver := range sourceDrivers {
+		log.Infof("Harvesting %s...", strings.Trim(driver.GetBaseUrlPath(), "/"))
+		if err := driver.Harvest(ctx); err != nil {
+			return err
+		}
+	}
+	if isPruneEnabled {
+		prune()
+	}
+	return nil
 }
 
-func harvestLoop(quit chan bool) {
+// harvestLoop periodically harvests every driver, until ctx is cancelled. Cancelling ctx
+// also aborts a harvest that's in progress.
+func harvestLoop(ctx context.Context) {
 	defer waitgroup.Done()
 
 	// The time.NewTimer provides a mechanism for running periodic function calls.
@@ -119,12 +281,13 @@ func harvestLoop(quit chan bool) {
 	defer timeout.Stop()
 
 	for {
-		for _, driver := range sourceDrivers {
-			log.Info("Harvesting...")
-			if err := driver.Harvest(); err != nil {
-				log.Fatal(err)
+		if err := harvestAll(ctx); err != nil {
+			if ctx.Err() != nil {
+				log.Info("Harvest cancelled")
 				return
 			}
+			log.Fatal(err)
+			return
 		}
 
 		log.Infof("Harvest complete. Waiting for %d minutes...", harvestInterval)
@@ -137,14 +300,145 @@ func harvestLoop(quit chan bool) {
 		case <-timeout.C:
 			timeout.Stop()
 			// Don't do anything, just exit the select{}
-		case <-quit:
+		case <-ctx.Done():
 			return
 		}
 	}
 }
 
-func main() {
-	initialize()
+// reloadConfig re-reads the config file, and applies it to the drivers that support reloading.
+// The other drivers keep their current config until the program is restarted, which is logged.
+// If the new config is invalid, it's logged and nothing is changed.
+func reloadConfig() {
+	conf, err := config.GetConfig()
+	if err != nil {
+		log.Errorf("Rejected the reloaded config file, as it is invalid: %v", err)
+		return
+	}
+	var reloaded, notReloaded []string
+	for _, driver := range sourceDrivers {
+		var name = strings.Trim(driver.GetBaseUrlPath(), "/")
+		if reloadDriver, ok := driver.(drivers.IReloadDriver); ok {
+			reloadDriver.Reload(conf)
+			reloaded = append(reloaded, name)
+		} else {
+			notReloaded = append(notReloaded, name)
+		}
+	}
+	log.Infof("Reloaded the config file for: %s", strings.Join(reloaded, ", "))
+	if len(notReloaded) != 0 {
+		log.Warnf("Config changes for these drivers will only apply after a restart: %s", strings.Join(notReloaded, ", "))
+	}
+}
+
+func beginWatchConfigFile(changed chan<- struct{}) {
+	var ctx context.Context
+	ctx, cancelWatch = context.WithCancel(context.Background())
+
+	waitgroup.Add(1)
+	go watchConfigFile(ctx, changed)
+}
+
+// watchConfigFile checks the config file's modification time every configPollInterval seconds,
+// and sends to changed when it changes, until ctx is cancelled.
+func watchConfigFile(ctx context.Context, changed chan<- struct{}) {
+	defer waitgroup.Done()
+
+	configFilePath, err := config.ConfigFilePath()
+	if err != nil {
+		log.Errorf("Not watching the config file for changes: %v", err)
+		return
+	}
+	var lastModTime time.Time
+	if info, err := os.Stat(configFilePath); err == nil {
+		lastModTime = info.ModTime()
+	}
+	var ticker = time.NewTicker(time.Duration(configPollInterval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			info, err := os.Stat(configFilePath)
+			if err != nil || info.ModTime().Equal(lastModTime) {
+				// (It may be missing briefly while an editor saves it).
+				continue
+			}
+			lastModTime = info.ModTime()
+			select {
+			case changed <- struct{}{}:
+			default:
+				// A reload is already pending.
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// waitForSignals returns when the program is told to shut down. Meanwhile, it reloads the config
+// file on SIGHUP, or when the file changes.
+func waitForSignals() {
+	// signal.Notify doesn't block when sending, so the channel must be buffered.
+	sig := make(chan os.Signal, 1)
+	configChanged := make(chan struct{}, 1)
+	if configPollInterval > 0 {
+		beginWatchConfigFile(configChanged)
+	}
+
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for {
+		select {
+		case s := <-sig:
+			if s == syscall.SIGHUP {
+				log.Info("Received SIGHUP, reloading the config file")
+				reloadConfig()
+				continue
+			}
+			log.Infof("Received signal %s, shutting down", s)
+			return
+		case <-configChanged:
+			log.Info("The config file changed, reloading it")
+			reloadConfig()
+		}
+	}
+}
+
+// runServeAndHarvest harvests and serves the feeds, which is what the program does when it's run
+// without a command.
+func runServeAndHarvest() int {
+	initLogging()
+	conf, err := loadConfig()
+	if err != nil {
+		log.Error(err)
+		return EXIT_INVALID_CONFIG
+	}
+	// (shutdown() closes the database too, but it's only deferred once everything's initialized)
+	defer database.Shutdown()
+	if isCheckMigrations {
+		if err = reportPendingMigrations(); err != nil {
+			log.Error(err)
+			return EXIT_ERROR
+		}
+		return EXIT_OK
+	}
+	if err = initDrivers(conf); err != nil {
+		log.Error(err)
+		return EXIT_ERROR
+	}
+	if isPruneDryRun {
+		if err = reportPrune(); err != nil {
+			log.Error(err)
+			return EXIT_ERROR
+		}
+		return EXIT_OK
+	}
+	if err = initPruning(); err != nil {
+		log.Error(err)
+		return EXIT_ERROR
+	}
+	initWebServer()
+	log.Debug("Initialization complete.")
+	// shutdown() cancels any harvest in progress, and waits for it to stop.
 	defer shutdown()
 
 	if isHarvestEnabled {
@@ -153,10 +447,22 @@ func main() {
 
 	log.Info("Launching web service")
 	go webServer.Launch()
-	sig := make(chan os.Signal)
+	waitForSignals()
+	return EXIT_OK
+}
 
-	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
-	s := <-sig
-	log.Infof("Received signal %s, shutting down", s)
-	os.Exit(0)
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		os.Exit(runServeAndHarvest())
+	}
+	for _, cmd := range commands {
+		if cmd.name == flag.Arg(0) {
+			os.Exit(cmd.run(flag.Args()[1:]))
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Unknown command: '%s'\n\n", flag.Arg(0))
+	flag.Usage()
+	os.Exit(EXIT_USAGE)
 }
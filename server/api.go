@@ -0,0 +1,219 @@
+package server
+
+// This handles the versioned JSON API, which exposes the same feeds & posts as the HTML UI
+// for use by other programs. The response schemas are documented in API.md.
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/coverprice/contentscraper/drivers"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	ApiBaseUrlPath = "/api/v1/"
+
+	API_DEFAULT_PAGE_SIZE = 25
+	API_MAX_PAGE_SIZE     = 100
+)
+
+// Verify that apiHandler implements http.Handler interface
+var _ http.Handler = &apiHandler{}
+
+type apiHandler struct {
+	server *Server
+}
+
+type apiFeed struct {
+	Name              string `json:"name"`
+	Description       string `json:"description"`
+	Url               string `json:"url"` // Path of the feed's HTML page
+	Status            string `json:"status"`
+	TimeLastHarvested int64  `json:"time_last_harvested"`
+	HasPosts          bool   `json:"has_posts"` // Whether the feed's posts are available via the API
+}
+
+type apiFeedList struct {
+	Feeds []apiFeed `json:"feeds"`
+}
+
+type apiPostPage struct {
+	Feed     string             `json:"feed"`
+	Page     int                `json:"page"`
+	PageSize int                `json:"page_size"`
+	NumPages int                `json:"num_pages"`
+	NumPosts int                `json:"num_posts"`
+	Posts    []drivers.IApiPost `json:"posts"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// ServeHTTP handles the following paths (relative to ApiBaseUrlPath): "feeds" lists all
+// feeds, "feeds/{feed}/posts" returns a page of the feed's posts, and "feeds/{feed}/posts/{id}"
+// returns a single post in the feed.
+func (this apiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeApiError(w, http.StatusMethodNotAllowed, "Only GET is supported")
+		return
+	}
+	// Post IDs may contain escaped slashes, so the path is split before it's unescaped.
+	var segments []string
+	for _, segment := range strings.Split(strings.Trim(strings.TrimPrefix(r.URL.EscapedPath(), ApiBaseUrlPath), "/"), "/") {
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil {
+			writeApiError(w, http.StatusBadRequest, "Invalid URL path")
+			return
+		}
+		segments = append(segments, unescaped)
+	}
+
+	switch {
+	case len(segments) == 1 && segments[0] == "feeds":
+		this.handleFeedList(w)
+	case len(segments) == 3 && segments[0] == "feeds" && segments[2] == "posts":
+		this.handlePostPage(w, r, segments[1])
+	case len(segments) == 4 && segments[0] == "feeds" && segments[2] == "posts":
+		this.handlePost(w, segments[1], segments[3])
+	default:
+		writeApiError(w, http.StatusNotFound, "Unknown API endpoint")
+	}
+}
+
+func (this apiHandler) handleFeedList(w http.ResponseWriter) {
+	var feedList = apiFeedList{Feeds: make([]apiFeed, 0)}
+	for _, driver := range this.server.Drivers {
+		var baseUrl = strings.TrimRight(driver.GetBaseUrlPath(), "/")
+		_, hasPosts := driver.(drivers.IApiDriver)
+		for _, feed := range driver.GetFeeds() {
+			feedList.Feeds = append(feedList.Feeds, apiFeed{
+				Name:              feed.Name,
+				Description:       feed.Description,
+				Url:               fmt.Sprintf("%s/?feed=%s", baseUrl, url.QueryEscape(feed.Name)),
+				Status:            feed.Status.String(),
+				TimeLastHarvested: feed.TimeLastHarvested,
+				HasPosts:          hasPosts,
+			})
+		}
+	}
+	sort.Sort(byApiFeedName(feedList.Feeds))
+	writeApiResponse(w, feedList)
+}
+
+type byApiFeedName []apiFeed
+
+func (a byApiFeedName) Len() int      { return len(a) }
+func (a byApiFeedName) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byApiFeedName) Less(i, j int) bool {
+	return a[i].Name < a[j].Name
+}
+
+func (this apiHandler) handlePostPage(w http.ResponseWriter, r *http.Request, feedName string) {
+	values := r.URL.Query()
+	pageNum, err := getIntParam(values, "page", 1, 1, 5000)
+	if err != nil {
+		writeApiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	pageSize, err := getIntParam(values, "page_size", API_DEFAULT_PAGE_SIZE, 1, API_MAX_PAGE_SIZE)
+	if err != nil {
+		writeApiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	posts, ok := this.getPosts(w, feedName)
+	if !ok {
+		return
+	}
+
+	page := apiPostPage{
+		Feed:     feedName,
+		Page:     pageNum,
+		PageSize: pageSize,
+		NumPages: (len(posts) + pageSize - 1) / pageSize,
+		NumPosts: len(posts),
+		Posts:    make([]drivers.IApiPost, 0),
+	}
+	startIdx := pageSize * (pageNum - 1)
+	if startIdx < len(posts) {
+		endIdx := startIdx + pageSize
+		if endIdx > len(posts) {
+			endIdx = len(posts)
+		}
+		page.Posts = posts[startIdx:endIdx]
+	}
+	writeApiResponse(w, page)
+}
+
+func (this apiHandler) handlePost(w http.ResponseWriter, feedName string, id string) {
+	posts, ok := this.getPosts(w, feedName)
+	if !ok {
+		return
+	}
+	for _, post := range posts {
+		if post.GetApiId() == id {
+			writeApiResponse(w, post)
+			return
+		}
+	}
+	writeApiError(w, http.StatusNotFound, fmt.Sprintf("Unknown post '%s' in feed '%s'", id, feedName))
+}
+
+// getPosts retrieves the feed's posts from whichever driver handles it. If that fails, it
+// writes an error response and returns false.
+func (this apiHandler) getPosts(w http.ResponseWriter, feedName string) (posts []drivers.IApiPost, ok bool) {
+	for _, driver := range this.server.Drivers {
+		for _, feed := range driver.GetFeeds() {
+			if feed.Name != feedName {
+				continue
+			}
+			apiDriver, isApiDriver := driver.(drivers.IApiDriver)
+			if !isApiDriver {
+				writeApiError(w, http.StatusNotImplemented, fmt.Sprintf("Feed '%s' does not support the API", feedName))
+				return nil, false
+			}
+			posts, err := apiDriver.GetApiPosts(feedName)
+			if err != nil {
+				log.Errorf("Error retrieving posts for feed '%s': %v", feedName, err)
+				writeApiError(w, http.StatusInternalServerError, fmt.Sprintf("Internal error retrieving posts for feed: %s", feedName))
+				return nil, false
+			}
+			return posts, true
+		}
+	}
+	writeApiError(w, http.StatusNotFound, fmt.Sprintf("Unknown feed '%s'", feedName))
+	return nil, false
+}
+
+func getIntParam(values url.Values, name string, defaultValue, min, max int) (int, error) {
+	str := values.Get(name)
+	if str == "" {
+		return defaultValue, nil
+	}
+	val, err := strconv.Atoi(str)
+	if err != nil || val < min || val > max {
+		return 0, fmt.Errorf("Invalid '%s' parameter, must be an integer from %d to %d", name, min, max)
+	}
+	return val, nil
+}
+
+func writeApiResponse(w http.ResponseWriter, response interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Errorf("Error writing API response: %v", err)
+	}
+}
+
+func writeApiError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(apiError{Error: msg}); err != nil {
+		log.Errorf("Error writing API response: %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/coverprice/contentscraper/drivers"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type fakeApiPost struct {
+	Id    string `json:"id"`
+	Title string `json:"title"`
+}
+
+func (this fakeApiPost) GetApiId() string {
+	return this.Id
+}
+
+// fakeDriver serves a single feed. If it has posts, it implements drivers.IApiDriver.
+type fakeDriver struct {
+	feed  drivers.Feed
+	posts []drivers.IApiPost
+}
+
+func (this *fakeDriver) Harvest(ctx context.Context) error { return nil }
+func (this *fakeDriver) GetBaseUrlPath() string            { return "/fake/" }
+func (this *fakeDriver) GetFeeds() []drivers.Feed          { return []drivers.Feed{this.feed} }
+func (this *fakeDriver) GetHttpHandler() http.Handler      { return http.NotFoundHandler() }
+
+type fakeApiDriver struct {
+	fakeDriver
+}
+
+func (this *fakeApiDriver) GetApiPosts(feedName string) ([]drivers.IApiPost, error) {
+	return this.posts, nil
+}
+
+func newTestApiHandler() apiHandler {
+	var posts []drivers.IApiPost
+	for i := 1; i <= 5; i++ {
+		posts = append(posts, fakeApiPost{Id: fmt.Sprintf("id/%d", i), Title: fmt.Sprintf("Post %d", i)})
+	}
+	return apiHandler{server: &Server{
+		Drivers: []drivers.IDriver{
+			&fakeApiDriver{fakeDriver{
+				feed:  drivers.Feed{Name: "withapi", Description: "Feed with API", Status: drivers.FEEDHARVESTSTATUS_ERROR, TimeLastHarvested: 1234},
+				posts: posts,
+			}},
+			&fakeDriver{
+				feed: drivers.Feed{Name: "noapi", Description: "Feed without API"},
+			},
+		},
+	}}
+}
+
+func doApiRequest(t *testing.T, handler apiHandler, url string, expectedStatus int, response interface{}) {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+	require.Equal(t, expectedStatus, w.Code, "Unexpected status for %s: %s", url, w.Body.String())
+	require.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), response), "Could not decode response for %s", url)
+}
+
+func TestApiFeedList(t *testing.T) {
+	var response struct {
+		Feeds []map[string]interface{} `json:"feeds"`
+	}
+	doApiRequest(t, newTestApiHandler(), "/api/v1/feeds", 200, &response)
+	require.Equal(t, 2, len(response.Feeds))
+	require.Equal(t, map[string]interface{}{
+		"name":                "noapi",
+		"description":         "Feed without API",
+		"url":                 "/fake/?feed=noapi",
+		"status":              "idle",
+		"time_last_harvested": 0.0,
+		"has_posts":           false,
+	}, response.Feeds[0])
+	require.Equal(t, "withapi", response.Feeds[1]["name"])
+	require.Equal(t, "error", response.Feeds[1]["status"])
+	require.Equal(t, 1234.0, response.Feeds[1]["time_last_harvested"])
+	require.Equal(t, true, response.Feeds[1]["has_posts"])
+}
+
+func TestApiPostPaging(t *testing.T) {
+	handler := newTestApiHandler()
+	var page struct {
+		Feed     string        `json:"feed"`
+		Page     int           `json:"page"`
+		PageSize int           `json:"page_size"`
+		NumPages int           `json:"num_pages"`
+		NumPosts int           `json:"num_posts"`
+		Posts    []fakeApiPost `json:"posts"`
+	}
+	doApiRequest(t, handler, "/api/v1/feeds/withapi/posts?page=2&page_size=2", 200, &page)
+	require.Equal(t, "withapi", page.Feed)
+	require.Equal(t, 2, page.Page)
+	require.Equal(t, 2, page.PageSize)
+	require.Equal(t, 3, page.NumPages)
+	require.Equal(t, 5, page.NumPosts)
+	require.Equal(t, []fakeApiPost{fakeApiPost{"id/3", "Post 3"}, fakeApiPost{"id/4", "Post 4"}}, page.Posts)
+
+	// Past the end
+	doApiRequest(t, handler, "/api/v1/feeds/withapi/posts?page=4&page_size=2", 200, &page)
+	require.Equal(t, 0, len(page.Posts))
+
+	var apiErr map[string]string
+	doApiRequest(t, handler, "/api/v1/feeds/withapi/posts?page_size=1000", 400, &apiErr)
+	require.Contains(t, apiErr["error"], "page_size")
+	doApiRequest(t, handler, "/api/v1/feeds/nosuchfeed/posts", 404, &apiErr)
+	doApiRequest(t, handler, "/api/v1/feeds/noapi/posts", 501, &apiErr)
+}
+
+func TestApiSinglePost(t *testing.T) {
+	handler := newTestApiHandler()
+	var post fakeApiPost
+	// IDs containing slashes must be escaped.
+	doApiRequest(t, handler, "/api/v1/feeds/withapi/posts/id%2F3", 200, &post)
+	require.Equal(t, fakeApiPost{"id/3", "Post 3"}, post)
+
+	var apiErr map[string]string
+	doApiRequest(t, handler, "/api/v1/feeds/withapi/posts/id%2F6", 404, &apiErr)
+	doApiRequest(t, handler, "/api/v1/nosuchendpoint", 404, &apiErr)
+}
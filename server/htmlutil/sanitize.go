@@ -0,0 +1,134 @@
+package htmlutil
+
+import (
+	"bytes"
+	"html"
+	"html/template"
+	"regexp"
+	"strings"
+)
+
+// The tags that SanitizeHtml keeps. They cover what Reddit (and most Markdown renderers) emit.
+// Every attribute is removed, except for the href of links.
+var allowedTags = map[string]bool{
+	"a": true, "b": true, "blockquote": true, "br": true, "code": true, "del": true,
+	"div": true, "em": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true,
+	"h6": true, "hr": true, "i": true, "li": true, "ol": true, "p": true, "pre": true,
+	"s": true, "span": true, "strike": true, "strong": true, "sub": true, "sup": true,
+	"table": true, "tbody": true, "td": true, "th": true, "thead": true, "tr": true,
+	"u": true, "ul": true,
+}
+
+// Tags that have no closing tag.
+var voidTags = map[string]bool{
+	"br": true,
+	"hr": true,
+}
+
+// Tags whose content is dropped along with the tag itself.
+var droppedContentTags = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"iframe":   true,
+	"object":   true,
+	"textarea": true,
+	"title":    true,
+}
+
+var (
+	tagRegex     = regexp.MustCompile(`(?s)<!--.*?(-->|$)|<(/?)([a-zA-Z][a-zA-Z0-9]*)([^>]*)>`)
+	hrefRegex    = regexp.MustCompile(`(?i)\bhref\s*=\s*("[^"]*"|'[^']*'|[^\s"'>]+)`)
+	safeUrlRegex = regexp.MustCompile(`(?i)^(https?://|/|#)`)
+)
+
+// SanitizeHtml removes everything from an untrusted HTML fragment except for basic formatting
+// and links, so that it's safe to embed in a page. Unclosed tags are closed, and stray
+// closing tags removed, so the fragment can't break the surrounding page's layout.
+func SanitizeHtml(s string) template.HTML {
+	var out bytes.Buffer
+	var openTags []string
+	var droppingUntil string // When non-empty, content is dropped until this closing tag.
+
+	var pos int
+	for _, match := range tagRegex.FindAllStringSubmatchIndex(s, -1) {
+		if droppingUntil == "" {
+			writeText(&out, s[pos:match[0]])
+		}
+		pos = match[1]
+		if match[6] < 0 {
+			// A comment.
+			continue
+		}
+		var isClosing = match[5] > match[4]
+		var tag = strings.ToLower(s[match[6]:match[7]])
+
+		if droppingUntil != "" {
+			if isClosing && tag == droppingUntil {
+				droppingUntil = ""
+			}
+			continue
+		}
+		if droppedContentTags[tag] {
+			if !isClosing {
+				droppingUntil = tag
+			}
+			continue
+		}
+		if !allowedTags[tag] {
+			continue
+		}
+
+		if voidTags[tag] {
+			if !isClosing {
+				out.WriteString("<" + tag + ">")
+			}
+			continue
+		}
+		if !isClosing {
+			out.WriteString("<" + tag)
+			if tag == "a" {
+				writeHref(&out, s[match[8]:match[9]])
+			}
+			out.WriteString(">")
+			openTags = append(openTags, tag)
+			continue
+		}
+		// Close everything up to and including the matching open tag. If there isn't one,
+		// the closing tag is dropped.
+		for i := len(openTags) - 1; i >= 0; i-- {
+			if openTags[i] == tag {
+				for j := len(openTags) - 1; j >= i; j-- {
+					out.WriteString("</" + openTags[j] + ">")
+				}
+				openTags = openTags[:i]
+				break
+			}
+		}
+	}
+	if droppingUntil == "" {
+		writeText(&out, s[pos:])
+	}
+	for i := len(openTags) - 1; i >= 0; i-- {
+		out.WriteString("</" + openTags[i] + ">")
+	}
+	return template.HTML(out.String())
+}
+
+// writeText writes text found between tags, re-escaped so that stray '<' etc. are harmless.
+func writeText(out *bytes.Buffer, text string) {
+	out.WriteString(html.EscapeString(html.UnescapeString(text)))
+}
+
+// writeHref writes the href attribute found amongst the given attributes, if it's a safe URL.
+func writeHref(out *bytes.Buffer, attrs string) {
+	var match = hrefRegex.FindStringSubmatch(attrs)
+	if match == nil {
+		return
+	}
+	var url = strings.Trim(match[1], `"'`)
+	url = strings.TrimSpace(html.UnescapeString(url))
+	if !safeUrlRegex.MatchString(url) || strings.HasPrefix(url, "//") {
+		return
+	}
+	out.WriteString(` href="` + html.EscapeString(url) + `" rel="nofollow noopener"`)
+}
@@ -0,0 +1,47 @@
+package htmlutil
+
+import (
+	"github.com/stretchr/testify/require"
+	"html/template"
+	"testing"
+)
+
+func TestSanitizeHtml(t *testing.T) {
+	var tests = []struct {
+		in       string
+		expected template.HTML
+	}{
+		{
+			`<!-- SC_OFF --><div class="md"><p>Hello <strong>world</strong> &amp; co</p></div><!-- SC_ON -->`,
+			`<div><p>Hello <strong>world</strong> &amp; co</p></div>`,
+		},
+		{
+			`<p onclick="alert(1)">Hi<script>alert("x")</script> there</p>`,
+			`<p>Hi there</p>`,
+		},
+		{
+			`<a href="https://example.com/?a=1&amp;b=2" style="x">link</a>`,
+			`<a href="https://example.com/?a=1&amp;b=2" rel="nofollow noopener">link</a>`,
+		},
+		{
+			`<a href="/r/funny">sub</a> <a href="javascript:alert(1)">bad</a> <a href="//evil.com">bad</a>`,
+			`<a href="/r/funny" rel="nofollow noopener">sub</a> <a>bad</a> <a>bad</a>`,
+		},
+		{
+			`<img src="x" onerror="alert(1)"><iframe src="x">inside</iframe>text`,
+			`text`,
+		},
+		{
+			// Unbalanced tags are fixed up.
+			`</div><ul><li><em>one</li></ul><p>unclosed`,
+			`<ul><li><em>one</em></li></ul><p>unclosed</p>`,
+		},
+		{
+			`1 < 2 > 0<br/>`,
+			`1 &lt; 2 &gt; 0<br>`,
+		},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.expected, SanitizeHtml(test.in), "Input: %s", test.in)
+	}
+}
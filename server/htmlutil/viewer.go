@@ -0,0 +1,41 @@
+package htmlutil
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// There are no user accounts. Instead, each browser is given a random viewer ID in a cookie, and
+// per-user state (e.g. which posts have been read) is stored against it.
+const (
+	VIEWER_COOKIE_NAME = "viewer"
+	viewerCookieMaxAge = 10 * 365 * 24 * time.Hour
+)
+
+var viewerIdRegexp = regexp.MustCompile("^[0-9a-f]{32}$")
+
+// GetViewerId returns the ID of the viewer making the request. If the request doesn't have one, a
+// new ID is generated and set as a cookie on the response, so it must be called before the
+// response body is written.
+func GetViewerId(w http.ResponseWriter, r *http.Request) string {
+	if cookie, err := r.Cookie(VIEWER_COOKIE_NAME); err == nil && viewerIdRegexp.MatchString(cookie.Value) {
+		return cookie.Value
+	}
+	var buf = make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		log.Errorf("Could not generate a viewer ID: %v", err)
+	}
+	var viewerId = hex.EncodeToString(buf)
+	http.SetCookie(w, &http.Cookie{
+		Name:     VIEWER_COOKIE_NAME,
+		Value:    viewerId,
+		Path:     "/",
+		Expires:  time.Now().Add(viewerCookieMaxAge),
+		HttpOnly: true,
+	})
+	return viewerId
+}
@@ -0,0 +1,24 @@
+package htmlutil
+
+import (
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestViewerIdIsAssignedOnce(t *testing.T) {
+	w := httptest.NewRecorder()
+	viewerId := GetViewerId(w, httptest.NewRequest("GET", "/", nil))
+	require.Regexp(t, "^[0-9a-f]{32}$", viewerId)
+	cookies := w.Result().Cookies()
+	require.Equal(t, 1, len(cookies))
+	require.Equal(t, VIEWER_COOKIE_NAME, cookies[0].Name)
+	require.Equal(t, viewerId, cookies[0].Value)
+
+	// The cookie identifies the viewer on subsequent requests.
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	require.Equal(t, viewerId, GetViewerId(w, r))
+	require.Empty(t, w.Result().Cookies())
+}
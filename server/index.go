@@ -35,11 +35,19 @@ var indexTemplateStr = `
             <tr class="mainmenu">
                 <td><a href="{{.BaseUrl}}/?feed={{.Feed.Name}}">{{.Feed.Name}}</td>
                 <td><a href="{{.BaseUrl}}/?feed={{.Feed.Name}}">{{.Feed.Description}}</td>
+                <td>{{if .HasUnreadCount}}<span class="badge badge-{{if .UnreadCount}}primary{{else}}light{{end}}">{{.UnreadCount}} unread</span>{{end}}</td>
                 <td><small class="text-muted">{{.StatusText}}</small></td>
+                <td>
+                    <small><a href="{{.BaseUrl}}/rss?feed={{.Feed.Name}}">RSS</a></small>
+                    <small><a href="{{.BaseUrl}}/atom?feed={{.Feed.Name}}">Atom</a></small>
+                </td>
             </tr>
         {{end}}
     </tbody>
     </table>
+    <small><a href="/search">Search</a></small>
+    <small><a href="/saved">Saved posts</a></small>
+    <small><a href="/stats">Harvest statistics</a></small>
     </div>
     {{end}}
 `
@@ -57,6 +65,9 @@ type driverFeed struct {
 	BaseUrl    string
 	Feed       drivers.Feed
 	StatusText string
+	// The # of the feed's posts the viewer hasn't read, if the driver tracks that. (See drivers.ISeenDriver)
+	UnreadCount    int
+	HasUnreadCount bool
 }
 
 // ServeHTTP is a handler called by the mux multiplexer, configured to respond to the "/" URL pattern.
@@ -69,16 +80,28 @@ func (this indexHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var viewerId = htmlutil.GetViewerId(w, r)
+
 	// Retrieve all Feeds from all Drivers, and sort them for display.
 	var allfeeds []driverFeed
 	for _, driver := range this.server.Drivers {
 		var baseUrl = strings.TrimRight(driver.GetBaseUrlPath(), "/")
+		seenDriver, isSeenDriver := driver.(drivers.ISeenDriver)
 		for _, feed := range driver.GetFeeds() {
-			allfeeds = append(allfeeds, driverFeed{
+			var item = driverFeed{
 				BaseUrl:    baseUrl,
 				Feed:       feed,
 				StatusText: getStatusText(feed),
-			})
+			}
+			if isSeenDriver {
+				var err error
+				if item.UnreadCount, err = seenDriver.GetUnreadCount(feed.Name, viewerId); err != nil {
+					log.Errorf("Could not count the unread posts in feed '%s': %v", feed.Name, err)
+				} else {
+					item.HasUnreadCount = true
+				}
+			}
+			allfeeds = append(allfeeds, item)
 		}
 	}
 	sort.Sort(byFeedName(allfeeds))
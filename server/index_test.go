@@ -0,0 +1,45 @@
+package server
+
+import (
+	"github.com/coverprice/contentscraper/drivers"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"testing"
+)
+
+type fakeSeenDriver struct {
+	fakeDriver
+	unreadCounts map[string]int // Viewer ID -> # of unread posts
+}
+
+func (this *fakeSeenDriver) GetUnreadCount(feedName string, viewerId string) (int, error) {
+	return this.unreadCounts[viewerId], nil
+}
+
+func TestIndexShowsUnreadCounts(t *testing.T) {
+	handler := indexHandler{server: &Server{
+		Drivers: []drivers.IDriver{
+			&fakeSeenDriver{
+				fakeDriver: fakeDriver{feed: drivers.Feed{Name: "withseen", Description: "Feed that tracks read posts"}},
+			},
+			&fakeDriver{
+				feed: drivers.Feed{Name: "noseen", Description: "Feed that doesn't"},
+			},
+		},
+	}}
+
+	// The 1st request assigns a viewer ID
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	require.Equal(t, 200, w.Code)
+	cookies := w.Result().Cookies()
+	require.Equal(t, 1, len(cookies))
+	require.Contains(t, w.Body.String(), "0 unread")
+
+	handler.server.Drivers[0].(*fakeSeenDriver).unreadCounts = map[string]int{cookies[0].Value: 42}
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Contains(t, w.Body.String(), "42 unread")
+}
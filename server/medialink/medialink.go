@@ -12,8 +12,8 @@ import (
 // It's the result from UrlToMediaLink(), which is a method that attempts to analyze a raw URL to a site
 // like imgur.com or gfycat.com, and return a way of rendering that content.
 type MediaLink struct {
-	Url   string        // Direct link (must construct <img> or <video> link yourself)
-	Embed template.HTML // raw HTML that will embed the image.
+	Url   string        `json:"url"`   // Direct link (must construct <img> or <video> link yourself)
+	Embed template.HTML `json:"embed"` // raw HTML that will embed the image.
 }
 
 type link struct {
@@ -0,0 +1,298 @@
+package server
+
+// This handles the saved posts page. It lists the posts the viewer has starred in any feed whose
+// driver supports starring, and exports them as JSON, CSV or a bookmarks file that browsers can
+// import.
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/coverprice/contentscraper/drivers"
+	"github.com/coverprice/contentscraper/server/htmlutil"
+	log "github.com/sirupsen/logrus"
+	"html"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	SavedUrlPath = "/saved"
+
+	SAVED_FORMAT_HTML      = "html"
+	SAVED_FORMAT_JSON      = "json"
+	SAVED_FORMAT_CSV       = "csv"
+	SAVED_FORMAT_BOOKMARKS = "bookmarks"
+)
+
+var savedTemplateStr = `
+    {{define "title"}}Saved posts{{end}}
+    {{define "content"}}
+    <div class="container">
+    <p>
+        {{if .Feed}}<a href="{{.AllFeedsUrl}}">All feeds</a>{{else}}<strong>All feeds</strong>{{end}}
+        {{range .FeedLinks}}
+            {{if .IsHighlighted}}<strong>{{.Text}}</strong>{{else}}<a href="{{.Link}}">{{.Text}}</a>{{end}}
+        {{end}}
+    </p>
+    {{if .Posts}}
+        <table class="table table-sm">
+        <thead><tr><th>Post</th><th>Feed</th><th>Source</th><th>Starred</th></tr></thead>
+        <tbody>
+        {{range .Posts}}
+            <tr>
+                <td>
+                    <a href="{{.Link}}">{{.Title}}</a>
+                    {{if .Url}}<small><a href="{{.Permalink}}">comments</a></small>{{end}}
+                </td>
+                <td>{{.FeedName}}</td>
+                <td><small class="text-muted">{{.Source}}</small></td>
+                <td><small>{{.Starred}}</small></td>
+            </tr>
+        {{end}}
+        </tbody>
+        </table>
+        <small>
+            Export:
+            {{range .ExportLinks}}<a href="{{.Link}}">{{.Text}}</a> {{end}}
+        </small>
+    {{else}}
+        <p class="text-muted">No saved posts. Star posts in a feed to save them here.</p>
+    {{end}}
+    </div>
+    {{end}}
+`
+
+var savedTempl = htmlutil.ParseTemplate(savedTemplateStr)
+
+// Verify that savedHandler implements http.Handler interface
+var _ http.Handler = &savedHandler{}
+
+type savedHandler struct {
+	server *Server
+}
+
+type savedPost struct {
+	drivers.StarredPost
+	Link    string // The post's link, or its permalink if it doesn't have one
+	Starred string
+}
+
+type savedLink struct {
+	Text          string
+	Link          string
+	IsHighlighted bool
+}
+
+// ServeHTTP lists the viewer's starred posts, optionally only those in the feed given by the
+// "feed" query parameter. The "format" query parameter selects an export format instead of HTML.
+func (this savedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var query = r.URL.Query()
+	var feedName = query.Get("feed")
+	var format = query.Get("format")
+	if format == "" {
+		format = SAVED_FORMAT_HTML
+	}
+	switch format {
+	case SAVED_FORMAT_HTML, SAVED_FORMAT_JSON, SAVED_FORMAT_CSV, SAVED_FORMAT_BOOKMARKS:
+	default:
+		http.Error(w, fmt.Sprintf("Invalid request. Unknown format: '%s'", format), http.StatusBadRequest)
+		return
+	}
+
+	var viewerId = htmlutil.GetViewerId(w, r)
+	allPosts, err := this.getStarredPosts(viewerId)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal error retrieving saved posts: %v", err), 500)
+		return
+	}
+	var feedNames []string
+	var posts = make([]drivers.StarredPost, 0)
+	for _, post := range allPosts {
+		if !containsString(feedNames, post.FeedName) {
+			feedNames = append(feedNames, post.FeedName)
+		}
+		if feedName == "" || post.FeedName == feedName {
+			posts = append(posts, post)
+		}
+	}
+	sort.Strings(feedNames)
+
+	switch format {
+	case SAVED_FORMAT_JSON:
+		setAttachment(w, "application/json; charset=utf-8", "saved.json")
+		json.NewEncoder(w).Encode(struct {
+			Posts []drivers.StarredPost `json:"posts"`
+		}{posts})
+	case SAVED_FORMAT_CSV:
+		setAttachment(w, "text/csv; charset=utf-8", "saved.csv")
+		writeSavedCsv(w, posts)
+	case SAVED_FORMAT_BOOKMARKS:
+		setAttachment(w, "text/html; charset=utf-8", "saved.html")
+		writeSavedBookmarks(w, posts)
+	default:
+		this.renderHtml(w, feedName, feedNames, posts)
+	}
+}
+
+func (this savedHandler) renderHtml(w http.ResponseWriter, feedName string, feedNames []string, posts []drivers.StarredPost) {
+	var feedLinks []savedLink
+	for _, name := range feedNames {
+		feedLinks = append(feedLinks, savedLink{
+			Text:          name,
+			Link:          constructSavedUrl(name, ""),
+			IsHighlighted: name == feedName,
+		})
+	}
+	var exportLinks []savedLink
+	for _, format := range []string{SAVED_FORMAT_JSON, SAVED_FORMAT_CSV, SAVED_FORMAT_BOOKMARKS} {
+		exportLinks = append(exportLinks, savedLink{Text: format, Link: constructSavedUrl(feedName, format)})
+	}
+	var savedPosts []savedPost
+	for _, post := range posts {
+		savedPosts = append(savedPosts, savedPost{
+			StarredPost: post,
+			Link:        getSavedPostLink(post),
+			Starred:     formatStatsTime(post.TimeStarred),
+		})
+	}
+
+	data := struct {
+		Title string
+		htmlutil.Breadcrumbs
+		Feed        string
+		AllFeedsUrl string
+		FeedLinks   []savedLink
+		ExportLinks []savedLink
+		Posts       []savedPost
+	}{
+		Title: "Saved posts",
+		Breadcrumbs: []htmlutil.Breadcrumb{
+			htmlutil.NewBreadcrumb("Home", "/"),
+			htmlutil.NewBreadcrumb("Saved posts", SavedUrlPath),
+		},
+		Feed:        feedName,
+		AllFeedsUrl: constructSavedUrl("", ""),
+		FeedLinks:   feedLinks,
+		ExportLinks: exportLinks,
+		Posts:       savedPosts,
+	}
+	htmlutil.RenderTemplate(w, savedTempl, data)
+}
+
+// getStarredPosts returns the viewer's starred posts from all drivers, most recently starred first.
+func (this savedHandler) getStarredPosts(viewerId string) (posts []drivers.StarredPost, err error) {
+	for _, driver := range this.server.Drivers {
+		starDriver, ok := driver.(drivers.IStarDriver)
+		if !ok {
+			continue
+		}
+		var driverPosts []drivers.StarredPost
+		if driverPosts, err = starDriver.GetStarredPosts(viewerId); err != nil {
+			log.Errorf("Could not retrieve starred posts: %v", err)
+			return
+		}
+		posts = append(posts, driverPosts...)
+	}
+	sort.Stable(byTimeStarred(posts))
+	return
+}
+
+type byTimeStarred []drivers.StarredPost
+
+func (a byTimeStarred) Len() int      { return len(a) }
+func (a byTimeStarred) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byTimeStarred) Less(i, j int) bool {
+	return a[i].TimeStarred > a[j].TimeStarred
+}
+
+func constructSavedUrl(feedName string, format string) string {
+	var v = url.Values{}
+	if feedName != "" {
+		v.Set("feed", feedName)
+	}
+	if format != "" {
+		v.Set("format", format)
+	}
+	if len(v) == 0 {
+		return SavedUrlPath
+	}
+	return SavedUrlPath + "?" + v.Encode()
+}
+
+func getSavedPostLink(post drivers.StarredPost) string {
+	if post.Url != "" {
+		return post.Url
+	}
+	return post.Permalink
+}
+
+func containsString(values []string, s string) bool {
+	for _, value := range values {
+		if value == s {
+			return true
+		}
+	}
+	return false
+}
+
+// setAttachment sets the headers of an export, so that browsers download it as the given filename.
+func setAttachment(w http.ResponseWriter, contentType string, filename string) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+}
+
+func writeSavedCsv(w http.ResponseWriter, posts []drivers.StarredPost) {
+	var writer = csv.NewWriter(w)
+	writer.Write([]string{"feed", "title", "url", "permalink", "source", "time_created", "time_starred"})
+	for _, post := range posts {
+		writer.Write([]string{
+			post.FeedName,
+			post.Title,
+			post.Url,
+			post.Permalink,
+			post.Source,
+			time.Unix(post.TimeCreated, 0).UTC().Format(time.RFC3339),
+			time.Unix(post.TimeStarred, 0).UTC().Format(time.RFC3339),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		log.Errorf("Could not write saved posts as CSV: %v", err)
+	}
+}
+
+// writeSavedBookmarks writes the posts in the Netscape bookmark file format, which most browsers
+// can import. Each feed's posts are put in a folder named after the feed.
+func writeSavedBookmarks(w http.ResponseWriter, posts []drivers.StarredPost) {
+	var feedNames []string
+	var feedPosts = make(map[string][]drivers.StarredPost)
+	for _, post := range posts {
+		if _, ok := feedPosts[post.FeedName]; !ok {
+			feedNames = append(feedNames, post.FeedName)
+		}
+		feedPosts[post.FeedName] = append(feedPosts[post.FeedName], post)
+	}
+	sort.Strings(feedNames)
+
+	fmt.Fprint(w, "<!DOCTYPE NETSCAPE-Bookmark-file-1>\n"+
+		"<META HTTP-EQUIV=\"Content-Type\" CONTENT=\"text/html; charset=UTF-8\">\n"+
+		"<TITLE>Saved posts</TITLE>\n"+
+		"<H1>Saved posts</H1>\n"+
+		"<DL><p>\n")
+	for _, feedName := range feedNames {
+		fmt.Fprintf(w, "    <DT><H3>%s</H3>\n    <DL><p>\n", html.EscapeString(feedName))
+		for _, post := range feedPosts[feedName] {
+			fmt.Fprintf(w, "        <DT><A HREF=\"%s\" ADD_DATE=\"%s\">%s</A>\n",
+				html.EscapeString(getSavedPostLink(post)),
+				strconv.FormatInt(post.TimeStarred, 10),
+				html.EscapeString(post.Title),
+			)
+		}
+		fmt.Fprint(w, "    </DL><p>\n")
+	}
+	fmt.Fprint(w, "</DL><p>\n")
+}
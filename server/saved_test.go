@@ -0,0 +1,95 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"github.com/coverprice/contentscraper/drivers"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type fakeStarDriver struct {
+	fakeDriver
+	starredPosts []drivers.StarredPost
+}
+
+func (this *fakeStarDriver) GetStarredPosts(viewerId string) ([]drivers.StarredPost, error) {
+	return this.starredPosts, nil
+}
+
+func newTestSavedHandler() savedHandler {
+	return savedHandler{server: &Server{
+		Drivers: []drivers.IDriver{
+			&fakeStarDriver{
+				fakeDriver: fakeDriver{feed: drivers.Feed{Name: "pics"}},
+				starredPosts: []drivers.StarredPost{
+					drivers.StarredPost{FeedName: "pics", Title: "A <cat>", Url: "https://example.com/cat.jpg", Permalink: "https://www.reddit.com/r/pics/1", Source: "pics", TimeStarred: 200},
+					drivers.StarredPost{FeedName: "news", Title: "Self post, with a comma", Permalink: "https://www.reddit.com/r/news/2", Source: "news", TimeStarred: 300},
+				},
+			},
+			&fakeDriver{
+				feed: drivers.Feed{Name: "nostar"},
+			},
+		},
+	}}
+}
+
+func doSavedRequest(t *testing.T, url string, expectedStatus int) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	newTestSavedHandler().ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+	require.Equal(t, expectedStatus, w.Code, "Unexpected status for %s: %s", url, w.Body.String())
+	return w
+}
+
+func TestSavedPage(t *testing.T) {
+	body := doSavedRequest(t, "/saved", 200).Body.String()
+	require.Contains(t, body, "A &lt;cat&gt;")
+	require.Contains(t, body, "Self post, with a comma")
+	require.Contains(t, body, `href="/saved?feed=news"`)
+	require.Contains(t, body, `href="/saved?format=csv"`)
+
+	body = doSavedRequest(t, "/saved?feed=pics", 200).Body.String()
+	require.Contains(t, body, "A &lt;cat&gt;")
+	require.NotContains(t, body, "Self post, with a comma")
+	require.Contains(t, body, `href="/saved?feed=pics&amp;format=csv"`)
+
+	doSavedRequest(t, "/saved?format=xml", 400)
+}
+
+func TestSavedJsonExport(t *testing.T) {
+	w := doSavedRequest(t, "/saved?format=json", 200)
+	require.Contains(t, w.Header().Get("Content-Disposition"), "attachment")
+	var response struct {
+		Posts []drivers.StarredPost `json:"posts"`
+	}
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &response))
+	// Most recently starred first
+	require.Equal(t, 2, len(response.Posts))
+	require.Equal(t, "news", response.Posts[0].FeedName)
+	require.Equal(t, "pics", response.Posts[1].FeedName)
+
+	w = doSavedRequest(t, "/saved?format=json&feed=unknown", 200)
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Equal(t, 0, len(response.Posts))
+}
+
+func TestSavedCsvExport(t *testing.T) {
+	w := doSavedRequest(t, "/saved?format=csv", 200)
+	records, err := csv.NewReader(w.Body).ReadAll()
+	require.Nil(t, err)
+	require.Equal(t, 3, len(records))
+	require.Equal(t, []string{"feed", "title", "url", "permalink", "source", "time_created", "time_starred"}, records[0])
+	require.Equal(t, "Self post, with a comma", records[1][1])
+	require.Equal(t, "1970-01-01T00:05:00Z", records[1][6])
+}
+
+func TestSavedBookmarksExport(t *testing.T) {
+	body := doSavedRequest(t, "/saved?format=bookmarks", 200).Body.String()
+	require.True(t, strings.HasPrefix(body, "<!DOCTYPE NETSCAPE-Bookmark-file-1>"))
+	// Posts are grouped by feed, and posts without a link are bookmarked by their permalink.
+	require.True(t, strings.Index(body, "<H3>news</H3>") < strings.Index(body, "<H3>pics</H3>"))
+	require.Contains(t, body, `<A HREF="https://www.reddit.com/r/news/2" ADD_DATE="300">Self post, with a comma</A>`)
+	require.Contains(t, body, `<A HREF="https://example.com/cat.jpg" ADD_DATE="200">A &lt;cat&gt;</A>`)
+}
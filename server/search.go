@@ -0,0 +1,244 @@
+package server
+
+// This handles the search page. It searches the stored posts of every driver that supports
+// searching, including posts that are too old to be shown in their feeds.
+
+import (
+	"fmt"
+	"github.com/coverprice/contentscraper/drivers"
+	"github.com/coverprice/contentscraper/server/htmlutil"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"sort"
+	"time"
+)
+
+const (
+	SearchUrlPath = "/search"
+
+	// The maximum # of posts shown for a search
+	SEARCH_RESULT_LIMIT = 100
+	// The format of the "from" & "to" query parameters
+	SEARCH_DATE_FORMAT = "2006-01-02"
+)
+
+var searchTemplateStr = `
+    {{define "title"}}Search{{end}}
+    {{define "content"}}
+    <div class="container">
+    <form class="form-inline mb-3" method="GET" action="{{.SearchUrl}}">
+        <input class="form-control mr-2" type="search" name="q" value="{{.Query}}" placeholder="Search" autofocus>
+        <select class="form-control mr-2" name="feed">
+            <option value="">All feeds</option>
+            {{range .FeedNames}}
+                <option value="{{.}}"{{if eq . $.Feed}} selected{{end}}>{{.}}</option>
+            {{end}}
+        </select>
+        <label class="mr-2">From <input class="form-control ml-1" type="date" name="from" value="{{.From}}"></label>
+        <label class="mr-2">To <input class="form-control ml-1" type="date" name="to" value="{{.To}}"></label>
+        <button class="btn btn-primary" type="submit">Search</button>
+    </form>
+    <p><small class="text-muted">
+        Matches all of the words. Also supports <code>OR</code>, <code>NOT</code>, <code>"exact phrases"</code>,
+        prefixes like <code>cat*</code>, and searching only titles with <code>title:cat</code>.
+    </small></p>
+    {{if .Error}}
+        <div class="alert alert-danger">{{.Error}}</div>
+    {{else if .Query}}
+        <p>{{len .Results}} posts found{{if .IsTruncated}} (only the most recent {{len .Results}} are shown){{end}}</p>
+        {{range .Results}}
+            <div class="row mb-3">
+                <div class="col">
+                    <div class="alert alert-secondary">
+                        <a href="{{.Link}}">{{.Title}}</a>
+                        <small class="text-muted">
+                            {{.Source}}{{if .FeedName}} &middot; {{.FeedName}}{{end}} &middot; Score: {{.Score}} &middot; {{.Created}}
+                            &middot; <a href="{{.Permalink}}">comments</a>
+                        </small>
+                    </div>
+                    {{if .MediaLink}}
+                    <a href="{{.Url}}">
+                        {{if not (eq .MediaLink.Embed "")}}
+                            {{.MediaLink.Embed}}
+                        {{else if hasSuffix .MediaLink.Url ".mp4"}}
+                            <video playsinline autoplay loop controls class="videocontainer">
+                                <source src="{{.MediaLink.Url}}" type="video/mp4" />
+                            </video>
+                        {{else if hasSuffix .MediaLink.Url ".webm"}}
+                            <video playsinline autoplay loop controls class="videocontainer">
+                                <source src="{{.MediaLink.Url}}" type="video/webm" />
+                            </video>
+                        {{else if not (eq .MediaLink.Url "")}}
+                            <img src="{{.MediaLink.Url}}">
+                        {{else}}
+                            {{.MediaLink.Url}}
+                            <small>[No preview available]</small>
+                        {{end}}
+                    </a>
+                    {{end}}
+                </div>
+            </div>
+        {{end}}
+    {{end}}
+    </div>
+    {{end}}
+`
+
+var searchTempl = htmlutil.ParseTemplate(searchTemplateStr)
+
+// Verify that searchHandler implements http.Handler interface
+var _ http.Handler = &searchHandler{}
+
+type searchHandler struct {
+	server *Server
+}
+
+type searchResult struct {
+	drivers.SearchResult
+	Link    string // The post's link, or its permalink if it doesn't have one
+	Created string
+}
+
+// ServeHTTP searches for the posts matching the "q" query parameter. They can be limited to the
+// feed given by the "feed" query parameter, and to those created between the dates given by the
+// "from" and "to" query parameters (inclusive, in YYYY-MM-DD format).
+func (this searchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var params = r.URL.Query()
+	var query = drivers.SearchQuery{
+		Query:    params.Get("q"),
+		FeedName: params.Get("feed"),
+		Limit:    SEARCH_RESULT_LIMIT + 1, // One extra, to detect if the results were truncated
+	}
+	var err error
+	if query.MinTime, err = parseSearchDate(params.Get("from")); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request. Cannot parse 'from' date: %v", err), http.StatusBadRequest)
+		return
+	}
+	if query.MaxTime, err = parseSearchDate(params.Get("to")); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request. Cannot parse 'to' date: %v", err), http.StatusBadRequest)
+		return
+	}
+	if query.MaxTime != 0 {
+		// The "to" date is inclusive
+		query.MaxTime = time.Unix(query.MaxTime, 0).AddDate(0, 0, 1).Unix()
+	}
+
+	var feedNames []string
+	var searchDrivers []drivers.ISearchDriver
+	for _, driver := range this.server.Drivers {
+		searchDriver, ok := driver.(drivers.ISearchDriver)
+		if !ok {
+			continue
+		}
+		var hasFeed = false
+		for _, feed := range driver.GetFeeds() {
+			feedNames = append(feedNames, feed.Name)
+			hasFeed = hasFeed || feed.Name == query.FeedName
+		}
+		if query.FeedName == "" || hasFeed {
+			searchDrivers = append(searchDrivers, searchDriver)
+		}
+	}
+	sort.Strings(feedNames)
+	if query.FeedName != "" && len(searchDrivers) == 0 {
+		http.Error(w, fmt.Sprintf("Invalid request. Unknown feed: '%s'", query.FeedName), http.StatusBadRequest)
+		return
+	}
+
+	var results []drivers.SearchResult
+	var errorMessage string
+	if query.Query != "" {
+		if results, err = search(searchDrivers, query); err != nil {
+			if _, ok := err.(*drivers.SearchQueryError); !ok {
+				http.Error(w, fmt.Sprintf("Internal error searching posts: %v", err), 500)
+				return
+			}
+			errorMessage = err.Error()
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}
+	var isTruncated = len(results) > SEARCH_RESULT_LIMIT
+	if isTruncated {
+		results = results[:SEARCH_RESULT_LIMIT]
+	}
+	var searchResults []searchResult
+	for _, result := range results {
+		searchResults = append(searchResults, searchResult{
+			SearchResult: result,
+			Link:         getSearchResultLink(result),
+			Created:      formatStatsTime(result.TimeCreated),
+		})
+	}
+
+	data := struct {
+		Title string
+		htmlutil.Breadcrumbs
+		SearchUrl   string
+		Query       string
+		Feed        string
+		FeedNames   []string
+		From        string
+		To          string
+		Error       string
+		IsTruncated bool
+		Results     []searchResult
+	}{
+		Title: "Search",
+		Breadcrumbs: []htmlutil.Breadcrumb{
+			htmlutil.NewBreadcrumb("Home", "/"),
+			htmlutil.NewBreadcrumb("Search", SearchUrlPath),
+		},
+		SearchUrl:   SearchUrlPath,
+		Query:       query.Query,
+		Feed:        query.FeedName,
+		FeedNames:   feedNames,
+		From:        params.Get("from"),
+		To:          params.Get("to"),
+		Error:       errorMessage,
+		IsTruncated: isTruncated,
+		Results:     searchResults,
+	}
+	htmlutil.RenderTemplate(w, searchTempl, data)
+}
+
+// search returns the posts from all of the drivers that match the query, most recently created first.
+func search(searchDrivers []drivers.ISearchDriver, query drivers.SearchQuery) (results []drivers.SearchResult, err error) {
+	for _, searchDriver := range searchDrivers {
+		var driverResults []drivers.SearchResult
+		if driverResults, err = searchDriver.SearchPosts(query); err != nil {
+			log.Errorf("Could not search posts: %v", err)
+			return
+		}
+		results = append(results, driverResults...)
+	}
+	sort.Stable(byTimeCreated(results))
+	return
+}
+
+type byTimeCreated []drivers.SearchResult
+
+func (a byTimeCreated) Len() int      { return len(a) }
+func (a byTimeCreated) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byTimeCreated) Less(i, j int) bool {
+	return a[i].TimeCreated > a[j].TimeCreated
+}
+
+// parseSearchDate returns the unix time of the start of the (local) day given in
+// SEARCH_DATE_FORMAT, or 0 if it's empty.
+func parseSearchDate(date string) (int64, error) {
+	if date == "" {
+		return 0, nil
+	}
+	t, err := time.ParseInLocation(SEARCH_DATE_FORMAT, date, time.Local)
+	if err != nil {
+		return 0, err
+	}
+	return t.Unix(), nil
+}
+
+func getSearchResultLink(result drivers.SearchResult) string {
+	if result.Url != "" {
+		return result.Url
+	}
+	return result.Permalink
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"github.com/coverprice/contentscraper/drivers"
+	"github.com/coverprice/contentscraper/server/medialink"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeSearchDriver struct {
+	fakeDriver
+	results []drivers.SearchResult
+	queries []drivers.SearchQuery
+}
+
+func (this *fakeSearchDriver) SearchPosts(query drivers.SearchQuery) ([]drivers.SearchResult, error) {
+	this.queries = append(this.queries, query)
+	if query.Query == "(" {
+		return nil, &drivers.SearchQueryError{Query: query.Query}
+	}
+	return this.results, nil
+}
+
+func newTestSearchHandler(searchDriver *fakeSearchDriver) searchHandler {
+	return searchHandler{server: &Server{
+		Drivers: []drivers.IDriver{
+			searchDriver,
+			&fakeDriver{
+				feed: drivers.Feed{Name: "nosearch"},
+			},
+		},
+	}}
+}
+
+func doSearchRequest(t *testing.T, handler searchHandler, url string, expectedStatus int) string {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+	require.Equal(t, expectedStatus, w.Code, "Unexpected status for %s: %s", url, w.Body.String())
+	return w.Body.String()
+}
+
+func TestSearchPage(t *testing.T) {
+	searchDriver := &fakeSearchDriver{
+		fakeDriver: fakeDriver{feed: drivers.Feed{Name: "pics"}},
+		results: []drivers.SearchResult{
+			drivers.SearchResult{FeedName: "pics", Title: "An old <cat>", Permalink: "https://www.reddit.com/r/pics/1", Source: "pics", TimeCreated: 100},
+			drivers.SearchResult{
+				FeedName:    "pics",
+				Title:       "A new cat",
+				Url:         "https://example.com/cat.jpg",
+				Permalink:   "https://www.reddit.com/r/pics/2",
+				Source:      "pics",
+				TimeCreated: 200,
+				MediaLink:   &medialink.MediaLink{Url: "https://example.com/cat.jpg"},
+			},
+		},
+	}
+	handler := newTestSearchHandler(searchDriver)
+
+	body := doSearchRequest(t, handler, "/search", 200)
+	require.Contains(t, body, `<option value="pics">pics</option>`)
+	require.NotContains(t, body, "nosearch")
+	require.Empty(t, searchDriver.queries, "Nothing should be searched without a query")
+
+	body = doSearchRequest(t, handler, "/search?q=cat&feed=pics&from=2018-01-02&to=2018-01-03", 200)
+	require.Equal(t, 1, len(searchDriver.queries))
+	require.Equal(t, "cat", searchDriver.queries[0].Query)
+	require.Equal(t, "pics", searchDriver.queries[0].FeedName)
+	require.Equal(t, time.Date(2018, 1, 2, 0, 0, 0, 0, time.Local).Unix(), searchDriver.queries[0].MinTime)
+	require.Equal(t, time.Date(2018, 1, 4, 0, 0, 0, 0, time.Local).Unix(), searchDriver.queries[0].MaxTime)
+	require.Contains(t, body, "2 posts found")
+	require.Contains(t, body, "An old &lt;cat&gt;")
+	require.Contains(t, body, `<img src="https://example.com/cat.jpg">`)
+	require.True(t, strings.Index(body, "A new cat") < strings.Index(body, "An old"), "Most recent posts should be first")
+	require.Contains(t, body, `<option value="pics" selected>pics</option>`)
+
+	body = doSearchRequest(t, handler, "/search?q=(", 400)
+	require.Contains(t, body, "Invalid search query")
+
+	doSearchRequest(t, handler, "/search?q=cat&feed=nosearch", 400)
+	doSearchRequest(t, handler, "/search?q=cat&from=yesterday", 400)
+}
@@ -26,6 +26,10 @@ func NewServer(port int) *Server {
 		mux: mux,
 	}
 	mux.Handle("/", indexHandler{server: &s})
+	mux.Handle(ApiBaseUrlPath, apiHandler{server: &s})
+	mux.Handle(StatsUrlPath, statsHandler{server: &s})
+	mux.Handle(SavedUrlPath, savedHandler{server: &s})
+	mux.Handle(SearchUrlPath, searchHandler{server: &s})
 
 	// Add the static directory so Javascript can be served
 	prefix := "/static/"
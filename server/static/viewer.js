@@ -61,6 +61,66 @@ function scrollToNextItem(is_up) {
    }
    window.scrollTo(0, new_top_y);
 }
+// Records that the viewer has read the posts with the given keys. (Only for feeds that track which
+// posts have been read, i.e. that define globals.seenUrl). Returns a promise that resolves once the
+// server has recorded them. keepalive lets the request complete even if the page is being unloaded.
+function markPostsRead(postKeys) {
+    if (!globals.seenUrl || postKeys.length == 0) {
+        return Promise.resolve();
+    }
+    let data = new URLSearchParams();
+    postKeys.forEach((postKey) => data.append('post', postKey));
+    return fetch(globals.seenUrl, {method: 'POST', body: data, credentials: 'same-origin', keepalive: true}).then(function(response) {
+        if (!response.ok) {
+            throw new Error("Failed to mark posts read: " + response.status);
+        }
+    });
+}
+
+function markPageReadAndContinue() {
+    let postKeys = $('.feeditem[data-post]').map((idx, el) => $(el).data('post')).get();
+    markPostsRead(postKeys).then(function() {
+        if (globals.hideSeen || globals.currentPageNum >= globals.numPages) {
+            // The posts just read are hidden, so the next unread posts move up to this page.
+            window.location.reload();
+        } else {
+            window.location = globals.nextPageLink;
+        }
+    }).catch(function(error) {
+        console.log(error.message);
+    });
+}
+
+// Opening a post marks it as read.
+$(document).on('click', '.feeditem[data-post] a:not(.showmore):not(.star)', function(event) {
+    markPostsRead([$(this).closest('.feeditem').data('post')]).catch(function(error) {
+        console.log(error.message);
+    });
+});
+
+// Stars (or unstars) a post. (Only for feeds that define globals.starUrl).
+$(document).on('click', '.feeditem[data-post] a.star', function(event) {
+    event.preventDefault();
+    if (!globals.starUrl) {
+        return;
+    }
+    let link = $(this);
+    let item = link.closest('.feeditem');
+    let isStarred = item.attr('data-starred') != 'true';
+    let data = new URLSearchParams();
+    data.append('post', item.data('post'));
+    data.append('feed', globals.feedName);
+    data.append('starred', isStarred ? '1' : '0');
+    fetch(globals.starUrl, {method: 'POST', body: data, credentials: 'same-origin'}).then(function(response) {
+        if (!response.ok) {
+            console.log("Failed to star post: " + response.status);
+            return;
+        }
+        item.attr('data-starred', isStarred ? 'true' : 'false');
+        link.html(isStarred ? '&#9733;' : '&#9734;');
+    });
+});
+
 $(document).keypress(function(event) {
     let key = String.fromCharCode(event.which);
     if (key == "k" || key == "j") {               // Up/Down
@@ -74,8 +134,18 @@ $(document).keypress(function(event) {
 
     } else if (key == "i") {        // Home
         window.location = '/';
+
+    } else if (key == "m" && globals.seenUrl) {        // Mark page read, then next page
+        markPageReadAndContinue();
     } else {
         return;
     }
     event.preventDefault();
 });
+
+// Unfold long text posts.
+$(document).on('click', '.showmore', function(event) {
+    $(this).siblings('.folded').removeClass('folded');
+    $(this).remove();
+    event.preventDefault();
+});
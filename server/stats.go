@@ -0,0 +1,342 @@
+package server
+
+// This handles the harvest statistics page. For each feed whose driver keeps a history of its
+// harvest runs, it shows how each run went, and a summary of each source (e.g. subreddit) to
+// help spot sources that are dead, rate-limited or misconfigured.
+
+import (
+	"fmt"
+	"github.com/coverprice/contentscraper/drivers"
+	"github.com/coverprice/contentscraper/server/htmlutil"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	StatsUrlPath = "/stats"
+
+	STATS_DEFAULT_DAYS = 7
+	STATS_MAX_DAYS     = 90
+
+	// The number of runs shown in each feed's table of recent runs
+	STATS_MAX_FEED_RUNS = 10
+)
+
+var statsTemplateStr = `
+    {{define "title"}}Harvest statistics{{end}}
+    {{define "content"}}
+    <div class="container">
+    <p>
+        Harvests over the last {{.Days}} days.
+        {{range .DayChoices}}<a href="?days={{.}}">{{.}} days</a> {{end}}
+    </p>
+    {{range .Feeds}}
+        <h4>{{.Name}} <small class="text-muted">{{.Description}}</small></h4>
+        {{if .Error}}
+            <div class="alert alert-danger">{{.Error}}</div>
+        {{else if not .Runs}}
+            <p class="text-muted">No harvests recorded.</p>
+        {{else}}
+            <table class="table table-sm">
+            <thead><tr>
+                <th>Source</th><th>Status</th><th>Runs</th><th>Errors</th><th>Last run</th>
+                <th>New posts</th><th>Trend (new posts/run)</th><th>Updated</th><th>Skipped</th><th>Pages/run</th>
+            </tr></thead>
+            <tbody>
+            {{range .Sources}}
+                <tr class="{{if ne .Diagnosis "OK"}}table-warning{{end}}">
+                    <td>{{.Source}}</td>
+                    <td>{{.Diagnosis}}{{if .LastError}}<br><small class="text-muted">{{.LastError}}</small>{{end}}</td>
+                    <td>{{.NumRuns}}</td>
+                    <td>{{.NumErrors}}</td>
+                    <td>{{.LastRun}}</td>
+                    <td>{{.PostsNew}}</td>
+                    <td><tt>{{.Trend}}</tt></td>
+                    <td>{{.PostsUpdated}}</td>
+                    <td>{{.PostsSkipped}}</td>
+                    <td>{{printf "%.1f" .PagesPerRun}}</td>
+                </tr>
+            {{end}}
+            </tbody>
+            </table>
+            <table class="table table-sm">
+            <thead><tr>
+                <th>Run started</th><th>Sources</th><th>Errors</th><th>New posts</th><th>Updated</th><th>Skipped</th>
+            </tr></thead>
+            <tbody>
+            {{range .Runs}}
+                <tr class="{{if .NumErrors}}table-warning{{end}}">
+                    <td>{{.Started}}</td>
+                    <td>{{.NumSources}}</td>
+                    <td>{{.NumErrors}}</td>
+                    <td>{{.PostsNew}}</td>
+                    <td>{{.PostsUpdated}}</td>
+                    <td>{{.PostsSkipped}}</td>
+                </tr>
+            {{end}}
+            </tbody>
+            </table>
+        {{end}}
+    {{else}}
+        <p class="text-muted">None of the feeds record their harvests.</p>
+    {{end}}
+    </div>
+    {{end}}
+`
+
+var statsTempl = htmlutil.ParseTemplate(statsTemplateStr)
+
+// Verify that statsHandler implements http.Handler interface
+var _ http.Handler = &statsHandler{}
+
+type statsHandler struct {
+	server *Server
+}
+
+// feedRunStats is the total of a single harvest run across a feed's sources.
+type feedRunStats struct {
+	TimeRunStarted int64
+	Started        string
+	NumSources     int
+	NumErrors      int
+	PostsNew       int
+	PostsUpdated   int
+	PostsSkipped   int
+}
+
+// sourceStats summarizes all the harvests of a single source.
+type sourceStats struct {
+	Source       string
+	NumRuns      int
+	NumErrors    int
+	PagesPerRun  float64
+	PostsNew     int
+	PostsUpdated int
+	PostsSkipped int
+	LastRun      string
+	LastError    string // The error from the most recent run, if it failed
+	Trend        string // A sparkline of new posts per run, oldest first
+	Diagnosis    string
+}
+
+type feedStats struct {
+	Name        string
+	Description string
+	Error       string
+	Runs        []feedRunStats
+	Sources     []sourceStats
+}
+
+func (this statsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	days, err := getIntParam(r.URL.Query(), "days", STATS_DEFAULT_DAYS, 1, STATS_MAX_DAYS)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var allFeedStats = collectFeedStats(this.server.Drivers, days)
+	for idx := range allFeedStats {
+		if len(allFeedStats[idx].Runs) > STATS_MAX_FEED_RUNS {
+			allFeedStats[idx].Runs = allFeedStats[idx].Runs[:STATS_MAX_FEED_RUNS]
+		}
+	}
+
+	data := struct {
+		Title string
+		htmlutil.Breadcrumbs
+		Days       int
+		DayChoices []int
+		Feeds      []feedStats
+	}{
+		Title: "Harvest statistics",
+		Breadcrumbs: []htmlutil.Breadcrumb{
+			htmlutil.NewBreadcrumb("Home", "/"),
+			htmlutil.NewBreadcrumb("Harvest statistics", StatsUrlPath),
+		},
+		Days:       days,
+		DayChoices: []int{1, 7, 30, 90},
+		Feeds:      allFeedStats,
+	}
+	htmlutil.RenderTemplate(w, statsTempl, data)
+}
+
+// collectFeedStats summarizes the harvest runs of the last few days of each feed whose driver
+// keeps a history of them, ordered by feed name.
+func collectFeedStats(statsDrivers []drivers.IDriver, days int) (allFeedStats []feedStats) {
+	var minTime = time.Now().Add(-time.Duration(days) * 24 * time.Hour).Unix()
+	for _, driver := range statsDrivers {
+		statsDriver, ok := driver.(drivers.IStatsDriver)
+		if !ok {
+			continue
+		}
+		for _, feed := range driver.GetFeeds() {
+			var stats = feedStats{
+				Name:        feed.Name,
+				Description: feed.Description,
+			}
+			runs, err := statsDriver.GetHarvestRuns(feed.Name, minTime)
+			if err != nil {
+				log.Errorf("Could not retrieve harvest runs for feed '%s': %v", feed.Name, err)
+				stats.Error = "Could not retrieve the feed's harvest runs"
+			} else {
+				stats.Runs, stats.Sources = summarizeHarvestRuns(runs)
+			}
+			allFeedStats = append(allFeedStats, stats)
+		}
+	}
+	sort.Sort(byFeedStatsName(allFeedStats))
+	return
+}
+
+// WriteStatsReport writes a plain text table of each feed's sources' harvests over the last few
+// days. It returns false if any feed's harvest runs couldn't be retrieved.
+func WriteStatsReport(w io.Writer, statsDrivers []drivers.IDriver, days int) (ok bool) {
+	ok = true
+	var tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "FEED\tSOURCE\tRUNS\tERRORS\tNEW\tUPDATED\tSKIPPED\tLAST RUN\tSTATUS")
+	for _, stats := range collectFeedStats(statsDrivers, days) {
+		if stats.Error != "" {
+			fmt.Fprintf(tw, "%s\t\t\t\t\t\t\t\t%s\n", stats.Name, stats.Error)
+			ok = false
+			continue
+		}
+		for _, source := range stats.Sources {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t%d\t%s\t%s\n",
+				stats.Name,
+				source.Source,
+				source.NumRuns,
+				source.NumErrors,
+				source.PostsNew,
+				source.PostsUpdated,
+				source.PostsSkipped,
+				source.LastRun,
+				source.Diagnosis,
+			)
+		}
+	}
+	tw.Flush()
+	return
+}
+
+type byFeedStatsName []feedStats
+
+func (a byFeedStatsName) Len() int      { return len(a) }
+func (a byFeedStatsName) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byFeedStatsName) Less(i, j int) bool {
+	return a[i].Name < a[j].Name
+}
+
+// summarizeHarvestRuns totals a feed's harvest runs (which must be ordered most recent first)
+// by run, and by source. Both are returned in the order they were most recently harvested.
+func summarizeHarvestRuns(runs []drivers.HarvestRun) (feedRuns []feedRunStats, sources []sourceStats) {
+	var feedRunIdx = make(map[int64]int)
+	var sourceIdx = make(map[string]int)
+	var newPostsBySource = make(map[string][]int)
+	var pagesBySource = make(map[string]int)
+
+	for _, run := range runs {
+		idx, ok := feedRunIdx[run.TimeRunStarted]
+		if !ok {
+			idx = len(feedRuns)
+			feedRunIdx[run.TimeRunStarted] = idx
+			feedRuns = append(feedRuns, feedRunStats{
+				TimeRunStarted: run.TimeRunStarted,
+				Started:        formatStatsTime(run.TimeRunStarted),
+			})
+		}
+		feedRuns[idx].NumSources++
+		feedRuns[idx].PostsNew += run.PostsNew
+		feedRuns[idx].PostsUpdated += run.PostsUpdated
+		feedRuns[idx].PostsSkipped += run.PostsSkipped
+		if run.Error != "" {
+			feedRuns[idx].NumErrors++
+		}
+
+		idx, ok = sourceIdx[run.Source]
+		if !ok {
+			// This is the source's most recent run.
+			idx = len(sources)
+			sourceIdx[run.Source] = idx
+			sources = append(sources, sourceStats{
+				Source:    run.Source,
+				LastRun:   formatStatsTime(run.TimeStarted),
+				LastError: run.Error,
+			})
+		}
+		sources[idx].NumRuns++
+		sources[idx].PostsNew += run.PostsNew
+		sources[idx].PostsUpdated += run.PostsUpdated
+		sources[idx].PostsSkipped += run.PostsSkipped
+		pagesBySource[run.Source] += run.PagesScraped
+		newPostsBySource[run.Source] = append(newPostsBySource[run.Source], run.PostsNew)
+		if run.Error != "" {
+			sources[idx].NumErrors++
+		}
+	}
+
+	for idx := range sources {
+		var source = &sources[idx]
+		source.PagesPerRun = float64(pagesBySource[source.Source]) / float64(source.NumRuns)
+		// The runs are most recent first, but trends read left to right.
+		var newPosts = newPostsBySource[source.Source]
+		for i, j := 0, len(newPosts)-1; i < j; i, j = i+1, j-1 {
+			newPosts[i], newPosts[j] = newPosts[j], newPosts[i]
+		}
+		source.Trend = sparkline(newPosts)
+		source.Diagnosis = diagnoseSource(source)
+	}
+	return
+}
+
+// diagnoseSource describes what (if anything) appears to be wrong with a source.
+func diagnoseSource(source *sourceStats) string {
+	if source.LastError != "" {
+		var lastError = strings.ToLower(source.LastError)
+		switch {
+		case strings.Contains(lastError, "429") || strings.Contains(lastError, "rate limit") || strings.Contains(lastError, "ratelimit"):
+			return "Rate-limited"
+		case strings.Contains(lastError, "403") || strings.Contains(lastError, "404") ||
+			strings.Contains(lastError, "forbidden") || strings.Contains(lastError, "not found") ||
+			strings.Contains(lastError, "private") || strings.Contains(lastError, "banned"):
+			return "Misconfigured"
+		default:
+			return "Failing"
+		}
+	}
+	if source.NumErrors > 0 {
+		return "Intermittent errors"
+	}
+	if source.PostsNew == 0 && source.NumRuns > 1 {
+		return "Dead"
+	}
+	return "OK"
+}
+
+var sparklineChars = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline renders the values as a string of bars, scaled so that the largest is a full bar.
+func sparkline(values []int) string {
+	var max int
+	for _, value := range values {
+		if value > max {
+			max = value
+		}
+	}
+	var ret []rune
+	for _, value := range values {
+		var idx int
+		if max > 0 && value > 0 {
+			idx = value * (len(sparklineChars) - 1) / max
+		}
+		ret = append(ret, sparklineChars[idx])
+	}
+	return string(ret)
+}
+
+func formatStatsTime(t int64) string {
+	return time.Unix(t, 0).Format("2006-01-02 15:04")
+}
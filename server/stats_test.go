@@ -0,0 +1,86 @@
+package server
+
+import (
+	"bytes"
+	"github.com/coverprice/contentscraper/drivers"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+type fakeStatsDriver struct {
+	fakeDriver
+	runs []drivers.HarvestRun
+}
+
+func (this *fakeStatsDriver) GetHarvestRuns(feedName string, minTime int64) ([]drivers.HarvestRun, error) {
+	return this.runs, nil
+}
+
+func TestSummarizeHarvestRuns(t *testing.T) {
+	// Most recent first
+	runs := []drivers.HarvestRun{
+		drivers.HarvestRun{Source: "funny", TimeRunStarted: 300, TimeStarted: 301, PagesScraped: 3, PostsNew: 10, PostsUpdated: 90},
+		drivers.HarvestRun{Source: "dead", TimeRunStarted: 300, TimeStarted: 300, PagesScraped: 1, PostsUpdated: 5},
+		drivers.HarvestRun{Source: "private", TimeRunStarted: 300, TimeStarted: 300, Error: "Failed to fetch listing: 403 Forbidden"},
+		drivers.HarvestRun{Source: "funny", TimeRunStarted: 200, TimeStarted: 200, PagesScraped: 1, PostsNew: 0, PostsSkipped: 2},
+		drivers.HarvestRun{Source: "dead", TimeRunStarted: 200, TimeStarted: 201, PagesScraped: 1, PostsUpdated: 5},
+		drivers.HarvestRun{Source: "funny", TimeRunStarted: 100, TimeStarted: 100, Error: "429 Too Many Requests"},
+	}
+	feedRuns, sources := summarizeHarvestRuns(runs)
+
+	require.Equal(t, 3, len(feedRuns))
+	require.Equal(t, int64(300), feedRuns[0].TimeRunStarted)
+	require.Equal(t, 3, feedRuns[0].NumSources)
+	require.Equal(t, 1, feedRuns[0].NumErrors)
+	require.Equal(t, 10, feedRuns[0].PostsNew)
+	require.Equal(t, 95, feedRuns[0].PostsUpdated)
+	require.Equal(t, 2, feedRuns[1].PostsSkipped)
+	require.Equal(t, 1, feedRuns[2].NumErrors)
+
+	require.Equal(t, 3, len(sources))
+	require.Equal(t, "funny", sources[0].Source)
+	require.Equal(t, 3, sources[0].NumRuns)
+	require.Equal(t, 1, sources[0].NumErrors)
+	require.Equal(t, 10, sources[0].PostsNew)
+	require.Equal(t, 4.0/3.0, sources[0].PagesPerRun)
+	require.Equal(t, "", sources[0].LastError)
+	require.Equal(t, "▁▁█", sources[0].Trend)
+	require.Equal(t, "Intermittent errors", sources[0].Diagnosis)
+
+	require.Equal(t, "dead", sources[1].Source)
+	require.Equal(t, "Dead", sources[1].Diagnosis)
+
+	require.Equal(t, "private", sources[2].Source)
+	require.Equal(t, "Misconfigured", sources[2].Diagnosis)
+}
+
+func TestDiagnoseSource(t *testing.T) {
+	require.Equal(t, "Rate-limited", diagnoseSource(&sourceStats{NumRuns: 1, NumErrors: 1, LastError: "Rate limit exceeded"}))
+	require.Equal(t, "Failing", diagnoseSource(&sourceStats{NumRuns: 1, NumErrors: 1, LastError: "connection reset"}))
+	require.Equal(t, "OK", diagnoseSource(&sourceStats{NumRuns: 1}))
+	require.Equal(t, "OK", diagnoseSource(&sourceStats{NumRuns: 2, PostsNew: 1}))
+}
+
+func TestWriteStatsReport(t *testing.T) {
+	var out bytes.Buffer
+	ok := WriteStatsReport(&out, []drivers.IDriver{
+		&fakeStatsDriver{
+			fakeDriver: fakeDriver{feed: drivers.Feed{Name: "pics"}},
+			runs: []drivers.HarvestRun{
+				drivers.HarvestRun{Source: "funny", TimeRunStarted: 200, TimeStarted: 200, PostsNew: 10, PostsUpdated: 5},
+				drivers.HarvestRun{Source: "private", TimeRunStarted: 200, TimeStarted: 200, Error: "403 Forbidden"},
+				drivers.HarvestRun{Source: "funny", TimeRunStarted: 100, TimeStarted: 100, PostsNew: 3},
+			},
+		},
+		&fakeDriver{feed: drivers.Feed{Name: "nostats"}},
+	}, 7)
+	require.True(t, ok)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Equal(t, 3, len(lines))
+	require.Equal(t, []string{"FEED", "SOURCE", "RUNS", "ERRORS", "NEW", "UPDATED", "SKIPPED", "LAST", "RUN", "STATUS"}, strings.Fields(lines[0]))
+	require.Equal(t, []string{"pics", "funny", "2", "0", "13", "5", "0"}, strings.Fields(lines[1])[:7])
+	require.Equal(t, "OK", strings.Fields(lines[1])[9])
+	require.Equal(t, "Misconfigured", strings.Fields(lines[2])[9])
+}
@@ -0,0 +1,250 @@
+package syndication
+
+// Renders a feed's published posts as an RSS 2.0 or Atom document, so that feeds can be
+// read in a normal feed reader. Drivers convert their posts into the generic Feed/Entry
+// structures below, and this package takes care of the XML.
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/coverprice/contentscraper/server/medialink"
+	"html"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	FORMAT_HTML = "html"
+	FORMAT_RSS  = "rss"
+	FORMAT_ATOM = "atom"
+
+	// The maximum # of entries to include in a document. Feed readers poll regularly,
+	// so only the most recent posts are of interest.
+	MAX_ENTRIES = 50
+)
+
+// Feed is the format-independent representation of a syndicated feed.
+type Feed struct {
+	Title       string
+	Description string
+	Link        string // Absolute URL of the HTML version of the feed
+	SelfLink    string // Absolute URL of the syndicated document itself
+	Updated     time.Time
+	Entries     []Entry
+}
+
+// Entry is a single post within a Feed.
+type Entry struct {
+	Id        string // Permanent, globally unique ID. Must be a URI.
+	Title     string
+	Link      string
+	Author    string
+	Published time.Time
+	Content   string // HTML
+}
+
+// GetRequestedFormat determines which format the request is for. It's either given by the
+// "format" query parameter, or by the path relative to the driver's base URL (e.g. "/reddit/rss").
+// Returns an error if the format is not supported.
+func GetRequestedFormat(r *http.Request, relativePath string) (format string, err error) {
+	format = r.URL.Query().Get("format")
+	if format == "" {
+		format = relativePath
+	}
+	switch format {
+	case "":
+		return FORMAT_HTML, nil
+	case FORMAT_HTML, FORMAT_RSS, FORMAT_ATOM:
+		return format, nil
+	default:
+		return "", fmt.Errorf("Unsupported format: '%s'", format)
+	}
+}
+
+// AbsoluteUrl converts a path on this server (e.g. "/reddit/?feed=foo") into an absolute URL,
+// using the host that the request was sent to.
+func AbsoluteUrl(r *http.Request, path string) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s%s", scheme, r.Host, path)
+}
+
+// MediaLinkToHtml returns HTML that displays the media (if any), linked to href.
+func MediaLinkToHtml(mediaLink *medialink.MediaLink, href string) string {
+	if mediaLink == nil {
+		return ""
+	}
+	if mediaLink.Embed != "" {
+		return string(mediaLink.Embed)
+	}
+	if mediaLink.Url != "" {
+		return fmt.Sprintf(`<a href="%s"><img src="%s"></a>`, html.EscapeString(href), html.EscapeString(mediaLink.Url))
+	}
+	return ""
+}
+
+// Write renders the feed to w in the given format (either FORMAT_RSS or FORMAT_ATOM).
+func (this *Feed) Write(w http.ResponseWriter, format string) {
+	var err error
+	switch format {
+	case FORMAT_RSS:
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		err = this.WriteRss(w)
+	case FORMAT_ATOM:
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		err = this.WriteAtom(w)
+	default:
+		err = fmt.Errorf("Unsupported format: '%s'", format)
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Internal error rendering feed: %s %v", this.Title, err), 500)
+	}
+}
+
+type rssDocument struct {
+	XMLName   xml.Name   `xml:"rss"`
+	Version   string     `xml:"version,attr"`
+	AtomXmlns string     `xml:"xmlns:atom,attr"`
+	Channel   rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string      `xml:"title"`
+	Link          string      `xml:"link"`
+	Description   string      `xml:"description"`
+	LastBuildDate string      `xml:"lastBuildDate"`
+	SelfLink      rssAtomLink `xml:"atom:link"`
+	Items         []rssItem   `xml:"item"`
+}
+
+type rssAtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link,omitempty"`
+	Description string  `xml:"description,omitempty"`
+	Guid        rssGuid `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+type rssGuid struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+// WriteRss renders the feed as an RSS 2.0 document.
+func (this *Feed) WriteRss(w io.Writer) error {
+	doc := rssDocument{
+		Version:   "2.0",
+		AtomXmlns: "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:         this.Title,
+			Link:          this.Link,
+			Description:   this.Description,
+			LastBuildDate: this.Updated.UTC().Format(time.RFC1123Z),
+			SelfLink: rssAtomLink{
+				Href: this.SelfLink,
+				Rel:  "self",
+				Type: "application/rss+xml",
+			},
+		},
+	}
+	for _, entry := range this.Entries {
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       entry.Title,
+			Link:        entry.Link,
+			Description: entry.Content,
+			Guid:        rssGuid{Value: entry.Id, IsPermaLink: false},
+			PubDate:     entry.Published.UTC().Format(time.RFC1123Z),
+		})
+	}
+	return writeXml(w, doc)
+}
+
+type atomDocument struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Id       string      `xml:"id"`
+	Updated  string      `xml:"updated"`
+	Author   atomAuthor  `xml:"author"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title     string       `xml:"title"`
+	Id        string       `xml:"id"`
+	Link      *atomLink    `xml:"link,omitempty"`
+	Published string       `xml:"published"`
+	Updated   string       `xml:"updated"`
+	Author    *atomAuthor  `xml:"author,omitempty"`
+	Content   *atomContent `xml:"content,omitempty"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// WriteAtom renders the feed as an Atom document.
+func (this *Feed) WriteAtom(w io.Writer) error {
+	doc := atomDocument{
+		Title:    this.Title,
+		Subtitle: this.Description,
+		Id:       this.SelfLink,
+		Updated:  this.Updated.UTC().Format(time.RFC3339),
+		// Atom requires an author. Entries without one inherit this.
+		Author: atomAuthor{Name: this.Title},
+		Links: []atomLink{
+			atomLink{Href: this.Link, Rel: "alternate", Type: "text/html"},
+			atomLink{Href: this.SelfLink, Rel: "self", Type: "application/atom+xml"},
+		},
+	}
+	for _, entry := range this.Entries {
+		published := entry.Published.UTC().Format(time.RFC3339)
+		atomentry := atomEntry{
+			Title:     entry.Title,
+			Id:        entry.Id,
+			Published: published,
+			Updated:   published,
+		}
+		if entry.Link != "" {
+			atomentry.Link = &atomLink{Href: entry.Link, Rel: "alternate"}
+		}
+		if entry.Author != "" {
+			atomentry.Author = &atomAuthor{Name: entry.Author}
+		}
+		if entry.Content != "" {
+			atomentry.Content = &atomContent{Type: "html", Value: entry.Content}
+		}
+		doc.Entries = append(doc.Entries, atomentry)
+	}
+	return writeXml(w, doc)
+}
+
+func writeXml(w io.Writer, doc interface{}) (err error) {
+	if _, err = io.WriteString(w, xml.Header); err != nil {
+		return
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return encoder.Encode(doc)
+}
@@ -0,0 +1,111 @@
+package syndication
+
+import (
+	"bytes"
+	"encoding/xml"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestFeed() *Feed {
+	return &Feed{
+		Title:       "funny",
+		Description: "Funny stuff & more",
+		Link:        "http://localhost:8080/reddit/?feed=funny",
+		SelfLink:    "http://localhost:8080/reddit/atom?feed=funny",
+		Updated:     time.Date(2017, 10, 17, 9, 0, 0, 0, time.UTC),
+		Entries: []Entry{
+			Entry{
+				Id:        "https://www.reddit.com/r/funny/comments/abc/",
+				Title:     "A <funny> post",
+				Link:      "https://i.imgur.com/abc.jpg",
+				Author:    "/r/funny",
+				Published: time.Date(2017, 10, 16, 9, 0, 0, 0, time.UTC),
+				Content:   `<img src="https://i.imgur.com/abc.jpg">`,
+			},
+		},
+	}
+}
+
+func TestWriteRss(t *testing.T) {
+	var buf bytes.Buffer
+	require.Nil(t, newTestFeed().WriteRss(&buf), "Could not write RSS")
+
+	var doc struct {
+		Channel struct {
+			Title string `xml:"title"`
+			Items []struct {
+				Title       string `xml:"title"`
+				Link        string `xml:"link"`
+				Description string `xml:"description"`
+				Guid        string `xml:"guid"`
+				PubDate     string `xml:"pubDate"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	require.Nil(t, xml.Unmarshal(buf.Bytes(), &doc), "Output is not valid XML:\n%s", buf.String())
+	require.Equal(t, "funny", doc.Channel.Title)
+	require.Equal(t, 1, len(doc.Channel.Items))
+	item := doc.Channel.Items[0]
+	require.Equal(t, "A <funny> post", item.Title)
+	require.Equal(t, "https://i.imgur.com/abc.jpg", item.Link)
+	require.Equal(t, `<img src="https://i.imgur.com/abc.jpg">`, item.Description)
+	require.Equal(t, "https://www.reddit.com/r/funny/comments/abc/", item.Guid)
+	require.Equal(t, "Mon, 16 Oct 2017 09:00:00 +0000", item.PubDate)
+}
+
+func TestWriteAtom(t *testing.T) {
+	var buf bytes.Buffer
+	require.Nil(t, newTestFeed().WriteAtom(&buf), "Could not write Atom")
+
+	var doc struct {
+		XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+		Id      string   `xml:"id"`
+		Entries []struct {
+			Id   string `xml:"id"`
+			Link struct {
+				Href string `xml:"href,attr"`
+			} `xml:"link"`
+			Published string `xml:"published"`
+			Author    string `xml:"author>name"`
+			Content   string `xml:"content"`
+		} `xml:"entry"`
+	}
+	require.Nil(t, xml.Unmarshal(buf.Bytes(), &doc), "Output is not valid Atom:\n%s", buf.String())
+	require.Equal(t, "http://localhost:8080/reddit/atom?feed=funny", doc.Id)
+	require.Equal(t, 1, len(doc.Entries))
+	entry := doc.Entries[0]
+	require.Equal(t, "https://www.reddit.com/r/funny/comments/abc/", entry.Id)
+	require.Equal(t, "https://i.imgur.com/abc.jpg", entry.Link.Href)
+	require.Equal(t, "2017-10-16T09:00:00Z", entry.Published)
+	require.Equal(t, "/r/funny", entry.Author)
+	require.Equal(t, `<img src="https://i.imgur.com/abc.jpg">`, entry.Content)
+}
+
+func TestGetRequestedFormat(t *testing.T) {
+	testcases := []struct {
+		url          string
+		relativePath string
+		format       string
+		isValid      bool
+	}{
+		{"/reddit/?feed=funny", "", FORMAT_HTML, true},
+		{"/reddit/?feed=funny&format=atom", "", FORMAT_ATOM, true},
+		{"/reddit/rss?feed=funny", "rss", FORMAT_RSS, true},
+		{"/reddit/rss?feed=funny&format=atom", "rss", FORMAT_ATOM, true},
+		{"/reddit/?feed=funny&format=json", "", "", false},
+		{"/reddit/foo?feed=funny", "foo", "", false},
+	}
+	for _, tc := range testcases {
+		r := httptest.NewRequest("GET", tc.url, nil)
+		format, err := GetRequestedFormat(r, tc.relativePath)
+		if tc.isValid {
+			require.Nil(t, err, "Unexpected error for %s", tc.url)
+			require.Equal(t, tc.format, format, "Unexpected format for %s", tc.url)
+		} else {
+			require.NotNil(t, err, "Expected an error for %s", tc.url)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package toolbox
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket that limits the rate of events (e.g. HTTP requests). Tokens are
+// added at a steady rate, up to a maximum of burst tokens, and each event consumes one. So
+// after a quiet period, up to burst events may occur at once. It's safe for concurrent use, so
+// a single RateLimiter can be shared by several workers to enforce a global limit.
+type RateLimiter struct {
+	lock        sync.Mutex
+	interval    time.Duration // The time it takes to add 1 token
+	burst       float64
+	tokens      float64   // -ve when events are waiting for tokens
+	last        time.Time // When tokens was last brought up to date
+	pausedUntil time.Time // No events occur before this time (see PauseUntil)
+}
+
+// NewRateLimiter creates a RateLimiter that allows eventsPerMinute events per minute, spaced
+// out evenly. A value <= 0 means there is no limit.
+func NewRateLimiter(eventsPerMinute int) *RateLimiter {
+	return NewTokenBucket(eventsPerMinute, 1)
+}
+
+// NewTokenBucket creates a RateLimiter that allows eventsPerMinute events per minute on average,
+// and bursts of up to burst events. An eventsPerMinute <= 0 means there is no limit.
+func NewTokenBucket(eventsPerMinute, burst int) *RateLimiter {
+	var interval time.Duration
+	if eventsPerMinute > 0 {
+		interval = time.Minute / time.Duration(eventsPerMinute)
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		interval: interval,
+		burst:    float64(burst),
+		tokens:   float64(burst),
+		last:     time.Now(),
+	}
+}
+
+// Wait blocks until the next event is allowed to occur. It returns early with ctx.Err()
+// if ctx is cancelled first.
+func (this *RateLimiter) Wait(ctx context.Context) error {
+	this.lock.Lock()
+	var now = time.Now()
+	var delay time.Duration
+	if this.interval > 0 {
+		this.refill(now)
+		this.tokens--
+		if this.tokens < 0 {
+			delay = time.Duration(-this.tokens * float64(this.interval))
+		}
+	}
+	if untilResumed := this.pausedUntil.Sub(now); untilResumed > delay {
+		delay = untilResumed
+	}
+	this.lock.Unlock()
+
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	var timer = time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// Return the token, since the event won't occur.
+		this.lock.Lock()
+		if this.interval > 0 {
+			this.tokens++
+		}
+		this.lock.Unlock()
+		return ctx.Err()
+	}
+}
+
+// PauseUntil prevents any events from occurring before the given time, e.g. because the server
+// has said that its limit has been reached.
+func (this *RateLimiter) PauseUntil(until time.Time) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if until.After(this.pausedUntil) {
+		this.pausedUntil = until
+	}
+}
+
+// refill adds the tokens that have accumulated since the last update.
+func (this *RateLimiter) refill(now time.Time) {
+	this.tokens += float64(now.Sub(this.last)) / float64(this.interval)
+	if this.tokens > this.burst {
+		this.tokens = this.burst
+	}
+	this.last = now
+}
+
+// Backoff returns how long to wait before the given retry (starting at 1) of a failed
+// operation. The delay doubles with each attempt, starting at baseDelay, up to maxDelay. A
+// random "jitter" of up to half the delay is subtracted, so that clients that failed at the same
+// time don't all retry at the same time.
+func Backoff(attempt int, baseDelay, maxDelay time.Duration) time.Duration {
+	var delay = baseDelay
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay - time.Duration(rand.Int63n(int64(delay)/2+1))
+}
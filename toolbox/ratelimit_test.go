@@ -0,0 +1,77 @@
+package toolbox
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterIsSharedByConcurrentWaiters(t *testing.T) {
+	// 1200/minute == 1 every 50ms
+	var limiter = NewRateLimiter(1200)
+	var start = time.Now()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.Nil(t, limiter.Wait(context.Background()))
+		}()
+	}
+	wg.Wait()
+
+	// The 1st event is immediate, the remaining 4 are spaced 50ms apart.
+	require.True(t, time.Since(start) >= 200*time.Millisecond, "Events were not rate limited: %v", time.Since(start))
+}
+
+func TestRateLimiterWaitIsCancellable(t *testing.T) {
+	var limiter = NewRateLimiter(1)
+	require.Nil(t, limiter.Wait(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var start = time.Now()
+	require.Equal(t, context.DeadlineExceeded, limiter.Wait(ctx))
+	require.True(t, time.Since(start) < time.Second, "Wait() was not cancelled")
+}
+
+func TestTokenBucketAllowsBursts(t *testing.T) {
+	// 1200/minute == 1 every 50ms
+	var limiter = NewTokenBucket(1200, 3)
+	var start = time.Now()
+	for i := 0; i < 5; i++ {
+		require.Nil(t, limiter.Wait(context.Background()))
+	}
+	// The first 3 events are immediate, the remaining 2 are spaced 50ms apart.
+	var elapsed = time.Since(start)
+	require.True(t, elapsed >= 100*time.Millisecond, "Events were not rate limited: %v", elapsed)
+	require.True(t, elapsed < 150*time.Millisecond, "Burst was not allowed: %v", elapsed)
+}
+
+func TestRateLimiterCanBePaused(t *testing.T) {
+	var limiter = NewRateLimiter(0)
+	var start = time.Now()
+	limiter.PauseUntil(start.Add(50 * time.Millisecond))
+	require.Nil(t, limiter.Wait(context.Background()))
+	require.True(t, time.Since(start) >= 50*time.Millisecond, "Limiter was not paused: %v", time.Since(start))
+}
+
+func TestBackoffIsExponentialWithJitter(t *testing.T) {
+	var tests = []struct {
+		attempt  int
+		maxDelay time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 5 * time.Second},
+		{10, 5 * time.Second},
+	}
+	for _, test := range tests {
+		var delay = Backoff(test.attempt, time.Second, 5*time.Second)
+		require.True(t, delay <= test.maxDelay && delay >= test.maxDelay/2, "Attempt %d: unexpected delay %v", test.attempt, delay)
+	}
+}
@@ -22,5 +22,5 @@ func IndexStr(haystack []string, needle string) int {
 
 // ContainsStr returns true if the "needle" string resides within the "haystack".
 func ContainsStr(haystack []string, needle string) bool {
-	return -1 == IndexStr(haystack, needle)
+	return IndexStr(haystack, needle) != -1
 }